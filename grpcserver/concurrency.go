@@ -0,0 +1,86 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AcquireFunc admits an RPC or returns an error to reject it (e.g.,
+// limiter.ConcurrencyLimiter.Admit). On admission, done must be called once
+// when the RPC ends: with nil if it completed, context.Canceled if its outcome
+// says nothing about load, or another error if it was dropped for overload.
+type AcquireFunc func(ctx context.Context) (done func(err error), err error)
+
+// ConcurrencyLimitUnary returns a unary interceptor that admits each RPC
+// through acquire and rejects the rest with codes.ResourceExhausted. The
+// handler's status is reported to done: DeadlineExceeded, ResourceExhausted
+// and Unavailable as overload, Canceled as context.Canceled, any other code
+// (application errors included) as nil. A handler that panics reports
+// context.Canceled. acquire must be non-nil.
+func ConcurrencyLimitUnary(acquire AcquireFunc) UnaryInterceptor {
+	if acquire == nil {
+		panic("grpcserver: ConcurrencyLimitUnary requires a non-nil AcquireFunc")
+	}
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		done, err := acquire(ctx)
+		if err != nil {
+			return nil, status.Error(codes.ResourceExhausted, "concurrency limit exceeded")
+		}
+		completed := false
+		defer func() {
+			if !completed {
+				done(context.Canceled) // the handler panicked
+			}
+		}()
+		resp, err = handler(ctx, req)
+		completed = true
+		done(outcomeOf(err))
+		return resp, err
+	}
+}
+
+// ConcurrencyLimitStream is ConcurrencyLimitUnary for streams: a stream holds
+// its slot until the handler returns.
+func ConcurrencyLimitStream(acquire AcquireFunc) StreamInterceptor {
+	if acquire == nil {
+		panic("grpcserver: ConcurrencyLimitStream requires a non-nil AcquireFunc")
+	}
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		done, err := acquire(ss.Context())
+		if err != nil {
+			return status.Error(codes.ResourceExhausted, "concurrency limit exceeded")
+		}
+		completed := false
+		defer func() {
+			if !completed {
+				done(context.Canceled) // the handler panicked
+			}
+		}()
+		err = handler(srv, ss)
+		completed = true
+		done(outcomeOf(err))
+		return err
+	}
+}
+
+// outcomeOf maps a handler's error to the one passed to done. Bare context
+// errors count like their status codes.
+func outcomeOf(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return context.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return err
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.ResourceExhausted, codes.Unavailable:
+		return err
+	case codes.Canceled:
+		return context.Canceled
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package grpcserver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/v8fg/kit4go/grpcserver"
+)
+
+// oneSlot is an AcquireFunc admitting one RPC at a time and recording what
+// each reports to done.
+type oneSlot struct {
+	busy     bool
+	reported []error
+}
+
+func (s *oneSlot) acquire(context.Context) (func(error), error) {
+	if s.busy {
+		return nil, errors.New("full")
+	}
+	s.busy = true
+	return func(err error) {
+		s.busy = false
+		s.reported = append(s.reported, err)
+	}, nil
+}
+
+type ctxStream struct {
+	grpc.ServerStream
+}
+
+func (ctxStream) Context() context.Context { return context.Background() }
+
+func TestConcurrencyLimitUnary(t *testing.T) {
+	slot := &oneSlot{}
+	ic := grpcserver.ConcurrencyLimitUnary(slot.acquire)
+	call := func(err error) error {
+		_, got := ic(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+			if _, err := ic(ctx, nil, &grpc.UnaryServerInfo{}, nil); status.Code(err) != codes.ResourceExhausted {
+				t.Errorf("over the limit: %v", err)
+			}
+			return nil, err
+		})
+		return got
+	}
+	overloaded := status.Error(codes.Unavailable, "down")
+	for _, err := range []error{
+		nil,
+		status.Error(codes.NotFound, "no such ad"),
+		overloaded,
+		context.DeadlineExceeded,
+		status.Error(codes.Canceled, "client went away"),
+	} {
+		if got := call(err); got != err {
+			t.Errorf("handler error %v returned as %v", err, got)
+		}
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("handler panic swallowed")
+			}
+		}()
+		ic(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) { panic("boom") })
+	}()
+
+	want := []error{nil, nil, overloaded, context.DeadlineExceeded, context.Canceled, context.Canceled}
+	if len(slot.reported) != len(want) {
+		t.Fatalf("reported %v want %v", slot.reported, want)
+	}
+	for i := range want {
+		if slot.reported[i] != want[i] {
+			t.Errorf("RPC %d: reported %v want %v", i, slot.reported[i], want[i])
+		}
+	}
+	if slot.busy {
+		t.Error("slot leaked")
+	}
+}
+
+func TestConcurrencyLimitStream(t *testing.T) {
+	slot := &oneSlot{}
+	ic := grpcserver.ConcurrencyLimitStream(slot.acquire)
+	exhausted := status.Error(codes.ResourceExhausted, "quota")
+	err := ic(nil, ctxStream{}, &grpc.StreamServerInfo{}, func(_ any, ss grpc.ServerStream) error {
+		if err := ic(nil, ss, &grpc.StreamServerInfo{}, nil); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("over the limit: %v", err)
+		}
+		return exhausted
+	})
+	if err != exhausted || len(slot.reported) != 1 || slot.reported[0] != exhausted || slot.busy {
+		t.Errorf("err=%v reported=%v busy=%v", err, slot.reported, slot.busy)
+	}
+
+	ic(nil, ctxStream{}, &grpc.StreamServerInfo{}, func(any, grpc.ServerStream) error { return context.Canceled })
+	func() {
+		defer func() { recover() }()
+		ic(nil, ctxStream{}, &grpc.StreamServerInfo{}, func(any, grpc.ServerStream) error { panic("boom") })
+	}()
+	if len(slot.reported) != 3 || slot.reported[1] != context.Canceled || slot.reported[2] != context.Canceled || slot.busy {
+		t.Errorf("cancelled / panicking streams: reported=%v busy=%v", slot.reported, slot.busy)
+	}
+
+	for _, build := range []func(){
+		func() { grpcserver.ConcurrencyLimitUnary(nil) },
+		func() { grpcserver.ConcurrencyLimitStream(nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("nil AcquireFunc should panic at construction")
+				}
+			}()
+			build()
+		}()
+	}
+}
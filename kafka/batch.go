@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// batchStats holds the ConsumeBatch counters of a consumer (the BatchCount /
+// BatchFailed / BatchMax fields of ConsumerMetrics).
+type batchStats struct {
+	count, failed, max atomic.Uint64
+}
+
+// record accounts one handler call over n messages.
+func (b *batchStats) record(n int, failed bool) {
+	b.count.Add(1)
+	if failed {
+		b.failed.Add(1)
+	}
+	for {
+		cur := b.max.Load()
+		if uint64(n) <= cur || b.max.CompareAndSwap(cur, uint64(n)) {
+			return
+		}
+	}
+}
+
+// fill copies the counters into m.
+func (b *batchStats) fill(m *ConsumerMetrics) {
+	m.BatchCount = b.count.Load()
+	m.BatchFailed = b.failed.Load()
+	m.BatchMax = b.max.Load()
+}
+
+// batchFull reports whether a batch of n messages and size Value bytes must
+// be handed over now (the time bound is the caller's).
+func batchFull(o Options, n, size int) bool {
+	return n >= o.ConsumeBatchMaxRecords || o.ConsumeBatchMaxBytes > 0 && size >= o.ConsumeBatchMaxBytes
+}
+
+// safeBatchCall runs handler with the same panic recovery as the per-message
+// paths: a panic is counted in recovered and NACKs the batch.
+func safeBatchCall(handler BatchMessageHandler, batch []Message, recovered *atomic.Uint64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			recovered.Add(1)
+			err = fmt.Errorf("kafka: consumer handler panic: %v", r)
+		}
+	}()
+	return handler(batch)
+}
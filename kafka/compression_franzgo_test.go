@@ -0,0 +1,43 @@
+//go:build franzgo
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func Test_KgoOpts_CompressionAndPartitioner(t *testing.T) {
+	o := applyOptions([]Option{WithBrokers("b:9092"), WithCompression(CompressionZstd, 3),
+		WithPartitioner(PartitionerMurmur2)}).withDefaults()
+	cl, err := newKgoClient(o, kgoProducerOpts(o)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	codecs, _ := cl.OptValue(kgo.ProducerBatchCompression).([]kgo.CompressionCodec)
+	if len(codecs) != 1 || codecs[0] != kgo.ZstdCompression().WithLevel(3) {
+		t.Errorf("ProducerBatchCompression=%v", codecs)
+	}
+	p, _ := cl.OptValue(kgo.RecordPartitioner).(kgo.Partitioner)
+	if p == nil {
+		t.Fatal("RecordPartitioner not set")
+	}
+	key := []byte("order-42")
+	if got := p.ForTopic("t").Partition(&kgo.Record{Key: key}, 8); int32(got) != (murmur2(key)&0x7fffffff)%8 {
+		t.Errorf("murmur2 via kgo=%d", got)
+	}
+
+	// The default pins no compression rather than franz-go's snappy.
+	o = applyOptions([]Option{WithBrokers("b:9092")}).withDefaults()
+	cl2, err := newKgoClient(o, kgoSyncProducerOpts(o)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl2.Close()
+	codecs, _ = cl2.OptValue(kgo.ProducerBatchCompression).([]kgo.CompressionCodec)
+	if len(codecs) != 1 || codecs[0] != kgo.NoCompression() {
+		t.Errorf("default ProducerBatchCompression=%v", codecs)
+	}
+}
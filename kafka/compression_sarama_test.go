@@ -0,0 +1,46 @@
+//go:build !franzgo
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+func Test_SaramaConfig_CompressionAndPartitioner(t *testing.T) {
+	for _, c := range []struct {
+		codec string
+		level int
+		want  sarama.CompressionCodec
+		lvl   int
+	}{
+		{"", 0, sarama.CompressionNone, sarama.CompressionLevelDefault},
+		{CompressionGzip, 9, sarama.CompressionGZIP, 9},
+		{CompressionSnappy, 0, sarama.CompressionSnappy, sarama.CompressionLevelDefault},
+		{CompressionLZ4, 0, sarama.CompressionLZ4, sarama.CompressionLevelDefault},
+		{CompressionZstd, 3, sarama.CompressionZSTD, 3},
+	} {
+		o := applyOptions([]Option{WithBrokers("b:9092"), WithCompression(c.codec, c.level)}).withDefaults()
+		cfg, err := buildSaramaConfig(o, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Producer.Compression != c.want || cfg.Producer.CompressionLevel != c.lvl {
+			t.Errorf("%q/%d: codec=%v level=%d", c.codec, c.level, cfg.Producer.Compression, cfg.Producer.CompressionLevel)
+		}
+	}
+
+	o := applyOptions([]Option{WithBrokers("b:9092"), WithPartitioner(PartitionerMurmur2)}).withDefaults()
+	cfg, _ := buildSaramaConfig(o, true)
+	p := cfg.Producer.Partitioner("t")
+	key := []byte("order-42")
+	got, err := p.Partition(&sarama.ProducerMessage{Key: sarama.ByteEncoder(key)}, 8)
+	if err != nil || got != (murmur2(key)&0x7fffffff)%8 {
+		t.Errorf("murmur2 via sarama=%d err=%v", got, err)
+	}
+	dyn := p.(sarama.DynamicConsistencyPartitioner)
+	if dyn.MessageRequiresConsistency(&sarama.ProducerMessage{}) || !dyn.MessageRequiresConsistency(&sarama.ProducerMessage{Key: sarama.ByteEncoder(key)}) {
+		t.Error("consistency: keyless must be movable, keyed must not")
+	}
+}
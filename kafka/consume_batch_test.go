@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ConsumeBatch runs unchanged on both backends (no build tag) against the
+// kfake broker of txn_test.go.
+
+func seedBatchTopic(t *testing.T, ctx context.Context, addrs []string, topic string, n int) {
+	t.Helper()
+	sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic(topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := make([]Message, n)
+	for i := range msgs {
+		msgs[i] = Message{Value: fmt.Appendf(nil, "value-%04d", i)} // 10 bytes
+	}
+	mustNoErr(t, sp.SendBatch(ctx, msgs))
+	mustNoErr(t, sp.Close())
+}
+
+func Test_ConsumerGroup_ConsumeBatch(t *testing.T) {
+	addrs := txnCluster(t, "bulk")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	seedBatchTopic(t, ctx, addrs, "bulk", 25)
+
+	g, err := NewConsumerGroup(WithBrokers(addrs...), WithGroupID("bulk-g"), WithConsumerOffsetInitial(OffsetOldest),
+		WithConsumeBatchMaxRecords(10), WithConsumeBatchMaxWait(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	var (
+		mu    sync.Mutex
+		sizes []int
+		next  int64
+	)
+	cctx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- g.ConsumeBatch(cctx, []string{"bulk"}, func(batch []Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sizes = append(sizes, len(batch))
+			for _, m := range batch {
+				if m.Offset != next {
+					t.Errorf("offset %d, want %d", m.Offset, next)
+				}
+				next++
+			}
+			return nil
+		})
+	}()
+	waitUntil(t, func() bool { return g.Metrics().Acked == 25 }, "25 acked")
+	stop()
+	<-done
+	mustNoErr(t, g.Close())
+
+	m := g.Metrics()
+	mu.Lock()
+	defer mu.Unlock()
+	if m.BatchCount != uint64(len(sizes)) || m.BatchCount < 3 || m.BatchMax != 10 || m.BatchFailed != 0 || m.Received != 25 {
+		t.Errorf("Metrics=%+v sizes=%v", m, sizes)
+	}
+	for _, n := range sizes {
+		if n > 10 {
+			t.Errorf("batch of %d exceeds max records", n)
+		}
+	}
+	if got := committedOffset(t, addrs, "bulk-g", "bulk"); got != 25 {
+		t.Errorf("committed offset=%d want 25", got)
+	}
+}
+
+func Test_PartitionConsumer_ConsumeBatch(t *testing.T) {
+	addrs := txnCluster(t, "bulk")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	seedBatchTopic(t, ctx, addrs, "bulk", 25)
+
+	// 10-byte values with a 35-byte cap: batches of 4 (the 4th crosses it).
+	pc, err := NewPartitionConsumer(WithBrokers(addrs...), WithTopic("bulk"), WithPartition(0), WithOffset(OffsetOldest),
+		WithConsumeBatchMaxBytes(35), WithConsumeBatchMaxWait(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	var calls int
+	var sizes []int
+	cctx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- pc.ConsumeBatch(cctx, func(batch []Message) error {
+			calls++
+			sizes = append(sizes, len(batch))
+			switch calls {
+			case 1:
+				return errBoom
+			case 2:
+				panic("bulk insert bug")
+			}
+			return nil
+		})
+	}()
+	waitUntil(t, func() bool { m := pc.Snapshot(); return m.Received == 25 && m.BatchCount == 7 }, "7 batches")
+	stop()
+	<-done
+
+	m := pc.Snapshot()
+	if m.BatchCount != 7 || m.BatchFailed != 2 || m.BatchMax != 4 || m.Failed != 8 || m.Acked != 17 || m.Recovered != 1 {
+		t.Errorf("Metrics=%+v sizes=%v", m.ConsumerMetrics, sizes)
+	}
+	if last := sizes[len(sizes)-1]; last != 1 { // flushed by max wait
+		t.Errorf("sizes=%v", sizes)
+	}
+	if m.TotalLag != 0 {
+		t.Errorf("TotalLag=%d", m.TotalLag)
+	}
+}
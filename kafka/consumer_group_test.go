@@ -25,15 +25,17 @@ func (s *stubSession) MarkMessage(*sarama.ConsumerMessage, string) { s.marked.Ad
 func (s *stubSession) Commit()                                     {}
 func (s *stubSession) Context() context.Context                    { return context.Background() }
 
-// stubClaim yields a controlled stream of messages via Messages().
+// stubClaim yields a controlled stream of messages via Messages(), starting
+// at initial (the committed offset) below the high watermark hwm.
 type stubClaim struct {
-	msgs chan *sarama.ConsumerMessage
+	msgs         chan *sarama.ConsumerMessage
+	initial, hwm int64
 }
 
 func (c *stubClaim) Topic() string                            { return "t" }
 func (c *stubClaim) Partition() int32                         { return 0 }
-func (c *stubClaim) InitialOffset() int64                     { return 0 }
-func (c *stubClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *stubClaim) InitialOffset() int64                     { return c.initial }
+func (c *stubClaim) HighWaterMarkOffset() int64               { return c.hwm }
 func (c *stubClaim) Messages() <-chan *sarama.ConsumerMessage { return c.msgs }
 
 // runHandler feeds msgs through the cgHandler adapter and waits for it to
@@ -106,6 +108,22 @@ func TestConsumerGroupHandler_Mixed(t *testing.T) {
 	}
 }
 
+// A claim reports the lag from its committed offset before any message
+// reaches the handler.
+func TestConsumerGroupHandler_LagFromCommittedOffset(t *testing.T) {
+	parent := &saramaConsumerGroup{}
+	parent.lag = newLagTracker(0, nil)
+	h := &cgHandler{parent: parent, handler: func(Message) error { return nil }}
+	claim := &stubClaim{msgs: make(chan *sarama.ConsumerMessage), initial: 3, hwm: 10}
+	done := make(chan error, 1)
+	go func() { done <- h.ConsumeClaim(&stubSession{}, claim) }()
+	waitUntil(t, func() bool { return parent.Snapshot().TotalLag == 7 }, "lag 7 from the committed offset")
+	close(claim.msgs)
+	if err := <-done; err != nil {
+		t.Fatalf("ConsumeClaim: %v", err)
+	}
+}
+
 func TestConsumerGroupHandler_SetupCleanup(t *testing.T) {
 	h := &cgHandler{handler: func(Message) error { return nil }}
 	if err := h.Setup(nil); err != nil {
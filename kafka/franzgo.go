@@ -6,6 +6,9 @@ import (
 	"context"
 
 	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
 )
 
 // This is the franz-go (kgo) backend, selected at build time with
@@ -29,6 +32,43 @@ func offsetToKgo(o int64) kgo.Offset {
 	}
 }
 
+// newKgoClient creates a kgo client from opts plus the TLS / SASL settings of
+// o. Every franz-go client is built here (the transactional group session
+// appends kgoSecurityOpts itself).
+func newKgoClient(o Options, opts ...kgo.Opt) (*kgo.Client, error) {
+	sec, err := kgoSecurityOpts(o)
+	if err != nil {
+		return nil, err
+	}
+	return kgo.NewClient(append(opts, sec...)...)
+}
+
+// kgoSecurityOpts maps Options.TLS / Options.SASL to kgo options (the sarama
+// counterpart is applySaramaSecurity).
+func kgoSecurityOpts(o Options) ([]kgo.Opt, error) {
+	var opts []kgo.Opt
+	tc, err := o.TLS.build()
+	if err != nil {
+		return nil, err
+	}
+	if tc != nil {
+		opts = append(opts, kgo.DialTLSConfig(tc))
+	}
+	if s := o.SASL; s != nil {
+		var m sasl.Mechanism
+		switch s.Mechanism {
+		case SASLPlain:
+			m = plain.Auth{User: s.Username, Pass: s.Password}.AsMechanism()
+		case SASLScramSHA256:
+			m = scram.Auth{User: s.Username, Pass: s.Password}.AsSha256Mechanism()
+		case SASLScramSHA512:
+			m = scram.Auth{User: s.Username, Pass: s.Password}.AsSha512Mechanism()
+		}
+		opts = append(opts, kgo.SASL(m))
+	}
+	return opts, nil
+}
+
 // kgoProducerOpts builds kgo client options for an ASYNC producer.
 //
 // RequiredAcks, ProducerLinger, and MaxBufferedRecords are ALWAYS set explicitly
@@ -45,6 +85,7 @@ func kgoProducerOpts(o Options) []kgo.Opt {
 		kgo.ProducerLinger(effectiveLinger(o.ProducerLinger)),
 		kgo.MaxBufferedRecords(o.MaxBufferedRecords),
 	}
+	opts = append(opts, kgoRecordOpts(o)...)
 	// BatchMaxBytes caps a single batch's byte size (0 = kgo default ~1MiB).
 	if o.BatchMaxBytes > 0 {
 		opts = append(opts, kgo.ProducerBatchMaxBytes(int32(o.BatchMaxBytes)))
@@ -94,12 +135,73 @@ func kgoSyncProducerOpts(o Options) []kgo.Opt {
 		kgo.RecordRetries(5),
 		kgo.RequiredAcks(kgoAcks(o.Acks)),
 	}
+	opts = append(opts, kgoRecordOpts(o)...)
 	if kgoNeedsIdempotencyDisabled(o.Acks) {
 		opts = append(opts, kgo.DisableIdempotentWrite())
 	}
 	return opts
 }
 
+// kgoRecordOpts pins the partitioner and batch compression for every
+// producing client. Both are always set so franz-go's native defaults (sticky
+// murmur2 partitioning, snappy compression) never leak in.
+func kgoRecordOpts(o Options) []kgo.Opt {
+	codec := kgoCompression(o.Compression)
+	if o.CompressionLevel != 0 {
+		codec = codec.WithLevel(o.CompressionLevel)
+	}
+	return []kgo.Opt{
+		kgo.RecordPartitioner(kgoPartitioner{o}),
+		kgo.ProducerBatchCompression(codec),
+	}
+}
+
+// kgoCompression maps Options.Compression to kgo's codec; "" / none → no
+// compression.
+func kgoCompression(c string) kgo.CompressionCodec {
+	switch c {
+	case CompressionGzip:
+		return kgo.GzipCompression()
+	case CompressionSnappy:
+		return kgo.SnappyCompression()
+	case CompressionLZ4:
+		return kgo.Lz4Compression()
+	case CompressionZstd:
+		return kgo.ZstdCompression()
+	default:
+		return kgo.NoCompression()
+	}
+}
+
+// kgoPartitioner adapts topicPartitioner to kgo.Partitioner (one per topic).
+type kgoPartitioner struct{ o Options }
+
+func (p kgoPartitioner) ForTopic(string) kgo.TopicPartitioner {
+	return kgoTopicPartitioner{newTopicPartitioner(p.o)}
+}
+
+type kgoTopicPartitioner struct{ p *topicPartitioner }
+
+func (t kgoTopicPartitioner) RequiresConsistency(r *kgo.Record) bool {
+	return t.p.requiresConsistency(recordView(r))
+}
+
+func (t kgoTopicPartitioner) Partition(r *kgo.Record, n int) int {
+	return int(t.p.partition(recordView(r), int32(n)))
+}
+
+// recordView rebuilds the Message fields a partitioner may look at.
+func recordView(r *kgo.Record) Message {
+	m := Message{Topic: r.Topic, Partition: r.Partition, Key: r.Key, Value: r.Value}
+	if n := len(r.Headers); n > 0 {
+		m.Headers = make([]Header, n)
+		for i, h := range r.Headers {
+			m.Headers[i] = Header{Key: []byte(h.Key), Value: h.Value}
+		}
+	}
+	return m
+}
+
 // kgoConsumerGroupOpts builds kgo client options for a consumer group.
 // AutoCommitMarks + MarkCommitRecords on ACK gives at-least-once (NACK = not
 // marked = re-delivered next session), matching the sarama backend's semantics.
@@ -142,7 +244,7 @@ func toKgoRecord(msg Message, defTopic string) *kgo.Record {
 	if topic == "" {
 		topic = defTopic
 	}
-	r := &kgo.Record{Topic: topic, Key: msg.Key, Value: msg.Value}
+	r := &kgo.Record{Topic: topic, Partition: msg.Partition, Key: msg.Key, Value: msg.Value}
 	if n := len(msg.Headers); n > 0 {
 		hdrs := make([]kgo.RecordHeader, n)
 		for i, h := range msg.Headers {
@@ -183,7 +285,7 @@ func NewProducer(opts ...Option) (Producer, error) {
 		return nil, err
 	}
 	logConfig("async-producer", o)
-	cl, err := kgo.NewClient(kgoProducerOpts(o)...)
+	cl, err := newKgoClient(o, kgoProducerOpts(o)...)
 	if err != nil {
 		return nil, err
 	}
@@ -198,7 +300,7 @@ func NewSyncProducer(opts ...Option) (SyncProducer, error) {
 		return nil, err
 	}
 	logConfig("sync-producer", o)
-	cl, err := kgo.NewClient(kgoSyncProducerOpts(o)...)
+	cl, err := newKgoClient(o, kgoSyncProducerOpts(o)...)
 	if err != nil {
 		return nil, err
 	}
@@ -215,7 +317,13 @@ func NewConsumerGroup(opts ...Option) (ConsumerGroup, error) {
 	if err := o.validate("consumer-group"); err != nil {
 		return nil, err
 	}
-	return &franzConsumerGroup{opts: o}, nil
+	router, err := newFailureRouter(o, NewSyncProducer)
+	if err != nil {
+		return nil, err
+	}
+	s := &franzConsumerGroup{opts: o, router: router}
+	s.lag = newLagTracker(o.LagThreshold, s.fire)
+	return s, nil
 }
 
 // NewPartitionConsumer builds a single-partition consumer. WithBrokers,
@@ -226,11 +334,13 @@ func NewPartitionConsumer(opts ...Option) (PartitionConsumer, error) {
 	if err := o.validate("partition-consumer"); err != nil {
 		return nil, err
 	}
-	cl, err := kgo.NewClient(kgoPartitionConsumerOpts(o)...)
+	cl, err := newKgoClient(o, kgoPartitionConsumerOpts(o)...)
 	if err != nil {
 		return nil, err
 	}
-	return &franzPartitionConsumer{opts: o, cl: cl}, nil
+	s := &franzPartitionConsumer{opts: o, cl: cl}
+	s.lag = newLagTracker(o.LagThreshold, s.fire)
+	return s, nil
 }
 
 // ctxDone is a tiny helper to keep the consume loops readable.
@@ -4,12 +4,14 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 // franzConsumerGroup is the franz-go ConsumerGroup. Consume runs a PollFetches
@@ -23,6 +25,13 @@ type franzConsumerGroup struct {
 	// write races, leaking or double-creating the kgo client.
 	clMu sync.Mutex
 	cl   *kgo.Client
+	// tierCls consume the WithRetryTopics tiers, one client per tier (same
+	// group) so a message waiting out its delay only holds back its own tier.
+	// Created alongside cl, guarded by clMu.
+	tierCls []*kgo.Client
+	router  *failureRouter // nil unless WithRetryTopics / WithDeadLetter
+	lag     *lagTracker
+	paused  pauseSet
 
 	closed    atomic.Bool
 	received  atomic.Uint64
@@ -31,6 +40,9 @@ type franzConsumerGroup struct {
 	recovered atomic.Uint64 // consumer handler panics recovered (mirrors sarama; observable + L5)
 	rebalance atomic.Uint64 // always 0 under franz-go (OnRebalance hook not available in v1.21.4; upgrade to track)
 	bytes     atomic.Uint64
+	retried   atomic.Uint64
+	deadLet   atomic.Uint64
+	batches   batchStats
 
 	errChOnce sync.Once
 	errCh     chan error
@@ -39,6 +51,22 @@ type franzConsumerGroup struct {
 }
 
 func (s *franzConsumerGroup) Consume(ctx context.Context, topics []string, handler MessageHandler) error {
+	return s.consume(ctx, topics, func(ctx context.Context, cl *kgo.Client) error {
+		return s.poll(ctx, cl, handler)
+	})
+}
+
+// ConsumeBatch is Consume with a BatchMessageHandler; a batch may span the
+// partitions of one poll.
+func (s *franzConsumerGroup) ConsumeBatch(ctx context.Context, topics []string, handler BatchMessageHandler) error {
+	return s.consume(ctx, topics, func(ctx context.Context, cl *kgo.Client) error {
+		return s.pollBatches(ctx, cl, handler)
+	})
+}
+
+// consume runs loop on the group client (and on each retry-tier client) until
+// ctx ends.
+func (s *franzConsumerGroup) consume(ctx context.Context, topics []string, loop func(context.Context, *kgo.Client) error) error {
 	if s.closed.Load() {
 		return ErrProducerClosed
 	}
@@ -52,45 +80,209 @@ func (s *franzConsumerGroup) Consume(ctx context.Context, topics []string, handl
 		return ErrProducerClosed
 	}
 	if s.cl == nil {
-		kopts := kgoConsumerGroupOpts(s.opts)
+		kopts := append(kgoConsumerGroupOpts(s.opts), s.lagHooks()...)
 		kopts = append(kopts, kgo.ConsumeTopics(topics...))
-		cl, err := kgo.NewClient(kopts...)
+		cl, err := newKgoClient(s.opts, kopts...)
 		if err != nil {
 			s.clMu.Unlock()
 			return err
 		}
 		s.cl = cl
+		s.applyPaused(cl)
+		for _, tier := range s.router.topics() {
+			// Tier topics start from the oldest record: a tier first joined
+			// after messages were routed to it must not skip them.
+			topts := append(kgoConsumerGroupOpts(s.opts), s.lagHooks()...)
+			topts = append(topts,
+				kgo.ConsumeTopics(tier), kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+			tcl, err := newKgoClient(s.opts, topts...)
+			if err != nil {
+				s.clMu.Unlock()
+				return err
+			}
+			s.applyPaused(tcl)
+			s.tierCls = append(s.tierCls, tcl)
+		}
 	}
+	cl, tierCls := s.cl, s.tierCls
 	s.clMu.Unlock()
+	defer s.lag.run(ctx, s.opts.LagInterval, kgoEndOffsets(cl))()
+
+	// The tier loops live as long as this Consume call.
+	tierCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, tcl := range tierCls {
+		wg.Go(func() { _ = loop(tierCtx, tcl) })
+	}
+	err := loop(ctx, cl)
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// poll runs the PollFetches loop of one group client until ctx ends.
+func (s *franzConsumerGroup) poll(ctx context.Context, cl *kgo.Client, handler MessageHandler) error {
 	for i := 0; ; i++ {
 		if ctxDone(ctx) {
 			return ctx.Err()
 		}
-		fetches := s.cl.PollFetches(ctx)
+		fetches := cl.PollFetches(ctx)
 		for _, fe := range fetches.Errors() {
 			s.failed.Add(1)
 			s.fire(ConsumerEvent{Name: "error", Err: fe.Err})
 			s.pushErr(fe.Err)
 		}
+		setHighWatermarks(s.lag, fetches)
+		var lag *partitionLag // of the current record's partition
 		iter := fetches.RecordIter()
 		for !iter.Done() {
 			r := iter.Next()
 			msg := fromKgoRecord(r)
+			if lag == nil || lag.topic != r.Topic || lag.partition != r.Partition {
+				lag = s.lag.partition(r.Topic, r.Partition)
+			}
+			lag.seen(r.Offset)
+			// A retry-tier message is held until its not-before time.
+			if err := waitDue(ctx, msg); err != nil {
+				return err
+			}
+			if err := s.admit(ctx, msg); err != nil {
+				return err
+			}
 			s.received.Add(1)
 			s.bytes.Add(uint64(len(r.Value)))
 			s.fire(ConsumerEvent{Name: "message", Msg: msg})
 			if err := s.safeHandler(handler, msg); err != nil {
 				s.failed.Add(1)
+				if s.routeFailure(ctx, msg, err) {
+					cl.MarkCommitRecords(r) // republished: move past it
+					lag.done(r.Offset)
+					continue
+				}
 				s.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
 				continue // NACK: do not mark → re-delivered next session
 			}
-			s.cl.MarkCommitRecords(r)
+			cl.MarkCommitRecords(r)
+			lag.done(r.Offset)
 			s.acked.Add(1)
 			s.fire(ConsumerEvent{Name: "ack", Msg: msg})
 		}
 	}
 }
 
+// pollBatches is poll for a BatchMessageHandler: the batch is marked (ACK)
+// or routed / NACKed as a whole.
+func (s *franzConsumerGroup) pollBatches(ctx context.Context, cl *kgo.Client, handler BatchMessageHandler) error {
+	b := &kgoBatcher{o: s.opts, cl: cl, lag: s.lag, onErr: func(err error) {
+		s.failed.Add(1)
+		s.fire(ConsumerEvent{Name: "error", Err: err})
+		s.pushErr(err)
+	}}
+	for {
+		recs, err := b.next(ctx)
+		if err != nil {
+			return err
+		}
+		msgs := make([]Message, len(recs))
+		lags := make([]*partitionLag, len(recs))
+		for i, r := range recs {
+			msgs[i] = fromKgoRecord(r)
+			if i > 0 && r.Topic == recs[i-1].Topic && r.Partition == recs[i-1].Partition {
+				lags[i] = lags[i-1]
+			} else {
+				lags[i] = s.lag.partition(r.Topic, r.Partition)
+			}
+			lags[i].seen(r.Offset)
+			if err := waitDue(ctx, msgs[i]); err != nil {
+				return err
+			}
+			if err := s.admit(ctx, msgs[i]); err != nil {
+				return err
+			}
+			s.received.Add(1)
+			s.bytes.Add(uint64(len(r.Value)))
+			s.fire(ConsumerEvent{Name: "message", Msg: msgs[i]})
+		}
+		herr := safeBatchCall(handler, msgs, &s.recovered)
+		s.batches.record(len(msgs), herr != nil)
+		for i, r := range recs {
+			if herr != nil {
+				s.failed.Add(1)
+				if !s.routeFailure(ctx, msgs[i], herr) {
+					s.fire(ConsumerEvent{Name: "nack", Msg: msgs[i], Err: herr})
+					continue
+				}
+			} else {
+				s.acked.Add(1)
+				s.fire(ConsumerEvent{Name: "ack", Msg: msgs[i]})
+			}
+			cl.MarkCommitRecords(r)
+			lags[i].done(r.Offset)
+		}
+	}
+}
+
+// Pause pauses fetching on every client of the group (kgo's
+// PauseFetchTopics / PauseFetchPartitions, which also drop what is buffered
+// for them). Records of the current poll are still delivered.
+func (s *franzConsumerGroup) Pause(topic string, partitions ...int32) {
+	s.clMu.Lock()
+	s.paused.pause(topic, partitions)
+	for _, cl := range s.clients() {
+		if len(partitions) == 0 {
+			cl.PauseFetchTopics(topic)
+		} else {
+			cl.PauseFetchPartitions(map[string][]int32{topic: partitions})
+		}
+	}
+	s.clMu.Unlock()
+	firePause(s.fire, "pause", topic, partitions)
+}
+
+// Resume resumes fetching on every client of the group.
+func (s *franzConsumerGroup) Resume(topic string, partitions ...int32) {
+	s.clMu.Lock()
+	s.paused.resume(topic, partitions)
+	for _, cl := range s.clients() {
+		if len(partitions) == 0 {
+			cl.ResumeFetchTopics(topic)
+		} else {
+			cl.ResumeFetchPartitions(map[string][]int32{topic: partitions})
+		}
+	}
+	s.clMu.Unlock()
+	firePause(s.fire, "resume", topic, partitions)
+}
+
+// clients returns the group client and the retry-tier clients (caller holds
+// clMu); none before the first Consume.
+func (s *franzConsumerGroup) clients() []*kgo.Client {
+	if s.cl == nil {
+		return nil
+	}
+	return append([]*kgo.Client{s.cl}, s.tierCls...)
+}
+
+// applyPaused carries the pauses made before Consume over to a new client.
+func (s *franzConsumerGroup) applyPaused(cl *kgo.Client) {
+	parts, topics := s.paused.snapshot()
+	if len(topics) > 0 {
+		cl.PauseFetchTopics(topics...)
+	}
+	if len(parts) > 0 {
+		cl.PauseFetchPartitions(parts)
+	}
+}
+
+// admit gates a polled record on WithConsumeRateLimit. Pause needs no gate
+// here: kgo stops returning paused partitions from the next poll.
+func (s *franzConsumerGroup) admit(ctx context.Context, msg Message) error {
+	return admit(ctx, nil, s.opts.ConsumeRateLimit, msg, func(err error) {
+		s.fire(ConsumerEvent{Name: "error", Msg: msg, Err: err})
+		s.pushErr(err)
+	})
+}
+
 func (s *franzConsumerGroup) Errors() <-chan error {
 	s.errChOnce.Do(func() { s.errCh = make(chan error, 16) })
 	return s.errCh
@@ -115,20 +307,54 @@ func (s *franzConsumerGroup) Close() error {
 	if s.cl != nil {
 		s.cl.Close()
 	}
+	for _, tcl := range s.tierCls {
+		tcl.Close()
+	}
 	s.clMu.Unlock()
+	err := s.router.close()
 	s.fire(ConsumerEvent{Name: "close"})
-	return nil
+	return err
 }
 
 func (s *franzConsumerGroup) Metrics() ConsumerMetrics {
-	return ConsumerMetrics{
+	m := ConsumerMetrics{
 		Received:  s.received.Load(),
 		Acked:     s.acked.Load(),
 		Failed:    s.failed.Load(),
 		Recovered: s.recovered.Load(),
 		Rebalance: s.rebalance.Load(),
 		Bytes:     s.bytes.Load(),
+
+		Retried:      s.retried.Load(),
+		DeadLettered: s.deadLet.Load(),
 	}
+	s.batches.fill(&m)
+	return m
+}
+
+// routeFailure republishes a failed message through the router (if any) and
+// reports whether it was routed, in which case the caller marks it consumed.
+// Mirrors saramaConsumerGroup.routeFailure.
+func (s *franzConsumerGroup) routeFailure(ctx context.Context, msg Message, cause error) bool {
+	if s.router == nil {
+		return false
+	}
+	dlq, err := s.router.route(ctx, msg, cause)
+	switch {
+	case errors.Is(err, errNoRoute):
+		return false
+	case err != nil:
+		s.fire(ConsumerEvent{Name: "error", Msg: msg, Err: err})
+		s.pushErr(err)
+		return false
+	case dlq:
+		s.deadLet.Add(1)
+		s.fire(ConsumerEvent{Name: "dead_letter", Msg: msg, Err: cause})
+	default:
+		s.retried.Add(1)
+		s.fire(ConsumerEvent{Name: "retry", Msg: msg, Err: cause})
+	}
+	return true
 }
 
 // Recovered returns the number of consumer-handler panics recovered since the
@@ -154,14 +380,144 @@ func (s *franzConsumerGroup) safeHandler(handler MessageHandler, msg Message) (e
 }
 
 func (s *franzConsumerGroup) Snapshot() ConsumerSnapshot {
+	lag, total := s.lag.snapshot()
+	paused, pausedTopics := s.paused.snapshot()
 	return ConsumerSnapshot{
 		Name:            s.Name(),
 		Backend:         s.Backend(),
 		Timestamp:       time.Now().UTC(),
 		ConsumerMetrics: s.Metrics(),
+		Lag:             lag,
+		TotalLag:        total,
+		Paused:          paused,
+		PausedTopics:    pausedTopics,
+	}
+}
+
+// lagHooks seed assigned partitions with the group's committed offsets and
+// drop revoked / lost ones from the lag tracker, so only the current owner
+// reports them. Installing OnPartitionsRevoked replaces kgo's default
+// commit-on-revoke, so the hook commits the marked offsets itself.
+func (s *franzConsumerGroup) lagHooks() []kgo.Opt {
+	assigned := func(ctx context.Context, cl *kgo.Client, m map[string][]int32) {
+		offs, err := kgoCommittedOffsets(cl, s.opts.GroupID)(ctx, m)
+		if err != nil {
+			return // lag starts at the first message seen instead
+		}
+		for topic, ps := range m {
+			for _, p := range ps {
+				if o, ok := offs[topic][p]; ok {
+					s.lag.partition(topic, p).start(o)
+				}
+			}
+		}
+	}
+	drop := func(m map[string][]int32) {
+		for topic, ps := range m {
+			for _, p := range ps {
+				s.lag.drop(topic, p)
+			}
+		}
+	}
+	revoked := func(ctx context.Context, cl *kgo.Client, m map[string][]int32) {
+		if err := cl.CommitMarkedOffsets(ctx); err != nil {
+			s.fire(ConsumerEvent{Name: "error", Err: err})
+			s.pushErr(err)
+		}
+		drop(m)
+	}
+	lost := func(_ context.Context, _ *kgo.Client, m map[string][]int32) { drop(m) }
+	return []kgo.Opt{kgo.OnPartitionsAssigned(assigned), kgo.OnPartitionsRevoked(revoked), kgo.OnPartitionsLost(lost)}
+}
+
+// kgoCommittedOffsets fetches group's committed offsets of parts through cl
+// (kgo routes the request to the group coordinator); a partition without a
+// commit maps to -1.
+func kgoCommittedOffsets(cl *kgo.Client, group string) endOffsetsFunc {
+	return func(ctx context.Context, parts map[string][]int32) (map[string]map[int32]int64, error) {
+		req := kmsg.NewPtrOffsetFetchRequest()
+		req.Group = group
+		for topic, ps := range parts {
+			rt := kmsg.NewOffsetFetchRequestTopic()
+			rt.Topic, rt.Partitions = topic, ps
+			req.Topics = append(req.Topics, rt)
+		}
+		resp, err := req.RequestWith(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]map[int32]int64)
+		add := func(topic string, p int32, offset int64, code int16) {
+			if code != 0 {
+				return
+			}
+			if out[topic] == nil {
+				out[topic] = make(map[int32]int64)
+			}
+			out[topic][p] = offset
+		}
+		for _, rt := range resp.Topics {
+			for _, rp := range rt.Partitions {
+				add(rt.Topic, rp.Partition, rp.Offset, rp.ErrorCode)
+			}
+		}
+		for _, g := range resp.Groups { // the batched form (OffsetFetch v8+)
+			for _, rt := range g.Topics {
+				for _, rp := range rt.Partitions {
+					add(rt.Topic, rp.Partition, rp.Offset, rp.ErrorCode)
+				}
+			}
+		}
+		return out, nil
+	}
+}
+
+// kgoEndOffsets lists the end offsets of parts through cl (kgo routes the
+// request to each partition's leader), for lagTracker.run.
+func kgoEndOffsets(cl *kgo.Client) endOffsetsFunc {
+	return func(ctx context.Context, parts map[string][]int32) (map[string]map[int32]int64, error) {
+		req := kmsg.NewPtrListOffsetsRequest()
+		req.ReplicaID = -1
+		for topic, ps := range parts {
+			rt := kmsg.NewListOffsetsRequestTopic()
+			rt.Topic = topic
+			for _, p := range ps {
+				rp := kmsg.NewListOffsetsRequestTopicPartition()
+				rp.Partition, rp.Timestamp = p, -1 // -1: the latest offset
+				rt.Partitions = append(rt.Partitions, rp)
+			}
+			req.Topics = append(req.Topics, rt)
+		}
+		resp, err := req.RequestWith(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]map[int32]int64, len(resp.Topics))
+		for _, rt := range resp.Topics {
+			for _, rp := range rt.Partitions {
+				if rp.ErrorCode != 0 {
+					continue
+				}
+				if out[rt.Topic] == nil {
+					out[rt.Topic] = make(map[int32]int64, len(rt.Partitions))
+				}
+				out[rt.Topic][rp.Partition] = rp.Offset
+			}
+		}
+		return out, nil
 	}
 }
 
+// setHighWatermarks records the high watermark each fetched partition
+// reported.
+func setHighWatermarks(t *lagTracker, fetches kgo.Fetches) {
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		if p.Err == nil {
+			t.partition(p.Topic, p.Partition).setHighWatermark(p.HighWatermark)
+		}
+	})
+}
+
 func (s *franzConsumerGroup) Name() string { return nameOr(s.opts.Name, s.opts.GroupID) }
 
 func (s *franzConsumerGroup) Backend() string { return backendName }
@@ -191,6 +547,7 @@ func (s *franzConsumerGroup) fire(e ConsumerEvent) {
 type franzPartitionConsumer struct {
 	opts Options
 	cl   *kgo.Client
+	lag  *lagTracker
 
 	closed atomic.Bool
 
@@ -207,6 +564,7 @@ type franzPartitionConsumer struct {
 	failed    atomic.Uint64
 	recovered atomic.Uint64 // consumer handler panics recovered (mirrors sarama; observable + L5)
 	bytes     atomic.Uint64
+	batches   batchStats
 
 	errChOnce sync.Once
 	errCh     chan error
@@ -222,6 +580,48 @@ func (s *franzPartitionConsumer) Consume(ctx context.Context, handler MessageHan
 	return s.pump(ctx, handler, nil)
 }
 
+// ConsumeBatch is Consume with a BatchMessageHandler (see
+// saramaPartitionConsumer.ConsumeBatch).
+func (s *franzPartitionConsumer) ConsumeBatch(ctx context.Context, handler BatchMessageHandler) error {
+	if s.closed.Load() {
+		return ErrProducerClosed
+	}
+	lag := s.lag.partition(s.opts.Topic, s.opts.Partition)
+	lag.start(s.opts.Offset)
+	defer s.lag.run(ctx, s.opts.LagInterval, kgoEndOffsets(s.cl))()
+	b := &kgoBatcher{o: s.opts, cl: s.cl, lag: s.lag, onErr: func(err error) {
+		s.failed.Add(1)
+		s.fire(ConsumerEvent{Name: "error", Err: err})
+		s.pushErr(err)
+	}}
+	for {
+		recs, err := b.next(ctx)
+		if err != nil {
+			return err
+		}
+		msgs := make([]Message, len(recs))
+		for i, r := range recs {
+			msgs[i] = fromKgoRecord(r)
+			lag.seen(r.Offset)
+			s.received.Add(1)
+			s.bytes.Add(uint64(len(r.Value)))
+			s.fire(ConsumerEvent{Name: "message", Msg: msgs[i]})
+		}
+		herr := safeBatchCall(handler, msgs, &s.recovered)
+		s.batches.record(len(msgs), herr != nil)
+		for _, msg := range msgs {
+			if herr != nil {
+				s.failed.Add(1)
+				s.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: herr})
+				continue
+			}
+			lag.done(msg.Offset)
+			s.acked.Add(1)
+			s.fire(ConsumerEvent{Name: "ack", Msg: msg})
+		}
+	}
+}
+
 func (s *franzPartitionConsumer) Messages() <-chan Message {
 	if s.opts.DeliveryMode != "channel" {
 		return nil
@@ -245,6 +645,9 @@ func (s *franzPartitionConsumer) pump(ctx context.Context, handler MessageHandle
 		// Close cancels the pump. Skipped in callback mode (out == nil).
 		defer close(out)
 	}
+	lag := s.lag.partition(s.opts.Topic, s.opts.Partition)
+	lag.start(s.opts.Offset)
+	defer s.lag.run(ctx, s.opts.LagInterval, kgoEndOffsets(s.cl))()
 	for i := 0; ; i++ {
 		if ctxDone(ctx) {
 			return ctx.Err()
@@ -255,10 +658,12 @@ func (s *franzPartitionConsumer) pump(ctx context.Context, handler MessageHandle
 			s.fire(ConsumerEvent{Name: "error", Err: fe.Err})
 			s.pushErr(fe.Err)
 		}
+		setHighWatermarks(s.lag, fetches)
 		iter := fetches.RecordIter()
 		for !iter.Done() {
 			r := iter.Next()
 			msg := fromKgoRecord(r)
+			lag.seen(r.Offset)
 			s.received.Add(1)
 			s.bytes.Add(uint64(len(r.Value)))
 			s.fire(ConsumerEvent{Name: "message", Msg: msg})
@@ -268,11 +673,13 @@ func (s *franzPartitionConsumer) pump(ctx context.Context, handler MessageHandle
 					s.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
 					continue
 				}
+				lag.done(r.Offset)
 				s.acked.Add(1)
 				s.fire(ConsumerEvent{Name: "ack", Msg: msg})
 			} else {
 				select {
 				case out <- msg:
+					lag.done(r.Offset) // handed over: the caller's from here
 				case <-ctx.Done():
 					return ctx.Err()
 				}
@@ -316,13 +723,15 @@ func (s *franzPartitionConsumer) Close() error {
 }
 
 func (s *franzPartitionConsumer) Metrics() ConsumerMetrics {
-	return ConsumerMetrics{
+	m := ConsumerMetrics{
 		Received:  s.received.Load(),
 		Acked:     s.acked.Load(),
 		Failed:    s.failed.Load(),
 		Recovered: s.recovered.Load(),
 		Bytes:     s.bytes.Load(),
 	}
+	s.batches.fill(&m)
+	return m
 }
 
 // Recovered returns the number of consumer-handler panics recovered. Parity
@@ -342,11 +751,14 @@ func (s *franzPartitionConsumer) safeHandler(handler MessageHandler, msg Message
 }
 
 func (s *franzPartitionConsumer) Snapshot() ConsumerSnapshot {
+	lag, total := s.lag.snapshot()
 	return ConsumerSnapshot{
 		Name:            s.Name(),
 		Backend:         s.Backend(),
 		Timestamp:       time.Now().UTC(),
 		ConsumerMetrics: s.Metrics(),
+		Lag:             lag,
+		TotalLag:        total,
 	}
 }
 
@@ -367,3 +779,57 @@ func (s *franzPartitionConsumer) fire(e ConsumerEvent) {
 		(*fnp)(e)
 	}
 }
+
+// kgoBatcher collects the records of one BatchMessageHandler call from a kgo
+// client, within the ConsumeBatchMax* bounds. carry holds records polled
+// beyond a full batch; they open the next one.
+type kgoBatcher struct {
+	o     Options
+	cl    *kgo.Client
+	lag   *lagTracker
+	onErr func(error) // fetch errors, minus the batcher's own poll deadline
+	carry []*kgo.Record
+}
+
+// next blocks for the first record, then fills the batch until it is full or
+// ConsumeBatchMaxWait after that record. When ctx ends it returns ctx.Err()
+// and the unfinished batch is dropped (left unmarked).
+func (b *kgoBatcher) next(ctx context.Context) ([]*kgo.Record, error) {
+	var (
+		batch    []*kgo.Record
+		size     int
+		deadline time.Time
+	)
+	for {
+		for len(b.carry) > 0 {
+			r := b.carry[0]
+			b.carry = b.carry[1:]
+			batch, size = append(batch, r), size+len(r.Value)
+			if len(batch) == 1 {
+				deadline = time.Now().Add(b.o.ConsumeBatchMaxWait)
+			}
+			if batchFull(b.o, len(batch), size) {
+				return batch, nil
+			}
+		}
+		pctx, cancel := ctx, context.CancelFunc(func() {})
+		if len(batch) > 0 {
+			if !time.Now().Before(deadline) {
+				return batch, nil
+			}
+			pctx, cancel = context.WithDeadline(ctx, deadline)
+		}
+		fetches := b.cl.PollRecords(pctx, b.o.ConsumeBatchMaxRecords-len(batch))
+		cancel()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, fe := range fetches.Errors() {
+			if !errors.Is(fe.Err, context.DeadlineExceeded) {
+				b.onErr(fe.Err)
+			}
+		}
+		setHighWatermarks(b.lag, fetches)
+		b.carry = append(b.carry, fetches.Records()...)
+	}
+}
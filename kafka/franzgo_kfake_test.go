@@ -222,3 +222,60 @@ func TestFranzgoKfake_PartitionConsumerChannelMode_RangeUnblocksOnClose(t *testi
 		t.Fatal("range over Messages() did not unblock after Close (channel never closed)")
 	}
 }
+
+// TestFranzgoKfake_LagFromCommittedOffset: a paused group fetches nothing,
+// yet reports the backlog between its committed offset and the high
+// watermark as soon as the partition is assigned.
+func TestFranzgoKfake_LagFromCommittedOffset(t *testing.T) {
+	_, addrs := kfakeCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic("backlog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := make([]Message, 10)
+	for i := range batch {
+		batch[i] = Message{Value: []byte{byte(i)}}
+	}
+	mustNoErr(t, sp.SendBatch(ctx, batch))
+	mustNoErr(t, sp.Close())
+	group := func() ConsumerGroup {
+		g, err := NewConsumerGroup(WithBrokers(addrs...), WithGroupID("backlog-g"),
+			WithConsumerOffsetInitial(OffsetOldest), WithLagInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+
+	// commit offset 3: ACK 0-2, NACK the rest.
+	g := group()
+	cctx, ccancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- g.Consume(cctx, []string{"backlog"}, func(m Message) error {
+			if m.Offset >= 3 {
+				return errBoom
+			}
+			return nil
+		})
+	}()
+	waitUntil(t, func() bool { return g.Metrics().Received == 10 }, "all 10 received")
+	ccancel()
+	<-done
+	mustNoErr(t, g.Close())
+
+	g = group()
+	defer g.Close()
+	g.Pause("backlog")
+	cctx, ccancel = context.WithCancel(ctx)
+	defer ccancel()
+	go func() { done <- g.Consume(cctx, []string{"backlog"}, func(Message) error { return nil }) }()
+	waitUntil(t, func() bool { return g.Snapshot().TotalLag == 7 }, "lag 7 from the committed offset")
+	if s := g.Snapshot(); s.Lag["backlog"][0] != 7 || s.Received != 0 {
+		t.Errorf("Lag=%v Received=%d, want 7 behind with nothing fetched", s.Lag, s.Received)
+	}
+	ccancel()
+	<-done
+}
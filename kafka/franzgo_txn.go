@@ -0,0 +1,552 @@
+//go:build franzgo
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/kversion"
+)
+
+// kgoTxnOpts builds kgo client options for a transactional producer: the sync
+// producer options (acks=all keeps the idempotent producer on) plus the
+// transactional id and timeout.
+func kgoTxnOpts(o Options) []kgo.Opt {
+	return append(kgoSyncProducerOpts(o),
+		kgo.TransactionalID(o.TransactionalID),
+		kgo.TransactionTimeout(o.TransactionTimeout),
+	)
+}
+
+// kgoTxnProducerOpts adds, for the standalone TransactionalProducer, a cap of
+// EndTxn at v4. SendOffsetsToTxn issues AddOffsetsToTxn / TxnOffsetCommit
+// itself (kgo only commits offsets for its own group), and a transaction that
+// carries offsets but no records has to be ended with our own EndTxn — which
+// must not bump the producer epoch behind kgo's back as v5+ (KIP-890) does.
+// The cap keeps kgo on the classic AddPartitionsToTxn protocol throughout.
+func kgoTxnProducerOpts(o Options) []kgo.Opt {
+	v := kversion.Stable()
+	v.SetMaxKeyVersion(int16(kmsg.EndTxn), 4)
+	return append(kgoTxnOpts(o), kgo.MaxVersions(v))
+}
+
+// NewTransactionalProducer builds a TransactionalProducer backed by franz-go.
+// WithBrokers and WithTransactionalID are required; acks=all and the
+// idempotent producer are forced (Kafka requires both for transactions).
+func NewTransactionalProducer(opts ...Option) (TransactionalProducer, error) {
+	o := txnOptions(opts)
+	if err := o.validate("transactional-producer"); err != nil {
+		return nil, err
+	}
+	logConfig("txn-producer", o)
+	cl, err := newKgoClient(o, kgoTxnProducerOpts(o)...)
+	if err != nil {
+		return nil, err
+	}
+	return &franzTxnProducer{opts: o, cl: cl}, nil
+}
+
+// franzTxnProducer is the franz-go TransactionalProducer on a transactional
+// kgo.Client; Send blocks via ProduceSync.
+type franzTxnProducer struct {
+	opts Options
+	cl   *kgo.Client
+
+	closed atomic.Bool
+	// produced / offsetsAdded track the open transaction (see CommitTxn).
+	produced     atomic.Bool
+	offsetsAdded atomic.Bool
+
+	enqueued    atomic.Uint64
+	success     atomic.Uint64
+	failed      atomic.Uint64
+	bytes       atomic.Uint64
+	bytesFailed atomic.Uint64
+	begun       atomic.Uint64
+	committed   atomic.Uint64
+	aborted     atomic.Uint64
+
+	onEvent atomic.Pointer[func(ProducerEvent)]
+}
+
+func (s *franzTxnProducer) BeginTxn() error {
+	if s.closed.Load() {
+		return ErrProducerClosed
+	}
+	if err := s.cl.BeginTransaction(); err != nil {
+		return err
+	}
+	s.produced.Store(false)
+	s.offsetsAdded.Store(false)
+	s.begun.Add(1)
+	s.fire(ProducerEvent{Name: "begin"})
+	return nil
+}
+
+func (s *franzTxnProducer) Send(ctx context.Context, msg Message) error {
+	if s.closed.Load() {
+		return ErrProducerClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r := toKgoRecord(msg, s.opts.Topic)
+	s.enqueued.Add(1)
+	s.fire(ProducerEvent{Name: "send", Topic: r.Topic, Bytes: len(msg.Value)})
+	if err := s.cl.ProduceSync(ctx, r).FirstErr(); err != nil {
+		s.failed.Add(1)
+		s.bytesFailed.Add(uint64(len(msg.Value)))
+		s.fire(ProducerEvent{Name: "error", Topic: r.Topic, Err: err})
+		return err
+	}
+	s.produced.Store(true)
+	s.success.Add(1)
+	s.bytes.Add(uint64(len(msg.Value)))
+	s.fire(ProducerEvent{Name: "success", Topic: r.Topic, Partition: r.Partition, Offset: r.Offset, Bytes: len(msg.Value)})
+	return nil
+}
+
+func (s *franzTxnProducer) SendOffsetsToTxn(ctx context.Context, groupID string, offsets map[string]map[int32]int64) error {
+	if s.closed.Load() {
+		return ErrProducerClosed
+	}
+	id, epoch, err := s.cl.ProducerID(ctx)
+	if err != nil {
+		return err
+	}
+	add := kmsg.NewPtrAddOffsetsToTxnRequest()
+	add.TransactionalID = s.opts.TransactionalID
+	add.ProducerID, add.ProducerEpoch = id, epoch
+	add.Group = groupID
+	if err := kgoTxnRetry(ctx, func() error {
+		resp, err := add.RequestWith(ctx, s.cl)
+		if err != nil {
+			return err
+		}
+		return kerr.ErrorForCode(resp.ErrorCode)
+	}); err != nil {
+		return fmt.Errorf("kafka: add offsets to transaction: %w", err)
+	}
+	s.offsetsAdded.Store(true)
+
+	commit := kmsg.NewPtrTxnOffsetCommitRequest()
+	commit.TransactionalID = s.opts.TransactionalID
+	commit.Group = groupID
+	commit.ProducerID, commit.ProducerEpoch = id, epoch
+	commit.Generation = -1
+	for topic, parts := range offsets {
+		t := kmsg.NewTxnOffsetCommitRequestTopic()
+		t.Topic = topic
+		for p, off := range parts {
+			rp := kmsg.NewTxnOffsetCommitRequestTopicPartition()
+			rp.Partition, rp.Offset, rp.LeaderEpoch = p, off, -1
+			t.Partitions = append(t.Partitions, rp)
+		}
+		commit.Topics = append(commit.Topics, t)
+	}
+	return kgoTxnRetry(ctx, func() error {
+		resp, err := commit.RequestWith(ctx, s.cl)
+		if err != nil {
+			return err
+		}
+		for _, t := range resp.Topics {
+			for _, p := range t.Partitions {
+				if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+					return fmt.Errorf("kafka: commit offsets for %s/%d: %w", t.Topic, p.Partition, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *franzTxnProducer) CommitTxn(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrProducerClosed
+	}
+	if err := s.end(ctx, kgo.TryCommit); err != nil {
+		s.fire(ProducerEvent{Name: "error", Err: err})
+		return err
+	}
+	s.committed.Add(1)
+	s.fire(ProducerEvent{Name: "commit"})
+	return nil
+}
+
+func (s *franzTxnProducer) AbortTxn(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrProducerClosed
+	}
+	return s.abort(ctx)
+}
+
+func (s *franzTxnProducer) abort(ctx context.Context) error {
+	if err := s.cl.AbortBufferedRecords(ctx); err != nil {
+		return err
+	}
+	if err := s.end(ctx, kgo.TryAbort); err != nil {
+		s.fire(ProducerEvent{Name: "error", Err: err})
+		return err
+	}
+	s.aborted.Add(1)
+	s.fire(ProducerEvent{Name: "abort"})
+	return nil
+}
+
+// end ends the transaction through kgo. kgo skips EndTxn when no record was
+// produced, so a transaction that only carries offsets is ended here with an
+// explicit EndTxn (INVALID_TXN_STATE means kgo did end it after all).
+func (s *franzTxnProducer) end(ctx context.Context, commit kgo.TransactionEndTry) error {
+	if err := s.cl.EndTransaction(ctx, commit); err != nil {
+		return err
+	}
+	if !s.offsetsAdded.Swap(false) || s.produced.Load() {
+		return nil
+	}
+	id, epoch, err := s.cl.ProducerID(ctx)
+	if err != nil {
+		return err
+	}
+	req := kmsg.NewPtrEndTxnRequest()
+	req.TransactionalID = s.opts.TransactionalID
+	req.ProducerID, req.ProducerEpoch = id, epoch
+	req.Commit = bool(commit)
+	err = kgoTxnRetry(ctx, func() error {
+		resp, err := req.RequestWith(ctx, s.cl)
+		if err != nil {
+			return err
+		}
+		return kerr.ErrorForCode(resp.ErrorCode)
+	})
+	if errors.Is(err, kerr.InvalidTxnState) {
+		return nil
+	}
+	return err
+}
+
+// kgoTxnRetry retries fn while the coordinator reports the previous
+// transaction is still completing (CONCURRENT_TRANSACTIONS), as kgo does for
+// its own transactional requests.
+func kgoTxnRetry(ctx context.Context, fn func() error) error {
+	for i := 1; ; i++ {
+		err := fn()
+		if !errors.Is(err, kerr.ConcurrentTransactions) || i == 10 {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(i) * 20 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *franzTxnProducer) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.ProducerTimeout)
+	defer cancel()
+	_ = s.abort(ctx) // no-op when no transaction is open
+	s.cl.Close()
+	s.fire(ProducerEvent{Name: "close"})
+	return nil
+}
+
+func (s *franzTxnProducer) Metrics() TxnMetrics {
+	e, su, f := s.enqueued.Load(), s.success.Load(), s.failed.Load()
+	ba, bf := s.bytes.Load(), s.bytesFailed.Load()
+	return TxnMetrics{
+		ProducerMetrics: ProducerMetrics{
+			Enqueued:      e,
+			Success:       su,
+			Failed:        f,
+			Bytes:         ba,
+			BytesFailed:   bf,
+			BytesEnqueued: ba + bf, // sync: every enqueued record is resolved on return
+			InFlight:      ComputeInFlight(e, su, f),
+		},
+		Begun:     s.begun.Load(),
+		Committed: s.committed.Load(),
+		Aborted:   s.aborted.Load(),
+	}
+}
+
+func (s *franzTxnProducer) Name() string { return nameOr(s.opts.Name, s.opts.TransactionalID) }
+
+func (s *franzTxnProducer) Backend() string { return backendName }
+
+func (s *franzTxnProducer) SetOnEvent(fn func(ProducerEvent)) {
+	if fn == nil {
+		s.onEvent.Store(nil)
+		return
+	}
+	s.onEvent.Store(&fn)
+}
+
+func (s *franzTxnProducer) fire(e ProducerEvent) {
+	if fnp := s.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
+
+// NewTxnConsumerGroup builds an exactly-once TxnConsumerGroup backed by
+// franz-go. WithBrokers, WithGroupID and WithTransactionalID are required.
+func NewTxnConsumerGroup(opts ...Option) (TxnConsumerGroup, error) {
+	o := txnOptions(opts)
+	if err := o.validate("txn-consumer-group"); err != nil {
+		return nil, err
+	}
+	logConfig("txn-consumer-group", o)
+	return &franzTxnConsumerGroup{opts: o}, nil
+}
+
+// franzTxnConsumerGroup is the franz-go TxnConsumerGroup, built on
+// kgo.GroupTransactSession: it aborts a transaction that overlaps a rebalance
+// and, after any abort, rewinds the consumer to the last committed offsets.
+// Like franzConsumerGroup, the session is created lazily in Consume because
+// the topics must be known at client creation.
+type franzTxnConsumerGroup struct {
+	opts Options
+
+	clMu sync.Mutex
+	sess *kgo.GroupTransactSession
+
+	closed    atomic.Bool
+	received  atomic.Uint64
+	acked     atomic.Uint64
+	failed    atomic.Uint64
+	recovered atomic.Uint64
+	bytes     atomic.Uint64
+
+	// produced-output and transaction counters (TxnMetrics).
+	outEnqueued  atomic.Uint64
+	outSuccess   atomic.Uint64
+	outFailed    atomic.Uint64
+	outBytes     atomic.Uint64
+	outBytesFail atomic.Uint64
+	begun        atomic.Uint64
+	committed    atomic.Uint64
+	aborted      atomic.Uint64
+
+	errChOnce sync.Once
+	errCh     chan error
+
+	onEvent atomic.Pointer[func(ConsumerEvent)]
+}
+
+func (s *franzTxnConsumerGroup) Consume(ctx context.Context, topics []string, handler TxnBatchHandler) error {
+	if s.closed.Load() {
+		return ErrProducerClosed
+	}
+	s.clMu.Lock()
+	if s.closed.Load() {
+		s.clMu.Unlock()
+		return ErrProducerClosed
+	}
+	if s.sess == nil {
+		kopts := append(kgoTxnOpts(s.opts),
+			kgo.ConsumerGroup(s.opts.GroupID),
+			kgo.ConsumeTopics(topics...),
+			kgo.ConsumeResetOffset(offsetToKgo(s.opts.ConsumerOffsetInitial)),
+			kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+			kgo.RequireStableFetchOffsets(),
+		)
+		sec, err := kgoSecurityOpts(s.opts)
+		if err != nil {
+			s.clMu.Unlock()
+			return err
+		}
+		sess, err := kgo.NewGroupTransactSession(append(kopts, sec...)...)
+		if err != nil {
+			s.clMu.Unlock()
+			return err
+		}
+		s.sess = sess
+	}
+	sess := s.sess
+	s.clMu.Unlock()
+
+	for {
+		if ctxDone(ctx) {
+			return ctx.Err()
+		}
+		fetches := sess.PollRecords(ctx, s.opts.TxnMaxBatch)
+		if fetches.IsClientClosed() {
+			return ErrProducerClosed
+		}
+		for _, fe := range fetches.Errors() {
+			if errors.Is(fe.Err, context.Canceled) || errors.Is(fe.Err, context.DeadlineExceeded) {
+				continue
+			}
+			s.fire(ConsumerEvent{Name: "error", Err: fe.Err})
+			s.pushErr(fe.Err)
+		}
+		records := fetches.Records()
+		if len(records) == 0 {
+			continue
+		}
+		if err := s.runTxn(ctx, sess, records, handler); err != nil {
+			s.fire(ConsumerEvent{Name: "error", Err: err})
+			return err
+		}
+	}
+}
+
+// runTxn processes one polled batch in one transaction. Only a fatal error
+// (the session can no longer end transactions) is returned; an abort is
+// counted and the loop carries on from the rewound offsets.
+func (s *franzTxnConsumerGroup) runTxn(ctx context.Context, sess *kgo.GroupTransactSession, records []*kgo.Record, handler TxnBatchHandler) error {
+	msgs := make([]Message, len(records))
+	for i, r := range records {
+		msgs[i] = fromKgoRecord(r)
+		s.received.Add(1)
+		s.bytes.Add(uint64(len(r.Value)))
+		s.fire(ConsumerEvent{Name: "message", Msg: msgs[i]})
+	}
+	last := msgs[len(msgs)-1]
+
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+	s.begun.Add(1)
+	outs, err := s.safeHandler(ctx, handler, msgs)
+	if err == nil && len(outs) > 0 {
+		rs := make([]*kgo.Record, len(outs))
+		var n uint64
+		for i, m := range outs {
+			rs[i] = toKgoRecord(m, s.opts.Topic)
+			n += uint64(len(m.Value))
+		}
+		s.outEnqueued.Add(uint64(len(rs)))
+		if err = sess.ProduceSync(ctx, rs...).FirstErr(); err != nil {
+			s.outFailed.Add(uint64(len(rs)))
+			s.outBytesFail.Add(n)
+		} else {
+			s.outSuccess.Add(uint64(len(rs)))
+			s.outBytes.Add(n)
+		}
+	}
+	committed, endErr := sess.End(ctx, kgo.TransactionEndTry(err == nil))
+	if committed {
+		s.committed.Add(1)
+		s.acked.Add(uint64(len(msgs)))
+		s.fire(ConsumerEvent{Name: "commit", Msg: last})
+		return nil
+	}
+	s.aborted.Add(1)
+	s.failed.Add(uint64(len(msgs)))
+	if err == nil {
+		err = endErr
+	}
+	if err == nil {
+		err = errors.New("kafka: transaction aborted by a rebalance")
+	}
+	s.fire(ConsumerEvent{Name: "abort", Msg: last, Err: err})
+	if endErr != nil && !ctxDone(ctx) {
+		return fmt.Errorf("kafka: end transaction: %w", endErr)
+	}
+	return nil
+}
+
+// safeHandler runs the TxnBatchHandler with panic recovery (see
+// franzConsumerGroup.safeHandler); a panic aborts the batch's transaction.
+func (s *franzTxnConsumerGroup) safeHandler(ctx context.Context, handler TxnBatchHandler, batch []Message) (outs []Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.recovered.Add(1)
+			err = fmt.Errorf("kafka: consumer handler panic: %v", r)
+		}
+	}()
+	return handler(ctx, batch)
+}
+
+func (s *franzTxnConsumerGroup) Errors() <-chan error {
+	s.errChOnce.Do(func() { s.errCh = make(chan error, 16) })
+	return s.errCh
+}
+
+func (s *franzTxnConsumerGroup) pushErr(err error) {
+	s.errChOnce.Do(func() { s.errCh = make(chan error, 16) })
+	select {
+	case s.errCh <- err:
+	default:
+	}
+}
+
+func (s *franzTxnConsumerGroup) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	s.clMu.Lock()
+	if s.sess != nil {
+		s.sess.Close()
+	}
+	s.clMu.Unlock()
+	s.fire(ConsumerEvent{Name: "close"})
+	return nil
+}
+
+func (s *franzTxnConsumerGroup) Metrics() ConsumerMetrics {
+	return ConsumerMetrics{
+		Received:  s.received.Load(),
+		Acked:     s.acked.Load(),
+		Failed:    s.failed.Load(),
+		Recovered: s.recovered.Load(),
+		Bytes:     s.bytes.Load(),
+	}
+}
+
+func (s *franzTxnConsumerGroup) TxnMetrics() TxnMetrics {
+	e, su, f := s.outEnqueued.Load(), s.outSuccess.Load(), s.outFailed.Load()
+	ba, bf := s.outBytes.Load(), s.outBytesFail.Load()
+	return TxnMetrics{
+		ProducerMetrics: ProducerMetrics{
+			Enqueued:      e,
+			Success:       su,
+			Failed:        f,
+			Bytes:         ba,
+			BytesFailed:   bf,
+			BytesEnqueued: ba + bf,
+			InFlight:      ComputeInFlight(e, su, f),
+		},
+		Begun:     s.begun.Load(),
+		Committed: s.committed.Load(),
+		Aborted:   s.aborted.Load(),
+	}
+}
+
+func (s *franzTxnConsumerGroup) Snapshot() ConsumerSnapshot {
+	return ConsumerSnapshot{
+		Name:            s.Name(),
+		Backend:         s.Backend(),
+		Timestamp:       time.Now().UTC(),
+		ConsumerMetrics: s.Metrics(),
+	}
+}
+
+func (s *franzTxnConsumerGroup) Name() string { return nameOr(s.opts.Name, s.opts.GroupID) }
+
+func (s *franzTxnConsumerGroup) Backend() string { return backendName }
+
+func (s *franzTxnConsumerGroup) SetOnEvent(fn func(ConsumerEvent)) {
+	if fn == nil {
+		s.onEvent.Store(nil)
+		return
+	}
+	s.onEvent.Store(&fn)
+}
+
+func (s *franzTxnConsumerGroup) fire(e ConsumerEvent) {
+	if fnp := s.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
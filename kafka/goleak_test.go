@@ -16,5 +16,8 @@ func TestMain(m *testing.M) {
 		// mock producers' dispatchers) that outlive a single Close; ignore any
 		// present at suite start so only this package's leaks surface.
 		goleak.IgnoreCurrent(),
+		// a real sarama client (the transaction tests run one against kfake)
+		// starts go-metrics' process-wide meter ticker, which never exits.
+		goleak.IgnoreTopFunction("github.com/rcrowley/go-metrics.(*meterArbiter).tick"),
 	)
 }
@@ -1,11 +1,11 @@
 // Package kafka is a library-agnostic Kafka producer + consumer wrapper.
 //
 // Callers depend only on the interfaces declared here (Producer, SyncProducer,
-// ConsumerGroup, PartitionConsumer) and the value types (Message, Codec,
-// Options). The default implementation wraps github.com/IBM/sarama (see the
-// sarama_*.go files); alternate backends (segmentio/kafka-go,
-// confluent-kafka-go) can be added later behind the same interfaces with zero
-// change to consuming code — the "无感切换" (seamless swap) goal.
+// TransactionalProducer, ConsumerGroup, TxnConsumerGroup, PartitionConsumer)
+// and the value types (Message, Codec, Options). The default implementation
+// wraps github.com/IBM/sarama (see the sarama_*.go files); alternate backends
+// (segmentio/kafka-go, confluent-kafka-go) can be added later behind the same
+// interfaces with zero change to consuming code — the "无感切换" (seamless swap) goal.
 //
 // Quick start — produce (async) and consume (group):
 //
@@ -33,6 +33,13 @@ import (
 // backends).
 var ErrProducerClosed = errors.New("kafka: client closed")
 
+// ErrTxnOffsetsOnly is returned by the sarama backend's CommitTxn for a
+// transaction that carries consumer offsets but no records: sarama never sends
+// such offsets to the broker, so the transaction is aborted instead of
+// dropping them silently. The franz-go backend commits these transactions, and
+// TxnConsumerGroup handles an empty batch output on both backends.
+var ErrTxnOffsetsOnly = errors.New("kafka: transaction has offsets but no records")
+
 // Offset sentinels for "consume from". They intentionally mirror sarama's
 // sentinel values but are owned here so callers never import sarama. A concrete
 // int64 >= 0 means "consume from this absolute offset".
@@ -68,6 +75,12 @@ type Message struct {
 // responsibility, e.g. via retry/idempotency).
 type MessageHandler func(Message) error
 
+// BatchMessageHandler is the ConsumeBatch callback: batch is ACKed or NACKed
+// as a whole (every message's offset is marked, or none is), e.g. for one
+// bulk insert per batch. With WithRetryTopics / WithDeadLetter a NACKed
+// batch is routed message by message. The slice is not reused after the call.
+type BatchMessageHandler func(batch []Message) error
+
 // Codec (de)serializes Message.Value so callers can hand structured values to
 // Send and receive typed values from a handler. It is OPTIONAL: a nil codec
 // means raw byte pass-through (the common case for pre-encoded payloads, e.g.
@@ -140,6 +153,74 @@ type SyncProducer interface {
 	Backend() string
 }
 
+// TransactionalProducer produces records atomically together with consumer
+// offsets (Kafka transactions) — the producer half of exactly-once
+// consume-transform-produce. Records sent between BeginTxn and CommitTxn, and
+// the offsets handed to SendOffsetsToTxn, become visible to read_committed
+// consumers all at once, or not at all after AbortTxn.
+//
+// Requires WithTransactionalID; acks=all + idempotence are forced. One
+// transaction is open at a time, so calls must not be made concurrently.
+type TransactionalProducer interface {
+	// BeginTxn opens a transaction. It errors if one is already open or the
+	// producer is in a fatal state (e.g. fenced by a newer instance with the
+	// same transactional id — Close it and build a new one).
+	BeginTxn() error
+	// Send writes msg inside the open transaction and blocks until the broker
+	// acks it (the record stays invisible to read_committed consumers until
+	// CommitTxn). A ctx already done fails the call before anything is sent;
+	// on franz-go ctx also bounds the wait for the ack, while sarama's sync
+	// producer cannot be interrupted once the record is handed over, so there
+	// the wait is bounded by ProducerTimeout instead.
+	Send(ctx context.Context, msg Message) error
+	// SendOffsetsToTxn commits the group's consumer offsets as part of the
+	// open transaction. offsets maps topic → partition → NEXT offset to
+	// consume (last processed offset + 1, the Kafka commit convention).
+	SendOffsetsToTxn(ctx context.Context, groupID string, offsets map[string]map[int32]int64) error
+	// CommitTxn commits the open transaction. On error call AbortTxn.
+	CommitTxn(ctx context.Context) error
+	// AbortTxn aborts the open transaction, discarding its records and offsets.
+	AbortTxn(ctx context.Context) error
+	// Close releases resources, aborting an open transaction. Idempotent.
+	Close() error
+	// Metrics returns the producer counters plus transaction outcomes.
+	Metrics() TxnMetrics
+	// SetOnEvent installs a per-event hook (the Producer events plus
+	// "begin","commit","abort"). Pass nil to disable.
+	SetOnEvent(fn func(ProducerEvent))
+	Name() string
+	Backend() string
+}
+
+// TxnBatchHandler transforms one consumed batch inside an open transaction.
+// The returned messages are produced in that transaction, and the batch's
+// offsets are committed with it. A non-nil error (or a panic) aborts the
+// transaction and the batch is re-delivered from the last committed offset.
+type TxnBatchHandler func(ctx context.Context, batch []Message) ([]Message, error)
+
+// TxnConsumerGroup is the exactly-once consume-transform-produce helper: a
+// read_committed consumer group whose batches each run in one transaction
+// (outputs + consumer offsets commit atomically). Built by
+// NewTxnConsumerGroup; WithGroupID and WithTransactionalID are required.
+type TxnConsumerGroup interface {
+	// Consume subscribes to topics and runs handler once per batch (at most
+	// Options.TxnMaxBatch records, from one partition on sarama or across
+	// partitions on franz-go) until ctx is cancelled or a fatal error occurs.
+	Consume(ctx context.Context, topics []string, handler TxnBatchHandler) error
+	Errors() <-chan error
+	Close() error
+	// Metrics counts input records: Acked = committed, Failed = aborted.
+	Metrics() ConsumerMetrics
+	// TxnMetrics counts the produced outputs and transaction outcomes.
+	TxnMetrics() TxnMetrics
+	Snapshot() ConsumerSnapshot
+	// SetOnEvent installs a per-event hook (the ConsumerGroup events plus
+	// "commit","abort" carrying the batch's last message).
+	SetOnEvent(fn func(ConsumerEvent))
+	Name() string
+	Backend() string
+}
+
 // ConsumerGroup is the rebalance-aware group consumer (the engine-master
 // consumerGroupProxy pattern). Consume runs the infinite Consume-loop —
 // recreating the session after a server-side rebalance — and returns only when
@@ -149,6 +230,19 @@ type ConsumerGroup interface {
 	// loop that survives rebalances. Returns ctx.Err() when ctx is cancelled,
 	// or a non-nil error on a fatal consume failure.
 	Consume(ctx context.Context, topics []string, handler MessageHandler) error
+	// ConsumeBatch is Consume in batches (see BatchMessageHandler and the
+	// ConsumeBatchMax* options): a batch holds messages of one partition on
+	// sarama and may span partitions on franz-go.
+	ConsumeBatch(ctx context.Context, topics []string, handler BatchMessageHandler) error
+	// Pause stops fetching and delivering topic's partitions (all of topic
+	// when none are given) without leaving the group, until Resume. Buffered
+	// messages are held back, except the rest of a franz-go poll already in
+	// hand. Pauses outlive rebalances. A paused topic and its paused
+	// partitions are independent (as in franz-go): Resume(topic) does not
+	// lift Pause(topic, 1).
+	Pause(topic string, partitions ...int32)
+	// Resume lifts a Pause with the same arguments.
+	Resume(topic string, partitions ...int32)
 	// Errors returns a channel of background errors (rebalance, broker, etc.).
 	Errors() <-chan error
 	Close() error
@@ -169,6 +263,9 @@ type PartitionConsumer interface {
 	// callback delivery mode it blocks until ctx is cancelled; Messages() is
 	// nil in this mode.
 	Consume(ctx context.Context, handler MessageHandler) error
+	// ConsumeBatch is Consume in batches (see BatchMessageHandler and the
+	// ConsumeBatchMax* options).
+	ConsumeBatch(ctx context.Context, handler BatchMessageHandler) error
 	// Messages returns the message channel in channel delivery mode, or nil in
 	// callback mode.
 	Messages() <-chan Message
@@ -177,6 +274,9 @@ type PartitionConsumer interface {
 	// Snapshot returns a point-in-time monitoring view with a UTC Timestamp
 	// (parity with ProducerSnapshot).
 	Snapshot() ConsumerSnapshot
+	// SetOnEvent installs the ConsumerEvent hook (same events as a
+	// ConsumerGroup, minus the group-only ones).
+	SetOnEvent(fn func(ConsumerEvent))
 	Name() string
 	Backend() string
 }
@@ -260,6 +360,15 @@ type SnapshotHistory interface {
 // mutex that the Send path never touches. Defined as a method on the Producer
 // interface above.
 
+// TxnMetrics is a snapshot of transactional producer counters: the usual
+// ProducerMetrics for the records sent inside transactions, plus outcomes.
+type TxnMetrics struct {
+	ProducerMetrics
+	Begun     uint64 // transactions opened
+	Committed uint64 // transactions committed
+	Aborted   uint64 // transactions aborted (explicitly, or by a failed commit)
+}
+
 // ConsumerMetrics is a snapshot of consumer counters.
 type ConsumerMetrics struct {
 	Received  uint64 // messages handed to a handler / Messages() channel
@@ -268,6 +377,16 @@ type ConsumerMetrics struct {
 	Recovered uint64 // consumer-handler panics recovered (goroutine survives)
 	Rebalance uint64 // consumer-group sessions recreated after a rebalance
 	Bytes     uint64 // bytes received (sum of Value lengths)
+
+	// Failed messages republished by WithRetryTopics / WithDeadLetter (also
+	// counted in Failed; their source offset is committed).
+	Retried      uint64 // routed to a retry tier
+	DeadLettered uint64 // routed to the dead-letter topic
+
+	// ConsumeBatch accounting (messages still count in Received/Acked/Failed).
+	BatchCount  uint64 // BatchMessageHandler calls
+	BatchFailed uint64 // batches NACKed (handler error or panic)
+	BatchMax    uint64 // largest batch handed over
 }
 
 // ConsumerSnapshot is the consumer counterpart of ProducerSnapshot — a
@@ -278,12 +397,26 @@ type ConsumerSnapshot struct {
 	Backend   string // "sarama" or "franz-go"
 	Timestamp time.Time
 	ConsumerMetrics
+
+	// Lag is topic → partition → messages behind: the partition's high
+	// watermark minus the committed position (the offset after the last ACKed
+	// message; a PartitionConsumer has no commits, so its consumed position).
+	// Only partitions currently assigned and seen since are listed; nil when
+	// there are none (and always for a TxnConsumerGroup).
+	Lag      map[string]map[int32]int64
+	TotalLag int64 // sum of Lag
+
+	// Paused is topic → partitions paused with ConsumerGroup.Pause, and
+	// PausedTopics the topics paused as a whole; nil when none.
+	Paused       map[string][]int32
+	PausedTopics []string
 }
 
 // ProducerEvent feeds Producer.SetOnEvent. Name is one of "send","success",
-// "error","close". On "success", Partition/Offset carry the broker-assigned
-// location of the ack'd message (so the async path surfaces the same info a
-// sarama Successes() channel would); they are zero for the other event names.
+// "error","close" (plus "begin","commit","abort" for a TransactionalProducer).
+// On "success", Partition/Offset carry the broker-assigned location of the
+// ack'd message (so the async path surfaces the same info a sarama Successes()
+// channel would); they are zero for the other event names.
 type ProducerEvent struct {
 	Name      string
 	Topic     string
@@ -294,9 +427,15 @@ type ProducerEvent struct {
 }
 
 // ConsumerEvent feeds ConsumerGroup.SetOnEvent. Name is one of "message",
-// "ack","nack","error","rebalance","close".
+// "ack","nack","error","rebalance","close" (plus "retry","dead_letter" when a
+// failed message is routed by WithRetryTopics / WithDeadLetter,
+// "lag","lag_recovered" when a partition crosses WithLagThreshold,
+// "pause","resume" per partition on ConsumerGroup.Pause / Resume (Msg
+// carries Topic/Partition; Partition -1 for a whole topic), and
+// "commit","abort" for a TxnConsumerGroup).
 type ConsumerEvent struct {
 	Name string
 	Msg  Message
 	Err  error
+	Lag  int64 // partition lag on "lag" / "lag_recovered" (Msg carries Topic/Partition)
 }
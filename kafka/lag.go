@@ -0,0 +1,269 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lagTracker follows, per assigned partition, the broker high watermark and
+// the consumer's committed position — the committed offset the partition was
+// assigned at, then the offset after the last ACKed message — for
+// ConsumerSnapshot.Lag and the "lag" / "lag_recovered" events.
+//
+// The hot path never touches the map: a consumer looks its partitionLag up
+// once per claim (sarama) or per fetched partition (franz-go) and updates its
+// atomics per message. The map itself changes only on (re)assignment and is
+// read on Snapshot and by run, which checks the threshold off the hot path.
+// A nil tracker (and the nil partitionLag it hands out) is a no-op, for
+// consumers built without a constructor in tests.
+type lagTracker struct {
+	threshold int64 // >0: fire "lag" / "lag_recovered" on crossing it
+	fire      func(ConsumerEvent)
+
+	mu    sync.Mutex
+	parts map[string]map[int32]*partitionLag
+}
+
+// partitionLag is one partition's lag state. pos is -1 until start seeds it
+// with the committed offset, or else until the first message of the
+// partition is seen.
+type partitionLag struct {
+	t         *lagTracker
+	topic     string
+	partition int32
+	hwm, pos  atomic.Int64
+	live      atomic.Pointer[func() int64] // the client's own watermark (sarama), read on demand
+	over      atomic.Bool                  // lag >= threshold (edge state for the events)
+}
+
+// endOffsetsFunc asks the broker for the high watermarks of parts (topic →
+// partitions), for a backend whose client does not track them between
+// fetches.
+type endOffsetsFunc func(ctx context.Context, parts map[string][]int32) (map[string]map[int32]int64, error)
+
+func newLagTracker(threshold int64, fire func(ConsumerEvent)) *lagTracker {
+	return &lagTracker{threshold: threshold, fire: fire, parts: make(map[string]map[int32]*partitionLag)}
+}
+
+// partition returns (creating on first use) the state of topic/partition.
+func (t *lagTracker) partition(topic string, partition int32) *partitionLag {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ps := t.parts[topic]
+	if ps == nil {
+		ps = make(map[int32]*partitionLag)
+		t.parts[topic] = ps
+	}
+	l := ps[partition]
+	if l == nil {
+		l = &partitionLag{t: t, topic: topic, partition: partition}
+		l.pos.Store(-1)
+		ps[partition] = l
+	}
+	return l
+}
+
+// drop forgets a partition that was revoked from this consumer, so its lag
+// is reported by the new owner only.
+func (t *lagTracker) drop(topic string, partition int32) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ps := t.parts[topic]; ps != nil {
+		delete(ps, partition)
+		if len(ps) == 0 {
+			delete(t.parts, topic)
+		}
+	}
+}
+
+// run refreshes the high watermarks through endOffsets (when non-nil) and
+// checks the threshold every interval until the returned stop is called, so
+// a stalled handler — which stops both the per-message updates and, with
+// them, any fetch-driven refresh — still shows its lag growing. A consumer
+// runs it for the duration of each Consume call.
+func (t *lagTracker) run(ctx context.Context, interval time.Duration, endOffsets endOffsetsFunc) (stop func()) {
+	if t == nil || interval <= 0 || (endOffsets == nil && (t.threshold <= 0 || t.fire == nil)) {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tk := time.NewTicker(interval)
+		defer tk.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tk.C:
+				t.tick(ctx, endOffsets)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// tick is one round of run. A failed refresh keeps the last known
+// watermarks.
+func (t *lagTracker) tick(ctx context.Context, endOffsets endOffsetsFunc) {
+	t.mu.Lock()
+	ls := make([]*partitionLag, 0, len(t.parts))
+	for _, ps := range t.parts {
+		for _, l := range ps {
+			ls = append(ls, l)
+		}
+	}
+	t.mu.Unlock()
+	if len(ls) == 0 {
+		return
+	}
+	if endOffsets != nil {
+		parts := make(map[string][]int32)
+		for _, l := range ls {
+			parts[l.topic] = append(parts[l.topic], l.partition)
+		}
+		if hwms, err := endOffsets(ctx, parts); err == nil {
+			for _, l := range ls {
+				if hwm, ok := hwms[l.topic][l.partition]; ok {
+					l.setHighWatermark(hwm)
+				}
+			}
+		}
+	}
+	for _, l := range ls {
+		l.check()
+	}
+}
+
+// snapshot returns topic → partition → lag for the partitions with a known
+// position, and their sum. The map is nil when there are none.
+func (t *lagTracker) snapshot() (map[string]map[int32]int64, int64) {
+	if t == nil {
+		return nil, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var (
+		out   map[string]map[int32]int64
+		total int64
+	)
+	for topic, ps := range t.parts {
+		for p, l := range ps {
+			lag, ok := l.lag()
+			if !ok {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]map[int32]int64)
+			}
+			if out[topic] == nil {
+				out[topic] = make(map[int32]int64, len(ps))
+			}
+			out[topic][p] = lag
+			total += lag
+		}
+	}
+	return out, total
+}
+
+// setHighWatermark records a high watermark of the partition, as reported
+// with a fetch or by run's refresh. Reports may arrive out of order, so the
+// highest one wins.
+func (l *partitionLag) setHighWatermark(hwm int64) {
+	if l == nil {
+		return
+	}
+	for {
+		cur := l.hwm.Load()
+		if cur >= hwm || l.hwm.CompareAndSwap(cur, hwm) {
+			return
+		}
+	}
+}
+
+// watch makes lag read the high watermark from hwm, the client's own view
+// of it (sarama's claim / partition consumer), instead of relying on
+// setHighWatermark calls.
+func (l *partitionLag) watch(hwm func() int64) {
+	if l != nil {
+		l.live.Store(&hwm)
+	}
+}
+
+// start seeds the position with the offset consumption of the partition
+// starts from: the group's committed offset on assignment, or a partition
+// consumer's absolute start offset. A partition that delivers nothing after
+// assignment — idle, paused, or queued behind a stalled handler — still
+// shows the backlog behind it. A negative offset (no commit yet, so the
+// OffsetNewest / OffsetOldest reset applies) leaves the position to the
+// first message seen.
+func (l *partitionLag) start(offset int64) {
+	if l != nil && offset >= 0 {
+		l.pos.CompareAndSwap(-1, offset)
+	}
+}
+
+// seen records that the message at offset arrived. Until it is ACKed it
+// counts as lag.
+func (l *partitionLag) seen(offset int64) {
+	if l != nil && l.pos.Load() < 0 {
+		l.pos.CompareAndSwap(-1, offset)
+	}
+}
+
+// done advances the committed position past offset (ACKed or routed).
+func (l *partitionLag) done(offset int64) {
+	if l == nil {
+		return
+	}
+	for {
+		cur := l.pos.Load()
+		if cur > offset || l.pos.CompareAndSwap(cur, offset+1) {
+			return
+		}
+	}
+}
+
+func (l *partitionLag) lag() (int64, bool) {
+	pos := l.pos.Load()
+	if pos < 0 {
+		return 0, false
+	}
+	hwm := l.hwm.Load()
+	if f := l.live.Load(); f != nil {
+		hwm = max(hwm, (*f)())
+	}
+	return max(hwm-pos, 0), true
+}
+
+// check fires "lag" when the partition's lag reaches the threshold and
+// "lag_recovered" when it drops back below it (edge-triggered: one event per
+// crossing, not per tick).
+func (l *partitionLag) check() {
+	t := l.t
+	if t.threshold <= 0 || t.fire == nil {
+		return
+	}
+	lag, _ := l.lag()
+	name := ""
+	switch {
+	case lag >= t.threshold && l.over.CompareAndSwap(false, true):
+		name = "lag"
+	case lag < t.threshold && l.over.CompareAndSwap(true, false):
+		name = "lag_recovered"
+	default:
+		return
+	}
+	t.fire(ConsumerEvent{Name: name, Msg: Message{Topic: l.topic, Partition: l.partition}, Lag: lag})
+}
@@ -0,0 +1,278 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_LagTracker(t *testing.T) {
+	var events []ConsumerEvent
+	tr := newLagTracker(5, func(e ConsumerEvent) { events = append(events, e) })
+	ctx := context.Background()
+	l := tr.partition("t", 1)
+	if lag, total := tr.snapshot(); lag != nil || total != 0 {
+		t.Fatalf("unseen partition reported: %v %d", lag, total)
+	}
+	l.setHighWatermark(10)
+	l.seen(2) // joined at offset 2: 8 behind
+	if len(events) != 0 {
+		t.Fatalf("threshold checked on the hot path: %+v", events)
+	}
+	tr.tick(ctx, nil)
+	l.done(2)
+	l.done(1)             // a stale ack never moves the position back
+	l.setHighWatermark(9) // a stale watermark never moves it back either
+	tr.partition("u", 0).setHighWatermark(4)
+	tr.partition("u", 0).seen(3)
+	lag, total := tr.snapshot()
+	if lag["t"][1] != 7 || lag["u"][0] != 1 || total != 8 {
+		t.Errorf("snapshot=%v total=%d", lag, total)
+	}
+	for o := int64(3); o < 10; o++ {
+		l.done(o)
+	}
+	tr.tick(ctx, nil)
+	tr.tick(ctx, nil) // edge-triggered: no repeat
+	if len(events) != 2 || events[0].Name != "lag" || events[0].Lag != 8 || events[0].Msg.Topic != "t" ||
+		events[0].Msg.Partition != 1 || events[1].Name != "lag_recovered" || events[1].Lag != 0 {
+		t.Errorf("events=%+v", events)
+	}
+
+	// the refresh moves the watermark while no message arrives.
+	tr.tick(ctx, func(_ context.Context, parts map[string][]int32) (map[string]map[int32]int64, error) {
+		if len(parts["t"]) != 1 || len(parts["u"]) != 1 {
+			t.Errorf("refresh asked for %v", parts)
+		}
+		return map[string]map[int32]int64{"t": {1: 30}}, nil
+	})
+	if lag, _ := tr.snapshot(); lag["t"][1] != 20 || lag["u"][0] != 1 {
+		t.Errorf("after refresh: %v", lag)
+	}
+	if len(events) != 3 || events[2].Name != "lag" || events[2].Lag != 20 {
+		t.Errorf("events=%+v", events)
+	}
+	var live atomic.Int64
+	tr.partition("u", 0).watch(live.Load)
+	live.Store(13)
+	if lag, _ := tr.snapshot(); lag["u"][0] != 10 {
+		t.Errorf("live watermark not read: %v", lag)
+	}
+
+	tr.drop("t", 1)
+	if lag, total := tr.snapshot(); len(lag) != 1 || total != 10 {
+		t.Errorf("after drop: %v %d", lag, total)
+	}
+
+	// a partition seeded with its committed offset reports the backlog
+	// before any message arrives; a pending reset (negative) does not seed.
+	v := tr.partition("v", 0)
+	v.setHighWatermark(50)
+	v.start(-1)
+	if lag, _ := tr.snapshot(); lag["v"] != nil {
+		t.Errorf("unseeded partition reported: %v", lag)
+	}
+	v.start(20)
+	v.start(30) // the first seed wins
+	v.seen(25)  // and a message does not move it back
+	if lag, _ := tr.snapshot(); lag["v"][0] != 30 {
+		t.Errorf("seeded lag=%v, want 30", lag["v"])
+	}
+	v.done(24)
+	if lag, _ := tr.snapshot(); lag["v"][0] != 25 {
+		t.Errorf("after ACK lag=%v, want 25", lag["v"])
+	}
+	tr.drop("v", 0)
+
+	var nilTr *lagTracker // consumers built without a constructor
+	nilTr.partition("t", 0).start(1)
+	nilTr.partition("t", 0).seen(1)
+	nilTr.partition("t", 0).done(1)
+	nilTr.partition("t", 0).watch(live.Load)
+	nilTr.drop("t", 0)
+	nilTr.run(ctx, time.Millisecond, nil)()
+	if lag, total := nilTr.snapshot(); lag != nil || total != 0 {
+		t.Error("nil tracker reported lag")
+	}
+}
+
+// lagProbe blocks the handler on the message at offset holdAt until release
+// is closed, so the consumer sits at a known lag.
+type lagProbe struct {
+	mu      sync.Mutex
+	events  []ConsumerEvent
+	holdAt  int64
+	release chan struct{}
+}
+
+func (p *lagProbe) onEvent(e ConsumerEvent) {
+	if e.Name == "lag" || e.Name == "lag_recovered" {
+		p.mu.Lock()
+		p.events = append(p.events, e)
+		p.mu.Unlock()
+	}
+}
+
+func (p *lagProbe) handle(m Message) error {
+	if m.Offset == p.holdAt {
+		<-p.release
+	}
+	return nil
+}
+
+func (p *lagProbe) names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ns []string
+	for _, e := range p.events {
+		ns = append(ns, e.Name)
+	}
+	return ns
+}
+
+func Test_ConsumerLag_Snapshot(t *testing.T) {
+	addrs := txnCluster(t, "lagged")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic("lagged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := make([]Message, 10)
+	for i := range batch {
+		batch[i] = Message{Value: []byte{byte(i)}}
+	}
+	mustNoErr(t, sp.SendBatch(ctx, batch))
+	mustNoErr(t, sp.Close())
+
+	type consumer interface {
+		Snapshot() ConsumerSnapshot
+		SetOnEvent(func(ConsumerEvent))
+		Close() error
+	}
+	run := func(t *testing.T, c consumer, stop context.CancelFunc, consume func(MessageHandler) error) {
+		defer c.Close()
+		p := &lagProbe{holdAt: 3, release: make(chan struct{})}
+		c.SetOnEvent(p.onEvent)
+		done := make(chan error, 1)
+		go func() { done <- consume(p.handle) }()
+
+		// offsets 0-2 ACKed, 3 held: 7 behind the high watermark of 10.
+		waitUntil(t, func() bool { return c.Snapshot().TotalLag == 7 }, "lag 7")
+		if s := c.Snapshot(); s.Lag["lagged"][0] != 7 || len(s.Lag) != 1 {
+			t.Errorf("Lag=%v", s.Lag)
+		}
+		waitUntil(t, func() bool { return len(p.names()) == 1 }, "lag event")
+		close(p.release)
+		waitUntil(t, func() bool { return c.Snapshot().TotalLag == 0 }, "caught up")
+		waitUntil(t, func() bool { return len(p.names()) == 2 }, "lag_recovered event")
+		if names := p.names(); names[0] != "lag" || names[1] != "lag_recovered" {
+			t.Errorf("lag events=%v", names)
+		}
+		stop()
+		<-done
+		mustNoErr(t, c.Close())
+	}
+
+	t.Run("group", func(t *testing.T) {
+		cctx, ccancel := context.WithCancel(ctx)
+		defer ccancel()
+		g, err := NewConsumerGroup(WithBrokers(addrs...), WithGroupID("lag-g"),
+			WithConsumerOffsetInitial(OffsetOldest), WithLagThreshold(5), WithLagInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		run(t, g, ccancel, func(h MessageHandler) error {
+			return g.Consume(cctx, []string{"lagged"}, h)
+		})
+	})
+	t.Run("partition", func(t *testing.T) {
+		cctx, ccancel := context.WithCancel(ctx)
+		defer ccancel()
+		pc, err := NewPartitionConsumer(WithBrokers(addrs...), WithTopic("lagged"), WithPartition(0),
+			WithOffset(OffsetOldest), WithLagThreshold(5), WithLagInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		run(t, pc, ccancel, func(h MessageHandler) error {
+			return pc.Consume(cctx, h)
+		})
+	})
+}
+
+// Test_ConsumerLag_HandlerStalled keeps producing while the handler is stuck
+// on the first message: no message reaches the consumer's loop, yet the lag
+// must keep up with the partition and cross the threshold.
+func Test_ConsumerLag_HandlerStalled(t *testing.T) {
+	addrs := txnCluster(t, "stalled-g", "stalled-p")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	type consumer interface {
+		Snapshot() ConsumerSnapshot
+		SetOnEvent(func(ConsumerEvent))
+		Close() error
+	}
+	run := func(t *testing.T, topic string, c consumer, stop context.CancelFunc, consume func(MessageHandler) error) {
+		defer c.Close()
+		sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic(topic))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sp.Close()
+		p := &lagProbe{holdAt: 0, release: make(chan struct{})}
+		release := sync.OnceFunc(func() { close(p.release) })
+		defer release() // before Close, which waits for the handler
+		c.SetOnEvent(p.onEvent)
+		blocked := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- consume(func(m Message) error {
+				if m.Offset == 0 {
+					close(blocked)
+				}
+				return p.handle(m)
+			})
+		}()
+		_, _, err = sp.Send(ctx, Message{Value: []byte("first")})
+		mustNoErr(t, err)
+		<-blocked
+		for i := 0; i < 40; i++ {
+			_, _, err := sp.Send(ctx, Message{Value: []byte{byte(i)}})
+			mustNoErr(t, err)
+		}
+		waitUntil(t, func() bool { return c.Snapshot().TotalLag == 41 }, "lag 41 while stalled")
+		waitUntil(t, func() bool { return len(p.names()) == 1 }, "lag event while stalled")
+		release()
+		waitUntil(t, func() bool { return c.Snapshot().TotalLag == 0 }, "caught up")
+		stop()
+		<-done
+	}
+
+	t.Run("group", func(t *testing.T) {
+		cctx, ccancel := context.WithCancel(ctx)
+		defer ccancel()
+		g, err := NewConsumerGroup(WithBrokers(addrs...), WithGroupID("stalled-g"),
+			WithConsumerOffsetInitial(OffsetOldest), WithLagThreshold(20), WithLagInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		run(t, "stalled-g", g, ccancel, func(h MessageHandler) error {
+			return g.Consume(cctx, []string{"stalled-g"}, h)
+		})
+	})
+	t.Run("partition", func(t *testing.T) {
+		cctx, ccancel := context.WithCancel(ctx)
+		defer ccancel()
+		pc, err := NewPartitionConsumer(WithBrokers(addrs...), WithTopic("stalled-p"), WithPartition(0),
+			WithOffset(OffsetOldest), WithLagThreshold(20), WithLagInterval(10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		run(t, "stalled-p", pc, ccancel, func(h MessageHandler) error {
+			return pc.Consume(cctx, h)
+		})
+	})
+}
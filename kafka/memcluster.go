@@ -0,0 +1,472 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// inMemoryBackendName is Backend() of the clients an InMemoryCluster builds.
+const inMemoryBackendName = "in-memory"
+
+// Fault operations matched by Fault.Op.
+const (
+	FaultProduce = "produce" // a record append (Producer / SyncProducer)
+	FaultFetch   = "fetch"   // a consumer fetch (ConsumerGroup / PartitionConsumer)
+	FaultCommit  = "commit"  // a consumer-group offset commit
+)
+
+// ErrUnknownPartition is returned for a partition the topic does not have
+// (e.g. a PartitionerManual message outside the topic).
+var ErrUnknownPartition = errors.New("kafka: unknown topic partition")
+
+// Fault is a failure injected into an InMemoryCluster (InjectFault): every
+// matching operation first sleeps Latency, then fails with Err (nil: latency
+// only).
+type Fault struct {
+	Op      string        // FaultProduce / FaultFetch / FaultCommit; "" matches every op
+	Topic   string        // "" matches every topic
+	Err     error         // returned by the operation; nil injects latency only
+	Latency time.Duration // added before the operation
+	Times   int           // operations affected; 0 = until ClearFaults
+}
+
+// InMemoryCluster is a broker-less backend for tests: partitioned in-process
+// logs with real offsets, consumer groups with committed offsets and
+// rebalances, and fault injection. Its NewProducer / NewSyncProducer /
+// NewConsumerGroup / NewPartitionConsumer take the usual Options (Brokers is
+// not required) and return the package interfaces, so code written against
+// them runs unchanged on either build tag.
+//
+// Topics are created on first produce with the cluster's default partition
+// count, or explicitly with CreateTopic. Group members are assigned the
+// partitions of their subscribed topics round-robin; a member joining or
+// leaving, a new subscribed topic and Rebalance all start a new generation,
+// and a partition is handed to its new owner only after the previous owner's
+// claim has ended (eager rebalancing).
+type InMemoryCluster struct {
+	defaultPartitions int32
+
+	mu         sync.Mutex
+	topics     map[string][]*memPartition
+	groups     map[string]*memGroup
+	faults     []*Fault
+	nextMember int
+	wake       chan struct{} // closed and replaced on every state change
+}
+
+type memPartition struct{ msgs []Message }
+
+type memTP struct {
+	topic     string
+	partition int32
+}
+
+// memGroup is one consumer group's coordinator state.
+type memGroup struct {
+	gen       int
+	members   []*memMember // join order
+	assign    map[int][]memTP
+	owners    map[memTP]int // partition → member id of the running claim
+	committed map[memTP]int64
+}
+
+type memMember struct {
+	id     int
+	topics []string
+}
+
+// ClusterOption configures an InMemoryCluster.
+type ClusterOption func(*InMemoryCluster)
+
+// WithDefaultPartitions sets the partition count of auto-created topics
+// (default 1).
+func WithDefaultPartitions(n int32) ClusterOption {
+	return func(c *InMemoryCluster) { c.defaultPartitions = n }
+}
+
+// NewInMemoryCluster returns an empty in-memory cluster.
+func NewInMemoryCluster(opts ...ClusterOption) *InMemoryCluster {
+	c := &InMemoryCluster{
+		defaultPartitions: 1,
+		topics:            make(map[string][]*memPartition),
+		groups:            make(map[string]*memGroup),
+		wake:              make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.defaultPartitions < 1 {
+		c.defaultPartitions = 1
+	}
+	return c
+}
+
+// CreateTopic creates topic with the given number of partitions.
+func (c *InMemoryCluster) CreateTopic(topic string, partitions int32) error {
+	if topic == "" || partitions < 1 {
+		return fmt.Errorf("kafka: invalid topic %q with %d partitions", topic, partitions)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.topics[topic]; ok {
+		return fmt.Errorf("kafka: topic %q already exists", topic)
+	}
+	c.createLocked(topic, partitions)
+	return nil
+}
+
+// createLocked adds topic and rebalances the groups subscribed to it.
+func (c *InMemoryCluster) createLocked(topic string, partitions int32) []*memPartition {
+	ps := make([]*memPartition, partitions)
+	for i := range ps {
+		ps[i] = &memPartition{}
+	}
+	c.topics[topic] = ps
+	for _, g := range c.groups {
+		if slices.ContainsFunc(g.members, func(m *memMember) bool { return slices.Contains(m.topics, topic) }) {
+			c.rebalanceLocked(g)
+		}
+	}
+	c.signalLocked()
+	return ps
+}
+
+// ensureTopic creates topic with the default partition count if missing and
+// returns its partition count.
+func (c *InMemoryCluster) ensureTopic(topic string) int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ps, ok := c.topics[topic]
+	if !ok {
+		ps = c.createLocked(topic, c.defaultPartitions)
+	}
+	return int32(len(ps))
+}
+
+// Records returns a copy of the messages stored in topic/partition.
+func (c *InMemoryCluster) Records(topic string, partition int32) []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ps := c.topics[topic]
+	if partition < 0 || int(partition) >= len(ps) {
+		return nil
+	}
+	return slices.Clone(ps[partition].msgs)
+}
+
+// HighWatermark returns the offset the next message of topic/partition gets.
+func (c *InMemoryCluster) HighWatermark(topic string, partition int32) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ps := c.topics[topic]
+	if partition < 0 || int(partition) >= len(ps) {
+		return 0
+	}
+	return int64(len(ps[partition].msgs))
+}
+
+// CommittedOffset returns group's committed offset (the next offset to
+// consume) for topic/partition, and whether one was committed.
+func (c *InMemoryCluster) CommittedOffset(group, topic string, partition int32) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.groups[group]
+	if g == nil {
+		return 0, false
+	}
+	off, ok := g.committed[memTP{topic, partition}]
+	return off, ok
+}
+
+// Generation returns group's current generation (0 before any member joined;
+// +1 per rebalance).
+func (c *InMemoryCluster) Generation(group string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if g := c.groups[group]; g != nil {
+		return g.gen
+	}
+	return 0
+}
+
+// Rebalance forces a new generation of group, as a broker-side rebalance
+// (e.g. a session timeout) would.
+func (c *InMemoryCluster) Rebalance(group string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if g := c.groups[group]; g != nil {
+		c.rebalanceLocked(g)
+		c.signalLocked()
+	}
+}
+
+// InjectFault adds f. Faults are matched in injection order and the first
+// match applies.
+func (c *InMemoryCluster) InjectFault(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &f)
+}
+
+// ClearFaults removes every injected fault.
+func (c *InMemoryCluster) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// fault applies the first fault matching op/topic: it waits out the latency
+// (or ctx) and returns the fault's error.
+func (c *InMemoryCluster) fault(ctx context.Context, op, topic string) error {
+	c.mu.Lock()
+	var f Fault
+	for i, r := range c.faults {
+		if (r.Op == "" || r.Op == op) && (r.Topic == "" || r.Topic == topic) {
+			f = *r
+			if r.Times > 0 {
+				if r.Times--; r.Times == 0 {
+					c.faults = slices.Delete(c.faults, i, i+1)
+				}
+			}
+			break
+		}
+	}
+	c.mu.Unlock()
+	if f.Latency > 0 {
+		t := time.NewTimer(f.Latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return f.Err
+}
+
+// signalLocked wakes every waiter (fetches, sessions, claims).
+func (c *InMemoryCluster) signalLocked() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// wait blocks until the next state change or ctx is done.
+func (c *InMemoryCluster) wait(ctx context.Context, w <-chan struct{}) error {
+	select {
+	case <-w:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// produce appends msg (Topic already resolved) to the partition pick chooses
+// and returns it with its partition, offset and timestamp.
+func (c *InMemoryCluster) produce(ctx context.Context, msg Message, pick func(Message, int32) int32) (Message, error) {
+	if err := c.fault(ctx, FaultProduce, msg.Topic); err != nil {
+		return msg, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ps, ok := c.topics[msg.Topic]
+	if !ok {
+		ps = c.createLocked(msg.Topic, c.defaultPartitions)
+	}
+	msg.Partition = pick(msg, int32(len(ps)))
+	if msg.Partition < 0 || int(msg.Partition) >= len(ps) {
+		return msg, fmt.Errorf("%w: %s/%d", ErrUnknownPartition, msg.Topic, msg.Partition)
+	}
+	p := ps[msg.Partition]
+	msg.Offset = int64(len(p.msgs))
+	msg.Timestamp = time.Now()
+	msg.Key, msg.Value = slices.Clone(msg.Key), slices.Clone(msg.Value)
+	msg.Headers = slices.Clone(msg.Headers)
+	p.msgs = append(p.msgs, msg)
+	c.signalLocked()
+	return msg, nil
+}
+
+// fetch returns up to max messages of topic/partition from offset on, with
+// the partition's high watermark, blocking until there is at least one.
+func (c *InMemoryCluster) fetch(ctx context.Context, topic string, partition int32, offset int64, max int) ([]Message, int64, error) {
+	if err := c.fault(ctx, FaultFetch, topic); err != nil {
+		return nil, 0, err
+	}
+	for {
+		c.mu.Lock()
+		ps := c.topics[topic]
+		if partition < 0 || int(partition) >= len(ps) {
+			c.mu.Unlock()
+			return nil, 0, fmt.Errorf("%w: %s/%d", ErrUnknownPartition, topic, partition)
+		}
+		log := ps[partition].msgs
+		if hwm := int64(len(log)); offset < hwm {
+			out := slices.Clone(log[offset:min(hwm, offset+int64(max))])
+			c.mu.Unlock()
+			return out, hwm, nil
+		}
+		w := c.wake
+		c.mu.Unlock()
+		if err := c.wait(ctx, w); err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// startOffset resolves a sentinel (OffsetNewest / OffsetOldest) or absolute
+// offset against topic/partition.
+func (c *InMemoryCluster) startOffset(topic string, partition int32, offset int64) int64 {
+	switch offset {
+	case OffsetOldest:
+		return 0
+	case OffsetNewest:
+		return c.HighWatermark(topic, partition)
+	}
+	return offset
+}
+
+// --- consumer groups ---
+
+// join adds a member subscribed to topics to group (creating missing topics,
+// as a broker with auto-creation does) and rebalances it.
+func (c *InMemoryCluster) join(group string, topics []string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range topics {
+		if _, ok := c.topics[t]; !ok {
+			c.createLocked(t, c.defaultPartitions)
+		}
+	}
+	g := c.groups[group]
+	if g == nil {
+		g = &memGroup{owners: make(map[memTP]int), committed: make(map[memTP]int64)}
+		c.groups[group] = g
+	}
+	c.nextMember++
+	g.members = append(g.members, &memMember{id: c.nextMember, topics: topics})
+	c.rebalanceLocked(g)
+	c.signalLocked()
+	return c.nextMember
+}
+
+// leave removes member from group and rebalances the rest.
+func (c *InMemoryCluster) leave(group string, member int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.groups[group]
+	g.members = slices.DeleteFunc(g.members, func(m *memMember) bool { return m.id == member })
+	c.rebalanceLocked(g)
+	c.signalLocked()
+}
+
+// rebalanceLocked starts a new generation: each existing partition of a
+// subscribed topic goes round-robin to the members subscribed to it.
+func (c *InMemoryCluster) rebalanceLocked(g *memGroup) {
+	g.gen++
+	g.assign = make(map[int][]memTP, len(g.members))
+	subs := map[string][]int{}
+	for _, m := range g.members {
+		for _, t := range m.topics {
+			if !slices.Contains(subs[t], m.id) {
+				subs[t] = append(subs[t], m.id)
+			}
+		}
+	}
+	topics := make([]string, 0, len(subs))
+	for t := range subs {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	for _, t := range topics {
+		ids := subs[t]
+		for p := range int32(len(c.topics[t])) {
+			id := ids[int(p)%len(ids)]
+			g.assign[id] = append(g.assign[id], memTP{t, p})
+		}
+	}
+}
+
+// assignment returns member's partitions in the current generation.
+func (c *InMemoryCluster) assignment(group string, member int) (int, []memTP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.groups[group]
+	return g.gen, slices.Clone(g.assign[member])
+}
+
+// awaitGeneration blocks until group leaves generation gen or ctx is done.
+func (c *InMemoryCluster) awaitGeneration(ctx context.Context, group string, gen int) error {
+	for {
+		c.mu.Lock()
+		cur, w := c.groups[group].gen, c.wake
+		c.mu.Unlock()
+		if cur != gen {
+			return nil
+		}
+		if err := c.wait(ctx, w); err != nil {
+			return err
+		}
+	}
+}
+
+// claim waits until no other member runs a claim on tp, takes it, and
+// returns the offset to start from (the committed offset, else initial).
+func (c *InMemoryCluster) claim(ctx context.Context, group string, member int, tp memTP, initial int64) (int64, error) {
+	for {
+		c.mu.Lock()
+		g := c.groups[group]
+		if owner, ok := g.owners[tp]; !ok || owner == member {
+			g.owners[tp] = member
+			off, ok := g.committed[tp]
+			c.mu.Unlock()
+			if !ok {
+				off = c.startOffset(tp.topic, tp.partition, initial)
+			}
+			return off, nil
+		}
+		w := c.wake
+		c.mu.Unlock()
+		if err := c.wait(ctx, w); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// release ends member's claim on tp.
+func (c *InMemoryCluster) release(group string, member int, tp memTP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.groups[group]
+	if g.owners[tp] == member {
+		delete(g.owners, tp)
+		c.signalLocked()
+	}
+}
+
+// commit stores offset (the next to consume) for group on tp; it never
+// moves a committed offset backwards.
+func (c *InMemoryCluster) commit(ctx context.Context, group string, tp memTP, offset int64) error {
+	if err := c.fault(ctx, FaultCommit, tp.topic); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.groups[group]
+	if cur, ok := g.committed[tp]; !ok || offset > cur {
+		g.committed[tp] = offset
+	}
+	return nil
+}
+
+var (
+	_ Producer          = (*memProducer)(nil)
+	_ SnapshotHistory   = (*memProducer)(nil)
+	_ SyncProducer      = (*memSyncProducer)(nil)
+	_ ConsumerGroup     = (*memConsumerGroup)(nil)
+	_ PartitionConsumer = (*memPartitionConsumer)(nil)
+)
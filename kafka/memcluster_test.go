@@ -0,0 +1,403 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memConsume runs g.Consume in the background and returns a stop func that
+// cancels it and waits for it to return.
+func memConsume(t *testing.T, g ConsumerGroup, topics []string, h MessageHandler) (stop func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- g.Consume(ctx, topics, h) }()
+	return func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(3 * time.Second):
+			t.Fatal("Consume did not return")
+			return nil
+		}
+	}
+}
+
+func Test_InMemory_SyncProducerOffsetsAndPartitioner(t *testing.T) {
+	c := NewInMemoryCluster()
+	if err := c.CreateTopic("orders", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateTopic("orders", 4); err == nil {
+		t.Error("duplicate CreateTopic accepted")
+	}
+	p, err := c.NewSyncProducer(WithTopic("orders"), WithPartitioner(PartitionerMurmur2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if p.Backend() != "in-memory" {
+		t.Errorf("Backend=%q", p.Backend())
+	}
+	next := map[int32]int64{}
+	for i := range 20 {
+		key := []byte(fmt.Sprintf("k%d", i))
+		part, off, err := p.Send(context.Background(), Message{Key: key, Value: []byte("v")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (murmur2(key) & 0x7fffffff) % 4; part != want {
+			t.Errorf("%s: partition %d want %d", key, part, want)
+		}
+		if off != next[part] {
+			t.Errorf("%s: offset %d want %d", key, off, next[part])
+		}
+		next[part]++
+	}
+	var total int64
+	for part := range int32(4) {
+		hwm := c.HighWatermark("orders", part)
+		if hwm != next[part] || int64(len(c.Records("orders", part))) != hwm {
+			t.Errorf("partition %d: hwm=%d records=%d want %d", part, hwm, len(c.Records("orders", part)), next[part])
+		}
+		total += hwm
+	}
+	if total != 20 || p.Metrics().Success != 20 {
+		t.Errorf("total=%d metrics=%+v", total, p.Metrics())
+	}
+
+	// Auto-created topic with the default partition count; manual partition
+	// outside it fails.
+	if _, _, err := p.Send(context.Background(), Message{Topic: "auto", Value: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	mp, _ := c.NewSyncProducer(WithPartitioner(PartitionerManual))
+	if _, _, err := mp.Send(context.Background(), Message{Topic: "auto", Partition: 3}); !errors.Is(err, ErrUnknownPartition) {
+		t.Errorf("manual out of range: %v", err)
+	}
+}
+
+func Test_InMemory_AsyncProducer(t *testing.T) {
+	c := NewInMemoryCluster(WithDefaultPartitions(2))
+	p, err := c.NewProducer(WithTopic("events"), WithPartitioner(PartitionerRoundRobin), WithSnapshotHistory(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var successes atomic.Int32
+	p.SetOnEvent(func(e ProducerEvent) {
+		if e.Name == "success" {
+			successes.Add(1)
+		}
+	})
+	for i := range 5 {
+		if err := p.Send(context.Background(), Message{Value: []byte{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.SendBatch(context.Background(), []Message{{Value: []byte("a")}, {Value: []byte("b")}, {Value: []byte("c")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m := p.Metrics()
+	if m.Enqueued != 8 || m.Success != 8 || m.InFlight != 0 || m.BatchMax != 3 || successes.Load() != 8 {
+		t.Errorf("metrics=%+v successes=%d", m, successes.Load())
+	}
+	if c.HighWatermark("events", 0) != 4 || c.HighWatermark("events", 1) != 4 {
+		t.Errorf("round robin: %d/%d", c.HighWatermark("events", 0), c.HighWatermark("events", 1))
+	}
+	if err := p.Send(context.Background(), Message{}); !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("Send after Close: %v", err)
+	}
+	p.Snapshot()
+	if h, ok := p.(SnapshotHistory); !ok || len(h.History()) != 1 {
+		t.Error("snapshot history not recorded")
+	}
+}
+
+// Test_InMemory_GroupRebalance: two members split a 4-partition topic, the
+// first sees a rebalance when the second joins, every message is handled
+// once, and the survivor takes over the partitions of a member that leaves.
+func Test_InMemory_GroupRebalance(t *testing.T) {
+	c := NewInMemoryCluster()
+	_ = c.CreateTopic("t", 4)
+	p, _ := c.NewSyncProducer(WithTopic("t"), WithPartitioner(PartitionerRoundRobin))
+	defer p.Close()
+	produce := func(n int) {
+		for range n {
+			if _, _, err := p.Send(context.Background(), Message{Value: []byte("v")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var (
+		mu     sync.Mutex
+		seen   = map[string]int{}
+		owners = map[int32]string{}
+	)
+	handler := func(name string) MessageHandler {
+		return func(m Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[fmt.Sprintf("%d/%d", m.Partition, m.Offset)]++
+			owners[m.Partition] = name
+			return nil
+		}
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen)
+	}
+
+	g1, _ := c.NewConsumerGroup(WithGroupID("grp"), WithConsumerOffsetInitial(OffsetOldest))
+	defer g1.Close()
+	stop1 := memConsume(t, g1, []string{"t"}, handler("g1"))
+	produce(8)
+	waitUntil(t, func() bool { return count() == 8 }, "g1 consumes 8")
+
+	g2, _ := c.NewConsumerGroup(WithGroupID("grp"), WithConsumerOffsetInitial(OffsetOldest))
+	defer g2.Close()
+	stop2 := memConsume(t, g2, []string{"t"}, handler("g2"))
+	waitUntil(t, func() bool { return g1.Metrics().Rebalance == 1 }, "g1 rebalanced")
+	produce(8)
+	waitUntil(t, func() bool { return count() == 16 }, "both consume 16")
+	mu.Lock()
+	split := map[string]int{}
+	for _, o := range owners {
+		split[o]++
+	}
+	mu.Unlock()
+	if split["g1"] != 2 || split["g2"] != 2 {
+		t.Errorf("partition owners %v, want 2 each", owners)
+	}
+
+	if err := stop2(); !errors.Is(err, context.Canceled) {
+		t.Errorf("stop2: %v", err)
+	}
+	waitUntil(t, func() bool { return g1.Metrics().Rebalance == 2 }, "g1 rebalanced after leave")
+	produce(8)
+	waitUntil(t, func() bool { return count() == 24 }, "g1 consumes all")
+	_ = stop1()
+
+	mu.Lock()
+	for k, n := range seen {
+		if n != 1 {
+			t.Errorf("%s handled %d times", k, n)
+		}
+	}
+	mu.Unlock()
+	for part := range int32(4) {
+		if off, ok := c.CommittedOffset("grp", "t", part); !ok || off != 6 {
+			t.Errorf("partition %d committed %d,%v want 6", part, off, ok)
+		}
+	}
+	if gen := c.Generation("grp"); gen != 4 { // g1 join, g2 join, g2 leave, g1 leave
+		t.Errorf("generation=%d want 4", gen)
+	}
+}
+
+// Test_InMemory_GroupResumeAndForcedRebalance: a new member resumes from the
+// committed offset, and a forced Rebalance re-delivers nothing.
+func Test_InMemory_GroupResumeAndForcedRebalance(t *testing.T) {
+	c := NewInMemoryCluster()
+	p, _ := c.NewSyncProducer(WithTopic("t"))
+	for i := range 5 {
+		_, _, _ = p.Send(context.Background(), Message{Value: []byte{byte(i)}})
+	}
+	var got atomic.Int32
+	g, _ := c.NewConsumerGroup(WithGroupID("g"), WithConsumerOffsetInitial(OffsetOldest))
+	stop := memConsume(t, g, []string{"t"}, func(Message) error { got.Add(1); return nil })
+	waitUntil(t, func() bool { return got.Load() == 5 }, "first 5")
+	c.Rebalance("g")
+	waitUntil(t, func() bool { return g.Metrics().Rebalance == 1 }, "forced rebalance")
+	_, _, _ = p.Send(context.Background(), Message{Value: []byte("x")})
+	waitUntil(t, func() bool { return got.Load() == 6 }, "sixth")
+	_ = stop()
+	_ = g.Close()
+	if err := g.Consume(context.Background(), []string{"t"}, nil); !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("Consume after Close: %v", err)
+	}
+
+	_, _, _ = p.Send(context.Background(), Message{Value: []byte("y")})
+	var vals []string
+	var mu sync.Mutex
+	g2, _ := c.NewConsumerGroup(WithGroupID("g"))
+	defer g2.Close()
+	stop2 := memConsume(t, g2, []string{"t"}, func(m Message) error {
+		mu.Lock()
+		vals = append(vals, string(m.Value))
+		mu.Unlock()
+		return nil
+	})
+	waitUntil(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(vals) == 1 }, "resumed")
+	_ = stop2()
+	if vals[0] != "y" {
+		t.Errorf("resumed at %q, want y", vals)
+	}
+}
+
+func Test_InMemory_Faults(t *testing.T) {
+	c := NewInMemoryCluster()
+	p, _ := c.NewSyncProducer(WithTopic("t"))
+	c.InjectFault(Fault{Op: FaultProduce, Err: errBoom, Times: 2})
+	for i := range 2 {
+		if _, _, err := p.Send(context.Background(), Message{Value: []byte("v")}); !errors.Is(err, errBoom) {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if _, _, err := p.Send(context.Background(), Message{Value: []byte("v")}); err != nil {
+		t.Fatalf("fault outlived Times: %v", err)
+	}
+	if m := p.Metrics(); m.Failed != 2 || m.Success != 1 {
+		t.Errorf("metrics=%+v", m)
+	}
+
+	c.InjectFault(Fault{Op: FaultProduce, Topic: "t", Latency: 50 * time.Millisecond})
+	start := time.Now()
+	_, _, _ = p.Send(context.Background(), Message{Value: []byte("v")})
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("latency fault took %v", d)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.Send(ctx, Message{Value: []byte("v")}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("latency vs ctx: %v", err)
+	}
+	c.ClearFaults()
+
+	// A failing fetch is reported and retried; a failing commit is reported
+	// and leaves the offset uncommitted.
+	c.InjectFault(Fault{Op: FaultFetch, Err: errBoom, Times: 1})
+	c.InjectFault(Fault{Op: FaultCommit, Err: errBoom, Times: 1})
+	var acked atomic.Int32
+	g, _ := c.NewConsumerGroup(WithGroupID("g"), WithConsumerOffsetInitial(OffsetOldest))
+	defer g.Close()
+	stop := memConsume(t, g, []string{"t"}, func(Message) error { acked.Add(1); return nil })
+	for range 2 {
+		select {
+		case err := <-g.Errors():
+			if !errors.Is(err, errBoom) {
+				t.Errorf("Errors: %v", err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("fault not reported")
+		}
+	}
+	waitUntil(t, func() bool { return acked.Load() == 2 }, "consumed after faults")
+	_ = stop()
+	if off, _ := c.CommittedOffset("g", "t", 0); off != 2 {
+		t.Errorf("committed=%d want 2", off)
+	}
+}
+
+func Test_InMemory_PartitionConsumer(t *testing.T) {
+	c := NewInMemoryCluster()
+	_ = c.CreateTopic("t", 2)
+	if _, err := c.NewPartitionConsumer(WithTopic("t"), WithPartition(2)); !errors.Is(err, ErrUnknownPartition) {
+		t.Errorf("partition 2: %v", err)
+	}
+	p, _ := c.NewSyncProducer(WithTopic("t"), WithPartitioner(PartitionerManual))
+	for i := range 6 {
+		_, _, _ = p.Send(context.Background(), Message{Partition: 1, Value: []byte{byte(i)}})
+	}
+
+	// Callback mode from an absolute offset, with one NACK.
+	pc, _ := c.NewPartitionConsumer(WithTopic("t"), WithPartition(1), WithOffset(2))
+	var offs []int64
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- pc.Consume(ctx, func(m Message) error {
+			offs = append(offs, m.Offset)
+			if len(offs) == 4 {
+				cancel()
+			}
+			if m.Offset == 3 {
+				return errBoom
+			}
+			return nil
+		})
+	}()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Consume: %v", err)
+	}
+	if fmt.Sprint(offs) != "[2 3 4 5]" {
+		t.Errorf("offsets %v", offs)
+	}
+	if m := pc.Snapshot(); m.Acked != 3 || m.Failed != 1 {
+		t.Errorf("metrics=%+v", m)
+	}
+	_ = pc.Close()
+
+	// Channel mode from the oldest offset; Close ends the channel.
+	ch, _ := c.NewPartitionConsumer(WithTopic("t"), WithPartition(1), WithOffset(OffsetOldest), WithDeliveryMode("channel"))
+	for i := range 6 {
+		if m := <-ch.Messages(); m.Offset != int64(i) {
+			t.Fatalf("channel offset %d want %d", m.Offset, i)
+		}
+	}
+	_ = ch.Close()
+	for range ch.Messages() {
+	}
+
+	// Batch mode: 6 records in batches of 4 + 2 (the second closed by MaxWait).
+	bc, _ := c.NewPartitionConsumer(WithTopic("t"), WithPartition(1), WithOffset(OffsetOldest),
+		WithConsumeBatchMaxRecords(4), WithConsumeBatchMaxWait(20*time.Millisecond))
+	defer bc.Close()
+	var sizes []int
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		done <- bc.ConsumeBatch(ctx, func(b []Message) error {
+			if sizes = append(sizes, len(b)); len(sizes) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	<-done
+	if fmt.Sprint(sizes) != "[4 2]" {
+		t.Errorf("batch sizes %v", sizes)
+	}
+}
+
+func Test_InMemory_GroupBatchAndDeadLetter(t *testing.T) {
+	c := NewInMemoryCluster()
+	p, _ := c.NewSyncProducer(WithTopic("in"))
+	for i := range 5 {
+		_, _, _ = p.Send(context.Background(), Message{Value: []byte{byte(i)}})
+	}
+	g, err := c.NewConsumerGroup(WithGroupID("g"), WithConsumerOffsetInitial(OffsetOldest),
+		WithDeadLetter("in-dlq"), WithConsumeBatchMaxRecords(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- g.ConsumeBatch(ctx, []string{"in"}, func([]Message) error { return errBoom })
+	}()
+	waitUntil(t, func() bool { return c.HighWatermark("in-dlq", 0) == 5 }, "batch dead-lettered")
+	cancel()
+	<-done
+	m := g.Metrics()
+	if m.BatchCount != 1 || m.BatchFailed != 1 || m.DeadLettered != 5 {
+		t.Errorf("metrics=%+v", m)
+	}
+	if off, _ := c.CommittedOffset("g", "in", 0); off != 5 {
+		t.Errorf("committed=%d want 5", off)
+	}
+	dlq := c.Records("in-dlq", 0)
+	if headerValue(dlq[0].Headers, HeaderOriginalTopic) != "in" {
+		t.Errorf("dlq headers %v", dlq[0].Headers)
+	}
+}
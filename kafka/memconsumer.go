@@ -0,0 +1,702 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memFetchBackoff is how long a reader waits after a failed fetch.
+const memFetchBackoff = 10 * time.Millisecond
+
+// memReader hands out one partition's messages in order, fetching up to max
+// at a time. It is used by one goroutine at a time.
+type memReader struct {
+	c     *InMemoryCluster
+	tp    memTP
+	pos   int64 // next offset to fetch
+	max   int
+	onErr func(error) // a failed fetch (injected fault)
+
+	buf []Message
+	hwm int64
+}
+
+// next returns the next message and the partition's high watermark as of its
+// fetch, blocking until one arrives; the error is ctx's.
+func (r *memReader) next(ctx context.Context) (Message, int64, error) {
+	for len(r.buf) == 0 {
+		msgs, hwm, err := r.c.fetch(ctx, r.tp.topic, r.tp.partition, r.pos, r.max)
+		if err == nil {
+			r.buf, r.hwm = msgs, hwm
+			r.pos = msgs[len(msgs)-1].Offset + 1
+			break
+		}
+		if ctx.Err() != nil {
+			return Message{}, 0, ctx.Err()
+		}
+		r.onErr(err)
+		t := time.NewTimer(memFetchBackoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return Message{}, 0, ctx.Err()
+		}
+	}
+	msg := r.buf[0]
+	r.buf = r.buf[1:]
+	return msg, r.hwm, nil
+}
+
+// nextBefore is next bounded by a batch deadline: it reports timedOut
+// (with a nil error) when the deadline passes before a message arrives.
+func (r *memReader) nextBefore(ctx context.Context, deadline time.Time) (msg Message, hwm int64, timedOut bool, err error) {
+	if deadline.IsZero() {
+		msg, hwm, err = r.next(ctx)
+		return msg, hwm, false, err
+	}
+	dctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	msg, hwm, err = r.next(dctx)
+	if err != nil && ctx.Err() == nil {
+		return msg, hwm, true, nil
+	}
+	return msg, hwm, false, err
+}
+
+// memEndOffsets reads the high watermarks of parts from c, as the other
+// backends ask the broker, for lagTracker.run.
+func memEndOffsets(c *InMemoryCluster) endOffsetsFunc {
+	return func(_ context.Context, parts map[string][]int32) (map[string]map[int32]int64, error) {
+		out := make(map[string]map[int32]int64, len(parts))
+		for topic, ps := range parts {
+			out[topic] = make(map[int32]int64, len(ps))
+			for _, p := range ps {
+				out[topic][p] = c.HighWatermark(topic, p)
+			}
+		}
+		return out, nil
+	}
+}
+
+// untilClosed returns ctx cancelled also when done closes.
+func untilClosed(ctx context.Context, done <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// NewConsumerGroup builds a ConsumerGroup on the cluster. WithGroupID is
+// required; WithRetryTopics / WithDeadLetter republish within the cluster.
+func (c *InMemoryCluster) NewConsumerGroup(opts ...Option) (ConsumerGroup, error) {
+	o, err := inMemoryOptions(opts, "consumer-group")
+	if err != nil {
+		return nil, err
+	}
+	router, err := newFailureRouter(o, c.NewSyncProducer)
+	if err != nil {
+		return nil, err
+	}
+	g := &memConsumerGroup{c: c, opts: o, router: router, done: make(chan struct{})}
+	g.lag = newLagTracker(o.LagThreshold, g.fire)
+	return g, nil
+}
+
+// memConsumerGroup is the InMemoryCluster ConsumerGroup. Each Consume call
+// joins the group as one member; every generation runs one claim goroutine
+// per assigned partition, and a rebalance ends them all before the next
+// generation starts (as a sarama session).
+type memConsumerGroup struct {
+	c      *InMemoryCluster
+	opts   Options
+	router *failureRouter
+	lag    *lagTracker
+	paused pauseSet
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{} // closed by Close; stops running Consume calls
+
+	errChOnce sync.Once
+	errCh     chan error
+
+	received  atomic.Uint64
+	acked     atomic.Uint64
+	failed    atomic.Uint64
+	recovered atomic.Uint64
+	rebalance atomic.Uint64
+	bytes     atomic.Uint64
+	retried   atomic.Uint64
+	deadLet   atomic.Uint64
+	batches   batchStats
+
+	onEvent atomic.Pointer[func(ConsumerEvent)]
+}
+
+func (g *memConsumerGroup) Consume(ctx context.Context, topics []string, handler MessageHandler) error {
+	return g.consume(ctx, topics, handler, nil)
+}
+
+func (g *memConsumerGroup) ConsumeBatch(ctx context.Context, topics []string, handler BatchMessageHandler) error {
+	return g.consume(ctx, topics, nil, handler)
+}
+
+func (g *memConsumerGroup) consume(parent context.Context, topics []string, handler MessageHandler, batch BatchMessageHandler) error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return ErrProducerClosed
+	}
+	g.mu.Unlock()
+
+	ctx, cancel := untilClosed(parent, g.done)
+	defer cancel()
+	defer g.lag.run(ctx, g.opts.LagInterval, memEndOffsets(g.c))()
+	group := g.opts.GroupID
+	member := g.c.join(group, mergeTopics(topics, g.router.topics()))
+	defer g.c.leave(group, member)
+	for first := true; ; first = false {
+		gen, tps := g.c.assignment(group, member)
+		if !first {
+			g.rebalance.Add(1)
+			g.fire(ConsumerEvent{Name: "rebalance"})
+		}
+		sess, end := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for _, tp := range tps {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				g.claim(sess, member, tp, handler, batch)
+			}()
+		}
+		err := g.c.awaitGeneration(ctx, group, gen)
+		end()
+		wg.Wait()
+		if err != nil {
+			if perr := parent.Err(); perr != nil {
+				return perr
+			}
+			return ErrProducerClosed
+		}
+	}
+}
+
+// claim consumes tp for one generation: from the committed offset (else
+// ConsumerOffsetInitial) until sess ends.
+func (g *memConsumerGroup) claim(sess context.Context, member int, tp memTP, handler MessageHandler, batch BatchMessageHandler) {
+	group := g.opts.GroupID
+	pos, err := g.c.claim(sess, group, member, tp, g.opts.ConsumerOffsetInitial)
+	if err != nil {
+		return
+	}
+	defer g.c.release(group, member, tp)
+	lag := g.lag.partition(tp.topic, tp.partition)
+	defer g.lag.drop(tp.topic, tp.partition)
+	if o, ok := g.c.CommittedOffset(group, tp.topic, tp.partition); ok {
+		lag.start(o)
+	}
+	r := &memReader{c: g.c, tp: tp, pos: pos, max: g.opts.ConsumeBatchMaxRecords, onErr: g.reportErr}
+	if batch != nil {
+		g.consumeBatches(sess, r, lag, batch)
+		return
+	}
+	for {
+		msg, hwm, err := r.next(sess)
+		if err != nil {
+			return
+		}
+		lag.setHighWatermark(hwm)
+		lag.seen(msg.Offset)
+		if err := waitDue(sess, msg); err != nil {
+			return
+		}
+		if err := g.admit(sess, msg); err != nil {
+			return
+		}
+		g.received.Add(1)
+		g.bytes.Add(uint64(len(msg.Value)))
+		g.fire(ConsumerEvent{Name: "message", Msg: msg})
+		if err := g.safeHandlerCall(handler, msg); err != nil {
+			g.failed.Add(1)
+			if g.routeFailure(sess, msg, err) {
+				g.mark(tp, msg, lag)
+				continue
+			}
+			g.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
+			continue
+		}
+		g.mark(tp, msg, lag)
+		g.acked.Add(1)
+		g.fire(ConsumerEvent{Name: "ack", Msg: msg})
+	}
+}
+
+// consumeBatches is claim for a BatchMessageHandler. A batch still filling
+// when the session ends is dropped uncommitted.
+func (g *memConsumerGroup) consumeBatches(sess context.Context, r *memReader, lag *partitionLag, handler BatchMessageHandler) {
+	var (
+		msgs     []Message
+		size     int
+		deadline time.Time
+	)
+	flush := func() {
+		g.handleBatch(sess, r.tp, msgs, lag, handler)
+		msgs, size, deadline = nil, 0, time.Time{}
+	}
+	for {
+		msg, hwm, timedOut, err := r.nextBefore(sess, deadline)
+		switch {
+		case err != nil:
+			return
+		case timedOut:
+			flush()
+			continue
+		}
+		lag.setHighWatermark(hwm)
+		lag.seen(msg.Offset)
+		if err := waitDue(sess, msg); err != nil {
+			return
+		}
+		// A pause hands over the batch filled so far.
+		if len(msgs) > 0 && g.paused.paused(msg.Topic, msg.Partition) {
+			flush()
+		}
+		if err := g.admit(sess, msg); err != nil {
+			return
+		}
+		g.received.Add(1)
+		g.bytes.Add(uint64(len(msg.Value)))
+		g.fire(ConsumerEvent{Name: "message", Msg: msg})
+		msgs, size = append(msgs, msg), size+len(msg.Value)
+		if len(msgs) == 1 {
+			deadline = time.Now().Add(g.opts.ConsumeBatchMaxWait)
+		}
+		if batchFull(g.opts, len(msgs), size) {
+			flush()
+		}
+	}
+}
+
+// handleBatch runs handler over one batch and commits all of it on success;
+// on failure every message is routed or NACKed.
+func (g *memConsumerGroup) handleBatch(sess context.Context, tp memTP, msgs []Message, lag *partitionLag, handler BatchMessageHandler) {
+	err := safeBatchCall(handler, msgs, &g.recovered)
+	g.batches.record(len(msgs), err != nil)
+	if err != nil {
+		g.failed.Add(uint64(len(msgs)))
+		for _, msg := range msgs {
+			if g.routeFailure(sess, msg, err) {
+				g.mark(tp, msg, lag)
+				continue
+			}
+			g.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
+		}
+		return
+	}
+	for _, msg := range msgs {
+		g.mark(tp, msg, lag)
+		g.acked.Add(1)
+		g.fire(ConsumerEvent{Name: "ack", Msg: msg})
+	}
+}
+
+// mark commits msg's offset for the group. The commit is not bound to the
+// session, so a message handled just before a rebalance is not re-delivered;
+// a failed (injected) commit is reported and leaves the offset uncommitted.
+func (g *memConsumerGroup) mark(tp memTP, msg Message, lag *partitionLag) {
+	if err := g.c.commit(context.Background(), g.opts.GroupID, tp, msg.Offset+1); err != nil {
+		g.reportErr(fmt.Errorf("kafka: commit %s/%d@%d: %w", tp.topic, tp.partition, msg.Offset+1, err))
+		return
+	}
+	lag.done(msg.Offset)
+}
+
+// routeFailure republishes a failed message through the router (if any) and
+// reports whether it was routed (see saramaConsumerGroup.routeFailure).
+func (g *memConsumerGroup) routeFailure(ctx context.Context, msg Message, cause error) bool {
+	if g.router == nil {
+		return false
+	}
+	dlq, err := g.router.route(ctx, msg, cause)
+	switch {
+	case errors.Is(err, errNoRoute):
+		return false
+	case err != nil:
+		g.reportErr(err)
+		return false
+	case dlq:
+		g.deadLet.Add(1)
+		g.fire(ConsumerEvent{Name: "dead_letter", Msg: msg, Err: cause})
+	default:
+		g.retried.Add(1)
+		g.fire(ConsumerEvent{Name: "retry", Msg: msg, Err: cause})
+	}
+	return true
+}
+
+func (g *memConsumerGroup) safeHandlerCall(handler MessageHandler, msg Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			g.recovered.Add(1)
+			err = fmt.Errorf("kafka: consumer handler panic: %v", r)
+		}
+	}()
+	return handler(msg)
+}
+
+// Pause holds the partitions' claims until Resume (the cluster has no fetch
+// sessions to pause).
+func (g *memConsumerGroup) Pause(topic string, partitions ...int32) {
+	g.paused.pause(topic, partitions)
+	firePause(g.fire, "pause", topic, partitions)
+}
+
+func (g *memConsumerGroup) Resume(topic string, partitions ...int32) {
+	g.paused.resume(topic, partitions)
+	firePause(g.fire, "resume", topic, partitions)
+}
+
+// admit gates a claimed message on Pause and WithConsumeRateLimit.
+func (g *memConsumerGroup) admit(ctx context.Context, msg Message) error {
+	return admit(ctx, &g.paused, g.opts.ConsumeRateLimit, msg, g.reportErr)
+}
+
+// reportErr surfaces a background error (failed fetch or commit).
+func (g *memConsumerGroup) reportErr(err error) {
+	g.fire(ConsumerEvent{Name: "error", Err: err})
+	g.errChOnce.Do(func() { g.errCh = make(chan error, 16) })
+	select {
+	case g.errCh <- err:
+	default:
+	}
+}
+
+func (g *memConsumerGroup) Errors() <-chan error {
+	g.errChOnce.Do(func() { g.errCh = make(chan error, 16) })
+	return g.errCh
+}
+
+func (g *memConsumerGroup) Close() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	g.closed = true
+	close(g.done)
+	g.mu.Unlock()
+	err := g.router.close()
+	g.fire(ConsumerEvent{Name: "close"})
+	return err
+}
+
+func (g *memConsumerGroup) Metrics() ConsumerMetrics {
+	m := ConsumerMetrics{
+		Received:  g.received.Load(),
+		Acked:     g.acked.Load(),
+		Failed:    g.failed.Load(),
+		Recovered: g.recovered.Load(),
+		Rebalance: g.rebalance.Load(),
+		Bytes:     g.bytes.Load(),
+
+		Retried:      g.retried.Load(),
+		DeadLettered: g.deadLet.Load(),
+	}
+	g.batches.fill(&m)
+	return m
+}
+
+func (g *memConsumerGroup) Snapshot() ConsumerSnapshot {
+	lag, total := g.lag.snapshot()
+	paused, pausedTopics := g.paused.snapshot()
+	return ConsumerSnapshot{
+		Name:            g.Name(),
+		Backend:         g.Backend(),
+		Timestamp:       time.Now().UTC(),
+		ConsumerMetrics: g.Metrics(),
+		Lag:             lag,
+		TotalLag:        total,
+		Paused:          paused,
+		PausedTopics:    pausedTopics,
+	}
+}
+
+func (g *memConsumerGroup) Name() string { return nameOr(g.opts.Name, g.opts.GroupID) }
+
+func (g *memConsumerGroup) Backend() string { return inMemoryBackendName }
+
+func (g *memConsumerGroup) SetOnEvent(fn func(ConsumerEvent)) {
+	if fn == nil {
+		g.onEvent.Store(nil)
+		return
+	}
+	g.onEvent.Store(&fn)
+}
+
+func (g *memConsumerGroup) fire(e ConsumerEvent) {
+	if fnp := g.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
+
+// NewPartitionConsumer builds a PartitionConsumer on the cluster. WithTopic
+// and WithPartition are required; the topic is created if missing, and
+// WithOffset is resolved against the partition now.
+func (c *InMemoryCluster) NewPartitionConsumer(opts ...Option) (PartitionConsumer, error) {
+	o, err := inMemoryOptions(opts, "partition-consumer")
+	if err != nil {
+		return nil, err
+	}
+	if n := c.ensureTopic(o.Topic); o.Partition < 0 || o.Partition >= n {
+		return nil, fmt.Errorf("%w: %s/%d", ErrUnknownPartition, o.Topic, o.Partition)
+	}
+	p := &memPartitionConsumer{opts: o, done: make(chan struct{})}
+	p.lag = newLagTracker(o.LagThreshold, p.fire)
+	tp := memTP{o.Topic, o.Partition}
+	p.r = &memReader{c: c, tp: tp, pos: c.startOffset(tp.topic, tp.partition, o.Offset),
+		max: o.ConsumeBatchMaxRecords, onErr: p.reportErr}
+	return p, nil
+}
+
+// memPartitionConsumer is the InMemoryCluster PartitionConsumer. Consume,
+// ConsumeBatch and Messages share one read position, so use one of them at
+// a time.
+type memPartitionConsumer struct {
+	opts Options
+	r    *memReader
+	lag  *lagTracker
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+
+	errChOnce sync.Once
+	errCh     chan error
+	msgChOnce sync.Once
+	msgCh     chan Message
+
+	received  atomic.Uint64
+	acked     atomic.Uint64
+	failed    atomic.Uint64
+	recovered atomic.Uint64
+	bytes     atomic.Uint64
+	batches   batchStats
+
+	onEvent atomic.Pointer[func(ConsumerEvent)]
+}
+
+func (p *memPartitionConsumer) Consume(ctx context.Context, handler MessageHandler) error {
+	if p.isClosed() {
+		return ErrProducerClosed
+	}
+	ctx, cancel := untilClosed(ctx, p.done)
+	defer cancel()
+	return p.pump(ctx, handler, nil)
+}
+
+// pump delivers to handler (callback mode) or out (channel mode) until ctx
+// ends.
+func (p *memPartitionConsumer) pump(ctx context.Context, handler MessageHandler, out chan Message) error {
+	if out != nil {
+		defer close(out)
+	}
+	lag := p.lag.partition(p.opts.Topic, p.opts.Partition)
+	lag.start(p.opts.Offset)
+	defer p.lag.run(ctx, p.opts.LagInterval, memEndOffsets(p.r.c))()
+	for {
+		msg, hwm, err := p.r.next(ctx)
+		if err != nil {
+			return err
+		}
+		lag.setHighWatermark(hwm)
+		lag.seen(msg.Offset)
+		p.received.Add(1)
+		p.bytes.Add(uint64(len(msg.Value)))
+		p.fire(ConsumerEvent{Name: "message", Msg: msg})
+		if out != nil {
+			select {
+			case out <- msg:
+				lag.done(msg.Offset)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		if err := p.safeHandlerCall(handler, msg); err != nil {
+			p.failed.Add(1)
+			p.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
+			continue
+		}
+		lag.done(msg.Offset)
+		p.acked.Add(1)
+		p.fire(ConsumerEvent{Name: "ack", Msg: msg})
+	}
+}
+
+func (p *memPartitionConsumer) ConsumeBatch(ctx context.Context, handler BatchMessageHandler) error {
+	if p.isClosed() {
+		return ErrProducerClosed
+	}
+	ctx, cancel := untilClosed(ctx, p.done)
+	defer cancel()
+	lag := p.lag.partition(p.opts.Topic, p.opts.Partition)
+	lag.start(p.opts.Offset)
+	defer p.lag.run(ctx, p.opts.LagInterval, memEndOffsets(p.r.c))()
+	var (
+		msgs     []Message
+		size     int
+		deadline time.Time
+	)
+	flush := func() {
+		err := safeBatchCall(handler, msgs, &p.recovered)
+		p.batches.record(len(msgs), err != nil)
+		for _, msg := range msgs {
+			if err != nil {
+				p.failed.Add(1)
+				p.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
+				continue
+			}
+			lag.done(msg.Offset)
+			p.acked.Add(1)
+			p.fire(ConsumerEvent{Name: "ack", Msg: msg})
+		}
+		msgs, size, deadline = nil, 0, time.Time{}
+	}
+	for {
+		msg, hwm, timedOut, err := p.r.nextBefore(ctx, deadline)
+		switch {
+		case err != nil:
+			return err
+		case timedOut:
+			flush()
+			continue
+		}
+		lag.setHighWatermark(hwm)
+		lag.seen(msg.Offset)
+		p.received.Add(1)
+		p.bytes.Add(uint64(len(msg.Value)))
+		p.fire(ConsumerEvent{Name: "message", Msg: msg})
+		msgs, size = append(msgs, msg), size+len(msg.Value)
+		if len(msgs) == 1 {
+			deadline = time.Now().Add(p.opts.ConsumeBatchMaxWait)
+		}
+		if batchFull(p.opts, len(msgs), size) {
+			flush()
+		}
+	}
+}
+
+// Messages starts the channel-mode pump on first call (nil in callback
+// mode); Close stops it and closes the channel.
+func (p *memPartitionConsumer) Messages() <-chan Message {
+	if p.opts.DeliveryMode != "channel" {
+		return nil
+	}
+	p.msgChOnce.Do(func() {
+		p.msgCh = make(chan Message, 64)
+		ctx, cancel := untilClosed(context.Background(), p.done)
+		go func() {
+			defer cancel()
+			_ = p.pump(ctx, nil, p.msgCh)
+		}()
+	})
+	return p.msgCh
+}
+
+func (p *memPartitionConsumer) safeHandlerCall(handler MessageHandler, msg Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.recovered.Add(1)
+			err = fmt.Errorf("kafka: consumer handler panic: %v", r)
+		}
+	}()
+	return handler(msg)
+}
+
+// reportErr surfaces a failed fetch, counted in Failed as on sarama.
+func (p *memPartitionConsumer) reportErr(err error) {
+	p.failed.Add(1)
+	p.fire(ConsumerEvent{Name: "error", Err: err})
+	p.errChOnce.Do(func() { p.errCh = make(chan error, 16) })
+	select {
+	case p.errCh <- err:
+	default:
+	}
+}
+
+func (p *memPartitionConsumer) Errors() <-chan error {
+	p.errChOnce.Do(func() { p.errCh = make(chan error, 16) })
+	return p.errCh
+}
+
+func (p *memPartitionConsumer) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *memPartitionConsumer) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+	p.fire(ConsumerEvent{Name: "close"})
+	return nil
+}
+
+func (p *memPartitionConsumer) Metrics() ConsumerMetrics {
+	m := ConsumerMetrics{
+		Received:  p.received.Load(),
+		Acked:     p.acked.Load(),
+		Failed:    p.failed.Load(),
+		Recovered: p.recovered.Load(),
+		Bytes:     p.bytes.Load(),
+	}
+	p.batches.fill(&m)
+	return m
+}
+
+func (p *memPartitionConsumer) Snapshot() ConsumerSnapshot {
+	lag, total := p.lag.snapshot()
+	return ConsumerSnapshot{
+		Name:            p.Name(),
+		Backend:         p.Backend(),
+		Timestamp:       time.Now().UTC(),
+		ConsumerMetrics: p.Metrics(),
+		Lag:             lag,
+		TotalLag:        total,
+	}
+}
+
+func (p *memPartitionConsumer) Name() string { return nameOr(p.opts.Name, p.opts.Topic) }
+
+func (p *memPartitionConsumer) Backend() string { return inMemoryBackendName }
+
+func (p *memPartitionConsumer) SetOnEvent(fn func(ConsumerEvent)) {
+	if fn == nil {
+		p.onEvent.Store(nil)
+		return
+	}
+	p.onEvent.Store(&fn)
+}
+
+func (p *memPartitionConsumer) fire(e ConsumerEvent) {
+	if fnp := p.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
@@ -0,0 +1,341 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// inMemoryOptions resolves Options for an InMemoryCluster client: Brokers is
+// not needed, so a placeholder satisfies validate.
+func inMemoryOptions(opts []Option, role string) (Options, error) {
+	o := applyOptions(opts).withDefaults()
+	if len(o.Brokers) == 0 {
+		o.Brokers = []string{inMemoryBackendName}
+	}
+	return o, o.validate(role)
+}
+
+// memPartitioners holds a producer's per-topic partitioners.
+type memPartitioners struct {
+	o     Options
+	mu    sync.Mutex
+	parts map[string]*topicPartitioner
+}
+
+func (m *memPartitioners) pick(msg Message, n int32) int32 {
+	m.mu.Lock()
+	p := m.parts[msg.Topic]
+	if p == nil {
+		if m.parts == nil {
+			m.parts = make(map[string]*topicPartitioner)
+		}
+		p = newTopicPartitioner(m.o)
+		m.parts[msg.Topic] = p
+	}
+	m.mu.Unlock()
+	return p.partition(msg, n)
+}
+
+// NewProducer builds an async Producer on the cluster. Records are appended
+// by a background goroutine in Send order; Close waits for the backlog.
+func (c *InMemoryCluster) NewProducer(opts ...Option) (Producer, error) {
+	o, err := inMemoryOptions(opts, "producer")
+	if err != nil {
+		return nil, err
+	}
+	p := &memProducer{
+		c: c, opts: o, parts: &memPartitioners{o: o},
+		input:   make(chan Message, o.ChannelBufferSize),
+		done:    make(chan struct{}),
+		history: newSnapshotHistory(o.SnapshotHistory),
+	}
+	go p.deliver()
+	return p, nil
+}
+
+// memProducer is the InMemoryCluster async Producer.
+type memProducer struct {
+	c     *InMemoryCluster
+	opts  Options
+	parts *memPartitioners
+	input chan Message
+	done  chan struct{}
+
+	// Send takes RLock, Close takes Lock before closing input (as the sarama
+	// producer), so no Send races the close.
+	mu     sync.RWMutex
+	closed bool
+
+	enqueued      atomic.Uint64
+	success       atomic.Uint64
+	failed        atomic.Uint64
+	bytes         atomic.Uint64
+	batchCount    atomic.Uint64
+	batchMax      atomic.Uint64
+	bytesEnqueued atomic.Uint64
+	bytesFailed   atomic.Uint64
+
+	history *snapshotHistory
+
+	onEvent atomic.Pointer[func(ProducerEvent)]
+}
+
+// deliver appends the queued records until input is closed.
+func (p *memProducer) deliver() {
+	defer close(p.done)
+	for msg := range p.input {
+		out, err := p.c.produce(context.Background(), msg, p.parts.pick)
+		if err != nil {
+			p.failed.Add(1)
+			p.bytesFailed.Add(uint64(len(msg.Value)))
+			p.fire(ProducerEvent{Name: "error", Topic: msg.Topic, Err: err})
+			continue
+		}
+		p.success.Add(1)
+		p.bytes.Add(uint64(len(msg.Value)))
+		p.fire(ProducerEvent{Name: "success", Topic: out.Topic, Partition: out.Partition, Offset: out.Offset, Bytes: len(msg.Value)})
+	}
+}
+
+func (p *memProducer) Send(ctx context.Context, msg Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+	if err := p.enqueue(ctx, msg); err != nil {
+		return err
+	}
+	p.fire(ProducerEvent{Name: "send", Topic: msg.Topic, Bytes: len(msg.Value)})
+	return nil
+}
+
+func (p *memProducer) SendBatch(ctx context.Context, msgs []Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+	p.batchCount.Add(1)
+	for n := uint64(len(msgs)); n > p.batchMax.Load(); p.batchMax.Store(n) {
+	}
+	for _, msg := range msgs {
+		if err := p.enqueue(ctx, msg); err != nil {
+			return err
+		}
+	}
+	p.fire(ProducerEvent{Name: "send", Topic: p.opts.Topic})
+	return nil
+}
+
+func (p *memProducer) enqueue(ctx context.Context, msg Message) error {
+	if msg.Topic == "" {
+		msg.Topic = p.opts.Topic
+	}
+	select {
+	case p.input <- msg:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.enqueued.Add(1)
+	p.bytesEnqueued.Add(uint64(len(msg.Value)))
+	return nil
+}
+
+func (p *memProducer) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.input)
+	p.mu.Unlock()
+	<-p.done
+	p.fire(ProducerEvent{Name: "close"})
+	return nil
+}
+
+func (p *memProducer) Metrics() ProducerMetrics {
+	e, su, f := p.enqueued.Load(), p.success.Load(), p.failed.Load()
+	be, ba, bf := p.bytesEnqueued.Load(), p.bytes.Load(), p.bytesFailed.Load()
+	return ProducerMetrics{
+		Enqueued:      e,
+		Success:       su,
+		Failed:        f,
+		Bytes:         ba,
+		BytesFailed:   bf,
+		BytesEnqueued: be,
+		BatchCount:    p.batchCount.Load(),
+		BatchMax:      p.batchMax.Load(),
+		InFlight:      ComputeInFlight(e, su, f),
+		BufferedBytes: ComputeBufferedBytes(be, ba, bf),
+	}
+}
+
+func (p *memProducer) Snapshot() ProducerSnapshot {
+	snap := ProducerSnapshot{
+		Name:             p.Name(),
+		Backend:          p.Backend(),
+		Timestamp:        time.Now().UTC(),
+		ProducerMetrics:  p.Metrics(),
+		Linger:           effectiveLinger(p.opts.ProducerLinger),
+		MaxBufferedRecs:  p.opts.MaxBufferedRecords,
+		BatchMaxBytesCfg: p.opts.BatchMaxBytes,
+	}
+	p.history.record(snap)
+	return snap
+}
+
+// History implements SnapshotHistory.
+func (p *memProducer) History() []ProducerSnapshot { return p.history.snapshot() }
+
+func (p *memProducer) Name() string { return nameOr(p.opts.Name, p.opts.Topic) }
+
+func (p *memProducer) Backend() string { return inMemoryBackendName }
+
+func (p *memProducer) SetOnEvent(fn func(ProducerEvent)) {
+	if fn == nil {
+		p.onEvent.Store(nil)
+		return
+	}
+	p.onEvent.Store(&fn)
+}
+
+func (p *memProducer) fire(e ProducerEvent) {
+	if fnp := p.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
+
+// NewSyncProducer builds a SyncProducer on the cluster: Send returns once the
+// record is appended (after any injected latency).
+func (c *InMemoryCluster) NewSyncProducer(opts ...Option) (SyncProducer, error) {
+	o, err := inMemoryOptions(opts, "producer")
+	if err != nil {
+		return nil, err
+	}
+	return &memSyncProducer{c: c, opts: o, parts: &memPartitioners{o: o}}, nil
+}
+
+// memSyncProducer is the InMemoryCluster SyncProducer.
+type memSyncProducer struct {
+	c     *InMemoryCluster
+	opts  Options
+	parts *memPartitioners
+
+	closed atomic.Bool
+
+	enqueued      atomic.Uint64
+	success       atomic.Uint64
+	failed        atomic.Uint64
+	bytes         atomic.Uint64
+	batchCount    atomic.Uint64
+	batchMax      atomic.Uint64
+	bytesEnqueued atomic.Uint64
+	bytesFailed   atomic.Uint64
+
+	onEvent atomic.Pointer[func(ProducerEvent)]
+}
+
+func (p *memSyncProducer) Send(ctx context.Context, msg Message) (int32, int64, error) {
+	if p.closed.Load() {
+		return 0, 0, ErrProducerClosed
+	}
+	out, err := p.send(ctx, msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	return out.Partition, out.Offset, nil
+}
+
+// SendBatch appends msgs in order and returns the first failure; the records
+// before it stay appended.
+func (p *memSyncProducer) SendBatch(ctx context.Context, msgs []Message) error {
+	if p.closed.Load() {
+		return ErrProducerClosed
+	}
+	p.batchCount.Add(1)
+	for n := uint64(len(msgs)); n > p.batchMax.Load(); p.batchMax.Store(n) {
+	}
+	for _, msg := range msgs {
+		if _, err := p.send(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *memSyncProducer) send(ctx context.Context, msg Message) (Message, error) {
+	if msg.Topic == "" {
+		msg.Topic = p.opts.Topic
+	}
+	n := uint64(len(msg.Value))
+	p.enqueued.Add(1)
+	p.bytesEnqueued.Add(n)
+	p.fire(ProducerEvent{Name: "send", Topic: msg.Topic, Bytes: len(msg.Value)})
+	out, err := p.c.produce(ctx, msg, p.parts.pick)
+	if err != nil {
+		p.failed.Add(1)
+		p.bytesFailed.Add(n)
+		p.fire(ProducerEvent{Name: "error", Topic: msg.Topic, Err: err})
+		return out, err
+	}
+	p.success.Add(1)
+	p.bytes.Add(n)
+	p.fire(ProducerEvent{Name: "success", Topic: out.Topic, Partition: out.Partition, Offset: out.Offset, Bytes: len(msg.Value)})
+	return out, nil
+}
+
+func (p *memSyncProducer) Close() error {
+	if p.closed.CompareAndSwap(false, true) {
+		p.fire(ProducerEvent{Name: "close"})
+	}
+	return nil
+}
+
+func (p *memSyncProducer) Metrics() ProducerMetrics {
+	e, su, f := p.enqueued.Load(), p.success.Load(), p.failed.Load()
+	be, ba, bf := p.bytesEnqueued.Load(), p.bytes.Load(), p.bytesFailed.Load()
+	return ProducerMetrics{
+		Enqueued:      e,
+		Success:       su,
+		Failed:        f,
+		Bytes:         ba,
+		BytesFailed:   bf,
+		BytesEnqueued: be,
+		BatchCount:    p.batchCount.Load(),
+		BatchMax:      p.batchMax.Load(),
+		InFlight:      ComputeInFlight(e, su, f),
+		BufferedBytes: ComputeBufferedBytes(be, ba, bf),
+	}
+}
+
+func (p *memSyncProducer) Snapshot() ProducerSnapshot {
+	return ProducerSnapshot{
+		Name:            p.Name(),
+		Backend:         p.Backend(),
+		Timestamp:       time.Now().UTC(),
+		ProducerMetrics: p.Metrics(),
+	}
+}
+
+func (p *memSyncProducer) Name() string { return nameOr(p.opts.Name, p.opts.Topic) }
+
+func (p *memSyncProducer) Backend() string { return inMemoryBackendName }
+
+func (p *memSyncProducer) SetOnEvent(fn func(ProducerEvent)) {
+	if fn == nil {
+		p.onEvent.Store(nil)
+		return
+	}
+	p.onEvent.Store(&fn)
+}
+
+func (p *memSyncProducer) fire(e ProducerEvent) {
+	if fnp := p.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
@@ -29,6 +29,13 @@ type Options struct {
 	// construction error.
 	Version string `json:"version" mapstructure:"version"`
 
+	// TLS enables TLS to the brokers; nil (default) is plaintext.
+	TLS *TLSConfig `json:"tls" mapstructure:"tls"`
+
+	// SASL enables SASL authentication (PLAIN, SCRAM-SHA-256/512); nil
+	// (default) is none. Bad TLS / SASL combinations fail at construction.
+	SASL *SASLConfig `json:"sasl" mapstructure:"sasl"`
+
 	// Topic is the default topic for produce, and for partition-consumer
 	// subscription. Consumer groups subscribe via Consume(topics, ...) so the
 	// group does not require this field.
@@ -127,6 +134,26 @@ type Options struct {
 	// Larger batches amortize RPC overhead but increase memory per batch.
 	BatchMaxBytes int `json:"batch_max_bytes" mapstructure:"batch_max_bytes"`
 
+	// Compression is the producer batch codec: CompressionNone (default),
+	// CompressionGzip, CompressionSnappy, CompressionLZ4 or CompressionZstd.
+	// Applied identically on both backends (franz-go's native snappy default
+	// is overridden).
+	Compression string `json:"compression" mapstructure:"compression"`
+
+	// CompressionLevel is the codec level (gzip 1-9, lz4 0-9+, zstd 1-22);
+	// 0 (default) keeps the codec's own default level. Ignored by snappy.
+	CompressionLevel int `json:"compression_level" mapstructure:"compression_level"`
+
+	// Partitioner picks the partition of each produced record: PartitionerHash
+	// (default), PartitionerMurmur2 (Java-client compatible), PartitionerSticky,
+	// PartitionerRoundRobin, PartitionerManual or PartitionerCustom. Both
+	// backends share one implementation, so the mapping is backend-independent.
+	Partitioner string `json:"partitioner" mapstructure:"partitioner"`
+
+	// PartitionFunc is the PartitionerCustom function (set by
+	// WithPartitionFunc).
+	PartitionFunc PartitionFunc `json:"-"`
+
 	// SnapshotHistory is the number of recent ProducerSnapshot samples to retain
 	// for trend analysis (obtained via the optional SnapshotHistory interface /
 	// History()). 0 (default) disables history — Snapshot() still works but no
@@ -137,8 +164,64 @@ type Options struct {
 	// goroutine.
 	SnapshotHistory int `json:"snapshot_history" mapstructure:"snapshot_history"`
 
+	// --- transactions ---
+
+	// TransactionalID enables Kafka transactions. Required by
+	// NewTransactionalProducer and NewTxnConsumerGroup; ignored elsewhere. Keep
+	// it stable across restarts of the same logical instance (the broker fences
+	// the previous incarnation) and unique among concurrently running ones.
+	// Transactions force acks=all + the idempotent producer on both backends.
+	TransactionalID string `json:"transactional_id" mapstructure:"transactional_id"`
+
+	// TransactionTimeout is how long the broker lets a transaction stay open
+	// before aborting it. Default 60s (sarama's native default; franz-go's 40s
+	// is overridden so both backends match). Must not exceed the broker's
+	// transaction.max.timeout.ms.
+	TransactionTimeout time.Duration `json:"transaction_timeout" mapstructure:"transaction_timeout"`
+
+	// TxnMaxBatch caps the records handed to one TxnBatchHandler call (one
+	// transaction) by a TxnConsumerGroup. Default 500.
+	TxnMaxBatch int `json:"txn_max_batch" mapstructure:"txn_max_batch"`
+
+	// RetryTopics are the delay tiers a ConsumerGroup republishes a message to
+	// when its handler fails (attempt n goes to RetryTopics[n-1]); the group
+	// also consumes them and hands each message back no earlier than its
+	// tier's Delay. Empty (default) keeps plain NACK / redelivery semantics.
+	RetryTopics []RetryTopic `json:"retry_topics" mapstructure:"retry_topics"`
+
+	// DeadLetterTopic receives a message once the retry tiers are exhausted
+	// (or on the first failure when RetryTopics is empty). Empty: a message
+	// that fails its last tier is NACKed.
+	DeadLetterTopic string `json:"dead_letter_topic" mapstructure:"dead_letter_topic"`
+
 	// --- consumer tuning ---
 
+	// ConsumeBatchMaxRecords / ConsumeBatchMaxBytes / ConsumeBatchMaxWait
+	// bound one BatchMessageHandler call (ConsumeBatch): a batch is handed
+	// over once it holds MaxRecords messages, once its Value bytes reach
+	// MaxBytes (the message crossing the limit is included), or MaxWait after
+	// its first message arrived, whichever comes first. Defaults 500, 0 (no
+	// byte bound), 100ms.
+	ConsumeBatchMaxRecords int           `json:"consume_batch_max_records" mapstructure:"consume_batch_max_records"`
+	ConsumeBatchMaxBytes   int           `json:"consume_batch_max_bytes" mapstructure:"consume_batch_max_bytes"`
+	ConsumeBatchMaxWait    time.Duration `json:"consume_batch_max_wait" mapstructure:"consume_batch_max_wait"`
+
+	// LagThreshold, when > 0, makes ConsumerGroup / PartitionConsumer fire a
+	// "lag" ConsumerEvent when a partition falls this many messages behind its
+	// high watermark, and "lag_recovered" once it is back below. Default 0
+	// (lag is still reported in Snapshot, without events).
+	LagThreshold int64 `json:"lag_threshold" mapstructure:"lag_threshold"`
+	// LagInterval is how often a consuming ConsumerGroup / PartitionConsumer
+	// checks LagThreshold and (franz-go) refreshes the high watermarks of its
+	// partitions from the broker, so lag keeps growing while a handler is
+	// stuck. Default 1s.
+	LagInterval time.Duration `json:"lag_interval" mapstructure:"lag_interval"`
+
+	// ConsumeRateLimit, when set, paces a ConsumerGroup: each message waits
+	// for one token before it is handed to the handler (set by
+	// WithConsumeRateLimit).
+	ConsumeRateLimit RateLimiter `json:"-"`
+
 	// ConsumerOffsetInitial is the group's Offsets.Initial (OffsetNewest /
 	// OffsetOldest) when the group has no committed offset. Default OffsetNewest.
 	ConsumerOffsetInitial int64 `json:"consumer_offset_initial" mapstructure:"consumer_offset_initial"`
@@ -167,6 +250,15 @@ func applyOptions(opts []Option) Options {
 	return o
 }
 
+// txnOptions resolves Options for the transactional constructors: acks=all
+// and the success path are not optional there.
+func txnOptions(opts []Option) Options {
+	o := applyOptions(opts)
+	o.Acks = AcksAll
+	o.ReturnSuccesses = true
+	return o.withDefaults()
+}
+
 // logConfig prints the resolved configuration at startup so operators see
 // exactly what is running. Both backends call this from their constructors.
 // The durability hint reminds ad-tech users (default acks=leader) how to
@@ -204,6 +296,17 @@ func WithName(name string) Option { return func(o *Options) { o.Name = name } }
 // WithVersion sets the Kafka cluster version string (e.g. "3.5.0").
 func WithVersion(v string) Option { return func(o *Options) { o.Version = v } }
 
+// WithTLS enables TLS to the brokers (see TLSConfig).
+func WithTLS(c TLSConfig) Option { return func(o *Options) { o.TLS = &c } }
+
+// WithSASL enables SASL authentication with mechanism SASLPlain,
+// SASLScramSHA256 or SASLScramSHA512.
+func WithSASL(mechanism, username, password string) Option {
+	return func(o *Options) {
+		o.SASL = &SASLConfig{Mechanism: mechanism, Username: username, Password: password}
+	}
+}
+
 // WithTopic sets the default topic.
 func WithTopic(t string) Option { return func(o *Options) { o.Topic = t } }
 
@@ -248,6 +351,21 @@ func WithMaxBufferedRecords(n int) Option { return func(o *Options) { o.MaxBuffe
 // WithBatchMaxBytes caps a single batch's byte size.
 func WithBatchMaxBytes(n int) Option { return func(o *Options) { o.BatchMaxBytes = n } }
 
+// WithCompression sets the producer batch codec and level (0 = the codec's
+// default level).
+func WithCompression(codec string, level int) Option {
+	return func(o *Options) { o.Compression, o.CompressionLevel = codec, level }
+}
+
+// WithPartitioner selects a built-in partitioner by name (PartitionerHash,
+// PartitionerMurmur2, ...).
+func WithPartitioner(name string) Option { return func(o *Options) { o.Partitioner = name } }
+
+// WithPartitionFunc installs a custom partitioner (PartitionerCustom).
+func WithPartitionFunc(fn PartitionFunc) Option {
+	return func(o *Options) { o.Partitioner, o.PartitionFunc = PartitionerCustom, fn }
+}
+
 // WithSnapshotHistory enables retaining the last n Snapshot() samples for trend
 // analysis (obtained via the optional SnapshotHistory interface). n ≤ 0 disables
 // history (the default). See the SnapshotHistory option field for sizing guidance.
@@ -263,6 +381,56 @@ func WithSnapshotHistory(n int) Option {
 // WithCodec installs a value (de)serialiser.
 func WithCodec(c Codec) Option { return func(o *Options) { o.Codec = c } }
 
+// WithTransactionalID enables transactions under the given id (required by
+// NewTransactionalProducer / NewTxnConsumerGroup).
+func WithTransactionalID(id string) Option { return func(o *Options) { o.TransactionalID = id } }
+
+// WithTransactionTimeout sets the broker-side transaction timeout.
+func WithTransactionTimeout(d time.Duration) Option {
+	return func(o *Options) { o.TransactionTimeout = d }
+}
+
+// WithTxnMaxBatch caps the records per transaction in a TxnConsumerGroup.
+func WithTxnMaxBatch(n int) Option { return func(o *Options) { o.TxnMaxBatch = n } }
+
+// WithRetryTopics sets the ConsumerGroup's retry delay tiers, in attempt
+// order, e.g. WithRetryTopics(RetryTopic{"orders-retry-5s", 5 * time.Second},
+// RetryTopic{"orders-retry-1m", time.Minute}).
+func WithRetryTopics(tiers ...RetryTopic) Option {
+	return func(o *Options) { o.RetryTopics = append([]RetryTopic(nil), tiers...) }
+}
+
+// WithDeadLetter sets the ConsumerGroup's dead-letter topic.
+func WithDeadLetter(topic string) Option { return func(o *Options) { o.DeadLetterTopic = topic } }
+
+// WithConsumeBatchMaxRecords caps the messages per ConsumeBatch handler call.
+func WithConsumeBatchMaxRecords(n int) Option {
+	return func(o *Options) { o.ConsumeBatchMaxRecords = n }
+}
+
+// WithConsumeBatchMaxBytes caps the Value bytes per ConsumeBatch handler call.
+func WithConsumeBatchMaxBytes(n int) Option { return func(o *Options) { o.ConsumeBatchMaxBytes = n } }
+
+// WithConsumeBatchMaxWait bounds how long a ConsumeBatch batch fills after
+// its first message.
+func WithConsumeBatchMaxWait(d time.Duration) Option {
+	return func(o *Options) { o.ConsumeBatchMaxWait = d }
+}
+
+// WithLagThreshold enables the per-partition "lag" / "lag_recovered" events.
+func WithLagThreshold(n int64) Option { return func(o *Options) { o.LagThreshold = n } }
+
+// WithLagInterval sets how often lag is checked and refreshed.
+func WithLagInterval(d time.Duration) Option { return func(o *Options) { o.LagInterval = d } }
+
+// WithConsumeRateLimit paces a ConsumerGroup with l (a limiter.Limiter from
+// github.com/v8fg/kit4go/limiter, or anything with its Wait method). The
+// limiter is shared by all of the group's partitions and is not closed by
+// the group.
+func WithConsumeRateLimit(l RateLimiter) Option {
+	return func(o *Options) { o.ConsumeRateLimit = l }
+}
+
 // WithConsumerOffsetInitial sets the group's initial offset (OffsetNewest/Oldest).
 func WithConsumerOffsetInitial(o int64) Option {
 	return func(opts *Options) { opts.ConsumerOffsetInitial = o }
@@ -343,6 +511,15 @@ const (
 	AcksNone   = "none"
 )
 
+// Compression codecs for Options.Compression.
+const (
+	CompressionNone   = "none" // default
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionLZ4    = "lz4"
+	CompressionZstd   = "zstd"
+)
+
 // defaultOptions returns the package defaults — the single source of truth.
 // Note: ChannelBufferSize is intentionally NOT set here — withDefaults derives
 // it from MaxBufferedRecords (see withDefaults) so the two stay coupled.
@@ -355,6 +532,14 @@ func defaultOptions() Options {
 		ConsumerOffsetInitial: OffsetNewest,
 		FetchMin:              1,
 		DeliveryMode:          "callback",
+		Compression:           CompressionNone,
+		Partitioner:           PartitionerHash,
+		TransactionTimeout:    60 * time.Second,
+		TxnMaxBatch:           500,
+
+		ConsumeBatchMaxRecords: 500,
+		ConsumeBatchMaxWait:    100 * time.Millisecond,
+		LagInterval:            time.Second,
 	}
 }
 
@@ -409,6 +594,27 @@ func (o Options) withDefaults() Options {
 	if o.DeliveryMode == "" {
 		o.DeliveryMode = d.DeliveryMode
 	}
+	if o.Compression == "" {
+		o.Compression = d.Compression
+	}
+	if o.Partitioner == "" {
+		o.Partitioner = d.Partitioner
+	}
+	if o.TransactionTimeout <= 0 {
+		o.TransactionTimeout = d.TransactionTimeout
+	}
+	if o.TxnMaxBatch <= 0 {
+		o.TxnMaxBatch = d.TxnMaxBatch
+	}
+	if o.ConsumeBatchMaxRecords <= 0 {
+		o.ConsumeBatchMaxRecords = d.ConsumeBatchMaxRecords
+	}
+	if o.ConsumeBatchMaxWait <= 0 {
+		o.ConsumeBatchMaxWait = d.ConsumeBatchMaxWait
+	}
+	if o.LagInterval <= 0 {
+		o.LagInterval = d.LagInterval
+	}
 	return o
 }
 
@@ -417,11 +623,18 @@ func (o Options) withDefaults() Options {
 func (o Options) consumerNeedsSuccesses() bool { return true }
 
 // validate checks the options required by the named constructor role.
-// role is "producer", "consumer-group", or "partition-consumer".
+// role is "producer", "consumer-group", "partition-consumer",
+// "transactional-producer" or "txn-consumer-group".
 func (o Options) validate(role string) error {
 	if len(o.Brokers) == 0 {
 		return errors.New("kafka: brokers required")
 	}
+	if err := validateSecurity(o); err != nil {
+		return err
+	}
+	if err := validateProducing(o); err != nil {
+		return err
+	}
 	switch role {
 	case "producer":
 		// Topic is optional per-message (Message.Topic can override), so allow empty.
@@ -429,10 +642,24 @@ func (o Options) validate(role string) error {
 		if o.GroupID == "" {
 			return errors.New("kafka: group_id required for consumer group")
 		}
+		if err := validateRouting(o); err != nil {
+			return err
+		}
 	case "partition-consumer":
 		if o.Topic == "" {
 			return errors.New("kafka: topic required for partition consumer")
 		}
+	case "transactional-producer":
+		if o.TransactionalID == "" {
+			return errors.New("kafka: transactional_id required for transactional producer")
+		}
+	case "txn-consumer-group":
+		if o.GroupID == "" {
+			return errors.New("kafka: group_id required for consumer group")
+		}
+		if o.TransactionalID == "" {
+			return errors.New("kafka: transactional_id required for transactional consumer group")
+		}
 	}
 	return nil
 }
@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// Partitioner names for Options.Partitioner. Both backends run the same
+// implementation (newTopicPartitioner), so a key lands on the same partition
+// whichever backend produced it.
+const (
+	// PartitionerHash (default): FNV-1a of the key, sarama's historic
+	// hash partitioner.
+	PartitionerHash = "hash"
+	// PartitionerMurmur2: murmur2 of the key, as the Java client's default
+	// partitioner — use it to co-partition with JVM producers.
+	PartitionerMurmur2 = "murmur2"
+	// PartitionerRoundRobin ignores the key and cycles through partitions.
+	PartitionerRoundRobin = "round_robin"
+	// PartitionerSticky hashes keys like PartitionerMurmur2 and keeps keyless
+	// records on one partition for MaxBufferedRecords records (about one
+	// batch) before moving to the next, for fewer, fuller batches.
+	PartitionerSticky = "sticky"
+	// PartitionerManual sends each record to Message.Partition.
+	PartitionerManual = "manual"
+	// PartitionerCustom calls Options.PartitionFunc (set by WithPartitionFunc).
+	PartitionerCustom = "custom"
+)
+
+// PartitionFunc picks the partition in [0, numPartitions) for msg.
+type PartitionFunc func(msg Message, numPartitions int32) int32
+
+// topicPartitioner is the partitioner of one topic; the sarama and franz-go
+// adapters wrap it. Keyless records under the hash-based strategies are spread
+// round-robin (sarama would pick randomly; round-robin is reproducible on
+// both backends). An empty key counts as no key: sarama cannot tell the two
+// apart on the wire path.
+type topicPartitioner struct {
+	kind        string
+	fn          PartitionFunc
+	stickyEvery int64
+	next        atomic.Int64 // keyless record counter
+	start       int64        // sticky: first partition, randomised per topic
+}
+
+func newTopicPartitioner(o Options) *topicPartitioner {
+	return &topicPartitioner{
+		kind:        o.Partitioner,
+		fn:          o.PartitionFunc,
+		stickyEvery: int64(max(o.MaxBufferedRecords, 1)),
+		start:       rand.Int64N(1 << 30),
+	}
+}
+
+// partition returns msg's partition among n.
+func (p *topicPartitioner) partition(msg Message, n int32) int32 {
+	switch p.kind {
+	case PartitionerManual:
+		return msg.Partition
+	case PartitionerCustom:
+		return p.fn(msg, n)
+	case PartitionerRoundRobin:
+		return int32((p.next.Add(1) - 1) % int64(n))
+	}
+	if len(msg.Key) == 0 {
+		c := p.next.Add(1) - 1
+		if p.kind == PartitionerSticky {
+			return int32((p.start + c/p.stickyEvery) % int64(n))
+		}
+		return int32(c % int64(n))
+	}
+	if p.kind == PartitionerHash {
+		h := fnv.New32a()
+		_, _ = h.Write(msg.Key)
+		part := int32(h.Sum32()) % n
+		if part < 0 {
+			part = -part
+		}
+		return part
+	}
+	return (murmur2(msg.Key) & 0x7fffffff) % n // murmur2 and sticky
+}
+
+// requiresConsistency reports whether msg must land on its chosen partition
+// even while that partition is unavailable (keyed hashing, manual, custom).
+func (p *topicPartitioner) requiresConsistency(msg Message) bool {
+	switch p.kind {
+	case PartitionerRoundRobin:
+		return false
+	case PartitionerManual, PartitionerCustom:
+		return true
+	}
+	return len(msg.Key) > 0
+}
+
+// murmur2 is the Java client's Utils.murmur2 (seed 0x9747b28c).
+func murmur2(data []byte) int32 {
+	const (
+		m    = 0x5bd1e995
+		r    = 24
+		seed = 0x9747b28c
+	)
+	n := len(data)
+	h := uint32(seed) ^ uint32(n)
+	for i := 0; i+4 <= n; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[n&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// validateProducing rejects unknown codec / partitioner names and a custom
+// partitioner without its function. Empty names mean the defaults.
+func validateProducing(o Options) error {
+	switch o.Compression {
+	case "", CompressionNone, CompressionGzip, CompressionSnappy, CompressionLZ4, CompressionZstd:
+	default:
+		return fmt.Errorf("kafka: unknown compression %q", o.Compression)
+	}
+	switch o.Partitioner {
+	case "", PartitionerHash, PartitionerMurmur2, PartitionerRoundRobin, PartitionerSticky, PartitionerManual:
+	case PartitionerCustom:
+		if o.PartitionFunc == nil {
+			return errors.New("kafka: partitioner custom requires a PartitionFunc")
+		}
+	default:
+		return fmt.Errorf("kafka: unknown partitioner %q", o.Partitioner)
+	}
+	return nil
+}
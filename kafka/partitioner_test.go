@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Test_Murmur2_JavaVectors pins murmur2 to the Java client's Utils.murmur2.
+func Test_Murmur2_JavaVectors(t *testing.T) {
+	for in, want := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		if got := murmur2([]byte(in)); got != want {
+			t.Errorf("murmur2(%q)=%d want %d", in, got, want)
+		}
+	}
+}
+
+// Test_Partitioner_KeyedParity checks the keyed mappings against the native
+// partitioners they replace: murmur2/sticky = franz-go's (Java-compatible)
+// sticky key partitioner, hash = sarama's hash partitioner.
+func Test_Partitioner_KeyedParity(t *testing.T) {
+	const n = 12
+	kgoP := kgo.StickyKeyPartitioner(nil).ForTopic("t")
+	saramaP := sarama.NewHashPartitioner("t")
+	mm := newTopicPartitioner(Options{Partitioner: PartitionerMurmur2})
+	st := newTopicPartitioner(Options{Partitioner: PartitionerSticky})
+	hs := newTopicPartitioner(Options{Partitioner: PartitionerHash})
+	for i := range 500 {
+		key := []byte(fmt.Sprintf("user-%d", i))
+		want := int32(kgoP.Partition(&kgo.Record{Key: key}, n))
+		if got := mm.partition(Message{Key: key}, n); got != want {
+			t.Fatalf("murmur2 %s: %d want %d", key, got, want)
+		}
+		if got := st.partition(Message{Key: key}, n); got != want {
+			t.Fatalf("sticky %s: %d want %d", key, got, want)
+		}
+		want, _ = saramaP.Partition(&sarama.ProducerMessage{Key: sarama.ByteEncoder(key)}, n)
+		if got := hs.partition(Message{Key: key}, n); got != want {
+			t.Fatalf("hash %s: %d want %d", key, got, want)
+		}
+	}
+}
+
+func Test_Partitioner_Keyless(t *testing.T) {
+	rr := newTopicPartitioner(Options{Partitioner: PartitionerRoundRobin})
+	for i := range 9 {
+		// round_robin ignores keys entirely.
+		if got := rr.partition(Message{Key: []byte("same")}, 3); got != int32(i%3) {
+			t.Fatalf("round_robin #%d=%d", i, got)
+		}
+	}
+	hs := newTopicPartitioner(Options{Partitioner: PartitionerHash})
+	seen := map[int32]bool{}
+	for range 4 {
+		seen[hs.partition(Message{}, 4)] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("keyless hash spread over %d partitions, want 4", len(seen))
+	}
+
+	st := newTopicPartitioner(Options{Partitioner: PartitionerSticky, MaxBufferedRecords: 5})
+	first := st.partition(Message{}, 4)
+	for i := 1; i < 5; i++ {
+		if got := st.partition(Message{}, 4); got != first {
+			t.Fatalf("sticky moved after %d records", i)
+		}
+	}
+	if got := st.partition(Message{}, 4); got != (first+1)%4 {
+		t.Errorf("sticky next=%d want %d", got, (first+1)%4)
+	}
+	if st.requiresConsistency(Message{}) || !st.requiresConsistency(Message{Key: []byte("k")}) {
+		t.Error("sticky consistency: keyless must be movable, keyed must not")
+	}
+}
+
+func Test_Partitioner_ManualAndCustom(t *testing.T) {
+	mp := newTopicPartitioner(Options{Partitioner: PartitionerManual})
+	if got := mp.partition(Message{Partition: 2, Key: []byte("k")}, 4); got != 2 {
+		t.Errorf("manual=%d want 2", got)
+	}
+	o := applyOptions([]Option{WithPartitionFunc(func(m Message, n int32) int32 { return int32(len(m.Value)) % n })})
+	cp := newTopicPartitioner(o)
+	if got := cp.partition(Message{Value: []byte("hello")}, 4); got != 1 {
+		t.Errorf("custom=%d want 1", got)
+	}
+	if !cp.requiresConsistency(Message{}) || !mp.requiresConsistency(Message{}) {
+		t.Error("manual/custom must require consistency")
+	}
+}
+
+func Test_Options_ValidateProducing(t *testing.T) {
+	for name, c := range map[string]struct {
+		opts []Option
+		ok   bool
+	}{
+		"defaults":       {nil, true},
+		"zstd level":     {[]Option{WithCompression(CompressionZstd, 3)}, true},
+		"unknown codec":  {[]Option{WithCompression("brotli", 0)}, false},
+		"murmur2":        {[]Option{WithPartitioner(PartitionerMurmur2)}, true},
+		"unknown":        {[]Option{WithPartitioner("consistent")}, false},
+		"custom sans fn": {[]Option{WithPartitioner(PartitionerCustom)}, false},
+		"custom with fn": {[]Option{WithPartitionFunc(func(Message, int32) int32 { return 0 })}, true},
+	} {
+		o := applyOptions(append([]Option{WithBrokers("b:9092")}, c.opts...)).withDefaults()
+		if err := o.validate("producer"); (err == nil) != c.ok {
+			t.Errorf("%s: err=%v", name, err)
+		}
+	}
+	o := applyOptions(nil).withDefaults()
+	if o.Compression != CompressionNone || o.Partitioner != PartitionerHash {
+		t.Errorf("defaults: compression=%q partitioner=%q", o.Compression, o.Partitioner)
+	}
+}
+
+// Test_Producer_CompressionAndMurmur2 produces keyed records under every codec
+// with the murmur2 partitioner and checks each landed where the Java client
+// would put it and reads back intact.
+func Test_Producer_CompressionAndMurmur2(t *testing.T) {
+	const parts = 6
+	c, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(parts, "co-part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	addrs := c.ListenAddrs()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	want := map[string]int32{}
+	for _, codec := range []string{CompressionNone, CompressionGzip, CompressionSnappy, CompressionLZ4, CompressionZstd} {
+		p, err := NewSyncProducer(WithBrokers(addrs...), WithTopic("co-part"),
+			WithPartitioner(PartitionerMurmur2), WithCompression(codec, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 10 {
+			key := fmt.Sprintf("%s-%d", codec, i)
+			part, _, err := p.Send(ctx, Message{Key: []byte(key), Value: []byte(key)})
+			if err != nil {
+				t.Fatalf("%s: %v", codec, err)
+			}
+			java := (murmur2([]byte(key)) & 0x7fffffff) % parts
+			if part != java {
+				t.Errorf("%s: partition %d want %d", key, part, java)
+			}
+			want[key] = java
+		}
+		_ = p.Close()
+	}
+
+	cl, err := kgo.NewClient(kgo.SeedBrokers(addrs...), kgo.ConsumeTopics("co-part"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	for got := 0; got < len(want); {
+		fs := cl.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("read %d/%d records", got, len(want))
+		}
+		fs.EachRecord(func(r *kgo.Record) {
+			got++
+			if string(r.Value) != string(r.Key) || want[string(r.Key)] != r.Partition {
+				t.Errorf("record %s=%s on partition %d", r.Key, r.Value, r.Partition)
+			}
+		})
+	}
+}
+
+func Test_Producer_ManualPartition(t *testing.T) {
+	c, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(4, "manual"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	p, err := NewSyncProducer(WithBrokers(c.ListenAddrs()...), WithTopic("manual"), WithPartitioner(PartitionerManual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for _, want := range []int32{3, 0, 2} {
+		part, _, err := p.Send(context.Background(), Message{Partition: want, Key: []byte("k"), Value: []byte("v")})
+		if err != nil || part != want {
+			t.Errorf("partition=%d err=%v want %d", part, err, want)
+		}
+	}
+	if _, _, err := p.Send(context.Background(), Message{Partition: 9, Value: []byte("v")}); err == nil {
+		t.Error("out-of-range manual partition accepted")
+	}
+}
@@ -0,0 +1,172 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// RateLimiter paces consumption (WithConsumeRateLimit). Wait blocks until
+// one message may be handed to the handler, or ctx ends. The limiters of
+// github.com/v8fg/kit4go/limiter satisfy it.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// pauseSet is a consumer's paused topics and partitions (Pause / Resume).
+// As in franz-go, the two are independent: pausing a topic and resuming one
+// of its partitions leaves the topic paused, and resuming the topic leaves
+// its individually paused partitions paused. The zero value is ready to use.
+type pauseSet struct {
+	mu      sync.Mutex
+	topics  map[string]struct{}
+	parts   map[string]map[int32]struct{}
+	resumed chan struct{} // closed (and replaced) by every resume
+}
+
+// pause adds topic (all of it when partitions is empty) or its partitions.
+func (s *pauseSet) pause(topic string, partitions []int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(partitions) == 0 {
+		if s.topics == nil {
+			s.topics = make(map[string]struct{})
+		}
+		s.topics[topic] = struct{}{}
+		return
+	}
+	if s.parts == nil {
+		s.parts = make(map[string]map[int32]struct{})
+	}
+	ps := s.parts[topic]
+	if ps == nil {
+		ps = make(map[int32]struct{}, len(partitions))
+		s.parts[topic] = ps
+	}
+	for _, p := range partitions {
+		ps[p] = struct{}{}
+	}
+}
+
+// resume removes what pause added for the same arguments and wakes the
+// waiters.
+func (s *pauseSet) resume(topic string, partitions []int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(partitions) == 0 {
+		delete(s.topics, topic)
+	} else if ps := s.parts[topic]; ps != nil {
+		for _, p := range partitions {
+			delete(ps, p)
+		}
+		if len(ps) == 0 {
+			delete(s.parts, topic)
+		}
+	}
+	if s.resumed != nil {
+		close(s.resumed)
+		s.resumed = nil
+	}
+}
+
+// paused reports whether topic/partition is paused, by topic or partition.
+// A nil set pauses nothing.
+func (s *pauseSet) paused(topic string, partition int32) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pausedLocked(topic, partition)
+}
+
+func (s *pauseSet) pausedLocked(topic string, partition int32) bool {
+	if _, ok := s.topics[topic]; ok {
+		return true
+	}
+	_, ok := s.parts[topic][partition]
+	return ok
+}
+
+// wait blocks while topic/partition is paused. It returns ctx.Err() if ctx
+// ends first.
+func (s *pauseSet) wait(ctx context.Context, topic string, partition int32) error {
+	if s == nil {
+		return nil
+	}
+	for {
+		s.mu.Lock()
+		if !s.pausedLocked(topic, partition) {
+			s.mu.Unlock()
+			return nil
+		}
+		if s.resumed == nil {
+			s.resumed = make(chan struct{})
+		}
+		resumed := s.resumed
+		s.mu.Unlock()
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// snapshot returns the paused partitions (topic → sorted partitions) and the
+// sorted paused topics, each nil when empty.
+func (s *pauseSet) snapshot() (map[string][]int32, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var parts map[string][]int32
+	for topic, ps := range s.parts {
+		if parts == nil {
+			parts = make(map[string][]int32, len(s.parts))
+		}
+		list := make([]int32, 0, len(ps))
+		for p := range ps {
+			list = append(list, p)
+		}
+		slices.Sort(list)
+		parts[topic] = list
+	}
+	var topics []string
+	for topic := range s.topics {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+	return parts, topics
+}
+
+// firePause fires name ("pause" / "resume") once per partition, or once
+// with Partition -1 for a whole topic.
+func firePause(fire func(ConsumerEvent), name, topic string, partitions []int32) {
+	if len(partitions) == 0 {
+		fire(ConsumerEvent{Name: name, Msg: Message{Topic: topic, Partition: -1}})
+		return
+	}
+	for _, p := range partitions {
+		fire(ConsumerEvent{Name: name, Msg: Message{Topic: topic, Partition: p}})
+	}
+}
+
+// admit holds msg while its partition is paused (ps may be nil), then for a
+// token of lim (may be nil). It returns ctx.Err() if ctx ends first; a
+// limiter failure (e.g. a closed limiter) goes to onErr and lets msg through,
+// so a broken limiter does not stall the group.
+func admit(ctx context.Context, ps *pauseSet, lim RateLimiter, msg Message, onErr func(error)) error {
+	if err := ps.wait(ctx, msg.Topic, msg.Partition); err != nil {
+		return err
+	}
+	if lim == nil {
+		return nil
+	}
+	if err := lim.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		onErr(fmt.Errorf("kafka: consume rate limit: %w", err))
+	}
+	return nil
+}
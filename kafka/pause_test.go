@@ -0,0 +1,310 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_PauseSet(t *testing.T) {
+	var s pauseSet
+	s.pause("t", []int32{2, 1})
+	s.pause("u", nil)
+	if !s.paused("t", 1) || s.paused("t", 0) || !s.paused("u", 7) {
+		t.Fatal("paused() disagrees with pause()")
+	}
+	parts, topics := s.snapshot()
+	if !slices.Equal(parts["t"], []int32{1, 2}) || len(parts) != 1 || !slices.Equal(topics, []string{"u"}) {
+		t.Errorf("snapshot=%v %v", parts, topics)
+	}
+
+	// Topic and partition pauses are independent.
+	s.pause("t", nil)
+	s.resume("t", []int32{1, 2})
+	if !s.paused("t", 1) {
+		t.Error("resuming partitions lifted the topic pause")
+	}
+	s.pause("t", []int32{1})
+	s.resume("t", nil)
+	if !s.paused("t", 1) || s.paused("t", 2) {
+		t.Error("resuming the topic lifted a partition pause")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.wait(context.Background(), "t", 1) }()
+	select {
+	case <-done:
+		t.Fatal("wait returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.resume("t", []int32{1})
+	if err := <-done; err != nil {
+		t.Errorf("wait: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.wait(ctx, "u", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait on a cancelled ctx = %v", err)
+	}
+	s.resume("u", nil)
+	if parts, topics := s.snapshot(); parts != nil || topics != nil {
+		t.Errorf("all resumed, snapshot=%v %v", parts, topics)
+	}
+
+	var nilSet *pauseSet // franz-go passes none to admit
+	if nilSet.paused("t", 0) || nilSet.wait(ctx, "t", 0) != nil {
+		t.Error("nil set paused")
+	}
+}
+
+// pauseEvents collects the "pause" / "resume" events of a consumer.
+type pauseEvents struct {
+	mu     sync.Mutex
+	events []ConsumerEvent
+}
+
+func (p *pauseEvents) onEvent(e ConsumerEvent) {
+	if e.Name == "pause" || e.Name == "resume" {
+		p.mu.Lock()
+		p.events = append(p.events, e)
+		p.mu.Unlock()
+	}
+}
+
+func (p *pauseEvents) list() []ConsumerEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.events)
+}
+
+// testPauseResume pauses the partition, then the whole topic, of a running
+// group and checks that nothing produced meanwhile is delivered until Resume.
+func testPauseResume(t *testing.T, g ConsumerGroup, send func(string), topic string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ev := &pauseEvents{}
+	g.SetOnEvent(ev.onEvent)
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	received := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- g.Consume(ctx, []string{topic}, func(m Message) error {
+			mu.Lock()
+			got = append(got, string(m.Value))
+			mu.Unlock()
+			return nil
+		})
+	}()
+	send("a")
+	waitUntil(t, func() bool { return received() == 1 }, "first message")
+
+	held := func(pause, resume func(), want int) {
+		t.Helper()
+		pause()
+		send("held")
+		time.Sleep(300 * time.Millisecond)
+		if n := received(); n != want-1 {
+			t.Fatalf("delivered while paused: %d messages", n)
+		}
+		resume()
+		waitUntil(t, func() bool { return received() == want }, "delivery after Resume")
+	}
+	held(func() {
+		g.Pause(topic, 0)
+		if s := g.Snapshot(); !slices.Equal(s.Paused[topic], []int32{0}) || s.PausedTopics != nil {
+			t.Errorf("Paused=%v PausedTopics=%v", s.Paused, s.PausedTopics)
+		}
+	}, func() { g.Resume(topic, 0) }, 2)
+	held(func() {
+		g.Pause(topic)
+		if s := g.Snapshot(); s.Paused != nil || !slices.Equal(s.PausedTopics, []string{topic}) {
+			t.Errorf("Paused=%v PausedTopics=%v", s.Paused, s.PausedTopics)
+		}
+	}, func() { g.Resume(topic) }, 3)
+	if s := g.Snapshot(); s.Paused != nil || s.PausedTopics != nil {
+		t.Errorf("after Resume: Paused=%v PausedTopics=%v", s.Paused, s.PausedTopics)
+	}
+
+	want := []ConsumerEvent{
+		{Name: "pause", Msg: Message{Topic: topic, Partition: 0}},
+		{Name: "resume", Msg: Message{Topic: topic, Partition: 0}},
+		{Name: "pause", Msg: Message{Topic: topic, Partition: -1}},
+		{Name: "resume", Msg: Message{Topic: topic, Partition: -1}},
+	}
+	events := ev.list()
+	if len(events) != len(want) {
+		t.Fatalf("events=%+v", events)
+	}
+	for i, e := range events {
+		if e.Name != want[i].Name || e.Msg.Topic != topic || e.Msg.Partition != want[i].Msg.Partition {
+			t.Errorf("event %d = %s %s/%d, want %s %d", i, e.Name, e.Msg.Topic, e.Msg.Partition,
+				want[i].Name, want[i].Msg.Partition)
+		}
+	}
+	cancel()
+	<-done
+	mustNoErr(t, g.Close())
+}
+
+func Test_ConsumerGroup_PauseResume(t *testing.T) {
+	addrs := txnCluster(t, "pausable")
+	sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic("pausable"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+	g, err := NewConsumerGroup(WithBrokers(addrs...), WithGroupID("pause-g"),
+		WithConsumerOffsetInitial(OffsetOldest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPauseResume(t, g, func(v string) {
+		if _, _, err := sp.Send(context.Background(), Message{Value: []byte(v)}); err != nil {
+			t.Fatal(err)
+		}
+	}, "pausable")
+}
+
+func Test_InMemory_PauseResume(t *testing.T) {
+	c := NewInMemoryCluster(WithDefaultPartitions(1))
+	sp, err := c.NewSyncProducer(WithTopic("pausable"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := c.NewConsumerGroup(WithGroupID("pause-g"), WithConsumerOffsetInitial(OffsetOldest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPauseResume(t, g, func(v string) {
+		if _, _, err := sp.Send(context.Background(), Message{Value: []byte(v)}); err != nil {
+			t.Fatal(err)
+		}
+	}, "pausable")
+}
+
+// tokenLimiter hands out one Wait per token sent on tokens.
+type tokenLimiter struct {
+	tokens chan struct{}
+	err    error
+}
+
+func (l *tokenLimiter) Wait(ctx context.Context) error {
+	if l.err != nil {
+		return l.err
+	}
+	select {
+	case <-l.tokens:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func Test_ConsumeRateLimit(t *testing.T) {
+	c := NewInMemoryCluster(WithDefaultPartitions(2))
+	sp, err := c.NewSyncProducer(WithTopic("paced"), WithPartitioner(PartitionerRoundRobin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if _, _, err := sp.Send(context.Background(), Message{Value: []byte("v")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("paced", func(t *testing.T) {
+		lim := &tokenLimiter{tokens: make(chan struct{}, 5)}
+		g, err := c.NewConsumerGroup(WithGroupID("paced-g"), WithConsumerOffsetInitial(OffsetOldest),
+			WithConsumeRateLimit(lim))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- g.Consume(ctx, []string{"paced"}, func(Message) error { return nil }) }()
+		lim.tokens <- struct{}{}
+		lim.tokens <- struct{}{}
+		waitUntil(t, func() bool { return g.Metrics().Received == 2 }, "two tokens spent")
+		time.Sleep(100 * time.Millisecond)
+		if n := g.Metrics().Received; n != 2 {
+			t.Fatalf("received %d messages on 2 tokens", n)
+		}
+		for range 3 {
+			lim.tokens <- struct{}{}
+		}
+		waitUntil(t, func() bool { return g.Metrics().Acked == 5 }, "all acked")
+		cancel()
+		<-done
+		mustNoErr(t, g.Close())
+	})
+
+	t.Run("limiter error", func(t *testing.T) {
+		boom := errors.New("limiter closed")
+		g, err := c.NewConsumerGroup(WithGroupID("broken-g"), WithConsumerOffsetInitial(OffsetOldest),
+			WithConsumeRateLimit(&tokenLimiter{err: boom}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- g.Consume(ctx, []string{"paced"}, func(Message) error { return nil }) }()
+		// A failing limiter is reported and does not stall the group.
+		waitUntil(t, func() bool { return g.Metrics().Acked == 5 }, "all acked")
+		select {
+		case err := <-g.Errors():
+			if !errors.Is(err, boom) {
+				t.Errorf("Errors() = %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Error("limiter error not reported")
+		}
+		cancel()
+		<-done
+		mustNoErr(t, g.Close())
+	})
+}
+
+// countingLimiter admits everything and counts the Waits.
+type countingLimiter struct{ n atomic.Int64 }
+
+func (l *countingLimiter) Wait(context.Context) error { l.n.Add(1); return nil }
+
+func Test_ConsumerGroup_ConsumeRateLimit(t *testing.T) {
+	addrs := txnCluster(t, "paced")
+	sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic("paced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNoErr(t, sp.SendBatch(context.Background(), []Message{{Value: []byte("a")}, {Value: []byte("b")}, {Value: []byte("c")}}))
+	mustNoErr(t, sp.Close())
+	lim := &countingLimiter{}
+	g, err := NewConsumerGroup(WithBrokers(addrs...), WithGroupID("paced-g"),
+		WithConsumerOffsetInitial(OffsetOldest), WithConsumeRateLimit(lim))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- g.ConsumeBatch(ctx, []string{"paced"}, func([]Message) error { return nil })
+	}()
+	waitUntil(t, func() bool { return g.Metrics().Acked == 3 }, "all acked")
+	if n := lim.n.Load(); n != 3 {
+		t.Errorf("limiter waited %d times for 3 messages", n)
+	}
+	cancel()
+	<-done
+	mustNoErr(t, g.Close())
+}
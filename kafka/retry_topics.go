@@ -0,0 +1,209 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryTopic is one delay tier of a ConsumerGroup's failure routing: a message
+// whose handler failed is republished here and handed to the handler again no
+// earlier than Delay after it failed.
+type RetryTopic struct {
+	Topic string        `json:"topic" mapstructure:"topic"`
+	Delay time.Duration `json:"delay" mapstructure:"delay"`
+}
+
+// Headers set on a routed message. The original-* headers are written on the
+// first hop and carried unchanged through later tiers and into the DLQ.
+const (
+	HeaderOriginalTopic     = "kafka-original-topic"
+	HeaderOriginalPartition = "kafka-original-partition"
+	HeaderOriginalOffset    = "kafka-original-offset"
+	HeaderError             = "kafka-error"      // the handler error of the latest attempt
+	HeaderAttempt           = "kafka-attempt"    // failed attempts so far (1 on the first hop)
+	HeaderNotBefore         = "kafka-not-before" // unix millis before which a tier message is not handled
+)
+
+// failureRouter republishes messages whose handler failed: attempt n (1-based)
+// goes to tiers[n-1] while tiers remain, then to the dead-letter topic. Shared
+// by both backends' consumer groups; it owns the SyncProducer it publishes
+// through.
+type failureRouter struct {
+	tiers []RetryTopic
+	dlq   string
+	prod  SyncProducer
+}
+
+// newFailureRouter returns nil when o configures no routing. The router's
+// producer reuses the consumer's brokers/version/security with acks=all: a
+// routed message is committed on the source topic, so losing it would lose
+// data. newProd builds it (NewSyncProducer, or an InMemoryCluster's).
+func newFailureRouter(o Options, newProd func(...Option) (SyncProducer, error)) (*failureRouter, error) {
+	if len(o.RetryTopics) == 0 && o.DeadLetterTopic == "" {
+		return nil, nil
+	}
+	prod, err := newProd(WithBrokers(o.Brokers...), WithVersion(o.Version), WithAcks(AcksAll),
+		WithName(nameOr(o.Name, o.GroupID)+"-router"),
+		func(p *Options) { p.TLS, p.SASL = o.TLS, o.SASL })
+	if err != nil {
+		return nil, err
+	}
+	return &failureRouter{tiers: o.RetryTopics, dlq: o.DeadLetterTopic, prod: prod}, nil
+}
+
+// topics returns the retry-tier topics a consumer group must also consume.
+func (r *failureRouter) topics() []string {
+	if r == nil {
+		return nil
+	}
+	ts := make([]string, len(r.tiers))
+	for i, t := range r.tiers {
+		ts[i] = t.Topic
+	}
+	return ts
+}
+
+// route republishes msg after its handler failed with cause, reporting
+// whether it went to the dead-letter topic; errNoRoute means the attempts
+// are exhausted and no DLQ is configured (the caller NACKs as before).
+func (r *failureRouter) route(ctx context.Context, msg Message, cause error) (dlq bool, err error) {
+	attempt := headerInt(msg.Headers, HeaderAttempt) + 1
+	var (
+		topic string
+		delay time.Duration
+	)
+	switch {
+	case int(attempt) <= len(r.tiers):
+		tier := r.tiers[attempt-1]
+		topic, delay = tier.Topic, tier.Delay
+	case r.dlq != "":
+		topic, dlq = r.dlq, true
+	default:
+		return false, errNoRoute
+	}
+	out := Message{Topic: topic, Key: msg.Key, Value: msg.Value, Headers: routedHeaders(msg, cause, attempt, delay, dlq)}
+	if _, _, err := r.prod.Send(ctx, out); err != nil {
+		return dlq, fmt.Errorf("kafka: route to %s: %w", topic, err)
+	}
+	return dlq, nil
+}
+
+func (r *failureRouter) close() error {
+	if r == nil {
+		return nil
+	}
+	return r.prod.Close()
+}
+
+// errNoRoute is route's "nowhere to go" result.
+var errNoRoute = errors.New("kafka: no retry tier or dead-letter topic left")
+
+// routedHeaders copies msg's user headers, keeps the original-* headers of an
+// earlier hop (or writes them on the first), and sets error/attempt and, for a
+// retry tier, the not-before time.
+func routedHeaders(msg Message, cause error, attempt int64, delay time.Duration, dlq bool) []Header {
+	first := !hasHeader(msg.Headers, HeaderOriginalTopic)
+	hs := make([]Header, 0, len(msg.Headers)+6)
+	for _, h := range msg.Headers {
+		switch string(h.Key) {
+		case HeaderError, HeaderAttempt, HeaderNotBefore:
+			continue
+		}
+		hs = append(hs, h)
+	}
+	if first {
+		hs = append(hs,
+			Header{Key: []byte(HeaderOriginalTopic), Value: []byte(msg.Topic)},
+			Header{Key: []byte(HeaderOriginalPartition), Value: strconv.AppendInt(nil, int64(msg.Partition), 10)},
+			Header{Key: []byte(HeaderOriginalOffset), Value: strconv.AppendInt(nil, msg.Offset, 10)},
+		)
+	}
+	hs = append(hs,
+		Header{Key: []byte(HeaderError), Value: []byte(cause.Error())},
+		Header{Key: []byte(HeaderAttempt), Value: strconv.AppendInt(nil, attempt, 10)},
+	)
+	if !dlq {
+		// round up so millisecond truncation never makes the wait shorter
+		due := time.Now().Add(delay + time.Millisecond - 1).UnixMilli()
+		hs = append(hs, Header{Key: []byte(HeaderNotBefore), Value: strconv.AppendInt(nil, due, 10)})
+	}
+	return hs
+}
+
+// waitDue blocks until a retry-tier message's not-before time (no-op for
+// other messages). It returns ctx.Err() if ctx ends first; the message must
+// then be left unacknowledged so the next session picks it up.
+func waitDue(ctx context.Context, msg Message) error {
+	due := headerInt(msg.Headers, HeaderNotBefore)
+	if due == 0 {
+		return nil
+	}
+	d := time.Until(time.UnixMilli(due))
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// headerInt returns the integer value of header key (0 if absent/invalid).
+func headerInt(hs []Header, key string) int64 {
+	for _, h := range hs {
+		if string(h.Key) == key {
+			n, _ := strconv.ParseInt(string(h.Value), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+func hasHeader(hs []Header, key string) bool {
+	for _, h := range hs {
+		if string(h.Key) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRouting checks the RetryTopics / DeadLetterTopic options.
+func validateRouting(o Options) error {
+	seen := make(map[string]bool, len(o.RetryTopics)+1)
+	for _, t := range o.RetryTopics {
+		if t.Topic == "" {
+			return errors.New("kafka: retry topic name required")
+		}
+		if t.Delay < 0 {
+			return fmt.Errorf("kafka: retry topic %s: negative delay", t.Topic)
+		}
+		if seen[t.Topic] {
+			return fmt.Errorf("kafka: retry topic %s listed twice", t.Topic)
+		}
+		seen[t.Topic] = true
+	}
+	if seen[o.DeadLetterTopic] {
+		return fmt.Errorf("kafka: dead-letter topic %s is also a retry topic", o.DeadLetterTopic)
+	}
+	return nil
+}
+
+// mergeTopics appends the extra topics missing from topics.
+func mergeTopics(topics, extra []string) []string {
+	out := append([]string(nil), topics...)
+	for _, t := range extra {
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
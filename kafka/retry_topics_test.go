@@ -0,0 +1,151 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Retry-topic routing runs unchanged on both backends (no build tag) against
+// the kfake broker of txn_test.go.
+
+func Test_RetryTopics_Validate(t *testing.T) {
+	base := []Option{WithBrokers("x:9092"), WithGroupID("g")}
+	for name, opts := range map[string][]Option{
+		"empty topic":    {WithRetryTopics(RetryTopic{Delay: time.Second})},
+		"negative delay": {WithRetryTopics(RetryTopic{Topic: "r", Delay: -1})},
+		"duplicate":      {WithRetryTopics(RetryTopic{Topic: "r"}, RetryTopic{Topic: "r"})},
+		"dlq is a tier":  {WithRetryTopics(RetryTopic{Topic: "r"}), WithDeadLetter("r")},
+	} {
+		if _, err := NewConsumerGroup(append(base, opts...)...); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func Test_RoutedHeaders(t *testing.T) {
+	src := Message{Topic: "orders", Partition: 2, Offset: 41,
+		Headers: []Header{{Key: []byte("trace"), Value: []byte("t1")}}}
+	first := Message{Topic: "r1", Headers: routedHeaders(src, errBoom, 1, time.Minute, false)}
+	if headerInt(first.Headers, HeaderAttempt) != 1 || headerInt(first.Headers, HeaderOriginalOffset) != 41 ||
+		headerInt(first.Headers, HeaderOriginalPartition) != 2 || !hasHeader(first.Headers, "trace") {
+		t.Fatalf("first hop headers=%v", first.Headers)
+	}
+	if due := headerInt(first.Headers, HeaderNotBefore); time.Until(time.UnixMilli(due)) < 50*time.Second {
+		t.Errorf("not-before %d is not ~1m ahead", due)
+	}
+	// a later hop keeps the original-* headers and replaces the rest.
+	first.Offset = 7
+	last := routedHeaders(first, errors.New("again"), 2, 0, true)
+	if headerValue(last, HeaderOriginalTopic) != "orders" || headerInt(last, HeaderOriginalOffset) != 41 ||
+		headerValue(last, HeaderError) != "again" || headerInt(last, HeaderAttempt) != 2 || hasHeader(last, HeaderNotBefore) {
+		t.Errorf("dlq hop headers=%v", last)
+	}
+	if len(last) != len(first.Headers)-1 { // not-before dropped, nothing duplicated
+		t.Errorf("dlq hop has %d headers, want %d", len(last), len(first.Headers)-1)
+	}
+}
+
+func headerValue(hs []Header, key string) string {
+	for _, h := range hs {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func Test_ConsumerGroup_RetryTopicsThenDeadLetter(t *testing.T) {
+	addrs := txnCluster(t, "orders", "orders-retry-a", "orders-retry-b", "orders-dlq")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNoErr(t, sp.SendBatch(ctx, []Message{{Value: []byte("ok")}, {Value: []byte("bad"), Key: []byte("k")}}))
+	mustNoErr(t, sp.Close())
+
+	const delayA, delayB = 200 * time.Millisecond, 400 * time.Millisecond
+	g, err := NewConsumerGroup(WithBrokers(addrs...), WithGroupID("orders-svc"), WithConsumerOffsetInitial(OffsetOldest),
+		WithRetryTopics(RetryTopic{"orders-retry-a", delayA}, RetryTopic{"orders-retry-b", delayB}),
+		WithDeadLetter("orders-dlq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	var mu sync.Mutex
+	var events []string
+	g.SetOnEvent(func(e ConsumerEvent) {
+		mu.Lock()
+		events = append(events, e.Name)
+		mu.Unlock()
+	})
+
+	var attempts []time.Time
+	done := make(chan error, 1)
+	go func() {
+		done <- g.Consume(ctx, []string{"orders"}, func(m Message) error {
+			if string(m.Value) != "bad" {
+				return nil
+			}
+			mu.Lock()
+			attempts = append(attempts, time.Now())
+			mu.Unlock()
+			return errBoom
+		})
+	}()
+	waitUntil(t, func() bool { return g.Metrics().DeadLettered == 1 }, "dead-lettered")
+
+	m := g.Metrics()
+	if m.Acked != 1 || m.Failed != 3 || m.Retried != 2 || m.DeadLettered != 1 {
+		t.Errorf("Metrics=%+v", m)
+	}
+	mu.Lock()
+	if len(attempts) != 3 || attempts[1].Sub(attempts[0]) < delayA || attempts[2].Sub(attempts[1]) < delayB {
+		t.Errorf("attempts=%v: tier delays not respected", attempts)
+	}
+	if !contains(events, "retry") || !contains(events, "dead_letter") || contains(events, "nack") {
+		t.Errorf("events=%v", events)
+	}
+	mu.Unlock()
+
+	cl, err := kgo.NewClient(kgo.SeedBrokers(addrs...), kgo.ConsumeTopics("orders-dlq"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	var dl *kgo.Record
+	for dl == nil && ctx.Err() == nil {
+		cl.PollFetches(ctx).EachRecord(func(r *kgo.Record) { dl = r })
+	}
+	if dl == nil {
+		t.Fatal("no dead-letter record")
+	}
+	got := Message{Key: dl.Key, Value: dl.Value}
+	for _, h := range dl.Headers {
+		got.Headers = append(got.Headers, Header{Key: []byte(h.Key), Value: h.Value})
+	}
+	if string(got.Value) != "bad" || string(got.Key) != "k" || headerValue(got.Headers, HeaderOriginalTopic) != "orders" ||
+		headerInt(got.Headers, HeaderOriginalOffset) != 1 || headerValue(got.Headers, HeaderOriginalPartition) != "0" ||
+		headerValue(got.Headers, HeaderError) != errBoom.Error() || headerInt(got.Headers, HeaderAttempt) != 3 {
+		t.Errorf("dead letter=%+v", got)
+	}
+
+	// the source and tier offsets are committed past the routed message
+	// (flushed when the group leaves).
+	cancel()
+	<-done
+	mustNoErr(t, g.Close())
+	for topic, want := range map[string]int64{"orders": 2, "orders-retry-a": 1, "orders-retry-b": 1} {
+		if got := committedOffset(t, addrs, "orders-svc", topic); got != want {
+			t.Errorf("%s committed offset=%d want %d", topic, got, want)
+		}
+	}
+}
@@ -3,7 +3,11 @@
 package kafka
 
 import (
+	"context"
+
 	"github.com/IBM/sarama"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
 )
 
 // backendName identifies the underlying client library for Backend()/monitoring.
@@ -46,10 +50,17 @@ func buildSaramaConfig(o Options, sync bool) (*sarama.Config, error) {
 		cfg.Producer.Retry.Max = 0
 	}
 	cfg.ChannelBufferSize = o.ChannelBufferSize
-	// partitioner defaults to hash-by-key (same-key -> same-partition, ordered
-	// consumption); empty key -> round-robin. sarama.HashPartitioner is the
-	// sensible default for request-scoped ordering.
-	cfg.Producer.Partitioner = sarama.NewHashPartitioner
+	// partitioner: the shared topicPartitioner (default hash-by-key, the same
+	// FNV-1a mapping sarama.HashPartitioner uses), so both backends place a
+	// key on the same partition.
+	cfg.Producer.Partitioner = func(string) sarama.Partitioner {
+		return saramaPartitioner{newTopicPartitioner(o)}
+	}
+	cfg.Producer.Compression = saramaCompression(o.Compression)
+	cfg.Producer.CompressionLevel = sarama.CompressionLevelDefault
+	if o.CompressionLevel != 0 {
+		cfg.Producer.CompressionLevel = o.CompressionLevel
+	}
 
 	if sync {
 		// Sync: per-send blocking, no batching. Flush off → SendMessage flushes
@@ -80,9 +91,74 @@ func buildSaramaConfig(o Options, sync bool) (*sarama.Config, error) {
 	}
 	cfg.Consumer.Offsets.Initial = mapOffsetInitial(o.ConsumerOffsetInitial)
 
+	if err := applySaramaSecurity(cfg, o); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
+// applySaramaSecurity maps Options.TLS / Options.SASL onto cfg.Net (the
+// franz-go counterpart is kgoSecurityOpts).
+func applySaramaSecurity(cfg *sarama.Config, o Options) error {
+	tc, err := o.TLS.build()
+	if err != nil {
+		return err
+	}
+	if tc != nil {
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tc
+	}
+	if s := o.SASL; s != nil {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.Handshake = true
+		cfg.Net.SASL.Version = sarama.SASLHandshakeV1
+		cfg.Net.SASL.User = s.Username
+		cfg.Net.SASL.Password = s.Password
+		cfg.Net.SASL.Mechanism = sarama.SASLMechanism(s.Mechanism)
+		switch s.Mechanism {
+		case SASLScramSHA256:
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &saramaSCRAM{mech: scram.Sha256}
+			}
+		case SASLScramSHA512:
+			cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &saramaSCRAM{mech: scram.Sha512}
+			}
+		}
+	}
+	return nil
+}
+
+// saramaSCRAM adapts franz-go's SCRAM implementation (already a module
+// dependency) to sarama.SCRAMClient, so both backends run the same SCRAM code.
+type saramaSCRAM struct {
+	mech  func(func(context.Context) (scram.Auth, error)) sasl.Mechanism
+	sess  sasl.Session
+	first []byte // client-first message, returned by the first Step
+	done  bool
+}
+
+func (c *saramaSCRAM) Begin(user, password, authzID string) error {
+	auth := scram.Auth{User: user, Pass: password, Zid: authzID}
+	m := c.mech(func(context.Context) (scram.Auth, error) { return auth, nil })
+	sess, first, err := m.Authenticate(context.Background(), "")
+	c.sess, c.first, c.done = sess, first, false
+	return err
+}
+
+func (c *saramaSCRAM) Step(challenge string) (string, error) {
+	if c.first != nil {
+		first := c.first
+		c.first = nil
+		return string(first), nil
+	}
+	done, resp, err := c.sess.Challenge([]byte(challenge))
+	c.done = done
+	return string(resp), err
+}
+
+func (c *saramaSCRAM) Done() bool { return c.done }
+
 // saramaAcks maps Options.Acks to sarama's RequiredAcks. Empty/unknown → leader
 // (acks=1, the package default — throughput-first, matches the historical sarama
 // default and unifies both backends on leader unless AcksAll/AcksNone is set).
@@ -97,6 +173,64 @@ func saramaAcks(a string) sarama.RequiredAcks {
 	}
 }
 
+// saramaCompression maps Options.Compression to sarama's codec; "" / none →
+// no compression.
+func saramaCompression(c string) sarama.CompressionCodec {
+	switch c {
+	case CompressionGzip:
+		return sarama.CompressionGZIP
+	case CompressionSnappy:
+		return sarama.CompressionSnappy
+	case CompressionLZ4:
+		return sarama.CompressionLZ4
+	case CompressionZstd:
+		return sarama.CompressionZSTD
+	default:
+		return sarama.CompressionNone
+	}
+}
+
+// saramaPartitioner adapts topicPartitioner to sarama.Partitioner.
+type saramaPartitioner struct{ p *topicPartitioner }
+
+func (s saramaPartitioner) Partition(pm *sarama.ProducerMessage, n int32) (int32, error) {
+	part := s.p.partition(producerMessageView(pm), n)
+	if part < 0 || part >= n {
+		return -1, sarama.ErrInvalidPartition
+	}
+	return part, nil
+}
+
+// RequiresConsistency is only sarama's fallback; MessageRequiresConsistency
+// answers per message.
+func (s saramaPartitioner) RequiresConsistency() bool {
+	return s.p.kind != PartitionerRoundRobin
+}
+
+// MessageRequiresConsistency lets sarama route keyless records around
+// unavailable partitions (sarama.DynamicConsistencyPartitioner).
+func (s saramaPartitioner) MessageRequiresConsistency(pm *sarama.ProducerMessage) bool {
+	return s.p.requiresConsistency(producerMessageView(pm))
+}
+
+// producerMessageView rebuilds the Message fields a partitioner may look at.
+func producerMessageView(pm *sarama.ProducerMessage) Message {
+	m := Message{Topic: pm.Topic, Partition: pm.Partition}
+	if pm.Key != nil {
+		m.Key, _ = pm.Key.Encode()
+	}
+	if pm.Value != nil {
+		m.Value, _ = pm.Value.Encode()
+	}
+	if n := len(pm.Headers); n > 0 {
+		m.Headers = make([]Header, n)
+		for i, h := range pm.Headers {
+			m.Headers[i] = Header{Key: h.Key, Value: h.Value}
+		}
+	}
+	return m
+}
+
 // mapOffsetInitial maps the package offset sentinels to sarama's. A concrete
 // int64 >= 0 is a valid absolute offset for a partition consumer (sarama accepts
 // it directly); the sentinels map to sarama's own.
@@ -110,3 +244,17 @@ func mapOffsetInitial(o int64) int64 {
 		return o
 	}
 }
+
+// applySaramaTxn turns cfg into a transactional producer config: sarama
+// requires the idempotent producer (acks=all, one in-flight request per
+// broker, retries > 0) underneath Producer.Transaction.ID.
+func applySaramaTxn(cfg *sarama.Config, o Options) {
+	cfg.Producer.Idempotent = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Transaction.ID = o.TransactionalID
+	cfg.Producer.Transaction.Timeout = o.TransactionTimeout
+	cfg.Net.MaxOpenRequests = 1
+	if cfg.Producer.Retry.Max < 1 {
+		cfg.Producer.Retry.Max = 1
+	}
+}
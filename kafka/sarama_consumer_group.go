@@ -4,6 +4,7 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -24,6 +25,12 @@ type saramaConsumerGroup struct {
 	cfg     *sarama.Config
 	factory consumerGroupFactory
 	cg      sarama.ConsumerGroup
+	router  *failureRouter // nil unless WithRetryTopics / WithDeadLetter
+	lag     *lagTracker
+	paused  pauseSet
+	// claims is the running session's assignment, for pausing a whole topic
+	// (sarama pauses partition consumers only).
+	claims atomic.Pointer[map[string][]int32]
 
 	mu     sync.Mutex
 	closed bool
@@ -40,6 +47,9 @@ type saramaConsumerGroup struct {
 	recovered atomic.Uint64 // consumer handler panics recovered (observable; L5)
 	rebalance atomic.Uint64
 	bytes     atomic.Uint64
+	retried   atomic.Uint64
+	deadLet   atomic.Uint64
+	batches   batchStats
 
 	onEvent atomic.Pointer[func(ConsumerEvent)]
 }
@@ -63,11 +73,17 @@ func newSaramaConsumerGroup(o Options, factory consumerGroupFactory) (*saramaCon
 	if factory == nil {
 		factory = sarama.NewConsumerGroup
 	}
+	router, err := newFailureRouter(o, NewSyncProducer)
+	if err != nil {
+		return nil, err
+	}
 	cg, err := factory(o.Brokers, o.GroupID, cfg)
 	if err != nil {
+		_ = router.close()
 		return nil, err
 	}
-	s := &saramaConsumerGroup{opts: o, cfg: cfg, factory: factory, cg: cg}
+	s := &saramaConsumerGroup{opts: o, cfg: cfg, factory: factory, cg: cg, router: router}
+	s.lag = newLagTracker(o.LagThreshold, s.fire)
 	go s.drainErrors()
 	return s, nil
 }
@@ -90,9 +106,20 @@ func (s *saramaConsumerGroup) pushErr(err error) {
 
 // Consume subscribes to topics and invokes handler per message, in a loop that
 // survives server-side rebalances (the session is recreated automatically by
-// re-calling sarama's Consume). Returns ctx.Err() when ctx is cancelled, or a
+// re-calling sarama's Consume). The retry-tier topics of WithRetryTopics are
+// subscribed alongside topics. Returns ctx.Err() when ctx is cancelled, or a
 // non-nil error on a fatal consume failure.
 func (s *saramaConsumerGroup) Consume(ctx context.Context, topics []string, handler MessageHandler) error {
+	return s.consume(ctx, topics, &cgHandler{parent: s, handler: handler})
+}
+
+// ConsumeBatch is Consume with a BatchMessageHandler; each claim (partition)
+// builds its own batches.
+func (s *saramaConsumerGroup) ConsumeBatch(ctx context.Context, topics []string, handler BatchMessageHandler) error {
+	return s.consume(ctx, topics, &cgHandler{parent: s, batch: handler})
+}
+
+func (s *saramaConsumerGroup) consume(ctx context.Context, topics []string, h *cgHandler) error {
 	s.mu.Lock()
 	if s.closed {
 		s.mu.Unlock()
@@ -100,7 +127,8 @@ func (s *saramaConsumerGroup) Consume(ctx context.Context, topics []string, hand
 	}
 	s.mu.Unlock()
 
-	h := &cgHandler{parent: s, handler: handler}
+	topics = mergeTopics(topics, s.router.topics())
+	defer s.lag.run(ctx, s.opts.LagInterval, nil)()
 	for {
 		if err := ctx.Err(); err != nil {
 			return err
@@ -119,6 +147,53 @@ func (s *saramaConsumerGroup) Consume(ctx context.Context, topics []string, hand
 	}
 }
 
+// Pause pauses the partitions' sarama consumers (the claimed partitions of
+// topic when none are given); a claim of a paused partition re-pauses its
+// consumer when a new session starts.
+func (s *saramaConsumerGroup) Pause(topic string, partitions ...int32) {
+	s.paused.pause(topic, partitions)
+	if ps := s.claimed(topic, partitions); len(ps) > 0 {
+		s.cg.Pause(map[string][]int32{topic: ps})
+	}
+	firePause(s.fire, "pause", topic, partitions)
+}
+
+// Resume resumes the sarama consumers of the partitions that are no longer
+// paused by topic or partition.
+func (s *saramaConsumerGroup) Resume(topic string, partitions ...int32) {
+	s.paused.resume(topic, partitions)
+	var ps []int32
+	for _, p := range s.claimed(topic, partitions) {
+		if !s.paused.paused(topic, p) {
+			ps = append(ps, p)
+		}
+	}
+	if len(ps) > 0 {
+		s.cg.Resume(map[string][]int32{topic: ps})
+	}
+	firePause(s.fire, "resume", topic, partitions)
+}
+
+// claimed returns partitions, or when empty topic's partitions in the
+// running session.
+func (s *saramaConsumerGroup) claimed(topic string, partitions []int32) []int32 {
+	if len(partitions) > 0 {
+		return partitions
+	}
+	if claims := s.claims.Load(); claims != nil {
+		return (*claims)[topic]
+	}
+	return nil
+}
+
+// admit gates a claimed message on Pause and WithConsumeRateLimit.
+func (s *saramaConsumerGroup) admit(ctx context.Context, msg Message) error {
+	return admit(ctx, &s.paused, s.opts.ConsumeRateLimit, msg, func(err error) {
+		s.fire(ConsumerEvent{Name: "error", Msg: msg, Err: err})
+		s.pushErr(err)
+	})
+}
+
 // Errors returns a channel of background errors. It is safe to ignore.
 func (s *saramaConsumerGroup) Errors() <-chan error {
 	s.errChOnce.Do(func() { s.errCh = make(chan error, 16) })
@@ -134,19 +209,27 @@ func (s *saramaConsumerGroup) Close() error {
 	s.closed = true
 	s.mu.Unlock()
 	err := s.cg.Close()
+	if rerr := s.router.close(); err == nil {
+		err = rerr
+	}
 	s.fire(ConsumerEvent{Name: "close"})
 	return err
 }
 
 func (s *saramaConsumerGroup) Metrics() ConsumerMetrics {
-	return ConsumerMetrics{
+	m := ConsumerMetrics{
 		Received:  s.received.Load(),
 		Acked:     s.acked.Load(),
 		Failed:    s.failed.Load(),
 		Recovered: s.recovered.Load(),
 		Rebalance: s.rebalance.Load(),
 		Bytes:     s.bytes.Load(),
+
+		Retried:      s.retried.Load(),
+		DeadLettered: s.deadLet.Load(),
 	}
+	s.batches.fill(&m)
+	return m
 }
 
 // Recovered returns the total number of consumer-handler panics recovered since
@@ -156,11 +239,17 @@ func (s *saramaConsumerGroup) Metrics() ConsumerMetrics {
 func (s *saramaConsumerGroup) Recovered() uint64 { return s.recovered.Load() }
 
 func (s *saramaConsumerGroup) Snapshot() ConsumerSnapshot {
+	lag, total := s.lag.snapshot()
+	paused, pausedTopics := s.paused.snapshot()
 	return ConsumerSnapshot{
 		Name:            s.Name(),
 		Backend:         s.Backend(),
 		Timestamp:       time.Now().UTC(),
 		ConsumerMetrics: s.Metrics(),
+		Lag:             lag,
+		TotalLag:        total,
+		Paused:          paused,
+		PausedTopics:    pausedTopics,
 	}
 }
 
@@ -170,6 +259,31 @@ func (s *saramaConsumerGroup) bumpReceived(valueLen int) {
 	s.bytes.Add(uint64(valueLen))
 }
 
+// routeFailure republishes a failed message through the router (if any) and
+// reports whether it was routed, in which case the caller marks it consumed.
+// A failed republish is surfaced as an "error" and the message NACKed.
+func (s *saramaConsumerGroup) routeFailure(ctx context.Context, msg Message, cause error) bool {
+	if s.router == nil {
+		return false
+	}
+	dlq, err := s.router.route(ctx, msg, cause)
+	switch {
+	case errors.Is(err, errNoRoute):
+		return false
+	case err != nil:
+		s.fire(ConsumerEvent{Name: "error", Msg: msg, Err: err})
+		s.pushErr(err)
+		return false
+	case dlq:
+		s.deadLet.Add(1)
+		s.fire(ConsumerEvent{Name: "dead_letter", Msg: msg, Err: cause})
+	default:
+		s.retried.Add(1)
+		s.fire(ConsumerEvent{Name: "retry", Msg: msg, Err: cause})
+	}
+	return true
+}
+
 func (s *saramaConsumerGroup) Name() string { return nameOr(s.opts.Name, s.opts.GroupID) }
 
 func (s *saramaConsumerGroup) Backend() string { return backendName }
@@ -4,6 +4,7 @@ package kafka
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/IBM/sarama"
 )
@@ -11,15 +12,17 @@ import (
 // toSaramaProducerMessage maps a library Message to a sarama ProducerMessage.
 // Topic falls back to defTopic (the Options.Topic) when msg.Topic is empty, so a
 // producer constructed WithTopic can Send messages that omit Topic. Key/Value
-// are passed through; Headers are copied. Partition/Offset/Timestamp are
-// broker-assigned and therefore ignored on the produce path.
+// are passed through; Headers are copied. Partition is carried for
+// PartitionerManual (any other partitioner overwrites it); Offset/Timestamp
+// are broker-assigned and therefore ignored on the produce path.
 func toSaramaProducerMessage(msg Message, defTopic string) *sarama.ProducerMessage {
 	topic := msg.Topic
 	if topic == "" {
 		topic = defTopic
 	}
 	pm := &sarama.ProducerMessage{
-		Topic: topic,
+		Topic:     topic,
+		Partition: msg.Partition,
 	}
 	if len(msg.Key) > 0 {
 		pm.Key = sarama.ByteEncoder(msg.Key)
@@ -68,14 +71,38 @@ func fromSaramaConsumerMessage(cm *sarama.ConsumerMessage) Message {
 type cgHandler struct {
 	parent  *saramaConsumerGroup
 	handler MessageHandler
+	batch   BatchMessageHandler // set instead of handler by ConsumeBatch
 }
 
 func (h *cgHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
 func (h *cgHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
 
 func (h *cgHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	lag := h.parent.lag.partition(claim.Topic(), claim.Partition())
+	defer h.parent.lag.drop(claim.Topic(), claim.Partition()) // the claim ends with the session
+	lag.watch(claim.HighWaterMarkOffset)
+	lag.start(claim.InitialOffset()) // the committed offset, when there is one
+	// Record the session's assignment for a whole-topic Pause; the claim's
+	// partition consumer is new, so carry a pause over from the last session.
+	claims := sess.Claims()
+	h.parent.claims.Store(&claims)
+	if h.parent.paused.paused(claim.Topic(), claim.Partition()) {
+		h.parent.cg.Pause(map[string][]int32{claim.Topic(): {claim.Partition()}})
+	}
+	if h.batch != nil {
+		return h.consumeBatches(sess, claim, lag)
+	}
 	for cm := range claim.Messages() {
 		msg := fromSaramaConsumerMessage(cm)
+		lag.seen(cm.Offset)
+		// A retry-tier message is held until its not-before time; a session
+		// that ends meanwhile leaves it unmarked for the next owner.
+		if err := waitDue(sess.Context(), msg); err != nil {
+			return nil
+		}
+		if err := h.parent.admit(sess.Context(), msg); err != nil {
+			return nil
+		}
 		h.parent.bumpReceived(len(cm.Value))
 		h.parent.fire(ConsumerEvent{Name: "message", Msg: msg})
 		// safeHandlerCall runs the user handler with panic recovery: a panicking
@@ -86,16 +113,105 @@ func (h *cgHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.
 		// goroutine and the whole process.
 		if err := h.safeHandlerCall(msg); err != nil {
 			h.parent.failed.Add(1)
+			if h.parent.routeFailure(sess.Context(), msg, err) {
+				sess.MarkMessage(cm, "") // republished: move past it
+				lag.done(cm.Offset)
+				continue
+			}
 			h.parent.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
 			continue // NACK: do not MarkMessage; re-delivered next session
 		}
 		sess.MarkMessage(cm, "")
+		lag.done(cm.Offset)
 		h.parent.acked.Add(1)
 		h.parent.fire(ConsumerEvent{Name: "ack", Msg: msg})
 	}
 	return nil
 }
 
+// consumeBatches is ConsumeClaim for a BatchMessageHandler: it collects up
+// to the ConsumeBatchMax* bounds and ACKs (marks) or NACKs the batch as a
+// whole. A batch still filling when the session ends is dropped unmarked, so
+// the partition's next owner re-delivers it.
+func (h *cgHandler) consumeBatches(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim, lag *partitionLag) error {
+	o := h.parent.opts
+	var (
+		cms     []*sarama.ConsumerMessage
+		msgs    []Message
+		size    int
+		timer   *time.Timer
+		timeout <-chan time.Time
+	)
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		h.handleBatch(sess, cms, msgs, lag)
+		cms, msgs, size, timeout = nil, nil, 0, nil
+	}
+	for {
+		select {
+		case cm, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			msg := fromSaramaConsumerMessage(cm)
+			lag.seen(cm.Offset)
+			if err := waitDue(sess.Context(), msg); err != nil {
+				return nil
+			}
+			// A pause hands over the batch filled so far.
+			if len(msgs) > 0 && h.parent.paused.paused(msg.Topic, msg.Partition) {
+				flush()
+			}
+			if err := h.parent.admit(sess.Context(), msg); err != nil {
+				return nil
+			}
+			h.parent.bumpReceived(len(cm.Value))
+			h.parent.fire(ConsumerEvent{Name: "message", Msg: msg})
+			cms, msgs, size = append(cms, cm), append(msgs, msg), size+len(cm.Value)
+			if len(msgs) == 1 {
+				timer = time.NewTimer(o.ConsumeBatchMaxWait)
+				timeout = timer.C
+			}
+			if batchFull(o, len(msgs), size) {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handleBatch runs the BatchMessageHandler over one batch and marks all of
+// it on success. On failure every message is routed (WithRetryTopics /
+// WithDeadLetter) or NACKed.
+func (h *cgHandler) handleBatch(sess sarama.ConsumerGroupSession, cms []*sarama.ConsumerMessage, msgs []Message, lag *partitionLag) {
+	p := h.parent
+	err := safeBatchCall(h.batch, msgs, &p.recovered)
+	p.batches.record(len(msgs), err != nil)
+	if err != nil {
+		p.failed.Add(uint64(len(msgs)))
+		for i, msg := range msgs {
+			if p.routeFailure(sess.Context(), msg, err) {
+				sess.MarkMessage(cms[i], "")
+				lag.done(msg.Offset)
+				continue
+			}
+			p.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
+		}
+		return
+	}
+	for i, msg := range msgs {
+		sess.MarkMessage(cms[i], "")
+		lag.done(msg.Offset)
+		p.acked.Add(1)
+		p.fire(ConsumerEvent{Name: "ack", Msg: msg})
+	}
+}
+
 // safeHandlerCall invokes the user MessageHandler with panic recovery. A panic
 // is turned into a "kafka: consumer handler panic" error, counted in the
 // parent's Recovered() counter, and the goroutine survives (does NOT re-panic).
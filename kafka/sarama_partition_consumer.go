@@ -25,6 +25,7 @@ type saramaPartitionConsumer struct {
 	factory  consumerFactory
 	consumer sarama.Consumer
 	pc       sarama.PartitionConsumer
+	lag      *lagTracker
 
 	mu     sync.Mutex
 	closed bool
@@ -40,6 +41,7 @@ type saramaPartitionConsumer struct {
 	failed    atomic.Uint64
 	recovered atomic.Uint64 // consumer handler panics recovered (observable; L5)
 	bytes     atomic.Uint64
+	batches   batchStats
 
 	onEvent atomic.Pointer[func(ConsumerEvent)]
 }
@@ -74,7 +76,9 @@ func newSaramaPartitionConsumer(o Options, factory consumerFactory) (*saramaPart
 		_ = c.Close()
 		return nil, err
 	}
-	return &saramaPartitionConsumer{opts: o, cfg: cfg, factory: factory, consumer: c, pc: pc}, nil
+	s := &saramaPartitionConsumer{opts: o, cfg: cfg, factory: factory, consumer: c, pc: pc}
+	s.lag = newLagTracker(o.LagThreshold, s.fire)
+	return s, nil
 }
 
 // Consume invokes handler for each message on the configured partition. It
@@ -90,6 +94,78 @@ func (s *saramaPartitionConsumer) Consume(ctx context.Context, handler MessageHa
 	return s.pump(ctx, handler, nil)
 }
 
+// ConsumeBatch is Consume with a BatchMessageHandler: messages are collected
+// up to the ConsumeBatchMax* bounds and ACKed or NACKed per batch. A batch
+// still filling when ctx ends is dropped. It blocks until ctx is cancelled.
+func (s *saramaPartitionConsumer) ConsumeBatch(ctx context.Context, handler BatchMessageHandler) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrProducerClosed
+	}
+	s.mu.Unlock()
+	lag := s.lag.partition(s.opts.Topic, s.opts.Partition)
+	lag.watch(s.pc.HighWaterMarkOffset)
+	lag.start(s.opts.Offset)
+	defer s.lag.run(ctx, s.opts.LagInterval, nil)()
+	var (
+		msgs    []Message
+		size    int
+		timer   *time.Timer
+		timeout <-chan time.Time
+	)
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		err := safeBatchCall(handler, msgs, &s.recovered)
+		s.batches.record(len(msgs), err != nil)
+		for _, msg := range msgs {
+			if err != nil {
+				s.failed.Add(1)
+				s.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
+				continue
+			}
+			lag.done(msg.Offset)
+			s.acked.Add(1)
+			s.fire(ConsumerEvent{Name: "ack", Msg: msg})
+		}
+		msgs, size, timeout = nil, 0, nil
+	}
+	for {
+		select {
+		case cm, ok := <-s.pc.Messages():
+			if !ok {
+				return nil
+			}
+			msg := fromSaramaConsumerMessage(cm)
+			lag.seen(cm.Offset)
+			s.received.Add(1)
+			s.bytes.Add(uint64(len(cm.Value)))
+			s.fire(ConsumerEvent{Name: "message", Msg: msg})
+			msgs, size = append(msgs, msg), size+len(cm.Value)
+			if len(msgs) == 1 {
+				timer = time.NewTimer(s.opts.ConsumeBatchMaxWait)
+				timeout = timer.C
+			}
+			if batchFull(s.opts, len(msgs), size) {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		case perr, ok := <-s.pc.Errors():
+			if !ok {
+				return nil
+			}
+			s.failed.Add(1)
+			s.fire(ConsumerEvent{Name: "error", Err: perr.Err})
+			s.pushErr(perr.Err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // Messages returns the message channel in channel delivery mode (started
 // lazily on first call), or nil in callback mode. When started, a goroutine
 // forwards the partition stream into the channel until Close.
@@ -121,6 +197,10 @@ func (s *saramaPartitionConsumer) pump(ctx context.Context, handler MessageHandl
 		// Close stops the pump. Skipped in callback mode (out == nil).
 		defer close(out)
 	}
+	lag := s.lag.partition(s.opts.Topic, s.opts.Partition)
+	lag.watch(s.pc.HighWaterMarkOffset)
+	lag.start(s.opts.Offset)
+	defer s.lag.run(ctx, s.opts.LagInterval, nil)()
 	for {
 		select {
 		case cm, ok := <-s.pc.Messages():
@@ -128,6 +208,7 @@ func (s *saramaPartitionConsumer) pump(ctx context.Context, handler MessageHandl
 				return nil
 			}
 			msg := fromSaramaConsumerMessage(cm)
+			lag.seen(cm.Offset)
 			s.received.Add(1)
 			s.bytes.Add(uint64(len(cm.Value)))
 			s.fire(ConsumerEvent{Name: "message", Msg: msg})
@@ -142,11 +223,13 @@ func (s *saramaPartitionConsumer) pump(ctx context.Context, handler MessageHandl
 					s.fire(ConsumerEvent{Name: "nack", Msg: msg, Err: err})
 					continue
 				}
+				lag.done(cm.Offset)
 				s.acked.Add(1)
 				s.fire(ConsumerEvent{Name: "ack", Msg: msg})
 			} else {
 				select {
 				case out <- msg:
+					lag.done(cm.Offset) // handed over: the caller's from here
 				case <-ctx.Done():
 					return ctx.Err()
 				}
@@ -202,13 +285,15 @@ func (s *saramaPartitionConsumer) Close() error {
 }
 
 func (s *saramaPartitionConsumer) Metrics() ConsumerMetrics {
-	return ConsumerMetrics{
+	m := ConsumerMetrics{
 		Received:  s.received.Load(),
 		Acked:     s.acked.Load(),
 		Failed:    s.failed.Load(),
 		Recovered: s.recovered.Load(),
 		Bytes:     s.bytes.Load(),
 	}
+	s.batches.fill(&m)
+	return m
 }
 
 // Recovered returns the total number of consumer-handler panics recovered since
@@ -232,11 +317,14 @@ func (s *saramaPartitionConsumer) safeHandlerCall(handler MessageHandler, msg Me
 }
 
 func (s *saramaPartitionConsumer) Snapshot() ConsumerSnapshot {
+	lag, total := s.lag.snapshot()
 	return ConsumerSnapshot{
 		Name:            s.Name(),
 		Backend:         s.Backend(),
 		Timestamp:       time.Now().UTC(),
 		ConsumerMetrics: s.Metrics(),
+		Lag:             lag,
+		TotalLag:        total,
 	}
 }
 
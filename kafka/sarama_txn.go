@@ -0,0 +1,550 @@
+//go:build !franzgo
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// saramaTxnProducer is the sarama TransactionalProducer: a transactional
+// sarama.SyncProducer (its transaction manager drives InitProducerId /
+// AddPartitionsToTxn / AddOffsetsToTxn / EndTxn), so Send blocks per record
+// exactly like SyncProducer.Send.
+type saramaTxnProducer struct {
+	opts Options
+	p    sarama.SyncProducer
+
+	mu     sync.RWMutex
+	closed bool
+	// produced / offsetsAdded track the open transaction (see CommitTxn).
+	produced     atomic.Bool
+	offsetsAdded atomic.Bool
+
+	enqueued    atomic.Uint64
+	success     atomic.Uint64
+	failed      atomic.Uint64
+	bytes       atomic.Uint64
+	bytesFailed atomic.Uint64
+	begun       atomic.Uint64
+	committed   atomic.Uint64
+	aborted     atomic.Uint64
+
+	onEvent atomic.Pointer[func(ProducerEvent)]
+}
+
+// NewTransactionalProducer builds a TransactionalProducer backed by sarama.
+// WithBrokers and WithTransactionalID are required; acks=all and the
+// idempotent producer are forced (Kafka requires both for transactions).
+func NewTransactionalProducer(opts ...Option) (TransactionalProducer, error) {
+	o := txnOptions(opts)
+	if err := o.validate("transactional-producer"); err != nil {
+		return nil, err
+	}
+	logConfig("txn-producer", o)
+	return newSaramaTxnProducer(o, nil)
+}
+
+func newSaramaTxnProducer(o Options, factory syncProducerFactory) (*saramaTxnProducer, error) {
+	cfg, err := buildSaramaConfig(o, true)
+	if err != nil {
+		return nil, err
+	}
+	applySaramaTxn(cfg, o)
+	if factory == nil {
+		factory = sarama.NewSyncProducer
+	}
+	sp, err := factory(o.Brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &saramaTxnProducer{opts: o, p: sp}, nil
+}
+
+func (s *saramaTxnProducer) BeginTxn() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrProducerClosed
+	}
+	if err := s.p.BeginTxn(); err != nil {
+		return err
+	}
+	s.produced.Store(false)
+	s.offsetsAdded.Store(false)
+	s.begun.Add(1)
+	s.fire(ProducerEvent{Name: "begin"})
+	return nil
+}
+
+func (s *saramaTxnProducer) Send(ctx context.Context, msg Message) error {
+	return s.sendBatch(ctx, []Message{msg})
+}
+
+// sendBatch writes msgs in the open transaction in one SendMessages round
+// trip (the TxnConsumerGroup produces a handler's outputs through it). ctx is
+// only checked up front: sarama's SyncProducer has no way to abandon a send.
+func (s *saramaTxnProducer) sendBatch(ctx context.Context, msgs []Message) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrProducerClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	pms := make([]*sarama.ProducerMessage, len(msgs))
+	var n uint64
+	for i, msg := range msgs {
+		pms[i] = toSaramaProducerMessage(msg, s.opts.Topic)
+		n += uint64(len(msg.Value))
+		s.fire(ProducerEvent{Name: "send", Topic: pms[i].Topic, Bytes: len(msg.Value)})
+	}
+	s.enqueued.Add(uint64(len(msgs)))
+	var err error
+	if len(pms) == 1 {
+		_, _, err = s.p.SendMessage(pms[0])
+	} else {
+		err = s.p.SendMessages(pms)
+	}
+	if err != nil {
+		// as with saramaSyncProducer.SendBatch, a partial failure is not
+		// attributable per record; the transaction aborts as a whole anyway.
+		s.failed.Add(uint64(len(msgs)))
+		s.bytesFailed.Add(n)
+		s.fire(ProducerEvent{Name: "error", Topic: pms[0].Topic, Err: err})
+		return err
+	}
+	s.produced.Store(true)
+	s.success.Add(uint64(len(msgs)))
+	s.bytes.Add(n)
+	for i, pm := range pms {
+		s.fire(ProducerEvent{Name: "success", Topic: pm.Topic, Partition: pm.Partition, Offset: pm.Offset, Bytes: len(msgs[i].Value)})
+	}
+	return nil
+}
+
+func (s *saramaTxnProducer) SendOffsetsToTxn(ctx context.Context, groupID string, offsets map[string]map[int32]int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrProducerClosed
+	}
+	pom := make(map[string][]*sarama.PartitionOffsetMetadata, len(offsets))
+	for topic, parts := range offsets {
+		for p, off := range parts {
+			pom[topic] = append(pom[topic], &sarama.PartitionOffsetMetadata{Partition: p, Offset: off})
+		}
+	}
+	if err := s.p.AddOffsetsToTxn(pom, groupID); err != nil {
+		return err
+	}
+	s.offsetsAdded.Store(true)
+	return nil
+}
+
+func (s *saramaTxnProducer) CommitTxn(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrProducerClosed
+	}
+	if s.offsetsAdded.Load() && !s.produced.Load() {
+		// sarama ends a transaction without partitions locally and never
+		// publishes its offsets; fail loudly rather than lose them.
+		_ = s.abortLocked()
+		return ErrTxnOffsetsOnly
+	}
+	if err := s.p.CommitTxn(); err != nil {
+		s.fire(ProducerEvent{Name: "error", Err: err})
+		return err
+	}
+	s.committed.Add(1)
+	s.fire(ProducerEvent{Name: "commit"})
+	return nil
+}
+
+func (s *saramaTxnProducer) AbortTxn(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrProducerClosed
+	}
+	return s.abortLocked()
+}
+
+func (s *saramaTxnProducer) abortLocked() error {
+	if err := s.p.AbortTxn(); err != nil {
+		s.fire(ProducerEvent{Name: "error", Err: err})
+		return err
+	}
+	s.aborted.Add(1)
+	s.fire(ProducerEvent{Name: "abort"})
+	return nil
+}
+
+// fatal reports whether the transaction manager can no longer make progress
+// (e.g. fenced by a newer producer with the same transactional id).
+func (s *saramaTxnProducer) fatal() bool {
+	return s.p.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0
+}
+
+func (s *saramaTxnProducer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	if s.p.TxnStatus()&sarama.ProducerTxnFlagInTransaction != 0 {
+		_ = s.abortLocked()
+	}
+	err := s.p.Close()
+	s.fire(ProducerEvent{Name: "close"})
+	return err
+}
+
+func (s *saramaTxnProducer) Metrics() TxnMetrics {
+	e, su, f := s.enqueued.Load(), s.success.Load(), s.failed.Load()
+	ba := s.bytes.Load()
+	return TxnMetrics{
+		ProducerMetrics: ProducerMetrics{
+			Enqueued:      e,
+			Success:       su,
+			Failed:        f,
+			Bytes:         ba,
+			BytesFailed:   s.bytesFailed.Load(),
+			BytesEnqueued: ba + s.bytesFailed.Load(), // sync: every enqueued record is resolved on return
+			InFlight:      ComputeInFlight(e, su, f),
+		},
+		Begun:     s.begun.Load(),
+		Committed: s.committed.Load(),
+		Aborted:   s.aborted.Load(),
+	}
+}
+
+func (s *saramaTxnProducer) Name() string { return nameOr(s.opts.Name, s.opts.TransactionalID) }
+
+func (s *saramaTxnProducer) Backend() string { return backendName }
+
+func (s *saramaTxnProducer) SetOnEvent(fn func(ProducerEvent)) {
+	if fn == nil {
+		s.onEvent.Store(nil)
+		return
+	}
+	s.onEvent.Store(&fn)
+}
+
+func (s *saramaTxnProducer) fire(e ProducerEvent) {
+	if fnp := s.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
+
+// saramaTxnConsumerGroup is the sarama TxnConsumerGroup: a read_committed
+// sarama consumer group (auto-commit off — offsets are committed inside each
+// transaction) plus one saramaTxnProducer. Claims are consumed concurrently
+// but a producer holds one transaction at a time, so batches from different
+// partitions commit one after another under txMu.
+//
+// An aborted batch cancels the session: the next session resumes every
+// partition from its last committed offset, which re-delivers the batch (the
+// claim's in-memory position would otherwise skip it).
+type saramaTxnConsumerGroup struct {
+	opts Options
+	cg   sarama.ConsumerGroup
+	tp   *saramaTxnProducer
+	txMu sync.Mutex
+
+	mu     sync.Mutex
+	closed bool
+
+	errChOnce sync.Once
+	errCh     chan error
+
+	received  atomic.Uint64
+	acked     atomic.Uint64
+	failed    atomic.Uint64
+	recovered atomic.Uint64
+	rebalance atomic.Uint64
+	bytes     atomic.Uint64
+
+	onEvent atomic.Pointer[func(ConsumerEvent)]
+}
+
+// NewTxnConsumerGroup builds an exactly-once TxnConsumerGroup backed by
+// sarama. WithBrokers, WithGroupID and WithTransactionalID are required.
+func NewTxnConsumerGroup(opts ...Option) (TxnConsumerGroup, error) {
+	o := txnOptions(opts)
+	if err := o.validate("txn-consumer-group"); err != nil {
+		return nil, err
+	}
+	logConfig("txn-consumer-group", o)
+	return newSaramaTxnConsumerGroup(o, nil, nil)
+}
+
+func newSaramaTxnConsumerGroup(o Options, cgf consumerGroupFactory, spf syncProducerFactory) (*saramaTxnConsumerGroup, error) {
+	cfg, err := buildSaramaConfig(o, false)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Consumer.IsolationLevel = sarama.ReadCommitted
+	cfg.Consumer.Offsets.AutoCommit.Enable = false
+	tp, err := newSaramaTxnProducer(o, spf)
+	if err != nil {
+		return nil, err
+	}
+	if cgf == nil {
+		cgf = sarama.NewConsumerGroup
+	}
+	cg, err := cgf(o.Brokers, o.GroupID, cfg)
+	if err != nil {
+		_ = tp.Close()
+		return nil, err
+	}
+	s := &saramaTxnConsumerGroup{opts: o, cg: cg, tp: tp}
+	go s.drainErrors()
+	return s, nil
+}
+
+func (s *saramaTxnConsumerGroup) drainErrors() {
+	for err := range s.cg.Errors() {
+		s.fire(ConsumerEvent{Name: "error", Err: err})
+		s.pushErr(err)
+	}
+}
+
+func (s *saramaTxnConsumerGroup) pushErr(err error) {
+	s.errChOnce.Do(func() { s.errCh = make(chan error, 16) })
+	select {
+	case s.errCh <- err:
+	default:
+	}
+}
+
+// Consume runs sessions until ctx is cancelled or a fatal error occurs. A
+// session ended by an aborted batch is restarted without counting a rebalance.
+func (s *saramaTxnConsumerGroup) Consume(ctx context.Context, topics []string, handler TxnBatchHandler) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrProducerClosed
+	}
+	s.mu.Unlock()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sctx, cancel := context.WithCancel(ctx)
+		h := &txnHandler{parent: s, handler: handler, rewind: cancel}
+		err := s.cg.Consume(sctx, topics, h)
+		cancel()
+		if err == nil {
+			err = h.fatal
+		}
+		if err != nil {
+			s.fire(ConsumerEvent{Name: "error", Err: err})
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if h.rewound.Load() {
+			continue
+		}
+		s.rebalance.Add(1)
+		s.fire(ConsumerEvent{Name: "rebalance"})
+	}
+}
+
+func (s *saramaTxnConsumerGroup) Errors() <-chan error {
+	s.errChOnce.Do(func() { s.errCh = make(chan error, 16) })
+	return s.errCh
+}
+
+func (s *saramaTxnConsumerGroup) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	err := s.cg.Close()
+	if perr := s.tp.Close(); err == nil {
+		err = perr
+	}
+	s.fire(ConsumerEvent{Name: "close"})
+	return err
+}
+
+func (s *saramaTxnConsumerGroup) Metrics() ConsumerMetrics {
+	return ConsumerMetrics{
+		Received:  s.received.Load(),
+		Acked:     s.acked.Load(),
+		Failed:    s.failed.Load(),
+		Recovered: s.recovered.Load(),
+		Rebalance: s.rebalance.Load(),
+		Bytes:     s.bytes.Load(),
+	}
+}
+
+func (s *saramaTxnConsumerGroup) TxnMetrics() TxnMetrics { return s.tp.Metrics() }
+
+func (s *saramaTxnConsumerGroup) Snapshot() ConsumerSnapshot {
+	return ConsumerSnapshot{
+		Name:            s.Name(),
+		Backend:         s.Backend(),
+		Timestamp:       time.Now().UTC(),
+		ConsumerMetrics: s.Metrics(),
+	}
+}
+
+func (s *saramaTxnConsumerGroup) Name() string { return nameOr(s.opts.Name, s.opts.GroupID) }
+
+func (s *saramaTxnConsumerGroup) Backend() string { return backendName }
+
+func (s *saramaTxnConsumerGroup) SetOnEvent(fn func(ConsumerEvent)) {
+	if fn == nil {
+		s.onEvent.Store(nil)
+		return
+	}
+	s.onEvent.Store(&fn)
+}
+
+func (s *saramaTxnConsumerGroup) fire(e ConsumerEvent) {
+	if fnp := s.onEvent.Load(); fnp != nil {
+		(*fnp)(e)
+	}
+}
+
+// txnHandler adapts a TxnBatchHandler to sarama.ConsumerGroupHandler for one
+// session. fatal is written before rewind (the session cancel) and read by
+// Consume after sarama's Consume has joined every ConsumeClaim goroutine.
+type txnHandler struct {
+	parent  *saramaTxnConsumerGroup
+	handler TxnBatchHandler
+	rewind  context.CancelFunc
+
+	rewound   atomic.Bool
+	fatalOnce sync.Once
+	fatal     error
+}
+
+func (h *txnHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *txnHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim blocks for the first record of a batch, then takes whatever is
+// already buffered on the claim, up to TxnMaxBatch, and runs it in one
+// transaction.
+func (h *txnHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	max := h.parent.opts.TxnMaxBatch
+	for cm := range claim.Messages() {
+		batch := []*sarama.ConsumerMessage{cm}
+	fill:
+		for len(batch) < max {
+			select {
+			case next, ok := <-claim.Messages():
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		if err := h.runTxn(sess, batch); err != nil {
+			h.rewound.Store(true)
+			h.rewind()
+			return nil
+		}
+	}
+	return nil
+}
+
+// runTxn processes one batch in one transaction. A non-nil return means the
+// transaction was aborted and the session must restart from the committed
+// offsets; a fatal producer state is also recorded in h.fatal.
+func (h *txnHandler) runTxn(sess sarama.ConsumerGroupSession, batch []*sarama.ConsumerMessage) error {
+	p, ctx := h.parent, sess.Context()
+	msgs := make([]Message, len(batch))
+	for i, cm := range batch {
+		msgs[i] = fromSaramaConsumerMessage(cm)
+		p.received.Add(1)
+		p.bytes.Add(uint64(len(cm.Value)))
+		p.fire(ConsumerEvent{Name: "message", Msg: msgs[i]})
+	}
+	last := msgs[len(msgs)-1]
+
+	p.txMu.Lock()
+	defer p.txMu.Unlock()
+	if err := p.tp.BeginTxn(); err != nil {
+		h.setFatal(err)
+		return err
+	}
+	outs, err := h.safeHandlerCall(ctx, msgs)
+	if err == nil && len(outs) > 0 {
+		err = p.tp.sendBatch(ctx, outs)
+		if err == nil {
+			err = p.tp.SendOffsetsToTxn(ctx, p.opts.GroupID, map[string]map[int32]int64{
+				last.Topic: {last.Partition: last.Offset + 1},
+			})
+		}
+	}
+	if err == nil {
+		// the session is ending (rebalance or Close): the partition may
+		// already belong to another member, so committing could duplicate.
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = p.tp.CommitTxn(ctx)
+	}
+	if err == nil && len(outs) == 0 {
+		// nothing was produced, so there is nothing for the offsets to be
+		// atomic with (and sarama drops offsets-only transactions): commit
+		// them through the session, fenced by its generation.
+		sess.MarkOffset(last.Topic, last.Partition, last.Offset+1, "")
+		sess.Commit()
+	}
+	if err == nil {
+		p.acked.Add(uint64(len(msgs)))
+		p.fire(ConsumerEvent{Name: "commit", Msg: last})
+		return nil
+	}
+	p.failed.Add(uint64(len(msgs)))
+	p.fire(ConsumerEvent{Name: "abort", Msg: last, Err: err})
+	if aerr := p.tp.AbortTxn(ctx); aerr != nil {
+		h.setFatal(fmt.Errorf("kafka: abort transaction: %w", aerr))
+	} else if p.tp.fatal() {
+		h.setFatal(err)
+	}
+	return err
+}
+
+func (h *txnHandler) setFatal(err error) {
+	if h.parent.tp.fatal() || err == ErrProducerClosed {
+		h.fatalOnce.Do(func() { h.fatal = err })
+	}
+}
+
+// safeHandlerCall runs the TxnBatchHandler with panic recovery (see
+// cgHandler.safeHandlerCall); a panic aborts the batch's transaction.
+func (h *txnHandler) safeHandlerCall(ctx context.Context, batch []Message) (outs []Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.parent.recovered.Add(1)
+			err = fmt.Errorf("kafka: consumer handler panic: %v", r)
+		}
+	}()
+	return h.handler(ctx, batch)
+}
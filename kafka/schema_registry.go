@@ -0,0 +1,394 @@
+package kafka
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Schema types for Schema.Type (the schema registry's schemaType names).
+const (
+	SchemaAvro     = "AVRO"
+	SchemaProtobuf = "PROTOBUF"
+)
+
+// ErrWireFormat is returned by a RegistryCodec's Decode for a value that is
+// not in the Confluent wire format (magic byte 0, 4-byte schema ID).
+var ErrWireFormat = errors.New("kafka: value is not in Confluent wire format")
+
+// ErrSchemaNotFound is returned by InMemoryRegistry for an unknown schema ID.
+var ErrSchemaNotFound = errors.New("kafka: schema not found")
+
+// Schema is a registered schema: its type, its text (Avro JSON, or for
+// protobuf the schema string the codec registers, see NewProtobufCodec) and
+// the schemas it imports. It marshals to the body of the registry's
+// POST /subjects/{subject}/versions.
+type Schema struct {
+	Type       string            `json:"schemaType"`
+	Schema     string            `json:"schema"`
+	References []SchemaReference `json:"references,omitempty"`
+}
+
+// SchemaReference names a registered schema that a Schema imports: Name is
+// the import as written in the schema (a .proto path), Subject/Version where
+// it is registered.
+type SchemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// SchemaRegistry is the schema-registry client a RegistryCodec uses. A
+// Confluent REST client implements it with POST /subjects/{subject}/versions
+// (then POST /subjects/{subject} for the version) and GET /schemas/ids/{id};
+// InMemoryRegistry implements it for tests.
+type SchemaRegistry interface {
+	// Register returns the ID of schema and its version under subject,
+	// registering it first if the subject does not have it yet.
+	Register(ctx context.Context, subject string, schema Schema) (id, version int, err error)
+	// SchemaByID returns the schema registered under id.
+	SchemaByID(ctx context.Context, id int) (Schema, error)
+}
+
+// RegistryCodec is a Codec writing the Confluent wire format: magic byte 0,
+// the big-endian 4-byte schema ID, (protobuf only) the message-index path,
+// then the Avro or protobuf payload. Encode registers the writer schema
+// under the codec's subject once and caches its ID; Decode looks up the
+// schema named by the embedded ID (cached per ID), so a value written with
+// an older schema version is read with that version.
+//
+// The registry is called with context.Background(): Codec has no context.
+type RegistryCodec struct {
+	reg     SchemaRegistry
+	subject string
+	typ     string
+
+	avroSchema avro.Schema // Avro: the writer schema
+
+	mu    sync.RWMutex
+	ids   map[string]int                             // Avro writer schema text → registered ID
+	files map[protoreflect.FileDescriptor]registered // protobuf file → its registration
+	byID  map[int]avro.Schema                        // Decode cache; protobuf IDs map to nil
+	types map[int]string                             // Decode cache: ID → schema type
+}
+
+// registered is where a protobuf file was registered.
+type registered struct{ id, version int }
+
+// NewAvroCodec returns a RegistryCodec writing Avro with schema (Avro JSON)
+// under subject (e.g. "orders-value", the TopicNameStrategy subject). Values
+// are (de)serialised with github.com/hamba/avro (struct fields tagged
+// `avro:"name"`, or map[string]any).
+func NewAvroCodec(reg SchemaRegistry, subject, schema string) (*RegistryCodec, error) {
+	s, err := parseAvro(schema)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: avro schema: %w", err)
+	}
+	c := newRegistryCodec(reg, subject, SchemaAvro)
+	c.avroSchema = s
+	return c, nil
+}
+
+// NewProtobufCodec returns a RegistryCodec writing protobuf under subject.
+// The schema registered for a message is its .proto file as a base64
+// serialized google.protobuf.FileDescriptorProto, a form the Confluent
+// registry accepts in place of .proto source. The files it imports are
+// registered first, each under its import path (the registry's default
+// reference subject), and listed as the schema's references. Decode needs
+// only the ID and the target message type.
+func NewProtobufCodec(reg SchemaRegistry, subject string) *RegistryCodec {
+	return newRegistryCodec(reg, subject, SchemaProtobuf)
+}
+
+func newRegistryCodec(reg SchemaRegistry, subject, typ string) *RegistryCodec {
+	return &RegistryCodec{
+		reg: reg, subject: subject, typ: typ,
+		ids: make(map[string]int), files: make(map[protoreflect.FileDescriptor]registered),
+		byID: make(map[int]avro.Schema), types: make(map[int]string),
+	}
+}
+
+// Encode serialises v (an Avro-mappable value, or a proto.Message) in the
+// Confluent wire format.
+func (c *RegistryCodec) Encode(v any) ([]byte, error) {
+	if c.typ == SchemaAvro {
+		id, err := c.register(c.avroSchema.String())
+		if err != nil {
+			return nil, err
+		}
+		payload, err := avro.Marshal(c.avroSchema, v)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: avro encode: %w", err)
+		}
+		return append(wireHeader(id), payload...), nil
+	}
+	pm, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("kafka: RegistryCodec.Encode: %T is not a proto.Message", v)
+	}
+	md := pm.ProtoReflect().Descriptor()
+	r, err := c.registerFile(c.subject, md.ParentFile())
+	if err != nil {
+		return nil, err
+	}
+	id := r.id
+	payload, err := proto.Marshal(pm)
+	if err != nil {
+		return nil, err
+	}
+	return append(appendMessageIndexes(wireHeader(id), md), payload...), nil
+}
+
+// Decode reads a wire-format value into out (a pointer for Avro, a
+// proto.Message for protobuf) using the schema its ID names.
+func (c *RegistryCodec) Decode(b []byte, out any) error {
+	if len(b) < 5 || b[0] != 0 {
+		return ErrWireFormat
+	}
+	id := int(binary.BigEndian.Uint32(b[1:5]))
+	typ, writer, err := c.schemaByID(id)
+	if err != nil {
+		return err
+	}
+	if typ != c.typ {
+		return fmt.Errorf("kafka: schema %d is %s, codec reads %s", id, typ, c.typ)
+	}
+	payload := b[5:]
+	if typ == SchemaAvro {
+		if err := avro.Unmarshal(writer, payload, out); err != nil {
+			return fmt.Errorf("kafka: avro decode (schema %d): %w", id, err)
+		}
+		return nil
+	}
+	pm, ok := out.(proto.Message)
+	if !ok {
+		return fmt.Errorf("kafka: RegistryCodec.Decode: %T is not a proto.Message", out)
+	}
+	if payload, err = skipMessageIndexes(payload); err != nil {
+		return err
+	}
+	return proto.Unmarshal(payload, pm)
+}
+
+// ContentType returns "application/vnd.confluent.avro" or
+// "application/vnd.confluent.protobuf".
+func (c *RegistryCodec) ContentType() string {
+	if c.typ == SchemaAvro {
+		return "application/vnd.confluent.avro"
+	}
+	return "application/vnd.confluent.protobuf"
+}
+
+// register returns the cached ID of the writer schema text, registering it
+// on first use. A failed registration is retried on the next Encode.
+func (c *RegistryCodec) register(text string) (int, error) {
+	c.mu.RLock()
+	id, ok := c.ids[text]
+	c.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+	id, _, err := c.reg.Register(context.Background(), c.subject, Schema{Type: c.typ, Schema: text})
+	if err != nil {
+		return 0, fmt.Errorf("kafka: register schema for %s: %w", c.subject, err)
+	}
+	c.mu.Lock()
+	c.ids[text] = id
+	c.mu.Unlock()
+	return id, nil
+}
+
+// registerFile returns the registration of the protobuf file fd under
+// subject, registering its imports (recursively) and then fd on first use.
+// Registrations are cached per file, so an import shared by two files is
+// registered once.
+func (c *RegistryCodec) registerFile(subject string, fd protoreflect.FileDescriptor) (registered, error) {
+	c.mu.RLock()
+	r, ok := c.files[fd]
+	c.mu.RUnlock()
+	if ok {
+		return r, nil
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(protodesc.ToFileDescriptorProto(fd))
+	if err != nil {
+		return registered{}, err
+	}
+	s := Schema{Type: SchemaProtobuf, Schema: base64.StdEncoding.EncodeToString(b)}
+	imports := fd.Imports()
+	for i := range imports.Len() {
+		dep := imports.Get(i).FileDescriptor
+		dr, err := c.registerFile(dep.Path(), dep)
+		if err != nil {
+			return registered{}, err
+		}
+		s.References = append(s.References, SchemaReference{Name: dep.Path(), Subject: dep.Path(), Version: dr.version})
+	}
+	r.id, r.version, err = c.reg.Register(context.Background(), subject, s)
+	if err != nil {
+		return registered{}, fmt.Errorf("kafka: register schema for %s: %w", subject, err)
+	}
+	c.mu.Lock()
+	c.files[fd] = r
+	c.mu.Unlock()
+	return r, nil
+}
+
+// schemaByID returns the type and (Avro) parsed schema of id, fetching it
+// from the registry on first use.
+func (c *RegistryCodec) schemaByID(id int) (string, avro.Schema, error) {
+	c.mu.RLock()
+	typ, ok := c.types[id]
+	s := c.byID[id]
+	c.mu.RUnlock()
+	if ok {
+		return typ, s, nil
+	}
+	sc, err := c.reg.SchemaByID(context.Background(), id)
+	if err != nil {
+		return "", nil, fmt.Errorf("kafka: schema %d: %w", id, err)
+	}
+	typ = sc.Type
+	if typ == "" {
+		typ = SchemaAvro // the registry omits schemaType for Avro
+	}
+	if typ == SchemaAvro {
+		if s, err = parseAvro(sc.Schema); err != nil {
+			return "", nil, fmt.Errorf("kafka: schema %d: %w", id, err)
+		}
+	}
+	c.mu.Lock()
+	c.types[id], c.byID[id] = typ, s
+	c.mu.Unlock()
+	return typ, s, nil
+}
+
+// parseAvro parses text with a private name cache: hamba's default cache is
+// process-wide, and two versions of one record name must not collide.
+func parseAvro(text string) (avro.Schema, error) {
+	return avro.ParseWithCache(text, "", &avro.SchemaCache{})
+}
+
+// wireHeader returns the magic byte and big-endian schema ID.
+func wireHeader(id int) []byte {
+	b := make([]byte, 5, 64)
+	binary.BigEndian.PutUint32(b[1:], uint32(id))
+	return b
+}
+
+// appendMessageIndexes appends md's path in its file (zigzag varints,
+// count first; the common first-message path [0] is the single byte 0).
+func appendMessageIndexes(b []byte, md protoreflect.MessageDescriptor) []byte {
+	var path []int
+	for d := protoreflect.Descriptor(md); ; d = d.Parent() {
+		path = append([]int{d.Index()}, path...)
+		if _, top := d.Parent().(protoreflect.FileDescriptor); top {
+			break
+		}
+	}
+	if len(path) == 1 && path[0] == 0 {
+		return append(b, 0)
+	}
+	b = binary.AppendVarint(b, int64(len(path)))
+	for _, i := range path {
+		b = binary.AppendVarint(b, int64(i))
+	}
+	return b
+}
+
+// skipMessageIndexes returns b after its message-index path.
+func skipMessageIndexes(b []byte) ([]byte, error) {
+	n, k := binary.Varint(b)
+	if k <= 0 || n < 0 {
+		return nil, ErrWireFormat
+	}
+	b = b[k:]
+	for range n {
+		if _, k = binary.Varint(b); k <= 0 {
+			return nil, ErrWireFormat
+		}
+		b = b[k:]
+	}
+	return b, nil
+}
+
+// InMemoryRegistry is a SchemaRegistry kept in memory, for tests. Like the
+// Confluent registry, IDs are global: the same schema registered under two
+// subjects gets one ID.
+type InMemoryRegistry struct {
+	mu       sync.Mutex
+	schemas  []Schema         // ID-1 → schema
+	ids      map[string]int   // schemaKey → ID
+	subjects map[string][]int // subject → IDs in version order
+}
+
+// NewInMemoryRegistry returns an empty registry.
+func NewInMemoryRegistry() *InMemoryRegistry {
+	return &InMemoryRegistry{ids: make(map[string]int), subjects: make(map[string][]int)}
+}
+
+// Register implements SchemaRegistry. IDs and versions start at 1. Like the
+// Confluent registry, it rejects a reference to a subject version that does
+// not exist.
+func (r *InMemoryRegistry) Register(_ context.Context, subject string, schema Schema) (int, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ref := range schema.References {
+		if ref.Version < 1 || ref.Version > len(r.subjects[ref.Subject]) {
+			return 0, 0, fmt.Errorf("%w: reference %s version %d", ErrSchemaNotFound, ref.Subject, ref.Version)
+		}
+	}
+	key := schemaKey(schema)
+	id, ok := r.ids[key]
+	if !ok {
+		r.schemas = append(r.schemas, schema)
+		id = len(r.schemas)
+		r.ids[key] = id
+	}
+	for i, v := range r.subjects[subject] {
+		if v == id {
+			return id, i + 1, nil
+		}
+	}
+	r.subjects[subject] = append(r.subjects[subject], id)
+	return id, len(r.subjects[subject]), nil
+}
+
+// schemaKey identifies a schema by its type, text and (ordered) references.
+func schemaKey(s Schema) string {
+	refs := make([]string, len(s.References))
+	for i, ref := range s.References {
+		refs[i] = fmt.Sprintf("%s\x00%s\x00%d", ref.Name, ref.Subject, ref.Version)
+	}
+	return s.Type + "\x00" + s.Schema + "\x00" + strings.Join(refs, "\x01")
+}
+
+// SchemaByID implements SchemaRegistry.
+func (r *InMemoryRegistry) SchemaByID(_ context.Context, id int) (Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id < 1 || id > len(r.schemas) {
+		return Schema{}, fmt.Errorf("%w: id %d", ErrSchemaNotFound, id)
+	}
+	return r.schemas[id-1], nil
+}
+
+// Versions returns the schema IDs registered under subject, oldest first.
+func (r *InMemoryRegistry) Versions(subject string) []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.subjects[subject]...)
+}
+
+var (
+	_ Codec          = (*RegistryCodec)(nil)
+	_ SchemaRegistry = (*InMemoryRegistry)(nil)
+)
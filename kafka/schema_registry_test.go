@@ -0,0 +1,277 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	orderV1 = `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"},{"name":"qty","type":"int"}]}`
+	orderV2 = `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"},{"name":"qty","type":"int"},` +
+		`{"name":"note","type":"string","default":""}]}`
+)
+
+type orderV1Rec struct {
+	ID  string `avro:"id"`
+	Qty int    `avro:"qty"`
+}
+
+type orderV2Rec struct {
+	ID   string `avro:"id"`
+	Qty  int    `avro:"qty"`
+	Note string `avro:"note"`
+}
+
+func Test_RegistryCodec_AvroWireFormat(t *testing.T) {
+	reg := NewInMemoryRegistry()
+	v1, err := NewAvroCodec(reg, "orders-value", orderV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := v1.Encode(orderV1Rec{ID: "o-1", Qty: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != 0 || binary.BigEndian.Uint32(b[1:5]) != 1 {
+		t.Fatalf("header % x, want magic 0 and id 1", b[:5])
+	}
+	var got orderV1Rec
+	if err := v1.Decode(b, &got); err != nil || got != (orderV1Rec{"o-1", 3}) {
+		t.Fatalf("round trip %+v err=%v", got, err)
+	}
+
+	// A v2 codec registers a second version and still reads v1 values with
+	// the v1 writer schema named by their ID.
+	v2, _ := NewAvroCodec(reg, "orders-value", orderV2)
+	b2, err := v2.Encode(orderV2Rec{ID: "o-2", Qty: 5, Note: "gift"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := binary.BigEndian.Uint32(b2[1:5]); id != 2 {
+		t.Errorf("v2 id=%d want 2", id)
+	}
+	var old orderV2Rec
+	if err := v2.Decode(b, &old); err != nil || old != (orderV2Rec{ID: "o-1", Qty: 3}) {
+		t.Errorf("v1 value via v2 codec: %+v err=%v", old, err)
+	}
+	var cur orderV2Rec
+	if err := v1.Decode(b2, &cur); err != nil || cur.Note != "gift" {
+		t.Errorf("v2 value via v1 codec: %+v err=%v", cur, err)
+	}
+	if vs := reg.Versions("orders-value"); len(vs) != 2 {
+		t.Errorf("versions %v", vs)
+	}
+
+	// Same schema under another subject: same global ID.
+	other, _ := NewAvroCodec(reg, "archive-value", orderV1)
+	b3, _ := other.Encode(orderV1Rec{ID: "o-3"})
+	if id := binary.BigEndian.Uint32(b3[1:5]); id != 1 {
+		t.Errorf("shared schema id=%d want 1", id)
+	}
+
+	if err := v1.Decode([]byte("{}"), &got); !errors.Is(err, ErrWireFormat) {
+		t.Errorf("plain JSON: %v", err)
+	}
+	if err := v1.Decode([]byte{0, 0, 0, 0, 99, 1}, &got); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("unknown id: %v", err)
+	}
+	if _, err := NewAvroCodec(reg, "x", `{"type":"nope"}`); err == nil {
+		t.Error("bad schema accepted")
+	}
+}
+
+func Test_RegistryCodec_Protobuf(t *testing.T) {
+	reg := NewInMemoryRegistry()
+	c := NewProtobufCodec(reg, "names-value")
+	if c.ContentType() != "application/vnd.confluent.protobuf" {
+		t.Errorf("ContentType=%q", c.ContentType())
+	}
+
+	// A nested message encodes its index path; a file's first message the
+	// single 0 byte.
+	in := &descriptorpb.DescriptorProto_ExtensionRange{Start: proto.Int32(1), End: proto.Int32(9)}
+	b, err := c.Encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != 0 || binary.BigEndian.Uint32(b[1:5]) != 1 {
+		t.Fatalf("header % x", b[:5])
+	}
+	// DescriptorProto is message 2 of descriptor.proto and ExtensionRange its
+	// first nested message: path [2 0], zigzag varints count 2 → 04, 2 → 04,
+	// 0 → 00.
+	if got := b[5:8]; string(got) != string([]byte{4, 4, 0}) {
+		t.Errorf("message indexes % x, want 04 04 00", got)
+	}
+	out := &descriptorpb.DescriptorProto_ExtensionRange{}
+	if err := c.Decode(b, out); err != nil || !proto.Equal(in, out) {
+		t.Fatalf("round trip %v err=%v", out, err)
+	}
+
+	w, _ := c.Encode(wrapperspb.String("x"))
+	if binary.BigEndian.Uint32(w[1:5]) != 2 {
+		t.Errorf("second file should register a second schema")
+	}
+	var sv wrapperspb.StringValue
+	if err := c.Decode(w, &sv); err != nil || sv.GetValue() != "x" {
+		t.Errorf("StringValue %v err=%v", sv.GetValue(), err)
+	}
+
+	avroC, _ := NewAvroCodec(reg, "a", orderV1)
+	ab, _ := avroC.Encode(orderV1Rec{ID: "a"})
+	if err := c.Decode(ab, out); err == nil {
+		t.Error("avro value decoded by the protobuf codec")
+	}
+	if _, err := c.Encode("not proto"); err == nil {
+		t.Error("non-proto value encoded")
+	}
+}
+
+// Test_RegistryCodec_OverInMemoryCluster runs the codec end to end through
+// the in-memory backend.
+func Test_RegistryCodec_OverInMemoryCluster(t *testing.T) {
+	reg := NewInMemoryRegistry()
+	codec, _ := NewAvroCodec(reg, "orders-value", orderV1)
+	c := NewInMemoryCluster()
+	p, _ := c.NewSyncProducer(WithTopic("orders"), WithCodec(codec))
+	v, _ := codec.Encode(orderV1Rec{ID: "o-9", Qty: 1})
+	if _, _, err := p.Send(context.Background(), Message{Value: v}); err != nil {
+		t.Fatal(err)
+	}
+	var got orderV1Rec
+	if err := codec.Decode(c.Records("orders", 0)[0].Value, &got); err != nil || got.ID != "o-9" {
+		t.Errorf("got %+v err=%v", got, err)
+	}
+}
+
+// recordingRegistry logs each Register call as subject and request body.
+type recordingRegistry struct {
+	*InMemoryRegistry
+	calls []string
+}
+
+func (r *recordingRegistry) Register(ctx context.Context, subject string, schema Schema) (int, int, error) {
+	body, err := json.Marshal(schema)
+	if err != nil {
+		return 0, 0, err
+	}
+	r.calls = append(r.calls, subject+" "+string(body))
+	return r.InMemoryRegistry.Register(ctx, subject, schema)
+}
+
+// orderFileProto is shop/order.proto: message Order { string id = 1;
+// google.protobuf.StringValue note = 2; }, importing wrappers.proto.
+func orderFileProto() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("shop/order.proto"),
+		Package:    proto.String("shop"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		Syntax:     proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("id"), JsonName: proto.String("id"), Number: proto.Int32(1),
+					Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:  descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("note"), JsonName: proto.String("note"), Number: proto.Int32(2),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".google.protobuf.StringValue")},
+			},
+		}},
+	}
+}
+
+func orderFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	fd, err := protodesc.NewFile(orderFileProto(), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+// orderSchemaFixture is the registration body for shop/order.proto as the
+// Confluent registry takes it: the base64 FileDescriptorProto and a
+// reference to wrappers.proto registered under its own path.
+const orderSchemaFixture = `{"schemaType":"PROTOBUF","schema":"` +
+	`ChBzaG9wL29yZGVyLnByb3RvEgRzaG9wGh5nb29nbGUvcHJvdG9idWYvd3JhcHBlcnMucHJvdG8iSQoFT3JkZXISDgoCaWQYASABKAlSAmlk` +
+	`EjAKBG5vdGUYAiABKAsyHC5nb29nbGUucHJvdG9idWYuU3RyaW5nVmFsdWVSBG5vdGViBnByb3RvMw==",` +
+	`"references":[{"name":"google/protobuf/wrappers.proto","subject":"google/protobuf/wrappers.proto","version":1}]}`
+
+func Test_RegistryCodec_ProtobufRegistration(t *testing.T) {
+	reg := &recordingRegistry{InMemoryRegistry: NewInMemoryRegistry()}
+	c := NewProtobufCodec(reg, "orders-value")
+	md := orderFile(t).Messages().ByName("Order")
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("id"), protoreflect.ValueOfString("o-1"))
+	note := wrapperspb.String("gift")
+	msg.Set(md.Fields().ByName("note"), protoreflect.ValueOfMessage(note.ProtoReflect()))
+
+	b, err := c.Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// magic 0, schema ID 2 (wrappers.proto got 1), message index 0, then
+	// id "o-1" (0a 03 ...) and note {value "gift"} (12 06 0a 04 ...).
+	want := []byte{0, 0, 0, 0, 2, 0, 0x0a, 3, 'o', '-', '1', 0x12, 6, 0x0a, 4, 'g', 'i', 'f', 't'}
+	if !bytes.Equal(b, want) {
+		t.Errorf("wire bytes\n got % x\nwant % x", b, want)
+	}
+	if len(reg.calls) != 2 {
+		t.Fatalf("registrations=%q", reg.calls)
+	}
+	dep, main, _ := strings.Cut(reg.calls[0], " ")
+	if dep != "google/protobuf/wrappers.proto" || strings.Contains(main, "references") {
+		t.Errorf("import registration %q", reg.calls[0])
+	}
+	var depSchema Schema
+	mustNoErr(t, json.Unmarshal([]byte(main), &depSchema))
+	raw, err := base64.StdEncoding.DecodeString(depSchema.Schema)
+	mustNoErr(t, err)
+	var depFile descriptorpb.FileDescriptorProto
+	mustNoErr(t, proto.Unmarshal(raw, &depFile))
+	if !proto.Equal(&depFile, protodesc.ToFileDescriptorProto(wrapperspb.File_google_protobuf_wrappers_proto)) {
+		t.Error("wrappers.proto registered with a different descriptor")
+	}
+	if got := reg.calls[1]; got != "orders-value "+orderSchemaFixture {
+		t.Errorf("schema registration\n got %s\nwant orders-value %s", got, orderSchemaFixture)
+	}
+	var fixture Schema
+	mustNoErr(t, json.Unmarshal([]byte(orderSchemaFixture), &fixture))
+	raw, err = base64.StdEncoding.DecodeString(fixture.Schema)
+	mustNoErr(t, err)
+	var file descriptorpb.FileDescriptorProto
+	mustNoErr(t, proto.Unmarshal(raw, &file))
+	if !proto.Equal(&file, orderFileProto()) {
+		t.Errorf("fixture schema is not shop/order.proto: %v", &file)
+	}
+
+	// cached: a second value registers nothing.
+	if _, err := c.Encode(msg); err != nil || len(reg.calls) != 2 {
+		t.Errorf("re-encode err=%v registrations=%d", err, len(reg.calls))
+	}
+	got := dynamicpb.NewMessage(md)
+	if err := c.Decode(b, got); err != nil || !proto.Equal(got, msg) {
+		t.Errorf("round trip %v err=%v", got, err)
+	}
+
+	if _, _, err := reg.Register(context.Background(), "x", Schema{Type: SchemaProtobuf, Schema: "e30=",
+		References: []SchemaReference{{Name: "a.proto", Subject: "a.proto", Version: 1}}}); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("dangling reference: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SASL mechanisms accepted by SASLConfig.Mechanism.
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// TLSConfig enables TLS to the brokers. The zero value verifies the brokers
+// against the system roots.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CAs to verify the brokers with (instead of
+	// the system roots).
+	CAFile string `json:"ca_file" mapstructure:"ca_file"`
+	// CertFile / KeyFile are the PEM client certificate and key for mutual
+	// TLS. Set both or neither.
+	CertFile string `json:"cert_file" mapstructure:"cert_file"`
+	KeyFile  string `json:"key_file" mapstructure:"key_file"`
+	// ServerName overrides the name the broker certificates are verified
+	// against (default: the host of each broker address).
+	ServerName string `json:"server_name" mapstructure:"server_name"`
+	// InsecureSkipVerify disables broker certificate verification. Testing
+	// only; it cannot be combined with CAFile.
+	InsecureSkipVerify bool `json:"insecure_skip_verify" mapstructure:"insecure_skip_verify"`
+}
+
+// SASLConfig enables SASL authentication. PLAIN requires TLS (it sends the
+// password as is); the SCRAM mechanisms do not.
+type SASLConfig struct {
+	Mechanism string `json:"mechanism" mapstructure:"mechanism"` // SASLPlain, SASLScramSHA256 or SASLScramSHA512
+	Username  string `json:"username" mapstructure:"username"`
+	Password  string `json:"password" mapstructure:"password"`
+}
+
+// validateSecurity rejects TLS / SASL settings that cannot work, so both
+// backends fail at construction rather than on the first broker dial. The TLS
+// files are loaded here too, for the same reason.
+func validateSecurity(o Options) error {
+	if s := o.SASL; s != nil {
+		switch s.Mechanism {
+		case SASLPlain:
+			if o.TLS == nil {
+				return errors.New("kafka: sasl PLAIN without tls would send the password in clear text")
+			}
+		case SASLScramSHA256, SASLScramSHA512:
+		default:
+			return fmt.Errorf("kafka: unsupported sasl mechanism %q", s.Mechanism)
+		}
+		if s.Username == "" || s.Password == "" {
+			return errors.New("kafka: sasl username and password required")
+		}
+	}
+	if t := o.TLS; t != nil {
+		if (t.CertFile == "") != (t.KeyFile == "") {
+			return errors.New("kafka: tls cert_file and key_file must be set together")
+		}
+		if t.InsecureSkipVerify && t.CAFile != "" {
+			return errors.New("kafka: tls insecure_skip_verify ignores ca_file; set one of them")
+		}
+		if _, err := t.build(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// build loads the files of c into a *tls.Config (nil for a nil c).
+func (c *TLSConfig) build() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // opt-in, testing only
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: tls ca_file: %w", err)
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka: tls ca_file %s: no PEM certificates", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: tls client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
@@ -0,0 +1,45 @@
+//go:build franzgo
+
+package kafka
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+)
+
+func Test_Security_KgoOpts(t *testing.T) {
+	pki := newTestPKI(t)
+	for _, mech := range []string{SASLPlain, SASLScramSHA256, SASLScramSHA512} {
+		o := applyOptions([]Option{WithBrokers("b:9092"), WithSASL(mech, "u", "p"),
+			WithTLS(TLSConfig{CAFile: pki.caFile, CertFile: pki.certFile, KeyFile: pki.keyFile, ServerName: "kafka.internal"})}).withDefaults()
+		cl, err := newKgoClient(o, kgoSyncProducerOpts(o)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc, _ := cl.OptValue(kgo.DialTLSConfig).(*tls.Config)
+		if tc == nil || tc.ServerName != "kafka.internal" || tc.RootCAs == nil || len(tc.Certificates) != 1 {
+			t.Errorf("%s: DialTLSConfig=%+v", mech, tc)
+		}
+		ms, _ := cl.OptValue(kgo.SASL).([]sasl.Mechanism)
+		if len(ms) != 1 || ms[0].Name() != mech {
+			t.Errorf("%s: SASL=%v", mech, ms)
+		}
+		cl.Close()
+	}
+
+	o := applyOptions([]Option{WithBrokers("b:9092")}).withDefaults()
+	cl, err := newKgoClient(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	if cl.OptValue(kgo.DialTLSConfig).(*tls.Config) != nil || len(cl.OptValue(kgo.SASL).([]sasl.Mechanism)) != 0 {
+		t.Error("security enabled without options")
+	}
+	if _, err := kgoSecurityOpts(Options{TLS: &TLSConfig{CAFile: "/nonexistent"}}); err == nil {
+		t.Error("missing CA file accepted")
+	}
+}
@@ -0,0 +1,53 @@
+//go:build !franzgo
+
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+func Test_Security_SaramaConfig(t *testing.T) {
+	pki := newTestPKI(t)
+	o := applyOptions([]Option{WithBrokers("b:9092"), WithSASL(SASLScramSHA512, "u", "p"),
+		WithTLS(TLSConfig{CAFile: pki.caFile, CertFile: pki.certFile, KeyFile: pki.keyFile, ServerName: "kafka.internal"})}).withDefaults()
+	cfg, err := buildSaramaConfig(o, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := cfg.Net
+	if !n.TLS.Enable || n.TLS.Config.ServerName != "kafka.internal" || n.TLS.Config.RootCAs == nil || len(n.TLS.Config.Certificates) != 1 {
+		t.Errorf("TLS=%+v", n.TLS)
+	}
+	if !n.SASL.Enable || n.SASL.Mechanism != sarama.SASLTypeSCRAMSHA512 || n.SASL.User != "u" || n.SASL.Password != "p" ||
+		n.SASL.Version != sarama.SASLHandshakeV1 || n.SASL.SCRAMClientGeneratorFunc == nil {
+		t.Errorf("SASL=%+v", n.SASL)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("sarama rejects the config: %v", err)
+	}
+
+	// the SCRAM adapter opens with the client-first message.
+	sc := cfg.Net.SASL.SCRAMClientGeneratorFunc()
+	mustNoErr(t, sc.Begin("u", "p", ""))
+	first, err := sc.Step("")
+	if err != nil || !strings.HasPrefix(first, "n,,n=u,r=") || sc.Done() {
+		t.Errorf("client-first=%q err=%v done=%v", first, err, sc.Done())
+	}
+	if _, err := sc.Step("garbage"); err == nil {
+		t.Error("malformed server-first accepted")
+	}
+
+	// PLAIN needs no SCRAM client; plaintext stays plaintext.
+	cfg, err = buildSaramaConfig(applyOptions([]Option{WithBrokers("b:9092"), WithTLS(TLSConfig{}),
+		WithSASL(SASLPlain, "u", "p")}).withDefaults(), false)
+	if err != nil || cfg.Net.SASL.Mechanism != sarama.SASLTypePlaintext || cfg.Net.SASL.SCRAMClientGeneratorFunc != nil {
+		t.Errorf("PLAIN: %v %+v", err, cfg.Net.SASL)
+	}
+	cfg, _ = buildSaramaConfig(applyOptions([]Option{WithBrokers("b:9092")}).withDefaults(), false)
+	if cfg.Net.TLS.Enable || cfg.Net.SASL.Enable {
+		t.Error("security enabled without options")
+	}
+}
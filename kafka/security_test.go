@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+)
+
+// testPKI is a throwaway CA plus a broker and a client certificate, written as
+// PEM files under t.TempDir().
+type testPKI struct {
+	caFile, certFile, keyFile string // CA bundle + client cert/key
+	server                    tls.Certificate
+	pool                      *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	caKey := mustKey(t)
+	caTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "kit4go test CA"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	issue := func(serial int64, usage x509.ExtKeyUsage, name string) ([]byte, *ecdsa.PrivateKey) {
+		key := mustKey(t)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial), Subject: pkix.Name{CommonName: name},
+			NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+			ExtKeyUsage: []x509.ExtKeyUsage{usage}, DNSNames: []string{name},
+			IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der, key
+	}
+	p := testPKI{
+		caFile:   filepath.Join(dir, "ca.pem"),
+		certFile: filepath.Join(dir, "client.pem"),
+		keyFile:  filepath.Join(dir, "client-key.pem"),
+		pool:     x509.NewCertPool(),
+	}
+	p.pool.AddCert(ca)
+	writePEM(t, p.caFile, "CERTIFICATE", caDER)
+	cDER, cKey := issue(2, x509.ExtKeyUsageClientAuth, "client")
+	writePEM(t, p.certFile, "CERTIFICATE", cDER)
+	writePEM(t, p.keyFile, "EC PRIVATE KEY", mustMarshalKey(t, cKey))
+	sDER, sKey := issue(3, x509.ExtKeyUsageServerAuth, "broker.test")
+	p.server = tls.Certificate{Certificate: [][]byte{sDER}, PrivateKey: sKey}
+	return p
+}
+
+func mustKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func mustMarshalKey(t *testing.T, k *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Security_Validate(t *testing.T) {
+	pki := newTestPKI(t)
+	junk := filepath.Join(t.TempDir(), "junk.pem")
+	if err := os.WriteFile(junk, []byte("not pem"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]struct {
+		opts []Option
+		want string // error substring; "" = accepted
+	}{
+		"scram plaintext":   {[]Option{WithSASL(SASLScramSHA256, "u", "p")}, ""},
+		"plain over tls":    {[]Option{WithSASL(SASLPlain, "u", "p"), WithTLS(TLSConfig{CAFile: pki.caFile})}, ""},
+		"mtls":              {[]Option{WithTLS(TLSConfig{CAFile: pki.caFile, CertFile: pki.certFile, KeyFile: pki.keyFile})}, ""},
+		"plain plaintext":   {[]Option{WithSASL(SASLPlain, "u", "p")}, "clear text"},
+		"unknown mechanism": {[]Option{WithSASL("GSSAPI", "u", "p")}, "unsupported sasl mechanism"},
+		"lowercase":         {[]Option{WithSASL("scram-sha-512", "u", "p")}, "unsupported sasl mechanism"},
+		"no password":       {[]Option{WithSASL(SASLScramSHA512, "u", "")}, "username and password"},
+		"cert without key":  {[]Option{WithTLS(TLSConfig{CertFile: pki.certFile})}, "set together"},
+		"insecure with ca":  {[]Option{WithTLS(TLSConfig{CAFile: pki.caFile, InsecureSkipVerify: true})}, "insecure_skip_verify"},
+		"missing ca":        {[]Option{WithTLS(TLSConfig{CAFile: junk + ".missing"})}, "ca_file"},
+		"junk ca":           {[]Option{WithTLS(TLSConfig{CAFile: junk})}, "no PEM certificates"},
+		"key mismatch":      {[]Option{WithTLS(TLSConfig{CertFile: pki.certFile, KeyFile: pki.caFile})}, "client certificate"},
+	}
+	for name, c := range cases {
+		opts := append([]Option{WithBrokers("127.0.0.1:1")}, c.opts...)
+		err := applyOptions(opts).withDefaults().validate("producer")
+		if c.want == "" && err != nil || c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+			t.Errorf("%s: err=%v want %q", name, err, c.want)
+		}
+		// every constructor goes through validate
+		if c.want != "" {
+			if _, err := NewConsumerGroup(append(opts, WithGroupID("g"))...); err == nil {
+				t.Errorf("%s: NewConsumerGroup accepted", name)
+			}
+		}
+	}
+}
+
+// Test_Security_KfakeTLSAndSASL runs each SASL mechanism over mutual TLS
+// against an in-process broker, on whichever backend is built.
+func Test_Security_KfakeTLSAndSASL(t *testing.T) {
+	pki := newTestPKI(t)
+	c, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.DefaultNumPartitions(1), kfake.SeedTopics(1, "secure"),
+		kfake.TLS(&tls.Config{Certificates: []tls.Certificate{pki.server}, ClientCAs: pki.pool,
+			ClientAuth: tls.RequireAndVerifyClientCert, MinVersion: tls.VersionTLS12}),
+		kfake.EnableSASL(),
+		kfake.Superuser(SASLPlain, "plain-user", "plain-pass"),
+		kfake.Superuser(SASLScramSHA256, "scram256", "pass-256"),
+		kfake.Superuser(SASLScramSHA512, "scram512", "pass-512"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tlsOpt := WithTLS(TLSConfig{CAFile: pki.caFile, CertFile: pki.certFile, KeyFile: pki.keyFile, ServerName: "broker.test"})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	for _, m := range []struct{ mech, user, pass string }{
+		{SASLPlain, "plain-user", "plain-pass"},
+		{SASLScramSHA256, "scram256", "pass-256"},
+		{SASLScramSHA512, "scram512", "pass-512"},
+	} {
+		sp, err := NewSyncProducer(WithBrokers(c.ListenAddrs()...), WithTopic("secure"), tlsOpt,
+			WithSASL(m.mech, m.user, m.pass), WithRetryMax(0))
+		if err != nil {
+			t.Fatalf("%s: %v", m.mech, err)
+		}
+		if _, _, err := sp.Send(ctx, Message{Value: []byte(m.mech)}); err != nil {
+			t.Errorf("%s: Send: %v", m.mech, err)
+		}
+		mustNoErr(t, sp.Close())
+	}
+	// a wrong password is refused by the broker.
+	sp, err := NewSyncProducer(WithBrokers(c.ListenAddrs()...), WithTopic("secure"), tlsOpt,
+		WithSASL(SASLScramSHA256, "scram256", "wrong"), WithRetryMax(0), WithProducerTimeout(2*time.Second))
+	if err == nil {
+		sctx, scancel := context.WithTimeout(ctx, 3*time.Second)
+		_, _, err = sp.Send(sctx, Message{Value: []byte("x")})
+		scancel()
+		_ = sp.Close()
+	}
+	if err == nil {
+		t.Error("wrong password accepted")
+	}
+}
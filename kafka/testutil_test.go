@@ -1,5 +1,3 @@
-//go:build !franzgo
-
 package kafka
 
 import (
@@ -0,0 +1,264 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Transaction tests run unchanged on both backends (no build tag) against an
+// in-process kfake broker, and verify what a read_committed consumer sees
+// through a plain kgo client.
+
+func txnCluster(t *testing.T, topics ...string) []string {
+	t.Helper()
+	opts := []kfake.Opt{kfake.NumBrokers(1), kfake.AllowAutoTopicCreation(), kfake.DefaultNumPartitions(1)}
+	if len(topics) > 0 {
+		opts = append(opts, kfake.SeedTopics(1, topics...))
+	}
+	c, err := kfake.NewCluster(opts...)
+	if err != nil {
+		t.Fatalf("kfake.NewCluster: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c.ListenAddrs()
+}
+
+// readCommitted returns the values a read_committed consumer sees on topic,
+// polling until want records arrived and then once more to catch extras.
+func readCommitted(t *testing.T, addrs []string, topic string, want int) []string {
+	t.Helper()
+	cl, err := kgo.NewClient(kgo.SeedBrokers(addrs...), kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()), kgo.FetchIsolationLevel(kgo.ReadCommitted()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	var got []string
+	deadline := time.Now().Add(5 * time.Second)
+	for extra := false; time.Now().Before(deadline); {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		fs := cl.PollFetches(ctx)
+		cancel()
+		fs.EachRecord(func(r *kgo.Record) { got = append(got, string(r.Value)) })
+		if len(got) >= want {
+			if extra {
+				break
+			}
+			extra = true
+		}
+	}
+	return got
+}
+
+// committedOffset fetches the group's committed offset for topic/partition 0.
+func committedOffset(t *testing.T, addrs []string, group, topic string) int64 {
+	t.Helper()
+	cl, err := kgo.NewClient(kgo.SeedBrokers(addrs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = group
+	rt := kmsg.NewOffsetFetchRequestTopic()
+	rt.Topic, rt.Partitions = topic, []int32{0}
+	req.Topics = append(req.Topics, rt)
+	resp, err := req.RequestWith(context.Background(), cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tp := range resp.Topics {
+		for _, p := range tp.Partitions {
+			return p.Offset
+		}
+	}
+	return -1
+}
+
+func Test_TransactionalProducer_CommitAbort(t *testing.T) {
+	addrs := txnCluster(t, "in")
+	ctx := context.Background()
+
+	if _, err := NewTransactionalProducer(WithBrokers(addrs...)); err == nil {
+		t.Fatal("missing transactional id accepted")
+	}
+	tp, err := NewTransactionalProducer(WithBrokers(addrs...), WithTopic("out"), WithTransactionalID("tx-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var events []string
+	tp.SetOnEvent(func(e ProducerEvent) {
+		mu.Lock()
+		events = append(events, e.Name)
+		mu.Unlock()
+	})
+
+	// committed: record + offsets.
+	mustNoErr(t, tp.BeginTxn())
+	mustNoErr(t, tp.Send(ctx, Message{Value: []byte("a")}))
+	mustNoErr(t, tp.SendOffsetsToTxn(ctx, "g1", map[string]map[int32]int64{"in": {0: 5}}))
+	mustNoErr(t, tp.CommitTxn(ctx))
+	// aborted: neither the record nor the offsets land.
+	mustNoErr(t, tp.BeginTxn())
+	mustNoErr(t, tp.Send(ctx, Message{Value: []byte("b")}))
+	mustNoErr(t, tp.SendOffsetsToTxn(ctx, "g1", map[string]map[int32]int64{"in": {0: 6}}))
+	mustNoErr(t, tp.AbortTxn(ctx))
+	if got := committedOffset(t, addrs, "g1", "in"); got != 5 {
+		t.Errorf("offset after abort=%d want 5", got)
+	}
+	// offsets only (a batch that produced nothing): sarama cannot publish
+	// them and says so; franz-go commits them.
+	wantOffset, wantAborted := int64(7), uint64(1)
+	mustNoErr(t, tp.BeginTxn())
+	mustNoErr(t, tp.SendOffsetsToTxn(ctx, "g1", map[string]map[int32]int64{"in": {0: 7}}))
+	if err := tp.CommitTxn(ctx); tp.Backend() == "sarama" {
+		if !errors.Is(err, ErrTxnOffsetsOnly) {
+			t.Fatalf("offsets-only commit on sarama: %v", err)
+		}
+		wantOffset, wantAborted = 5, 2
+	} else {
+		mustNoErr(t, err)
+	}
+	// the producer stays usable after an offsets-only transaction.
+	mustNoErr(t, tp.BeginTxn())
+	mustNoErr(t, tp.Send(ctx, Message{Value: []byte("c")}))
+	mustNoErr(t, tp.CommitTxn(ctx))
+
+	if got := readCommitted(t, addrs, "out", 2); strings.Join(got, ",") != "a,c" {
+		t.Errorf("read_committed=%v want [a c]", got)
+	}
+	if got := committedOffset(t, addrs, "g1", "in"); got != wantOffset {
+		t.Errorf("committed offset=%d want %d", got, wantOffset)
+	}
+	m := tp.Metrics()
+	if m.Begun != 4 || m.Committed != 4-wantAborted || m.Aborted != wantAborted || m.Success != 3 {
+		t.Errorf("Metrics=%+v", m)
+	}
+	mustNoErr(t, tp.Close())
+	mustNoErr(t, tp.Close())
+	if err := tp.BeginTxn(); !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("BeginTxn after Close: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if joined := strings.Join(events, ","); !strings.HasPrefix(joined, "begin,send,success,commit,begin") || !contains(events, "abort") || events[len(events)-1] != "close" {
+		t.Errorf("events=%v", events)
+	}
+}
+
+func Test_TransactionalProducer_SendCancelledCtx(t *testing.T) {
+	addrs := txnCluster(t, "out")
+	tp, err := NewTransactionalProducer(WithBrokers(addrs...), WithTopic("out"), WithTransactionalID("tx-ctx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mustNoErr(t, tp.BeginTxn())
+	if err := tp.Send(ctx, Message{Value: []byte("x")}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Send with cancelled ctx: %v", err)
+	}
+	if m := tp.Metrics(); m.Enqueued != 0 || m.Failed != 0 {
+		t.Errorf("cancelled Send reached the client: %+v", m)
+	}
+	mustNoErr(t, tp.Send(context.Background(), Message{Value: []byte("y")}))
+	mustNoErr(t, tp.CommitTxn(context.Background()))
+	if got := readCommitted(t, addrs, "out", 1); strings.Join(got, ",") != "y" {
+		t.Errorf("read_committed=%v want [y]", got)
+	}
+}
+
+func Test_TxnConsumerGroup_ExactlyOnce(t *testing.T) {
+	addrs := txnCluster(t, "in", "out")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sp, err := NewSyncProducer(WithBrokers(addrs...), WithTopic("in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make([]Message, 20)
+	for i := range in {
+		in[i] = Message{Value: []byte{'a' + byte(i)}}
+	}
+	mustNoErr(t, sp.SendBatch(ctx, in))
+	mustNoErr(t, sp.Close())
+
+	if _, err := NewTxnConsumerGroup(WithBrokers(addrs...), WithGroupID("etl")); err == nil {
+		t.Fatal("missing transactional id accepted")
+	}
+	g, err := NewTxnConsumerGroup(WithBrokers(addrs...), WithGroupID("etl"), WithTransactionalID("etl-0"),
+		WithTopic("out"), WithConsumerOffsetInitial(OffsetOldest), WithTxnMaxBatch(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	// the batch holding "h" fails once and the one holding "n" panics once:
+	// both abort and are re-delivered, so every output still appears once.
+	var failed, panicked bool
+	handler := func(_ context.Context, batch []Message) ([]Message, error) {
+		outs := make([]Message, 0, len(batch))
+		for _, m := range batch {
+			switch v := string(m.Value); {
+			case v == "h" && !failed:
+				failed = true
+				return nil, errBoom
+			case v == "n" && !panicked:
+				panicked = true
+				panic("transform bug")
+			default:
+				outs = append(outs, Message{Value: []byte(strings.ToUpper(v))})
+			}
+		}
+		return outs, nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- g.Consume(ctx, []string{"in"}, handler) }()
+
+	waitUntil(t, func() bool { return g.Metrics().Acked == 20 }, "all 20 inputs committed")
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Consume returned %v", err)
+	}
+
+	got := readCommitted(t, addrs, "out", 20)
+	if len(got) != 20 {
+		t.Fatalf("read_committed got %d records want 20: %v", len(got), got)
+	}
+	seen := map[string]bool{}
+	for _, v := range got {
+		if seen[v] {
+			t.Errorf("duplicate output %q", v)
+		}
+		seen[v] = true
+	}
+	if got := committedOffset(t, addrs, "etl", "in"); got != 20 {
+		t.Errorf("committed offset=%d want 20", got)
+	}
+	m, tm := g.Metrics(), g.TxnMetrics()
+	if m.Recovered != 1 || m.Failed == 0 || tm.Aborted < 2 || tm.Success != 20 {
+		t.Errorf("Metrics=%+v TxnMetrics=%+v", m, tm)
+	}
+	mustNoErr(t, g.Close())
+	if err := g.Consume(context.Background(), []string{"in"}, handler); !errors.Is(err, ErrProducerClosed) {
+		t.Errorf("Consume after Close: %v", err)
+	}
+}
+
+func mustNoErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
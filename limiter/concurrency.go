@@ -0,0 +1,404 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/v8fg/kit4go/latency"
+)
+
+// ErrLimitExceeded is returned by [ConcurrencyLimiter.Acquire] when the
+// in-flight limit is reached (and no slot freed within MaxWait).
+var ErrLimitExceeded = errors.New("limiter: concurrency limit exceeded")
+
+// Strategy identifiers accepted by [NewConcurrencyLimiter]. Use them via
+// [ConcurrencyLimiterOptions].
+const (
+	// StrategyVegas grows the limit while latency stays near the no-load RTT
+	// (the window minimum) and shrinks it as the estimated queue builds —
+	// TCP Vegas applied to requests. Reacts to latency before errors appear.
+	StrategyVegas = "vegas"
+
+	// StrategyGradient2 scales the limit by the ratio of the long-term RTT
+	// (the window median) to the recent RTT, plus a sqrt(limit) queue
+	// allowance. Tolerates Tolerance× latency growth before backing off.
+	StrategyGradient2 = "gradient2"
+
+	// StrategyAIMD adds one per sample while requests succeed and multiplies
+	// by Backoff on a drop (or a sample slower than Timeout). Loss-based: it
+	// needs drops to find the limit.
+	StrategyAIMD = "aimd"
+)
+
+// Outcome is how an admitted request ended, reported to its release func.
+type Outcome int
+
+const (
+	// OutcomeSuccess: the request completed; its latency is a valid sample.
+	OutcomeSuccess Outcome = iota
+	// OutcomeDropped: the request timed out or was rejected downstream for
+	// overload — the signal that shrinks the limit.
+	OutcomeDropped
+	// OutcomeIgnored: the request ended without telling anything about load
+	// (e.g. the client cancelled); only its slot is released.
+	OutcomeIgnored
+)
+
+// OutcomeOf maps a request's error to an Outcome: nil is OutcomeSuccess,
+// context.Canceled is OutcomeIgnored, any other error is OutcomeDropped. It is
+// the contract of [ConcurrencyLimiter.Admit]: pass nil for requests that
+// completed (application errors included) and an error only for overload.
+func OutcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, context.Canceled):
+		return OutcomeIgnored
+	}
+	return OutcomeDropped
+}
+
+// ConcurrencyLimiterOptions configures a [ConcurrencyLimiter]. Pass it to
+// [NewConcurrencyLimiter].
+type ConcurrencyLimiterOptions struct {
+	// Strategy selects how the limit adapts: [StrategyVegas] (default),
+	// [StrategyGradient2] or [StrategyAIMD].
+	Strategy string `json:"strategy" mapstructure:"strategy"`
+
+	// InitialLimit is the in-flight limit before any sample (default 20).
+	// MinLimit and MaxLimit bound the adapted limit (defaults 1 and 1000).
+	InitialLimit int `json:"initial_limit" mapstructure:"initial_limit"`
+	MinLimit     int `json:"min_limit" mapstructure:"min_limit"`
+	MaxLimit     int `json:"max_limit" mapstructure:"max_limit"`
+
+	// MaxWait is how long Acquire waits for a free slot at the limit, bounded
+	// by ctx. 0 (default) rejects at once — load shedding, not queueing.
+	MaxWait time.Duration `json:"max_wait" mapstructure:"max_wait"`
+
+	// Latency configures the histogram successful requests' latency is
+	// recorded in. Vegas reads the no-load RTT (minimum) and Gradient2 the
+	// long-term RTT (median) from its trailing window, refreshed every
+	// 100ms. Default 60s window.
+	Latency latency.Options `json:"latency" mapstructure:"latency"`
+
+	// Smoothing weighs each new limit against the current one, in (0, 1]
+	// (defaults: 1 for Vegas and AIMD, 0.2 for Gradient2).
+	Smoothing float64 `json:"smoothing" mapstructure:"smoothing"`
+
+	// Tolerance is the RTT growth Gradient2 accepts before shrinking
+	// (default 1.5, i.e. 50% over the long-term RTT).
+	Tolerance float64 `json:"tolerance" mapstructure:"tolerance"`
+
+	// Backoff is AIMD's multiplicative decrease, in (0, 1) (default 0.9).
+	// Timeout, when > 0, makes AIMD treat a slower success as a drop.
+	Backoff float64       `json:"backoff" mapstructure:"backoff"`
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+}
+
+// withDefaults returns a copy of o with zero values replaced by defaults.
+// An unknown Strategy is left for [NewConcurrencyLimiter] to reject.
+func (o ConcurrencyLimiterOptions) withDefaults() ConcurrencyLimiterOptions {
+	if o.Strategy == "" {
+		o.Strategy = StrategyVegas
+	}
+	if o.MinLimit <= 0 {
+		o.MinLimit = 1
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 1000
+	}
+	o.MaxLimit = max(o.MaxLimit, o.MinLimit)
+	if o.InitialLimit <= 0 {
+		o.InitialLimit = 20
+	}
+	o.InitialLimit = min(max(o.InitialLimit, o.MinLimit), o.MaxLimit)
+	if o.Smoothing <= 0 || o.Smoothing > 1 {
+		o.Smoothing = 1
+		if o.Strategy == StrategyGradient2 {
+			o.Smoothing = 0.2
+		}
+	}
+	if o.Tolerance < 1 {
+		o.Tolerance = 1.5
+	}
+	if o.Backoff <= 0 || o.Backoff >= 1 {
+		o.Backoff = 0.9
+	}
+	return o
+}
+
+// ConcurrencyMetrics is the observability snapshot returned by
+// [ConcurrencyLimiter.Metrics].
+type ConcurrencyMetrics struct {
+	// Limit is the current in-flight limit; InFlight the admitted requests
+	// not yet released.
+	Limit    int
+	InFlight int
+
+	// Admitted and Rejected count Acquire results; Dropped counts releases
+	// with OutcomeDropped.
+	Admitted uint64
+	Rejected uint64
+	Dropped  uint64
+
+	// Latency summarizes the successful requests in the histogram window.
+	Latency latency.Stats
+}
+
+// rttRefresh is how often a release re-reads the no-load and long-term RTT
+// from the latency window; folding the window on every release would put a
+// full histogram merge on the hot path.
+const rttRefresh = 100 * time.Millisecond
+
+// ConcurrencyLimiter bounds the requests in flight and discovers the bound
+// from observed latency and drops, like Netflix's concurrency-limits: the
+// limit grows while latency holds and shrinks when requests queue or fail,
+// so a service sheds load instead of queueing into a timeout. All methods are
+// safe for concurrent use.
+type ConcurrencyLimiter struct {
+	opts ConcurrencyLimiterOptions
+	rtt  *latency.Histogram
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	shortRTT float64 // Gradient2: recent RTT (EWMA, ns)
+	freed    chan struct{}
+	waiters  int
+
+	// noLoadRTT and longRTT are the window's minimum and median RTT (ns) as
+	// of the last refreshRTT, which is due at refreshAt (unix ns).
+	noLoadRTT atomic.Int64
+	longRTT   atomic.Int64
+	refreshAt atomic.Int64
+
+	admitted atomic.Uint64
+	rejected atomic.Uint64
+	dropped  atomic.Uint64
+
+	// now is the clock source (time.Now); tests inject a fake clock.
+	now func() time.Time
+}
+
+// NewConcurrencyLimiter builds a [ConcurrencyLimiter] from opts. It returns
+// nil for an unknown Strategy or invalid Latency options.
+func NewConcurrencyLimiter(opts ConcurrencyLimiterOptions) *ConcurrencyLimiter {
+	opts = opts.withDefaults()
+	switch opts.Strategy {
+	case StrategyVegas, StrategyGradient2, StrategyAIMD:
+	default:
+		return nil
+	}
+	h := latency.NewHistogram(opts.Latency)
+	if h == nil {
+		return nil
+	}
+	return &ConcurrencyLimiter{
+		opts:  opts,
+		rtt:   h,
+		limit: float64(opts.InitialLimit),
+		freed: make(chan struct{}),
+		now:   time.Now,
+	}
+}
+
+// Acquire admits one request, or returns [ErrLimitExceeded] when the limit
+// is reached (after waiting up to MaxWait for a slot), or ctx.Err() if ctx
+// ends first. On admission the caller must call release exactly once with
+// how the request ended; later calls are no-ops.
+func (c *ConcurrencyLimiter) Acquire(ctx context.Context) (release func(Outcome), err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	var deadline <-chan time.Time
+	for c.inflight >= int(c.limit) {
+		if c.opts.MaxWait <= 0 {
+			c.mu.Unlock()
+			c.rejected.Add(1)
+			return nil, ErrLimitExceeded
+		}
+		if deadline == nil {
+			t := time.NewTimer(c.opts.MaxWait)
+			defer t.Stop()
+			deadline = t.C
+		}
+		freed := c.freed
+		c.waiters++
+		c.mu.Unlock()
+		select {
+		case <-freed:
+		case <-deadline:
+			c.mu.Lock()
+			c.waiters--
+			c.mu.Unlock()
+			c.rejected.Add(1)
+			return nil, ErrLimitExceeded
+		case <-ctx.Done():
+			c.mu.Lock()
+			c.waiters--
+			c.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+		c.waiters--
+	}
+	c.inflight++
+	inflight := c.inflight
+	c.mu.Unlock()
+	c.admitted.Add(1)
+
+	start := c.now()
+	var once atomic.Bool
+	return func(o Outcome) {
+		if once.CompareAndSwap(false, true) {
+			c.release(o, c.now().Sub(start), inflight)
+		}
+	}, nil
+}
+
+// Admit is Acquire with the outcome reported as an error (see [OutcomeOf]).
+// It matches middleware.AcquireFunc and grpcserver.AcquireFunc, so those
+// adapters take c.Admit directly.
+func (c *ConcurrencyLimiter) Admit(ctx context.Context) (done func(error), err error) {
+	release, err := c.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(err error) { release(OutcomeOf(err)) }, nil
+}
+
+// release frees a slot and feeds the request's sample to the strategy.
+// inflight is the in-flight count when the request was admitted.
+func (c *ConcurrencyLimiter) release(o Outcome, rtt time.Duration, inflight int) {
+	if o == OutcomeSuccess {
+		c.rtt.Observe(rtt)
+		c.refreshRTT()
+	}
+	if o == OutcomeDropped {
+		c.dropped.Add(1)
+	}
+	c.mu.Lock()
+	c.inflight--
+	if o != OutcomeIgnored {
+		c.update(rtt, inflight, o == OutcomeDropped)
+	}
+	if c.waiters > 0 {
+		close(c.freed)
+		c.freed = make(chan struct{})
+	}
+	c.mu.Unlock()
+}
+
+// refreshRTT re-reads the no-load and long-term RTT from the latency window
+// once rttRefresh has passed. One releaser does the fold, outside c.mu; the
+// others keep the previous values.
+func (c *ConcurrencyLimiter) refreshRTT() {
+	if c.opts.Strategy == StrategyAIMD {
+		return
+	}
+	now := c.now().UnixNano()
+	at := c.refreshAt.Load()
+	if now < at || !c.refreshAt.CompareAndSwap(at, now+int64(rttRefresh)) {
+		return
+	}
+	s := c.rtt.Snapshot()
+	c.noLoadRTT.Store(int64(s.Min))
+	c.longRTT.Store(int64(s.P50))
+}
+
+// update adapts the limit to one sample in O(1). Caller holds c.mu.
+func (c *ConcurrencyLimiter) update(rtt time.Duration, inflight int, drop bool) {
+	limit := c.limit
+	next := limit
+	switch c.opts.Strategy {
+	case StrategyVegas:
+		next = c.vegas(limit, rtt, inflight, drop)
+	case StrategyGradient2:
+		next = c.gradient2(limit, rtt, inflight, drop)
+	case StrategyAIMD:
+		switch {
+		case drop || c.opts.Timeout > 0 && rtt > c.opts.Timeout:
+			next = limit * c.opts.Backoff
+		case float64(inflight)*2 >= limit:
+			next = limit + 1
+		}
+	}
+	next = limit + (next-limit)*c.opts.Smoothing
+	c.limit = min(max(next, float64(c.opts.MinLimit)), float64(c.opts.MaxLimit))
+}
+
+// vegas estimates the queue as limit × (1 − noLoadRTT/rtt) and keeps it
+// between alpha = 3·log10(limit) and beta = 6·log10(limit).
+func (c *ConcurrencyLimiter) vegas(limit float64, rtt time.Duration, inflight int, drop bool) float64 {
+	lg := math.Max(1, math.Log10(limit))
+	if drop {
+		return limit - lg
+	}
+	if float64(inflight)*2 < limit {
+		return limit // app-limited: the limit is not being tested
+	}
+	noLoad := c.noLoadRTT.Load()
+	if noLoad <= 0 || rtt <= 0 {
+		return limit
+	}
+	queue := math.Ceil(limit * (1 - float64(noLoad)/float64(rtt)))
+	switch {
+	case queue <= lg:
+		return limit + 6*lg
+	case queue < 3*lg:
+		return limit + lg
+	case queue > 6*lg:
+		return limit - lg
+	}
+	return limit
+}
+
+// gradient2 scales the limit by longRTT/shortRTT (times Tolerance, clamped
+// to [0.5, 1]) and adds sqrt(limit) of queueing headroom.
+func (c *ConcurrencyLimiter) gradient2(limit float64, rtt time.Duration, inflight int, drop bool) float64 {
+	if drop {
+		return limit / 2
+	}
+	sample := float64(rtt)
+	if c.shortRTT == 0 {
+		c.shortRTT = sample
+	} else {
+		c.shortRTT += (sample - c.shortRTT) * 0.5
+	}
+	if float64(inflight)*2 < limit {
+		return limit
+	}
+	long := float64(c.longRTT.Load())
+	if long <= 0 || c.shortRTT <= 0 {
+		return limit
+	}
+	gradient := math.Max(0.5, math.Min(1, c.opts.Tolerance*long/c.shortRTT))
+	return limit*gradient + math.Sqrt(limit)
+}
+
+// Limit returns the current in-flight limit.
+func (c *ConcurrencyLimiter) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// Metrics returns a best-effort snapshot of the limiter's state.
+func (c *ConcurrencyLimiter) Metrics() ConcurrencyMetrics {
+	c.mu.Lock()
+	limit, inflight := int(c.limit), c.inflight
+	c.mu.Unlock()
+	return ConcurrencyMetrics{
+		Limit:    limit,
+		InFlight: inflight,
+		Admitted: c.admitted.Load(),
+		Rejected: c.rejected.Load(),
+		Dropped:  c.dropped.Load(),
+		Latency:  c.rtt.Snapshot(),
+	}
+}
@@ -0,0 +1,279 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/v8fg/kit4go/latency"
+)
+
+func newTestConcurrency(t *testing.T, opts ConcurrencyLimiterOptions) (*ConcurrencyLimiter, *fakeClock) {
+	t.Helper()
+	c := NewConcurrencyLimiter(opts)
+	if c == nil {
+		t.Fatal("NewConcurrencyLimiter returned nil")
+	}
+	fc := newFakeClock()
+	c.now = fc.now
+	return c, fc
+}
+
+// loadRound admits as many requests as the limit allows, lets rtt pass and
+// releases them all with o: one round of a saturated service.
+func loadRound(c *ConcurrencyLimiter, fc *fakeClock, rtt time.Duration, o Outcome) int {
+	var releases []func(Outcome)
+	for {
+		release, err := c.Acquire(context.Background())
+		if err != nil {
+			break
+		}
+		releases = append(releases, release)
+	}
+	fc.add(rtt)
+	for _, release := range releases {
+		release(o)
+	}
+	return len(releases)
+}
+
+func TestConcurrencyLimiter_Options(t *testing.T) {
+	if NewConcurrencyLimiter(ConcurrencyLimiterOptions{Strategy: "nope"}) != nil {
+		t.Error("unknown strategy: want nil")
+	}
+	bad := latency.Options{Boundaries: []time.Duration{2, 1}}
+	if NewConcurrencyLimiter(ConcurrencyLimiterOptions{Latency: bad}) != nil {
+		t.Error("invalid boundaries: want nil")
+	}
+	c := NewConcurrencyLimiter(ConcurrencyLimiterOptions{})
+	if c.Limit() != 20 || c.opts.Strategy != StrategyVegas || c.opts.Smoothing != 1 {
+		t.Errorf("defaults: limit=%d %+v", c.Limit(), c.opts)
+	}
+	c = NewConcurrencyLimiter(ConcurrencyLimiterOptions{Strategy: StrategyGradient2, InitialLimit: 5000, MinLimit: 10, MaxLimit: 5})
+	if c.Limit() != 10 || c.opts.Smoothing != 0.2 {
+		t.Errorf("clamped: limit=%d %+v", c.Limit(), c.opts)
+	}
+}
+
+func TestConcurrencyLimiter_RejectAndWait(t *testing.T) {
+	fixed := ConcurrencyLimiterOptions{Strategy: StrategyAIMD, InitialLimit: 2, MinLimit: 2, MaxLimit: 2}
+	c := NewConcurrencyLimiter(fixed)
+	r1, _ := c.Acquire(context.Background())
+	r2, err := c.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Acquire(context.Background()); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("at the limit: %v", err)
+	}
+	r1(OutcomeIgnored)
+	r1(OutcomeDropped) // no-op: already released
+	if m := c.Metrics(); m.InFlight != 1 || m.Admitted != 2 || m.Rejected != 1 || m.Dropped != 0 {
+		t.Errorf("metrics: %+v", m)
+	}
+	r1, _ = c.Acquire(context.Background())
+
+	fixed.MaxWait = 2 * time.Second
+	w := NewConcurrencyLimiter(fixed)
+	w1, _ := w.Acquire(context.Background())
+	w.Acquire(context.Background())
+	got := make(chan error, 1)
+	go func() {
+		_, err := w.Acquire(context.Background())
+		got <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	w1(OutcomeSuccess)
+	if err := <-got; err != nil {
+		t.Errorf("waiter not admitted on release: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := w.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx ends before MaxWait: %v", err)
+	}
+	w.opts.MaxWait = 10 * time.Millisecond
+	if _, err := w.Acquire(context.Background()); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("MaxWait elapsed: %v", err)
+	}
+	if _, err := w.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("done ctx: %v", err)
+	}
+	r1(OutcomeSuccess)
+	r2(OutcomeSuccess)
+}
+
+func TestConcurrencyLimiter_Vegas(t *testing.T) {
+	c, fc := newTestConcurrency(t, ConcurrencyLimiterOptions{InitialLimit: 10, MaxLimit: 200})
+	for range 20 {
+		loadRound(c, fc, 10*time.Millisecond, OutcomeSuccess)
+	}
+	if c.Limit() != 200 {
+		t.Fatalf("steady latency: limit %d want 200", c.Limit())
+	}
+	for range 20 {
+		loadRound(c, fc, 100*time.Millisecond, OutcomeSuccess)
+	}
+	if l := c.Limit(); l >= 150 {
+		t.Errorf("queueing latency: limit %d did not shrink", l)
+	}
+	before := c.Limit()
+	loadRound(c, fc, 10*time.Millisecond, OutcomeDropped)
+	if c.Limit() >= before {
+		t.Errorf("drops: limit %d -> %d", before, c.Limit())
+	}
+
+	// One request at a time never tests the limit: it holds.
+	a, afc := newTestConcurrency(t, ConcurrencyLimiterOptions{})
+	for range 50 {
+		release, _ := a.Acquire(context.Background())
+		afc.add(time.Millisecond)
+		release(OutcomeSuccess)
+	}
+	if a.Limit() != 20 {
+		t.Errorf("app-limited: limit %d want 20", a.Limit())
+	}
+}
+
+func TestConcurrencyLimiter_Gradient2(t *testing.T) {
+	c, fc := newTestConcurrency(t, ConcurrencyLimiterOptions{Strategy: StrategyGradient2, InitialLimit: 10, MaxLimit: 100})
+	for range 30 {
+		loadRound(c, fc, 20*time.Millisecond, OutcomeSuccess)
+	}
+	high := c.Limit()
+	if high <= 50 {
+		t.Fatalf("steady latency: limit %d did not grow", high)
+	}
+	for range 3 {
+		loadRound(c, fc, 500*time.Millisecond, OutcomeSuccess)
+	}
+	if l := c.Limit(); l >= high/2 {
+		t.Errorf("latency spike: limit %d -> %d", high, l)
+	}
+	before := c.Limit()
+	loadRound(c, fc, 20*time.Millisecond, OutcomeDropped)
+	if c.Limit() >= before {
+		t.Errorf("drops: limit %d -> %d", before, c.Limit())
+	}
+}
+
+func TestConcurrencyLimiter_AIMD(t *testing.T) {
+	c, fc := newTestConcurrency(t, ConcurrencyLimiterOptions{Strategy: StrategyAIMD, InitialLimit: 10, Timeout: 50 * time.Millisecond})
+	loadRound(c, fc, 10*time.Millisecond, OutcomeSuccess)
+	// Requests admitted below half the limit do not test it: only the last
+	// six of the ten add one.
+	if c.Limit() != 16 {
+		t.Fatalf("additive increase: limit %d want 16", c.Limit())
+	}
+	c.Acquire(context.Background())
+	release, _ := c.Acquire(context.Background())
+	fc.add(10 * time.Millisecond)
+	release(OutcomeDropped)
+	if c.Limit() != 14 {
+		t.Errorf("multiplicative decrease: limit %d want 14", c.Limit())
+	}
+	release, _ = c.Acquire(context.Background())
+	fc.add(time.Second)
+	release(OutcomeSuccess)
+	if c.Limit() != 12 {
+		t.Errorf("slower than Timeout: limit %d want 12", c.Limit())
+	}
+	if m := c.Metrics(); m.Dropped != 1 || m.Latency.Count != 11 || m.InFlight != 1 {
+		t.Errorf("metrics: %+v", m)
+	}
+}
+
+func TestConcurrencyLimiter_Admit(t *testing.T) {
+	for err, want := range map[error]Outcome{
+		nil:                      OutcomeSuccess,
+		context.Canceled:         OutcomeIgnored,
+		context.DeadlineExceeded: OutcomeDropped,
+		ErrLimitExceeded:         OutcomeDropped,
+	} {
+		if got := OutcomeOf(err); got != want {
+			t.Errorf("OutcomeOf(%v)=%d want %d", err, got, want)
+		}
+	}
+	c := NewConcurrencyLimiter(ConcurrencyLimiterOptions{Strategy: StrategyAIMD, InitialLimit: 1, MaxLimit: 1})
+	done, err := c.Admit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Admit(context.Background()); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("second Admit: %v", err)
+	}
+	done(context.DeadlineExceeded)
+	if m := c.Metrics(); m.InFlight != 0 || m.Dropped != 1 {
+		t.Errorf("metrics: %+v", m)
+	}
+}
+
+func TestConcurrencyLimiter_Concurrent(t *testing.T) {
+	c := NewConcurrencyLimiter(ConcurrencyLimiterOptions{Strategy: StrategyGradient2, MaxWait: time.Millisecond})
+	var wg sync.WaitGroup
+	for g := range 16 {
+		wg.Go(func() {
+			for i := range 300 {
+				release, err := c.Acquire(context.Background())
+				if err != nil {
+					continue
+				}
+				c.Metrics()
+				release(Outcome((g + i) % 3))
+			}
+		})
+	}
+	wg.Wait()
+	m := c.Metrics()
+	if m.InFlight != 0 || m.Admitted+m.Rejected != 16*300 {
+		t.Errorf("metrics: %+v", m)
+	}
+}
+
+// BenchmarkConcurrencyLimiter_Acquire_Parallel measures an Acquire / release
+// pair under contention: the admission lock, a histogram Observe and the
+// Vegas update.
+func BenchmarkConcurrencyLimiter_Acquire_Parallel(b *testing.B) {
+	c := NewConcurrencyLimiter(ConcurrencyLimiterOptions{InitialLimit: 1000, MinLimit: 1000})
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if release, err := c.Acquire(ctx); err == nil {
+				release(OutcomeSuccess)
+			}
+		}
+	})
+}
+
+// BenchmarkConcurrencyLimiter_Saturated_Parallel measures Acquire / release
+// with the limit under test (half the slots held), so every release runs the
+// strategy's latency-driven update rather than the app-limited shortcut.
+func BenchmarkConcurrencyLimiter_Saturated_Parallel(b *testing.B) {
+	for _, strategy := range []string{StrategyVegas, StrategyGradient2} {
+		b.Run(strategy, func(b *testing.B) {
+			c := NewConcurrencyLimiter(ConcurrencyLimiterOptions{Strategy: strategy,
+				InitialLimit: 1024, MinLimit: 1024, MaxLimit: 1024})
+			ctx := context.Background()
+			for range 512 {
+				release, _ := c.Acquire(ctx)
+				defer release(OutcomeIgnored)
+			}
+			for i := range 10_000 { // a populated latency window
+				c.rtt.Observe(time.Duration(i%500) * time.Microsecond)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if release, err := c.Acquire(ctx); err == nil {
+						release(OutcomeSuccess)
+					}
+				}
+			})
+		})
+	}
+}
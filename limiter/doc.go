@@ -22,6 +22,28 @@
 //	    Window:    time.Second,
 //	})
 //
+// # Per-key limits
+//
+// [KeyedLimiter] lazily keeps one limiter per key (tenant, user, IP), with
+// per-key overrides, a MaxKeys bound and idle eviction:
+//
+//	kl := limiter.NewKeyedLimiter(limiter.KeyedLimiterOptions{
+//	    Limiter: limiter.LimiterOptions{Rate: 100, Burst: 20},
+//	    IdleTTL: 10 * time.Minute,
+//	})
+//	defer kl.Close()
+//	if !kl.Allow(tenantID) { ... }
+//
+// # Adaptive concurrency
+//
+// [ConcurrencyLimiter] bounds in-flight requests and adapts the bound to
+// observed latency and drops (Vegas, Gradient2 or AIMD):
+//
+//	cl := limiter.NewConcurrencyLimiter(limiter.ConcurrencyLimiterOptions{})
+//	release, err := cl.Acquire(ctx)
+//	if err != nil { ... } // ErrLimitExceeded: shed the request
+//	defer release(limiter.OutcomeSuccess)
+//
 // # Performance
 //
 //	BenchmarkTokenBucket_Allow          69 ns    0 allocs
@@ -0,0 +1,415 @@
+package limiter
+
+import (
+	"cmp"
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyedLimiterOptions configures a [KeyedLimiter]. Pass it to
+// [NewKeyedLimiter].
+type KeyedLimiterOptions struct {
+	// Limiter is the per-key limiter every key gets unless Resolve overrides
+	// it. Same rules as [NewLimiter]; Rate is required.
+	Limiter LimiterOptions `json:"limiter" mapstructure:"limiter"`
+
+	// Resolve, when set, returns the options for a key on its first use (a
+	// tenant's plan, a route's quota, ...). Returning false — or options
+	// [NewLimiter] rejects — falls back to Limiter. It is called once per key
+	// creation, under the key's shard lock, so it must be fast and must not
+	// call back into the KeyedLimiter.
+	Resolve func(key string) (LimiterOptions, bool) `json:"-" mapstructure:"-"`
+
+	// MaxKeys bounds the number of live keys across all shards (<= 0
+	// unlimited). A new key beyond it evicts the least recently used key of
+	// its own shard or, when that shard holds no other key, the least
+	// recently used key overall; the evicted key's next request starts from
+	// a fresh limiter.
+	MaxKeys int `json:"max_keys" mapstructure:"max_keys"`
+
+	// IdleTTL evicts keys not used for this long (<= 0 never), so callers that
+	// never come back do not leak. A background sweep runs every IdleTTL/2
+	// (at least 100ms) until [KeyedLimiter.Close].
+	IdleTTL time.Duration `json:"idle_ttl" mapstructure:"idle_ttl"`
+
+	// Shards is the number of independently locked key maps (<= 0 selects
+	// 4×GOMAXPROCS, at least 16), rounded up to a power of two.
+	Shards int `json:"shards" mapstructure:"shards"`
+}
+
+// KeyedLimiterMetrics is the observability snapshot returned by
+// [KeyedLimiter.Metrics].
+type KeyedLimiterMetrics struct {
+	// LimiterMetrics aggregates every key's calls, evicted keys included.
+	LimiterMetrics
+
+	// Keys is the number of live keys.
+	Keys int
+
+	// Created and Evicted count key creations and evictions (idle, MaxKeys or
+	// Remove) over the limiter's lifetime.
+	Created uint64
+	Evicted uint64
+}
+
+// KeyDenied is one entry of [KeyedLimiter.TopThrottled].
+type KeyDenied struct {
+	Key    string
+	Denied uint64
+}
+
+// KeyedLimiter is a registry of per-key limiters — one per tenant, user, IP
+// or route — created lazily on a key's first request. Keys are spread over
+// independently locked shards; an existing key costs a read lock and a map
+// lookup before its limiter runs. All methods are safe for concurrent use.
+type KeyedLimiter struct {
+	opts   KeyedLimiterOptions
+	shards []keyedShard
+	mask   uint64
+	live   atomic.Int64 // keys across all shards, for MaxKeys
+
+	allowed  atomic.Uint64
+	denied   atomic.Uint64
+	acquired atomic.Uint64
+	created  atomic.Uint64
+	evicted  atomic.Uint64
+
+	closed    atomic.Bool
+	stop      chan struct{}
+	closeOnce sync.Once
+	done      chan struct{} // closed when the sweep goroutine exits; nil without one
+
+	// now is the clock source (time.Now); tests inject a fake clock.
+	now func() time.Time
+}
+
+type keyedShard struct {
+	mu   sync.RWMutex
+	keys map[string]*keyedEntry
+}
+
+type keyedEntry struct {
+	lim     Limiter
+	lastUse atomic.Int64 // unix nano
+}
+
+// NewKeyedLimiter builds a [KeyedLimiter] from opts. It returns nil when
+// opts.Limiter is invalid (see [NewLimiter]).
+func NewKeyedLimiter(opts KeyedLimiterOptions) *KeyedLimiter {
+	probe := NewLimiter(opts.Limiter)
+	if probe == nil {
+		return nil
+	}
+	probe.Close()
+	n := opts.Shards
+	if n <= 0 {
+		n = max(4*runtime.GOMAXPROCS(0), 16)
+	}
+	n = nextPow2(n)
+	k := &KeyedLimiter{
+		opts:   opts,
+		shards: make([]keyedShard, n),
+		mask:   uint64(n - 1),
+		stop:   make(chan struct{}),
+		now:    time.Now,
+	}
+	for i := range k.shards {
+		k.shards[i].keys = make(map[string]*keyedEntry)
+	}
+	if opts.IdleTTL > 0 {
+		k.done = make(chan struct{})
+		go k.sweepLoop(max(opts.IdleTTL/2, 100*time.Millisecond))
+	}
+	return k
+}
+
+func nextPow2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// Allow reports whether one request for key may proceed (see
+// [Limiter.Allow]). It returns false after Close.
+func (k *KeyedLimiter) Allow(key string) bool {
+	if k.closed.Load() {
+		return false
+	}
+	return k.count(k.entry(key).Allow(), 1)
+}
+
+// TryAcquire acquires n tokens for key at once (see [Limiter.TryAcquire]).
+func (k *KeyedLimiter) TryAcquire(key string, n int) bool {
+	if n <= 0 {
+		return true
+	}
+	if k.closed.Load() {
+		return false
+	}
+	return k.count(k.entry(key).TryAcquire(n), n)
+}
+
+// Wait blocks until one token for key is acquired or ctx is done (see
+// [Limiter.Wait]). After Close it returns ctx.Err() if ctx is done, else
+// [ErrLimiterClosed].
+func (k *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	if k.closed.Load() {
+		return closedWaitResult(ctx)
+	}
+	if err := k.entry(key).Wait(ctx); err != nil {
+		return err
+	}
+	k.count(true, 1)
+	return nil
+}
+
+// Limiter returns key's limiter, creating it if needed, for callers that
+// hold on to it (e.g. one per connection). A limiter later evicted keeps
+// working for its holders, but no longer shares state with new requests for
+// key. It returns nil after Close.
+func (k *KeyedLimiter) Limiter(key string) Limiter {
+	if k.closed.Load() {
+		return nil
+	}
+	return k.entry(key)
+}
+
+func (k *KeyedLimiter) count(ok bool, n int) bool {
+	if ok {
+		k.allowed.Add(1)
+		k.acquired.Add(uint64(n))
+	} else {
+		k.denied.Add(1)
+	}
+	return ok
+}
+
+// entry returns key's limiter, creating it (and making room under MaxKeys)
+// on first use, and marks it used. A limiter created while Close runs is
+// closed here, since Close may have visited its shard already.
+func (k *KeyedLimiter) entry(key string) Limiter {
+	now := k.now().UnixNano()
+	s := &k.shards[hashKey(key)&k.mask]
+	s.mu.RLock()
+	e := s.keys[key]
+	s.mu.RUnlock()
+	if e == nil {
+		created := false
+		s.mu.Lock()
+		if e = s.keys[key]; e == nil {
+			if k.opts.MaxKeys > 0 && k.live.Load() >= int64(k.opts.MaxKeys) {
+				k.evictOldest(s)
+			}
+			e = &keyedEntry{lim: k.newLimiter(key)}
+			if k.closed.Load() {
+				e.lim.Close()
+			}
+			e.lastUse.Store(now)
+			s.keys[key] = e
+			k.live.Add(1)
+			k.created.Add(1)
+			created = true
+		}
+		s.mu.Unlock()
+		if created && k.opts.MaxKeys > 0 {
+			k.trim()
+		}
+	}
+	e.lastUse.Store(now)
+	return e.lim
+}
+
+// trim evicts the least recently used keys overall until MaxKeys holds
+// again, for a key created in a shard with no other key to give up (or
+// racing a creation in another shard). It scans every key, one shard lock at
+// a time; since a shard without other keys is the common case only when
+// MaxKeys is small, so is the scan.
+func (k *KeyedLimiter) trim() {
+	for k.live.Load() > int64(k.opts.MaxKeys) {
+		var (
+			victim *keyedShard
+			at     int64
+		)
+		for i := range k.shards {
+			s := &k.shards[i]
+			s.mu.RLock()
+			for _, e := range s.keys {
+				if t := e.lastUse.Load(); victim == nil || t < at {
+					victim, at = s, t
+				}
+			}
+			s.mu.RUnlock()
+		}
+		if victim == nil {
+			return
+		}
+		victim.mu.Lock()
+		k.evictOldest(victim)
+		victim.mu.Unlock()
+	}
+}
+
+func (k *KeyedLimiter) newLimiter(key string) Limiter {
+	if k.opts.Resolve != nil {
+		if o, ok := k.opts.Resolve(key); ok {
+			if l := NewLimiter(o); l != nil {
+				return l
+			}
+		}
+	}
+	return NewLimiter(k.opts.Limiter)
+}
+
+// evictOldest drops the least recently used key of s. Caller holds s.mu.
+func (k *KeyedLimiter) evictOldest(s *keyedShard) {
+	var (
+		oldest string
+		at     int64
+		found  bool
+	)
+	for key, e := range s.keys {
+		if t := e.lastUse.Load(); !found || t < at {
+			oldest, at, found = key, t, true
+		}
+	}
+	if found {
+		delete(s.keys, oldest)
+		k.live.Add(-1)
+		k.evicted.Add(1)
+	}
+}
+
+// Remove forgets key; its next request starts from a fresh limiter.
+func (k *KeyedLimiter) Remove(key string) {
+	s := &k.shards[hashKey(key)&k.mask]
+	s.mu.Lock()
+	if _, ok := s.keys[key]; ok {
+		delete(s.keys, key)
+		k.live.Add(-1)
+		k.evicted.Add(1)
+	}
+	s.mu.Unlock()
+}
+
+func (k *KeyedLimiter) sweepLoop(every time.Duration) {
+	defer close(k.done)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-t.C:
+			k.sweep()
+		}
+	}
+}
+
+// sweep evicts the keys idle for IdleTTL or longer.
+func (k *KeyedLimiter) sweep() {
+	cutoff := k.now().Add(-k.opts.IdleTTL).UnixNano()
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.mu.Lock()
+		for key, e := range s.keys {
+			if e.lastUse.Load() <= cutoff {
+				delete(s.keys, key)
+				k.live.Add(-1)
+				k.evicted.Add(1)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Len returns the number of live keys.
+func (k *KeyedLimiter) Len() int {
+	n := 0
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.mu.RLock()
+		n += len(s.keys)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Metrics returns a best-effort snapshot of the aggregate counters.
+func (k *KeyedLimiter) Metrics() KeyedLimiterMetrics {
+	return KeyedLimiterMetrics{
+		LimiterMetrics: LimiterMetrics{
+			Allowed:  k.allowed.Load(),
+			Denied:   k.denied.Load(),
+			Acquired: k.acquired.Load(),
+		},
+		Keys:    k.Len(),
+		Created: k.created.Load(),
+		Evicted: k.evicted.Load(),
+	}
+}
+
+// TopThrottled returns up to n live keys with the most denied requests, most
+// denied first (ties by key). Keys never denied are left out. It walks every
+// key, so call it from a report or a debug endpoint, not per request.
+func (k *KeyedLimiter) TopThrottled(n int) []KeyDenied {
+	if n <= 0 {
+		return nil
+	}
+	var out []KeyDenied
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.mu.RLock()
+		for key, e := range s.keys {
+			if d := e.lim.Metrics().Denied; d > 0 {
+				out = append(out, KeyDenied{Key: key, Denied: d})
+			}
+		}
+		s.mu.RUnlock()
+	}
+	slices.SortFunc(out, func(a, b KeyDenied) int {
+		if c := cmp.Compare(b.Denied, a.Denied); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// Close stops the idle sweep, closes every key's limiter (unblocking their
+// Waits) and makes Allow / TryAcquire / Wait no-ops. Idempotent.
+func (k *KeyedLimiter) Close() {
+	k.closeOnce.Do(func() {
+		k.closed.Store(true)
+		close(k.stop)
+		if k.done != nil {
+			<-k.done
+		}
+		for i := range k.shards {
+			s := &k.shards[i]
+			s.mu.RLock()
+			for _, e := range s.keys {
+				e.lim.Close()
+			}
+			s.mu.RUnlock()
+		}
+	})
+}
+
+// hashKey is 64-bit FNV-1a, inlined to stay allocation-free.
+func hashKey(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,274 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestKeyed builds a KeyedLimiter on a fake clock: keys get a token
+// bucket with a slow refill, so a burst is what a key can spend.
+func newTestKeyed(t *testing.T, opts KeyedLimiterOptions) (*KeyedLimiter, *fakeClock) {
+	t.Helper()
+	if opts.Limiter.Rate == 0 {
+		opts.Limiter = LimiterOptions{Rate: 0.001, Burst: 2}
+	}
+	k := NewKeyedLimiter(opts)
+	if k == nil {
+		t.Fatal("NewKeyedLimiter returned nil")
+	}
+	t.Cleanup(k.Close)
+	fc := newFakeClock()
+	k.now = fc.now
+	return k, fc
+}
+
+func TestKeyedLimiter_PerKeyAndResolve(t *testing.T) {
+	k, _ := newTestKeyed(t, KeyedLimiterOptions{
+		Resolve: func(key string) (LimiterOptions, bool) {
+			switch key {
+			case "premium":
+				return LimiterOptions{Rate: 0.001, Burst: 5}, true
+			case "broken":
+				return LimiterOptions{Algorithm: "nope", Rate: 1}, true
+			}
+			return LimiterOptions{}, false
+		},
+	})
+	allowed := func(key string) (n int) {
+		for range 10 {
+			if k.Allow(key) {
+				n++
+			}
+		}
+		return n
+	}
+	for key, want := range map[string]int{"a": 2, "b": 2, "premium": 5, "broken": 2} {
+		if got := allowed(key); got != want {
+			t.Errorf("%s: allowed %d want %d", key, got, want)
+		}
+	}
+	if k.TryAcquire("c", 3) || !k.TryAcquire("c", 2) || !k.TryAcquire("c", 0) {
+		t.Error("TryAcquire")
+	}
+	if err := k.Wait(context.Background(), "d"); err != nil {
+		t.Errorf("Wait: %v", err)
+	}
+	if l := k.Limiter("d"); l == nil || !l.Allow() || l.Allow() {
+		t.Error("Limiter(d) is not the key's limiter")
+	}
+
+	m := k.Metrics()
+	// a, b, broken: 2+2+2 allowed, premium 5, c 1 (2 tokens), d 1 (Wait);
+	// denied: 8+8+8+5 Allow, 1 TryAcquire.
+	if m.Allowed != 13 || m.Acquired != 14 || m.Denied != 30 || m.Keys != 6 || m.Created != 6 {
+		t.Errorf("metrics: %+v", m)
+	}
+	top := k.TopThrottled(2)
+	if len(top) != 2 || top[0] != (KeyDenied{"a", 8}) || top[1] != (KeyDenied{"b", 8}) {
+		t.Errorf("TopThrottled: %v", top)
+	}
+	if k.TopThrottled(0) != nil {
+		t.Error("TopThrottled(0)")
+	}
+}
+
+func TestKeyedLimiter_MaxKeysEvictsLeastRecentlyUsed(t *testing.T) {
+	k, fc := newTestKeyed(t, KeyedLimiterOptions{MaxKeys: 2, Shards: 1})
+	k.Allow("a")
+	k.Allow("a")
+	fc.add(time.Second)
+	k.Allow("b")
+	fc.add(time.Second)
+	k.Allow("a") // a is now the most recent
+	fc.add(time.Second)
+	k.Allow("c") // evicts b
+	if k.Len() != 2 {
+		t.Fatalf("Len=%d want 2", k.Len())
+	}
+	if k.Allow("a") {
+		t.Error("a was evicted: its spent burst came back")
+	}
+	if !k.Allow("b") || !k.Allow("b") {
+		t.Error("b was not evicted")
+	}
+	if m := k.Metrics(); m.Evicted != 2 || m.Created != 4 {
+		t.Errorf("metrics: %+v", m) // b, then a to make room for b again
+	}
+	k.Remove("b")
+	k.Remove("missing")
+	if m := k.Metrics(); m.Evicted != 3 || m.Keys != 1 {
+		t.Errorf("after Remove: %+v", m)
+	}
+}
+
+func TestKeyedLimiter_MaxKeysIsGlobal(t *testing.T) {
+	k, fc := newTestKeyed(t, KeyedLimiterOptions{MaxKeys: 4, Shards: 16})
+	// A fixed key set: 4 keys sharing shard 0, and 12 keys in 12 distinct
+	// other shards.
+	var same, spread []string
+	seen := map[uint64]bool{0: true}
+	for i := 0; len(same) < 4 || len(spread) < 12; i++ {
+		key := fmt.Sprintf("k%d", i)
+		switch sh := hashKey(key) & k.mask; {
+		case sh == 0 && len(same) < 4:
+			same = append(same, key)
+		case !seen[sh] && len(spread) < 12:
+			seen[sh] = true
+			spread = append(spread, key)
+		}
+	}
+
+	// Colliding keys fill the bound without evicting each other.
+	for _, key := range same {
+		fc.add(time.Second)
+		k.Allow(key)
+	}
+	if m := k.Metrics(); m.Keys != 4 || m.Evicted != 0 {
+		t.Fatalf("colliding keys: %+v", m)
+	}
+	// Keys in other shards never push the total past MaxKeys; the least
+	// recently used keys go first.
+	for i, key := range spread {
+		fc.add(time.Second)
+		k.Allow(key)
+		if n := k.Len(); n != 4 {
+			t.Fatalf("after %d spread keys: Len=%d want 4", i+1, n)
+		}
+	}
+	if m := k.Metrics(); m.Created != 16 || m.Evicted != 12 {
+		t.Errorf("metrics: %+v", m)
+	}
+	for _, key := range spread[8:] {
+		if !k.Limiter(key).Allow() {
+			t.Errorf("%s was evicted before older keys", key)
+		}
+	}
+	if k.Len() != 4 {
+		t.Errorf("Len=%d want 4", k.Len())
+	}
+}
+
+func TestKeyedLimiter_IdleTTL(t *testing.T) {
+	k, fc := newTestKeyed(t, KeyedLimiterOptions{IdleTTL: time.Minute})
+	k.Allow("idle")
+	k.Allow("idle")
+	fc.add(40 * time.Second)
+	k.Allow("busy")
+	fc.add(20 * time.Second)
+	k.sweep()
+	if k.Len() != 1 {
+		t.Fatalf("Len=%d want 1 (idle swept, busy kept)", k.Len())
+	}
+	if !k.Allow("idle") {
+		t.Error("a swept key did not start over")
+	}
+	if m := k.Metrics(); m.Evicted != 1 {
+		t.Errorf("Evicted=%d want 1", m.Evicted)
+	}
+}
+
+func TestKeyedLimiter_SweepLoop(t *testing.T) {
+	k := NewKeyedLimiter(KeyedLimiterOptions{
+		Limiter: LimiterOptions{Rate: 100, Burst: 1},
+		IdleTTL: 50 * time.Millisecond,
+	})
+	defer k.Close()
+	k.Allow("x")
+	deadline := time.Now().Add(3 * time.Second)
+	for k.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if k.Len() != 0 {
+		t.Error("idle key never swept")
+	}
+}
+
+func TestKeyedLimiter_Close(t *testing.T) {
+	if NewKeyedLimiter(KeyedLimiterOptions{}) != nil {
+		t.Error("zero Rate: want nil")
+	}
+	if NewKeyedLimiter(KeyedLimiterOptions{Limiter: LimiterOptions{Algorithm: "nope", Rate: 1}}) != nil {
+		t.Error("unknown algorithm: want nil")
+	}
+
+	k := NewKeyedLimiter(KeyedLimiterOptions{Limiter: LimiterOptions{Rate: 0.001, Burst: 1}, IdleTTL: time.Hour})
+	k.Allow("a")
+	waited := make(chan error, 1)
+	go func() { waited <- k.Wait(context.Background(), "a") }()
+	time.Sleep(20 * time.Millisecond)
+	k.Close()
+	k.Close()
+	select {
+	case err := <-waited:
+		if !errors.Is(err, ErrLimiterClosed) {
+			t.Errorf("blocked Wait: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not unblock Wait")
+	}
+	if k.Allow("b") || k.TryAcquire("b", 1) || k.Limiter("b") != nil {
+		t.Error("calls after Close")
+	}
+	if err := k.Wait(context.Background(), "b"); !errors.Is(err, ErrLimiterClosed) {
+		t.Errorf("Wait after Close: %v", err)
+	}
+	// a call that passed the closed check before Close creates its key after
+	// Close visited the shard: the new limiter is closed all the same.
+	if err := k.entry("c").Wait(context.Background()); !errors.Is(err, ErrLimiterClosed) {
+		t.Errorf("key created during Close: Wait=%v", err)
+	}
+}
+
+func TestKeyedLimiter_Concurrent(t *testing.T) {
+	k := NewKeyedLimiter(KeyedLimiterOptions{
+		Limiter: LimiterOptions{Rate: 1e6, Burst: 1000},
+		MaxKeys: 64,
+		IdleTTL: 100 * time.Millisecond,
+		Shards:  4,
+	})
+	defer k.Close()
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 2000 {
+				key := fmt.Sprintf("k%d", (g*7+i)%200)
+				k.Allow(key)
+				k.TryAcquire(key, 2)
+				if i%100 == 0 {
+					k.TopThrottled(3)
+					k.Remove(key)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	m := k.Metrics()
+	if m.Allowed+m.Denied != 8*2000*2 || m.Keys > 64 {
+		t.Errorf("metrics: %+v", m)
+	}
+}
+
+// BenchmarkKeyedLimiter_Allow_Parallel measures Allow on existing keys from
+// many goroutines: shard read lock, map lookup, then the key's token bucket.
+func BenchmarkKeyedLimiter_Allow_Parallel(b *testing.B) {
+	k := NewKeyedLimiter(KeyedLimiterOptions{Limiter: LimiterOptions{Rate: 1e9, Burst: 1 << 20}})
+	defer k.Close()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("tenant-%d", i)
+		k.Allow(keys[i])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_ = k.Allow(keys[i&1023])
+			i++
+		}
+	})
+}
@@ -0,0 +1,509 @@
+package log4go
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdminHandlerOptions configures NewAdminHandler.
+type AdminHandlerOptions struct {
+	// Auth authorizes every mutating request (anything but GET/HEAD): return
+	// nil to allow, an error to reject with 403 and the error text. nil Auth
+	// disables the mutating endpoints entirely — the read-only ones stay up —
+	// so an admin handler is never writable by accident. See AdminBearerAuth.
+	Auth func(r *http.Request) error
+	// Audit, when set, is called after every successful mutation with the
+	// request and a short description ("pause file_writer", "level DEBUG").
+	// Default: a "[log4go] admin: ..." line on the standard logger.
+	Audit func(r *http.Request, action string)
+}
+
+// AdminBearerAuth returns an AdminHandlerOptions.Auth that accepts
+// "Authorization: Bearer <token>" (constant-time compare). An empty token
+// rejects everything.
+func AdminBearerAuth(token string) func(*http.Request) error {
+	return func(r *http.Request) error {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return errors.New("log4go: admin: unauthorized")
+		}
+		return nil
+	}
+}
+
+// AdminStatus is the JSON body of GET /status.
+type AdminStatus struct {
+	Level          string                        `json:"level"`
+	Format         string                        `json:"format"`
+	Caller         bool                          `json:"caller"`
+	FuncName       bool                          `json:"func_name"`
+	FullPath       bool                          `json:"full_path"`
+	Sampling       AdminSampling                 `json:"sampling"`
+	Writers        []AdminWriterStatus           `json:"writers"`
+	Levels         map[string]AdminLevelCounters `json:"levels"`
+	LevelOverrides []LevelOverride               `json:"level_overrides"`
+	RingDump       *RingDumpMetrics              `json:"ring_dump,omitempty"`
+}
+
+// AdminSampling is the sampling section of AdminStatus, also the body of
+// PUT /sampling (see NewAdminHandler).
+type AdminSampling struct {
+	// Strategy is the id-based strategy: "full" | "trace_id_ratio:<r>" |
+	// "tail_digit:<modulus>:<keep>" (see ParseSamplingStrategy).
+	Strategy string `json:"strategy,omitempty"`
+	// Duration, on PUT, installs Strategy only for that long (Go duration,
+	// e.g. "30m"), then reverts to the previous one.
+	Duration string `json:"duration,omitempty"`
+	// Initial / Thereafter are the rate sampler (SetSampling); both 0 = off.
+	Initial    *int `json:"initial,omitempty"`
+	Thereafter *int `json:"thereafter,omitempty"`
+	// PriorityLevel is the level at or above which records bypass sampling
+	// ("" when no bypass is set; "none" on PUT clears it).
+	PriorityLevel string `json:"priority_level,omitempty"`
+}
+
+// AdminWriterStatus is one writer in AdminStatus / GET /writers.
+type AdminWriterStatus struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Paused bool   `json:"paused"`
+	// Level is the writer's own level ("" when it has no runtime level).
+	Level   string `json:"level,omitempty"`
+	Metrics any    `json:"metrics,omitempty"`
+}
+
+// AdminLevelCounters are the per-level LoggerMetrics counters.
+type AdminLevelCounters struct {
+	Occurred uint64 `json:"occurred"`
+	Written  uint64 `json:"written"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// adminHandler carries the state of one NewAdminHandler.
+type adminHandler struct {
+	l    *Logger
+	opts AdminHandlerOptions
+
+	mu           sync.Mutex
+	stopSampling func() // reverts a timed PUT /sampling strategy
+}
+
+// NewAdminHandler returns an http.Handler exposing l's runtime state and
+// controls as JSON (nil l -> the default logger). Routes are relative; mount
+// with http.StripPrefix:
+//
+//	mux.Handle("/debug/log/", http.StripPrefix("/debug/log", log4go.NewAdminHandler(lg, opts)))
+//
+// Read-only:
+//
+//	GET  /status                  AdminStatus
+//	GET  /metrics                 per-level counters + per-writer Metrics()
+//	GET  /writers                 []AdminWriterStatus
+//	GET  /levels                  level overrides (LevelOverrideHandler)
+//
+// Mutating (behind AdminHandlerOptions.Auth):
+//
+//	PUT  /level                   {"level":"DEBUG"}
+//	PUT  /format                  {"format":"json"}
+//	PUT  /sampling                AdminSampling; only the keys present change
+//	POST /flush, /rotate          every writer that supports it (rotate
+//	                              forces a segment: ForceRotater, else Rotate)
+//	POST /writers/{name}/pause    also resume | flush | rotate
+//	PUT  /writers/{name}/level    {"level":"WARNING"} (LevelSetter writers)
+//	PUT|POST|DELETE /levels       level overrides (LevelOverrideHandler)
+//
+// Errors are JSON {"error": "..."} with 400 (bad input), 403 (Auth), 404
+// (unknown writer) or 500 (a writer's Flush/Rotate failed).
+func NewAdminHandler(l *Logger, opts AdminHandlerOptions) http.Handler {
+	h := &adminHandler{l: l, opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", h.status)
+	mux.HandleFunc("GET /metrics", h.metrics)
+	mux.HandleFunc("GET /writers", h.writers)
+	mux.Handle("/levels", h.guard(LevelOverrideHandler(l)))
+	mux.Handle("PUT /level", h.guard(http.HandlerFunc(h.setLevel)))
+	mux.Handle("PUT /format", h.guard(http.HandlerFunc(h.setFormat)))
+	mux.Handle("PUT /sampling", h.guard(http.HandlerFunc(h.setSampling)))
+	mux.Handle("POST /flush", h.guard(http.HandlerFunc(h.flushAll)))
+	mux.Handle("POST /rotate", h.guard(http.HandlerFunc(h.rotateAll)))
+	mux.Handle("POST /writers/{name}/{action}", h.guard(http.HandlerFunc(h.writerAction)))
+	mux.Handle("PUT /writers/{name}/level", h.guard(http.HandlerFunc(h.setWriterLevel)))
+	return mux
+}
+
+func (h *adminHandler) logger() *Logger {
+	if h.l != nil {
+		return h.l
+	}
+	return defaultLogger()
+}
+
+// guard runs Auth ahead of every non-GET/HEAD request.
+func (h *adminHandler) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if h.opts.Auth == nil {
+				writeAdminError(w, http.StatusForbidden, errors.New("log4go: admin: mutating endpoints disabled (no Auth configured)"))
+				return
+			}
+			if err := h.opts.Auth(r); err != nil {
+				writeAdminError(w, http.StatusForbidden, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *adminHandler) audit(r *http.Request, action string) {
+	if h.opts.Audit != nil {
+		h.opts.Audit(r, action)
+		return
+	}
+	log.Printf("[log4go] admin: %s (from %s)", action, r.RemoteAddr)
+}
+
+func (h *adminHandler) status(w http.ResponseWriter, _ *http.Request) {
+	l := h.logger()
+	st := AdminStatus{
+		Level:          levelFlag(int(l.level.Load())),
+		Format:         l.Format().String(),
+		Caller:         l.hasCaller.Load(),
+		FuncName:       l.withFuncName.Load(),
+		FullPath:       l.fullPath.Load(),
+		Sampling:       h.sampling(l),
+		Writers:        adminWriters(l),
+		Levels:         adminLevels(l.Metrics()),
+		LevelOverrides: l.LevelOverrides(),
+	}
+	if l.ring.Load() != nil {
+		m := l.RingDumpMetrics()
+		st.RingDump = &m
+	}
+	writeAdminJSON(w, http.StatusOK, st)
+}
+
+func (h *adminHandler) sampling(l *Logger) AdminSampling {
+	s := AdminSampling{Strategy: describeStrategy(l.samplingStrategy.Load())}
+	if sp := l.sampler.Load(); sp != nil {
+		initial, thereafter := sp.Initial, sp.Thereafter
+		s.Initial, s.Thereafter = &initial, &thereafter
+	}
+	if p := l.priorityLevel.Load(); p >= 0 {
+		s.PriorityLevel = levelFlag(int(p))
+	}
+	return s
+}
+
+func (h *adminHandler) metrics(w http.ResponseWriter, _ *http.Request) {
+	l := h.logger()
+	out := struct {
+		Levels         map[string]AdminLevelCounters `json:"levels"`
+		Redacted       uint64                        `json:"redacted"`
+		RedactedValues uint64                        `json:"redacted_values"`
+		Writers        map[string]any                `json:"writers"`
+	}{Writers: make(map[string]any)}
+	m := l.Metrics()
+	out.Levels = adminLevels(m)
+	out.Redacted, out.RedactedValues = m.Redacted, m.RedactedValues
+	for _, ws := range adminWriters(l) {
+		if ws.Metrics != nil {
+			out.Writers[ws.Name] = ws.Metrics
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, out)
+}
+
+func (h *adminHandler) writers(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminWriters(h.logger()))
+}
+
+func adminWriters(l *Logger) []AdminWriterStatus {
+	ws := l.Writers()
+	out := make([]AdminWriterStatus, 0, len(ws))
+	for _, w := range ws {
+		out = append(out, adminWriterStatus(w))
+	}
+	return out
+}
+
+func adminWriterStatus(w Writer) AdminWriterStatus {
+	s := AdminWriterStatus{Type: fmt.Sprintf("%T", w), Metrics: metricSnapshot(w)}
+	if n, ok := w.(Named); ok {
+		s.Name = n.Name()
+	}
+	if p, ok := w.(Pauser); ok {
+		s.Paused = p.Paused()
+	}
+	if lv, ok := w.(interface{ Level() int }); ok {
+		s.Level = levelFlag(lv.Level())
+	}
+	return s
+}
+
+// levelFlag names lvl, or renders the number when it is out of range.
+func levelFlag(lvl int) string {
+	if lvl >= 0 && lvl < len(LevelFlags) {
+		return LevelFlags[lvl]
+	}
+	return fmt.Sprint(lvl)
+}
+
+func adminLevels(m LoggerMetrics) map[string]AdminLevelCounters {
+	out := make(map[string]AdminLevelCounters, TRACE+1)
+	for i := 0; i <= TRACE; i++ {
+		out[LevelFlags[i]] = AdminLevelCounters{Occurred: m.Occurred[i], Written: m.Records[i], Dropped: m.Dropped[i]}
+	}
+	return out
+}
+
+// decodeAdmin decodes a JSON request body into v (64 KiB cap).
+func decodeAdmin(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(v); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("log4go: admin: decode body: %w", err))
+		return false
+	}
+	return true
+}
+
+func (h *adminHandler) setLevel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Level string `json:"level"`
+	}
+	if !decodeAdmin(w, r, &req) {
+		return
+	}
+	lvl, err := ParseLevel(req.Level)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.logger().SetLevel(lvl)
+	h.audit(r, "level "+LevelFlags[lvl])
+	h.status(w, r)
+}
+
+func (h *adminHandler) setFormat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Format string `json:"format"`
+	}
+	if !decodeAdmin(w, r, &req) {
+		return
+	}
+	var f LogFormat
+	switch strings.ToLower(strings.TrimSpace(req.Format)) {
+	case "text":
+		f = FormatText
+	case "json":
+		f = FormatJSON
+	case "logfmt":
+		f = FormatLogfmt
+	default:
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("log4go: admin: unknown format %q", req.Format))
+		return
+	}
+	h.logger().SetFormat(f)
+	h.audit(r, "format "+f.String())
+	h.status(w, r)
+}
+
+func (h *adminHandler) setSampling(w http.ResponseWriter, r *http.Request) {
+	var req AdminSampling
+	if !decodeAdmin(w, r, &req) {
+		return
+	}
+	// validate everything before changing anything
+	var (
+		strategy SamplingStrategy
+		duration time.Duration
+		priority = -1
+		err      error
+	)
+	if req.Strategy != "" {
+		if strategy, err = ParseSamplingStrategy(req.Strategy); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if req.Duration != "" {
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 || req.Strategy == "" {
+			writeAdminError(w, http.StatusBadRequest, fmt.Errorf("log4go: admin: duration %q needs a strategy and a positive Go duration", req.Duration))
+			return
+		}
+	}
+	if (req.Initial == nil) != (req.Thereafter == nil) {
+		writeAdminError(w, http.StatusBadRequest, errors.New("log4go: admin: initial and thereafter go together"))
+		return
+	}
+	if req.PriorityLevel != "" && !strings.EqualFold(req.PriorityLevel, "none") {
+		if priority, err = ParseLevel(req.PriorityLevel); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	l := h.logger()
+	var changed []string
+	if req.Strategy != "" {
+		h.mu.Lock()
+		if h.stopSampling != nil {
+			h.stopSampling() // end the previous timed window first
+			h.stopSampling = nil
+		}
+		if duration > 0 {
+			h.stopSampling = l.SetSamplingStrategyFor(strategy, duration)
+		} else {
+			l.SetSamplingStrategy(strategy)
+		}
+		h.mu.Unlock()
+		changed = append(changed, "strategy "+req.Strategy)
+	}
+	if req.Initial != nil {
+		l.SetSampling(*req.Initial, *req.Thereafter)
+		changed = append(changed, fmt.Sprintf("rate %d/%d", *req.Initial, *req.Thereafter))
+	}
+	if req.PriorityLevel != "" {
+		l.SetPriorityLevel(priority)
+		changed = append(changed, "priority "+req.PriorityLevel)
+	}
+	if len(changed) == 0 {
+		writeAdminError(w, http.StatusBadRequest, errors.New("log4go: admin: nothing to change"))
+		return
+	}
+	h.audit(r, "sampling "+strings.Join(changed, ", "))
+	writeAdminJSON(w, http.StatusOK, h.sampling(l))
+}
+
+// runAll calls fn on every writer implementing it (ok=false skips the writer)
+// and reports per-writer results.
+func (h *adminHandler) runAll(w http.ResponseWriter, r *http.Request, action string, fn func(Writer) (bool, error)) {
+	results := make(map[string]string)
+	status := http.StatusOK
+	for i, wr := range h.logger().Writers() {
+		ok, err := fn(wr)
+		if !ok {
+			continue
+		}
+		name := fmt.Sprintf("#%d", i)
+		if n, ok := wr.(Named); ok {
+			name = n.Name()
+		}
+		results[name] = "ok"
+		if err != nil {
+			results[name] = err.Error()
+			status = http.StatusInternalServerError
+		}
+	}
+	h.audit(r, action+" all")
+	writeAdminJSON(w, status, map[string]any{"writers": results})
+}
+
+func (h *adminHandler) flushAll(w http.ResponseWriter, r *http.Request) {
+	h.runAll(w, r, "flush", func(wr Writer) (bool, error) {
+		if f, ok := wr.(Flusher); ok {
+			return true, f.Flush()
+		}
+		return false, nil
+	})
+}
+
+func (h *adminHandler) rotateAll(w http.ResponseWriter, r *http.Request) {
+	h.runAll(w, r, "rotate", rotateWriter)
+}
+
+// rotateWriter forces a new segment where the writer supports it
+// (ForceRotater), else runs its Rotate; ok=false when it does neither.
+func rotateWriter(wr Writer) (ok bool, err error) {
+	if fr, ok := wr.(ForceRotater); ok {
+		return true, fr.ForceRotate()
+	}
+	if rt, ok := wr.(Rotater); ok {
+		return true, rt.Rotate()
+	}
+	return false, nil
+}
+
+// namedWriter resolves {name}, answering 404 itself when it is unknown.
+func (h *adminHandler) namedWriter(w http.ResponseWriter, r *http.Request) (Writer, string, bool) {
+	name := r.PathValue("name")
+	wr := h.logger().findWriter(name)
+	if wr == nil {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("log4go: admin: no writer %q", name))
+		return nil, name, false
+	}
+	return wr, name, true
+}
+
+func (h *adminHandler) writerAction(w http.ResponseWriter, r *http.Request) {
+	wr, name, ok := h.namedWriter(w, r)
+	if !ok {
+		return
+	}
+	action := r.PathValue("action")
+	var err error
+	supported := true
+	switch action {
+	case "pause", "resume":
+		p, ok := wr.(Pauser)
+		if supported = ok; ok {
+			if action == "pause" {
+				p.Pause()
+			} else {
+				p.Resume()
+			}
+		}
+	case "flush":
+		f, ok := wr.(Flusher)
+		if supported = ok; ok {
+			err = f.Flush()
+		}
+	case "rotate":
+		supported, err = rotateWriter(wr)
+	default:
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("log4go: admin: unknown writer action %q", action))
+		return
+	}
+	if !supported {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("log4go: admin: writer %q does not support %s", name, action))
+		return
+	}
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, fmt.Errorf("log4go: admin: %s %s: %w", action, name, err))
+		return
+	}
+	h.audit(r, action+" "+name)
+	writeAdminJSON(w, http.StatusOK, adminWriterStatus(wr))
+}
+
+func (h *adminHandler) setWriterLevel(w http.ResponseWriter, r *http.Request) {
+	wr, name, ok := h.namedWriter(w, r)
+	if !ok {
+		return
+	}
+	ls, ok := wr.(LevelSetter)
+	if !ok {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("log4go: admin: writer %q has no runtime level", name))
+		return
+	}
+	var req struct {
+		Level string `json:"level"`
+	}
+	if !decodeAdmin(w, r, &req) {
+		return
+	}
+	lvl, err := ParseLevel(req.Level)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	ls.SetLevel(lvl)
+	h.audit(r, "level "+name+" "+LevelFlags[lvl])
+	writeAdminJSON(w, http.StatusOK, map[string]string{"name": name, "level": LevelFlags[lvl]})
+}
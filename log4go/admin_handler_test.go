@@ -0,0 +1,268 @@
+package log4go
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// adminFakeWriter is a named, flushable, rotatable writer for the admin tests.
+type adminFakeWriter struct {
+	mu               sync.Mutex
+	flushes, rotates int
+	rotateErr        error
+}
+
+func (w *adminFakeWriter) Init() error                 { return nil }
+func (w *adminFakeWriter) Write(*Record) error         { return nil }
+func (w *adminFakeWriter) Name() string                { return "fake" }
+func (w *adminFakeWriter) SetPathPattern(string) error { return nil }
+func (w *adminFakeWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushes++
+	return nil
+}
+func (w *adminFakeWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rotates++
+	return w.rotateErr
+}
+
+type adminClient struct {
+	t     *testing.T
+	srv   *httptest.Server
+	token string
+}
+
+func (c adminClient) do(method, path, body string) (int, string) {
+	c.t.Helper()
+	req, _ := http.NewRequest(method, c.srv.URL+path, strings.NewReader(body))
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.srv.Client().Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func newAdminTest(t *testing.T, auth func(*http.Request) error) (*Logger, *adminFakeWriter, *[]string, *httptest.Server) {
+	t.Helper()
+	l := newLoggerWithRecords(make(chan *Record, 64))
+	t.Cleanup(l.Close)
+	fw := &adminFakeWriter{}
+	l.Register(NewIOWriter(io.Discard, DEBUG))
+	l.Register(fw)
+	var audit []string
+	var mu sync.Mutex
+	h := NewAdminHandler(l, AdminHandlerOptions{Auth: auth, Audit: func(_ *http.Request, action string) {
+		mu.Lock()
+		audit = append(audit, action)
+		mu.Unlock()
+	}})
+	srv := httptest.NewServer(http.StripPrefix("/debug/log", h))
+	t.Cleanup(srv.Close)
+	return l, fw, &audit, srv
+}
+
+func Test_AdminHandler_ReadOnly(t *testing.T) {
+	l, _, _, srv := newAdminTest(t, nil)
+	l.SetSampling(10, 5)
+	l.SetPriorityLevel(ERROR)
+	l.Info("one")
+	c := adminClient{t: t, srv: srv}
+
+	code, body := c.do(http.MethodGet, "/debug/log/status", "")
+	var st AdminStatus
+	if err := json.Unmarshal([]byte(body), &st); err != nil || code != http.StatusOK {
+		t.Fatalf("status %d %s err=%v", code, body, err)
+	}
+	if st.Level != LevelFlagDebug || st.Format != "text" || !st.Caller || len(st.Writers) != 2 {
+		t.Errorf("status=%+v", st)
+	}
+	if st.Sampling.Strategy != "full" || *st.Sampling.Initial != 10 || *st.Sampling.Thereafter != 5 || st.Sampling.PriorityLevel != LevelFlagError {
+		t.Errorf("sampling=%+v", st.Sampling)
+	}
+	if w := st.Writers[0]; w.Name != WriterNameIO || w.Level != LevelFlagDebug || w.Paused || w.Type != "*log4go.IOWriter" {
+		t.Errorf("writer=%+v", w)
+	}
+	if st.Levels[LevelFlagInfo].Occurred != 1 {
+		t.Errorf("levels=%+v", st.Levels)
+	}
+
+	if code, body := c.do(http.MethodGet, "/debug/log/metrics", ""); code != http.StatusOK || !strings.Contains(body, `"levels"`) {
+		t.Errorf("metrics %d %s", code, body)
+	}
+	if code, body := c.do(http.MethodGet, "/debug/log/writers", ""); code != http.StatusOK || !strings.Contains(body, `"fake"`) {
+		t.Errorf("writers %d %s", code, body)
+	}
+	if code, _ := c.do(http.MethodGet, "/debug/log/levels", ""); code != http.StatusOK {
+		t.Errorf("levels %d", code)
+	}
+
+	// without Auth every mutating endpoint is disabled.
+	for _, p := range []string{"/level", "/writers/fake/pause", "/flush", "/levels?pattern=x&level=DEBUG"} {
+		method := http.MethodPost
+		if p == "/level" {
+			method = http.MethodPut
+		}
+		if code, body := c.do(method, "/debug/log"+p, `{"level":"INFO"}`); code != http.StatusForbidden || !strings.Contains(body, "no Auth") {
+			t.Errorf("%s %s: %d %s", method, p, code, body)
+		}
+	}
+	if l.level.Load() != DEBUG {
+		t.Error("level changed without Auth")
+	}
+}
+
+func Test_AdminHandler_Mutations(t *testing.T) {
+	l, fw, audit, srv := newAdminTest(t, AdminBearerAuth("s3cret"))
+	c := adminClient{t: t, srv: srv, token: "s3cret"}
+
+	if code, _ := (adminClient{t: t, srv: srv, token: "wrong"}).do(http.MethodPut, "/debug/log/level", `{"level":"INFO"}`); code != http.StatusForbidden {
+		t.Errorf("wrong token: %d", code)
+	}
+	if code, body := c.do(http.MethodPut, "/debug/log/level", `{"level":"warn"}`); code != http.StatusOK || l.level.Load() != WARNING {
+		t.Errorf("level %d %s", code, body)
+	}
+	if code, body := c.do(http.MethodPut, "/debug/log/format", `{"format":"logfmt"}`); code != http.StatusOK || l.Format() != FormatLogfmt {
+		t.Errorf("format %d %s", code, body)
+	}
+
+	code, body := c.do(http.MethodPut, "/debug/log/sampling", `{"strategy":"trace_id_ratio:0.5","duration":"1h","initial":0,"thereafter":0,"priority_level":"ERROR"}`)
+	if code != http.StatusOK || describeStrategy(l.samplingStrategy.Load()) != "trace_id_ratio:0.5" || l.sampler.Load() != nil || l.priorityLevel.Load() != ERROR {
+		t.Errorf("sampling %d %s", code, body)
+	}
+	// a new strategy ends the timed window first, reverting to the pre-window one.
+	if code, _ := c.do(http.MethodPut, "/debug/log/sampling", `{"strategy":"tail_digit:10:3"}`); code != http.StatusOK ||
+		describeStrategy(l.samplingStrategy.Load()) != "tail_digit:10:3" {
+		t.Errorf("strategy replace %d", code)
+	}
+
+	if code, body := c.do(http.MethodPost, "/debug/log/writers/io_writer/pause", ""); code != http.StatusOK || !l.WriterPaused(WriterNameIO) || !strings.Contains(body, `"paused":true`) {
+		t.Errorf("pause %d %s", code, body)
+	}
+	if code, _ := c.do(http.MethodPost, "/debug/log/writers/io_writer/resume", ""); code != http.StatusOK || l.WriterPaused(WriterNameIO) {
+		t.Errorf("resume %d", code)
+	}
+	if code, body := c.do(http.MethodPut, "/debug/log/writers/io_writer/level", `{"level":"ERROR"}`); code != http.StatusOK || !strings.Contains(body, `"ERROR"`) {
+		t.Errorf("writer level %d %s", code, body)
+	}
+	if code, _ := c.do(http.MethodPost, "/debug/log/writers/fake/flush", ""); code != http.StatusOK || fw.flushes != 1 {
+		t.Errorf("writer flush %d flushes=%d", code, fw.flushes)
+	}
+	if code, _ := c.do(http.MethodPost, "/debug/log/flush", ""); code != http.StatusOK || fw.flushes != 2 {
+		t.Errorf("flush all %d flushes=%d", code, fw.flushes)
+	}
+	fw.rotateErr = errors.New("disk full")
+	if code, body := c.do(http.MethodPost, "/debug/log/rotate", ""); code != http.StatusInternalServerError || !strings.Contains(body, "disk full") {
+		t.Errorf("rotate all %d %s", code, body)
+	}
+	if code, _ := c.do(http.MethodPut, "/debug/log/levels", `{"pattern":"*_repo.go","level":"DEBUG"}`); code != http.StatusOK || len(l.LevelOverrides()) != 1 {
+		t.Errorf("level override %d", code)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPut, "/level", `{"level":"LOUD"}`, http.StatusBadRequest},
+		{http.MethodPut, "/format", `{"format":"xml"}`, http.StatusBadRequest},
+		{http.MethodPut, "/sampling", `{}`, http.StatusBadRequest},
+		{http.MethodPut, "/sampling", `{"strategy":"bogus"}`, http.StatusBadRequest},
+		{http.MethodPut, "/sampling", `{"duration":"1h"}`, http.StatusBadRequest},
+		{http.MethodPut, "/sampling", `{"initial":1}`, http.StatusBadRequest},
+		{http.MethodPost, "/writers/nope/pause", ``, http.StatusNotFound},
+		{http.MethodPost, "/writers/fake/explode", ``, http.StatusNotFound},
+		{http.MethodPost, "/writers/fake/pause", ``, http.StatusBadRequest}, // not a Pauser
+		{http.MethodPut, "/writers/fake/level", `{"level":"INFO"}`, http.StatusBadRequest},
+		{http.MethodPut, "/writers/io_writer/level", `{`, http.StatusBadRequest},
+	} {
+		if code, body := c.do(tc.method, "/debug/log"+tc.path, tc.body); code != tc.want {
+			t.Errorf("%s %s %s: %d want %d (%s)", tc.method, tc.path, tc.body, code, tc.want, body)
+		}
+	}
+	if got := strings.Join(*audit, "|"); !strings.Contains(got, "level WARNING") || !strings.Contains(got, "pause io_writer") {
+		t.Errorf("audit=%q", got)
+	}
+}
+
+// Flush and Rotate from the admin handler run while the bootstrap goroutine
+// writes the same buffers (sync FileWriter, buffered ConsoleWriter); -race
+// checks they are serialized, and every record lands in exactly one segment.
+func Test_AdminHandler_FlushRotateWhileLogging(t *testing.T) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = null // the console writer's bufio wraps it at Init
+	defer func() { os.Stdout = stdout; null.Close() }()
+
+	l := newLoggerWithRecords(make(chan *Record, 1024))
+	l.SetLevel(DEBUG)
+	dir := t.TempDir()
+	fw := NewFileWriterWithOptions(FileWriterOptions{Enable: true, Filename: filepath.Join(dir, "admin-%Y%M%D.log"), Rotate: true, Daily: true})
+	l.Register(fw)
+	l.Register(NewConsoleWriterWithOptions(ConsoleWriterOptions{Enable: true, Buffered: true}))
+	srv := httptest.NewServer(http.StripPrefix("/debug/log", NewAdminHandler(l, AdminHandlerOptions{
+		Auth: func(*http.Request) error { return nil },
+	})))
+	defer srv.Close()
+	c := adminClient{t: t, srv: srv}
+
+	stop := make(chan struct{})
+	logged := make(chan int)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				logged <- i
+				return
+			default:
+				l.Info("record %d", i)
+			}
+		}
+	}()
+	for i := range 40 {
+		path := "/debug/log/flush"
+		if i%4 == 0 {
+			path = "/debug/log/writers/file_writer/rotate"
+		}
+		if code, body := c.do(http.MethodPost, path, ""); code != http.StatusOK {
+			t.Fatalf("%s: %d %s", path, code, body)
+		}
+	}
+	close(stop)
+	n := <-logged
+	l.Close()
+	fw.Stop()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "admin-*"))
+	lines := 0
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines += strings.Count(string(b), "\n")
+	}
+	if lines != n || uint64(len(files)) != fw.Metrics().Rotated+1 {
+		t.Errorf("lines=%d in %d files, Rotated=%d; want %d lines", lines, len(files), fw.Metrics().Rotated, n)
+	}
+	if fw.Metrics().Rotated != 10 { // the rotate action forces a segment each time
+		t.Errorf("Rotated=%d, want 10", fw.Metrics().Rotated)
+	}
+}
@@ -433,6 +433,34 @@ func Benchmark_DeliverPipeline_NoCaller(b *testing.B) {
 	}
 }
 
+// formatDiscardWriter renders each record in its own format like the real
+// writers do (writerFormat.bytes), then drops it.
+type formatDiscardWriter struct{ format writerFormat }
+
+func (formatDiscardWriter) Init() error { return nil }
+func (w formatDiscardWriter) Write(r *Record) error {
+	_ = w.format.bytes(r)
+	return nil
+}
+
+// Benchmark_DeliverPipeline_PerWriterFormat measures the pipeline with four
+// writers in two formats of their own (JSON, JSON, logfmt, logfmt) under a
+// text Logger: each record is encoded once per distinct format (twice), not
+// once per writer.
+func Benchmark_DeliverPipeline_PerWriterFormat(b *testing.B) {
+	lg := newBenchLogger()
+	lg.SetLevel(DEBUG)
+	for _, f := range []string{"json", "json", "logfmt", "logfmt"} {
+		lg.Register(formatDiscardWriter{newWriterFormat(f, "")})
+	}
+	defer lg.Close()
+	b.ReportAllocs()
+
+	for b.Loop() {
+		lg.With("user", 7).Info("x")
+	}
+}
+
 // Benchmark_Logger_WithInterfaceInt vs Benchmark_Logger_WithTypedInt isolates the
 // boxing cost the typed API removes: With(key, any) boxes the int at the
 // call site (one alloc), WithInt never boxes.
@@ -0,0 +1,189 @@
+// Command log4q queries log4go JSON and logfmt log files: it reads rotated
+// and gzip / zstd compressed segments in order, filters them and prints the
+// matches as ConsoleWriter text (colored on a terminal), JSON or logfmt.
+//
+// Usage:
+//
+//	log4q [flags] [file|dir|glob ...]
+//
+// With no path (or "-") it reads standard input. A file is read after its
+// size-rotated segments. Flags:
+//
+//	-level WARNING          WARNING and more severe
+//	-since 2026-10-18T09:00:00Z, -since 15m
+//	                        from a time (RFC 3339, "2006-01-02 15:04:05" or
+//	                        "2006-01-02" local) or a duration ago
+//	-until ...              before a time, same forms
+//	-field key=value        field equality; repeatable, dotted keys reach
+//	-field key~regexp       into groups ("req.user.id=42")
+//	-caller 'payment/*.go'  caller glob (see reader.Filter.Caller)
+//	-o pretty|json|logfmt   output format (default pretty)
+//	-color auto|always|never, -full-color
+//	-n 100                  stop after 100 matches
+//
+// The exit status is 0 when something matched, 1 when nothing did and 2 on
+// error, as with grep.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/v8fg/kit4go/log4go"
+	"github.com/v8fg/kit4go/log4go/reader"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, isTerminal(os.Stdout)))
+}
+
+// fieldFlags collects repeated -field flags.
+type fieldFlags []reader.FieldMatch
+
+func (f *fieldFlags) String() string { return fmt.Sprint(len(*f)) }
+
+func (f *fieldFlags) Set(s string) error {
+	m, err := reader.ParseFieldMatch(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, m)
+	return nil
+}
+
+// run is main with its environment injected; tty tells whether stdout is a
+// terminal (-color auto).
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, tty bool) int {
+	fs := flag.NewFlagSet("log4q", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		filter    reader.Filter
+		fields    fieldFlags
+		since     = fs.String("since", "", "keep entries at or after this time, or this long ago (15m)")
+		until     = fs.String("until", "", "keep entries before this time, or this long ago")
+		output    = fs.String("o", "pretty", "output format: pretty, json or logfmt")
+		color     = fs.String("color", "auto", "color the pretty output: auto, always or never")
+		fullColor = fs.Bool("full-color", false, "color the whole line, not just the level")
+		limit     = fs.Int("n", 0, "stop after this many matches (0: no limit)")
+	)
+	fs.StringVar(&filter.Level, "level", "", "keep entries at this level or more severe")
+	fs.StringVar(&filter.Caller, "caller", "", "caller glob (file.go, pkg/*.go, file.go:42)")
+	fs.Var(&fields, "field", "key=value or key~regexp; repeatable")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: log4q [flags] [file|dir|glob ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	filter.Fields = fields
+	now := time.Now()
+	var err error
+	if filter.Since, err = parseTime(*since, now); err != nil {
+		return fail(stderr, err)
+	}
+	if filter.Until, err = parseTime(*until, now); err != nil {
+		return fail(stderr, err)
+	}
+
+	var render func(*reader.Entry) []byte
+	switch *output {
+	case "pretty":
+		cw := log4go.NewConsoleWriter()
+		switch *color {
+		case "always":
+			cw.SetColor(true)
+		case "auto":
+			cw.SetColor(tty)
+		case "never":
+		default:
+			return fail(stderr, fmt.Errorf("unknown -color %q", *color))
+		}
+		cw.SetFullColor(*fullColor)
+		render = func(e *reader.Entry) []byte { return []byte(cw.Format(e.Record())) }
+	case "json":
+		render = func(e *reader.Entry) []byte { return e.Record().JSON() }
+	case "logfmt":
+		render = func(e *reader.Entry) []byte { return e.Record().Logfmt() }
+	default:
+		return fail(stderr, fmt.Errorf("unknown -o %q", *output))
+	}
+
+	var r *reader.Reader
+	if paths := fs.Args(); len(paths) == 0 || len(paths) == 1 && paths[0] == "-" {
+		r, err = reader.NewReader(stdin, filter)
+	} else {
+		r, err = reader.Open(filter, paths...)
+	}
+	if err != nil {
+		return fail(stderr, err)
+	}
+	defer r.Close()
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	matched := 0
+	for *limit <= 0 || matched < *limit {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			return fail(stderr, err)
+		}
+		matched++
+		if _, err := out.Write(render(e)); err != nil {
+			return fail(stderr, err)
+		}
+	}
+	if n := r.Skipped(); n > 0 {
+		out.Flush()
+		fmt.Fprintf(stderr, "log4q: skipped %d lines that are not log4go JSON or logfmt\n", n)
+	}
+	if matched == 0 {
+		return 1
+	}
+	return 0
+}
+
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintf(stderr, "log4q: %v\n", err)
+	return 2
+}
+
+// parseTime reads a -since / -until value: empty (no bound), a duration
+// before now, RFC 3339, or a local "2006-01-02 15:04:05" / "2006-01-02".
+func parseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad time %q: want RFC 3339, %q, %q or a duration", s, time.DateTime, time.DateOnly)
+}
+
+// isTerminal reports whether f is a character device (a terminal).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/v8fg/kit4go/log4go"
+)
+
+func logFile(t *testing.T) string {
+	t.Helper()
+	t0 := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	buf.Write(log4go.NewRecord(t0, 1, log4go.INFO, "api.go:10", "start", log4go.String("svc", "api")).JSON())
+	buf.Write(log4go.NewRecord(t0.Add(time.Minute), 2, log4go.ERROR, "db.go:7", "query failed",
+		log4go.Group("req", log4go.String("id", "r-9"))).Logfmt())
+	buf.WriteString("plain text line\n")
+	p := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(p, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func runLog4q(tty bool, stdin string, args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &out, &errOut, tty)
+	return code, out.String(), errOut.String()
+}
+
+func Test_Run(t *testing.T) {
+	p := logFile(t)
+
+	code, out, errOut := runLog4q(false, "", "-level", "error", p)
+	if code != 0 || strings.Count(out, "\n") != 1 || !strings.Contains(out, "[ERROR] <db.go:7> query failed") {
+		t.Errorf("-level: code=%d out=%q", code, out)
+	}
+	if !strings.Contains(errOut, "skipped 1 lines") {
+		t.Errorf("stderr=%q", errOut)
+	}
+	if strings.Contains(out, "\033[") {
+		t.Error("colored output off a terminal")
+	}
+
+	code, out, _ = runLog4q(true, "", "-field", "req.id~^r-", p)
+	if code != 0 || !strings.Contains(out, "\033[31mERROR\033[0m") || !strings.Contains(out, `{"req.id":"r-9"}`) {
+		t.Errorf("-field on a tty: code=%d out=%q", code, out)
+	}
+
+	code, out, _ = runLog4q(false, "", "-o", "json", "-since", "2026-10-18T09:00:30Z", p)
+	if code != 0 || !strings.HasPrefix(out, `{"unix_nano":`) || !strings.Contains(out, `"msg":"query failed"`) || strings.Contains(out, "start") {
+		t.Errorf("-o json -since: code=%d out=%q", code, out)
+	}
+
+	code, out, _ = runLog4q(false, "", "-o", "logfmt", "-n", "1", "-color", "always", p)
+	if code != 0 || out != "time=2026-10-18T09:00:00.000000Z level=INFO msg=start file=api.go:10 svc=api\n" {
+		t.Errorf("-o logfmt -n 1: code=%d out=%q", code, out)
+	}
+
+	// stdin, and grep's exit statuses.
+	stdin, _ := os.ReadFile(p)
+	if code, out, _ := runLog4q(false, string(stdin), "-caller", "api.go"); code != 0 || !strings.Contains(out, "start") {
+		t.Errorf("stdin: code=%d out=%q", code, out)
+	}
+	if code, _, _ := runLog4q(false, "", "-field", "svc=web", p); code != 1 {
+		t.Errorf("no match: code=%d want 1", code)
+	}
+	for _, args := range [][]string{
+		{"-level", "LOUD", p},
+		{"-since", "yesterday", p},
+		{"-o", "xml", p},
+		{"-color", "sometimes", p},
+		{"-field", "novalue", p},
+		{filepath.Join(t.TempDir(), "missing.log")},
+	} {
+		if code, _, errOut := runLog4q(false, "", args...); code != 2 || errOut == "" {
+			t.Errorf("%v: code=%d stderr=%q want 2", args, code, errOut)
+		}
+	}
+	if code, _, errOut := runLog4q(false, "", "-h"); code != 0 || !strings.Contains(errOut, "usage: log4q") {
+		t.Errorf("-h: code=%d stderr=%q", code, errOut)
+	}
+}
+
+func Test_ParseTime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Time{
+		"":                     {},
+		"90m":                  now.Add(-90 * time.Minute),
+		"2026-10-18T09:00:00Z": time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+		"2026-10-18 09:30:00":  time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local),
+		"2026-10-17":           time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local),
+	} {
+		if got, err := parseTime(in, now); err != nil || !got.Equal(want) {
+			t.Errorf("parseTime(%q)=%v, %v want %v", in, got, err, want)
+		}
+	}
+}
@@ -14,11 +14,14 @@ var GlobalLevel = DEBUG
 // WriterName* are the stable names returned by each writer's Name(), used for
 // by-name control (Logger.PauseWriter / ResumeWriter / WriterPaused, SetWriterLevel).
 const (
-	WriterNameConsole = "console_writer"
-	WriterNameFile    = "file_writer"
-	WriterNameKafka   = "kafka_writer"
-	WriterNameNet     = "net_writer"
-	WriterNameIO      = "io_writer"
+	WriterNameConsole  = "console_writer"
+	WriterNameFile     = "file_writer"
+	WriterNameKafka    = "kafka_writer"
+	WriterNameNet      = "net_writer"
+	WriterNameIO       = "io_writer"
+	WriterNameOTLP     = "otlp_writer"
+	WriterNameSyslog   = "syslog_writer"
+	WriterNameJournald = "journald_writer"
 )
 
 // LogConfig log config
@@ -28,11 +31,16 @@ type LogConfig struct {
 	FullPath bool   `json:"full_path" mapstructure:"full_path"`
 	// Format selects the record serialization: "text" (default, human-readable
 	// line) or "json" (one JSON object per record, machine-readable). Unknown
-	// values fall back to text. See LogFormat / SetFormat.
+	// values fall back to text. See LogFormat / SetFormat. A writer's own
+	// Format / Layout options override it for that writer.
 	Format        string               `json:"format" mapstructure:"format"`
 	ConsoleWriter ConsoleWriterOptions `json:"console_writer" mapstructure:"console_writer"`
 	FileWriter    FileWriterOptions    `json:"file_writer" mapstructure:"file_writer"`
 	KafkaWriter   KafkaWriterOptions   `json:"kafka_writer" mapstructure:"kafka_writer"`
+	// JournaldWriter ships to systemd-journald over its native socket (Linux).
+	JournaldWriter JournaldWriterOptions `json:"journald_writer" mapstructure:"journald_writer"`
+	// Redact masks/hashes/drops sensitive data before any writer sees it.
+	Redact RedactOptions `json:"redact" mapstructure:"redact"`
 }
 
 // applyConfig configures l (level, format, full-path, writers) from lc. It is the
@@ -55,6 +63,7 @@ func (l *Logger) applyConfig(lc LogConfig) error {
 	fileWriterLevelDefault := newGlobal
 	consoleWriterLevelDefault := newGlobal
 	kafkaWriterLevelDefault := newGlobal
+	journaldWriterLevelDefault := newGlobal
 
 	if lc.ConsoleWriter.Enable {
 		consoleWriterLevelDefault = getLevelDefault(lc.ConsoleWriter.Level, newGlobal, WriterNameConsole)
@@ -80,6 +89,24 @@ func (l *Logger) applyConfig(lc LogConfig) error {
 		}
 	}
 
+	if lc.JournaldWriter.Enable {
+		journaldWriterLevelDefault = getLevelDefault(lc.JournaldWriter.Level, newGlobal, WriterNameJournald)
+		validGlobalMinLevel = maxInt(journaldWriterLevelDefault, validGlobalMinLevel)
+		if validGlobalMinLevel == journaldWriterLevelDefault {
+			validGlobalMinLevelBy = WriterNameJournald
+		}
+	}
+
+	// Compile the redactor before any writer starts so a bad rule fails the
+	// setup instead of letting raw values through.
+	if lc.Redact.Enable {
+		rd, err := NewRedactor(lc.Redact)
+		if err != nil {
+			return fmt.Errorf("redactor: %w", err)
+		}
+		l.SetRedactor(rd)
+	}
+
 	l.WithFullPath(lc.FullPath)
 	l.SetLevel(validGlobalMinLevel)
 	// Apply the serialization format (text default, json for structured logs).
@@ -88,7 +115,7 @@ func (l *Logger) applyConfig(lc LogConfig) error {
 
 	if lc.ConsoleWriter.Enable {
 		w := NewConsoleWriterWithOptions(lc.ConsoleWriter)
-		w.level = consoleWriterLevelDefault
+		w.SetLevel(consoleWriterLevelDefault)
 		log.Print("[log4go] enable " + WriterNameConsole + " with level " + LevelFlags[consoleWriterLevelDefault])
 		// ConsoleWriter.Init is infallible, so Register cannot panic here; file
 		// and kafka below use registerOrFail because their Init can fail.
@@ -97,7 +124,7 @@ func (l *Logger) applyConfig(lc LogConfig) error {
 
 	if lc.FileWriter.Enable {
 		w := NewFileWriterWithOptions(lc.FileWriter)
-		w.level = fileWriterLevelDefault
+		w.SetLevel(fileWriterLevelDefault)
 		log.Print("[log4go] enable    " + WriterNameFile + " with level " + LevelFlags[fileWriterLevelDefault])
 		if err := l.registerOrFail(w); err != nil {
 			return fmt.Errorf("file writer init: %w", err)
@@ -106,13 +133,22 @@ func (l *Logger) applyConfig(lc LogConfig) error {
 
 	if lc.KafkaWriter.Enable {
 		w := NewKafkaWriter(lc.KafkaWriter)
-		w.level = kafkaWriterLevelDefault
+		w.SetLevel(kafkaWriterLevelDefault)
 		log.Print("[log4go] enable   " + WriterNameKafka + " with level " + LevelFlags[kafkaWriterLevelDefault])
 		if err := l.registerOrFail(w); err != nil {
 			return fmt.Errorf("kafka writer init: %w", err)
 		}
 	}
 
+	if lc.JournaldWriter.Enable {
+		w := NewJournaldWriter(lc.JournaldWriter)
+		w.SetLevel(journaldWriterLevelDefault)
+		log.Print("[log4go] enable " + WriterNameJournald + " with level " + LevelFlags[journaldWriterLevelDefault])
+		if err := l.registerOrFail(w); err != nil {
+			return fmt.Errorf("journald writer init: %w", err)
+		}
+	}
+
 	log.Printf("[log4go] valid global_level(min:%v, flag:%v, by:%v), default(%v, flag:%v)",
 		validGlobalMinLevel, LevelFlags[validGlobalMinLevel], validGlobalMinLevelBy, newGlobal, LevelFlags[newGlobal])
 
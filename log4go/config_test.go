@@ -1,6 +1,8 @@
 package log4go
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -45,7 +47,14 @@ var (
 )
 
 func TestConfig(t *testing.T) {
-	if err := SetLog([]byte(logConfig)); err != nil {
+	// The file writer goes to a per-test directory so runs leave nothing in
+	// the tree; the default logger's writers are restored before it goes away.
+	dl := defaultLogger()
+	saved := dl.snapshotWriters()
+	defer dl.writers.Store(saved)
+	config := strings.Replace(logConfig, "./test/log4go-test-%Y%M%D.log",
+		filepath.Join(t.TempDir(), "log4go-test-%Y%M%D.log"), 1)
+	if err := SetLog([]byte(config)); err != nil {
 		panic(err)
 	}
 	var name = "log4go config test"
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"sync"
 	"sync/atomic"
 )
 
@@ -49,45 +50,54 @@ var colors = []brush{
 	newBrush("90"),   // Trace              dark grey
 }
 
+// text is the message followed, as in Record.String, by the structured
+// fields as a JSON object when any are attached.
+func (r *colorRecord) text() string {
+	if fj := (*Record)(r).FieldsJSON(); fj != "" {
+		return r.msg + " " + fj
+	}
+	return r.msg
+}
+
 // ColorString renders the record as a single line fully tinted with the level
 // color (used when ConsoleWriter FullColor is set).
 func (r *colorRecord) ColorString() string {
-	inf := fmt.Sprintf("%s %s %s %s\n", r.time, LevelFlags[r.level], r.file, r.msg)
+	inf := fmt.Sprintf("%s %s %s %s\n", r.time, LevelFlags[r.level], r.file, r.text())
 	return colors[r.level](inf)
 }
 
 // String renders the record with the level flag colorized and the file field
 // on an inverted background (used when ConsoleWriter Color is set without FullColor).
 func (r *colorRecord) String() string {
-	inf := ""
+	inf, msg := "", r.text()
 	switch r.level {
 	case EMERGENCY:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[1;41m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case ALERT:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[1;31m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case CRITICAL:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[35m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case ERROR:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[31m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case WARNING:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[33m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case NOTICE:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[32m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case INFO:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[36m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case DEBUG:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[34m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	case TRACE:
 		inf = fmt.Sprintf("\033[36m%s\033[0m [\033[90m%s\033[0m] \033[47;30m%s\033[0m %s\n",
-			r.time, LevelFlags[r.level], r.file, r.msg)
+			r.time, LevelFlags[r.level], r.file, msg)
 	}
 
 	return inf
@@ -95,17 +105,26 @@ func (r *colorRecord) String() string {
 
 // ConsoleWriter console writer define
 type ConsoleWriter struct {
-	level     int
+	level     writerLevel
 	color     bool
 	fullColor bool // line all with color
 	buffered  bool
 	buf       *bufio.Writer
+	mu        sync.Mutex // guards buf: Flush may come from another goroutine
 	paused    atomic.Bool
+	format    writerFormat
 }
 
 // Name returns WriterNameConsole (for by-name control).
 func (w *ConsoleWriter) Name() string { return WriterNameConsole }
 
+// SetLevel changes the writer's level at runtime (LevelSetter); the next
+// record observes it.
+func (w *ConsoleWriter) SetLevel(level int) { w.level.store(level) }
+
+// Level returns the writer's current level.
+func (w *ConsoleWriter) Level() int { return w.level.load() }
+
 // Pause drops incoming records without removing the writer (atomic, non-blocking).
 func (w *ConsoleWriter) Pause() { w.paused.Store(true) }
 
@@ -135,6 +154,14 @@ type ConsoleWriterOptions struct {
 	Buffered bool `json:"buffered" mapstructure:"buffered"`
 	// BufferSize bufio size in bytes (<=0 -> 4096).
 	BufferSize int `json:"buffer_size" mapstructure:"buffer_size"`
+	// Format is this writer's serialization: "text", "json" or "logfmt".
+	// Empty (default) follows the Logger's format (LogConfig.Format), so a
+	// JSON logger can still print colored text lines to the terminal.
+	Format string `json:"format" mapstructure:"format"`
+	// Layout is the time layout of this writer's text lines (e.g.
+	// "15:04:05.000"). Empty keeps the Logger's layout; JSON and logfmt always
+	// carry ISO time.
+	Layout string `json:"layout" mapstructure:"layout"`
 }
 
 // NewConsoleWriter create new console writer
@@ -151,10 +178,11 @@ func NewConsoleWriterWithOptions(options ConsoleWriterOptions) *ConsoleWriter {
 	}
 
 	return &ConsoleWriter{
-		level:     defaultLevel,
+		level:     writerLevel(defaultLevel),
 		color:     options.Color,
 		fullColor: options.FullColor,
 		buffered:  options.Buffered,
+		format:    newWriterFormat(options.Format, options.Layout),
 	}
 }
 
@@ -163,48 +191,49 @@ func (w *ConsoleWriter) Write(r *Record) error {
 	if w.paused.Load() {
 		return nil
 	}
-	if r.level > w.level {
+	if r.filteredBy(w.level.load()) {
 		return nil
 	}
-	// FormatJSON fast path: when the Logger pre-serialized the record, emit the
-	// bytes verbatim (no color, no String()) — JSON is for machine ingestion.
-	// This keeps the format decision in one place (deliverRecordToWriter) and
-	// avoids re-serializing per writer.
-	if len(r.formattedBytes) > 0 {
-		var out *os.File = os.Stdout
+	// JSON / logfmt: emit the pre-serialized bytes verbatim (no color, they are
+	// for machine ingestion) — the Logger's, or the record's shared encoding
+	// when this writer has its own Format, so writers sharing a format never
+	// re-serialize.
+	if !w.format.text(r) {
+		b := w.format.bytes(r)
 		if w.buf != nil {
-			_, _ = w.buf.Write(r.formattedBytes)
+			w.mu.Lock()
+			_, _ = w.buf.Write(b)
+			w.mu.Unlock()
 			return nil
 		}
-		_, _ = fmt.Fprint(out, string(r.formattedBytes))
+		_, _ = os.Stdout.Write(b)
 		return nil
 	}
-	var out *os.File = os.Stdout
+	line := w.Format(w.format.retime(r))
 	if w.buf != nil {
 		// buffered path: write to bufio (flushed by bootstrap timer)
-		if w.color {
-			if w.fullColor {
-				_, _ = w.buf.WriteString(((*colorRecord)(r)).ColorString())
-			} else {
-				_, _ = w.buf.WriteString(((*colorRecord)(r)).String())
-			}
-		} else {
-			_, _ = w.buf.WriteString(r.String())
-		}
+		w.mu.Lock()
+		_, _ = w.buf.WriteString(line)
+		w.mu.Unlock()
 		return nil
 	}
-	if w.color {
-		if w.fullColor {
-			_, _ = fmt.Fprint(out, ((*colorRecord)(r)).ColorString())
-		} else {
-			_, _ = fmt.Fprint(out, ((*colorRecord)(r)).String())
-		}
-	} else {
-		_, _ = fmt.Fprint(out, r.String())
-	}
+	_, _ = fmt.Fprint(os.Stdout, line)
 	return nil
 }
 
+// Format renders r as the writer prints a text record: plain (Record.String),
+// level-colored, or fully colored per the Color / FullColor settings. The
+// pre-serialized JSON / logfmt bytes are not consulted.
+func (w *ConsoleWriter) Format(r *Record) string {
+	if !w.color {
+		return r.String()
+	}
+	if w.fullColor {
+		return ((*colorRecord)(r)).ColorString()
+	}
+	return ((*colorRecord)(r)).String()
+}
+
 // Init console init; wraps os.Stdout in bufio when Buffered is set.
 func (w *ConsoleWriter) Init() error {
 	if w.buffered {
@@ -219,8 +248,11 @@ func (w *ConsoleWriter) Init() error {
 }
 
 // Flush implements Flusher; flushes the bufio buffer when Buffered is set.
+// Safe to call from any goroutine.
 func (w *ConsoleWriter) Flush() error {
 	if w.buf != nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
 		return w.buf.Flush()
 	}
 	return nil
@@ -2,6 +2,7 @@ package log4go
 
 import (
 	"testing"
+	"time"
 )
 
 func generateNewConsoleWriterWithOptions(level string, color, fullColor bool) *ConsoleWriter {
@@ -281,6 +282,28 @@ func Benchmark_NewConsoleWriter(b *testing.B) {
 	loggerDefaultTest.Alert("%#v", loggerDefaultTest)
 }
 
+// Test_ConsoleWriter_Format checks the three text renderings of a record
+// built with NewRecord, fields included.
+func Test_ConsoleWriter_Format(t *testing.T) {
+	ts := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+	r := NewRecord(ts, 7, WARNING, "a.go:1", "slow", Int("ms", 12))
+	w := NewConsoleWriter()
+	if got, want := w.Format(r), "#7 2026/10/18 09:00:00 [WARNING] <a.go:1> slow {\"ms\":12}\n"; got != want {
+		t.Errorf("plain=%q want %q", got, want)
+	}
+	w.SetColor(true)
+	if got, want := w.Format(r), "\033[36m2026/10/18 09:00:00\033[0m [\033[33mWARNING\033[0m] \033[47;30ma.go:1\033[0m slow {\"ms\":12}\n"; got != want {
+		t.Errorf("color=%q want %q", got, want)
+	}
+	w.SetFullColor(true)
+	if got, want := w.Format(r), "\033[33m2026/10/18 09:00:00 WARNING a.go:1 slow {\"ms\":12}\n\033[0m"; got != want {
+		t.Errorf("full color=%q want %q", got, want)
+	}
+	if r := NewRecord(time.Time{}, 0, INFO, "", "m"); r.UnixNano() != 0 || r.TimeStr() != "" {
+		t.Errorf("zero time: unix_nano=%d time=%q", r.UnixNano(), r.TimeStr())
+	}
+}
+
 func Benchmark_NewConsoleWriterAll(b *testing.B) {
 	var color, fullColor, fullPath, funcName bool
 	var layout string
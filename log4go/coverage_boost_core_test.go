@@ -595,7 +595,7 @@ func TestCore_SlogHandler_Handle_LevelFiltered(t *testing.T) {
 }
 
 func TestCore_SlogHandler_Handle_ZeroTime(t *testing.T) {
-	// sr.Time.IsZero() -> time.Now() (line 56-58). Use a real PC so slogSource
+	// sr.Time.IsZero() -> a record without a time. Use a real PC so slogSource
 	// also exercises its happy path.
 	root := newLoggerWithRecords(make(chan *Record, 4))
 	defer root.Close()
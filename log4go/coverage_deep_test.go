@@ -143,32 +143,35 @@ func Test_Logger_FromContext_Nil(t *testing.T) {
 	}
 }
 
-// Test_SlogAttrToField_AllKinds covers the slog kind switch branches the existing
-// tests miss: Group, Duration, Bool, Float64, Time, plus the group-prefix path.
-func Test_SlogAttrToField_AllKinds(t *testing.T) {
+// Test_SlogAttrFields_AllKinds covers the slog kind switch branches: Group,
+// Duration, Bool, Float64, Time, Uint64, plus the nested-group path.
+func Test_SlogAttrFields_AllKinds(t *testing.T) {
 	cases := []struct {
 		name string
 		a    slog.Attr
+		kind fieldKind
 	}{
-		{"string", slog.String("s", "v")},
-		{"int64", slog.Int64("i", 42)},
-		{"float64", slog.Float64("f", 1.5)},
-		{"bool", slog.Bool("b", true)},
-		{"duration", slog.Duration("d", 250*time.Millisecond)},
-		{"time", slog.Time("t", time.Unix(1e9, 0).UTC())},
-		{"group", slog.Group("g", slog.String("inner", "x"))},
-		{"any", slog.Any("a", map[string]int{"k": 1})},
+		{"string", slog.String("s", "v"), kindString},
+		{"int64", slog.Int64("i", 42), kindInt64},
+		{"uint64", slog.Uint64("u", 7), kindUint},
+		{"float64", slog.Float64("f", 1.5), kindFloat64},
+		{"bool", slog.Bool("b", true), kindBool},
+		{"duration", slog.Duration("d", 250*time.Millisecond), kindDuration},
+		{"time", slog.Time("t", time.Unix(1e9, 0).UTC()), kindTime},
+		{"group", slog.Group("g", slog.String("inner", "x")), kindGroup},
+		{"any", slog.Any("a", map[string]int{"k": 1}), kindAny},
 	}
+	h := NewSlogHandler(NewLogger())
 	for _, c := range cases {
-		f := slogAttrToField("", c.a)
-		if f.key != c.a.Key {
-			t.Errorf("%s: key=%q want %q", c.name, f.key, c.a.Key)
+		fs := h.attrFields(nil, []slog.Attr{c.a})
+		if len(fs) != 1 || fs[0].key != c.a.Key || fs[0].kind != c.kind {
+			t.Errorf("%s: fields=%+v want key %q kind %d", c.name, fs, c.a.Key, c.kind)
 		}
 	}
-	// Group prefix is applied as "grp.key".
-	f := slogAttrToField("grp", slog.String("s", "v"))
-	if f.key != "grp.s" {
-		t.Errorf("group prefix: key=%q want \"grp.s\"", f.key)
+	// A group nests its members rather than prefixing their keys.
+	fs := h.attrFields(nil, []slog.Attr{slog.Group("grp", slog.String("s", "v"))})
+	if v, ok := lookupField(fs, "grp.s"); !ok || v != "v" {
+		t.Errorf("grp.s=%v,%v want v", v, ok)
 	}
 }
 
@@ -181,22 +184,18 @@ func Test_NewSlogHandler_NilLogger(t *testing.T) {
 	}
 }
 
-// Test_SlogHandler_WithGroup_Chained covers the chained-group branch
-// (g1.g2) in WithGroup when a group prefix already exists.
+// Test_SlogHandler_WithGroup_Chained covers chained WithGroup calls: attrs
+// logged through the inner handler nest under both groups.
 func Test_SlogHandler_WithGroup_Chained(t *testing.T) {
 	h := NewSlogHandler(NewLogger())
-	h1 := h.WithGroup("g1").(*SlogHandler)
-	if h1.group != "g1" {
-		t.Errorf("first WithGroup: group=%q want g1", h1.group)
-	}
-	h2 := h1.WithGroup("g2").(*SlogHandler)
-	if h2.group != "g1.g2" {
-		t.Errorf("chained WithGroup: group=%q want g1.g2", h2.group)
-	}
-	// An attr emitted through the chained handler carries the dotted prefix.
-	f := slogAttrToField(h2.group, slog.String("k", "v"))
-	if f.key != "g1.g2.k" {
-		t.Errorf("chained attr key=%q want g1.g2.k", f.key)
+	h2 := h.WithGroup("g1").WithGroup("g2").(*SlogHandler)
+	if len(h2.goas) != 2 || h2.goas[0].group != "g1" || h2.goas[1].group != "g2" {
+		t.Fatalf("goas=%+v want g1, g2", h2.goas)
+	}
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.String("k", "v"))
+	if v, ok := lookupField(h2.fields(r), "g1.g2.k"); !ok || v != "v" {
+		t.Errorf("g1.g2.k=%v,%v want v", v, ok)
 	}
 }
 
@@ -98,6 +98,11 @@
 //     (Loki/Promtail/docker native). FormatText/FormatJSON/FormatLogfmt all
 //     pre-serialize once into r.formattedBytes, shared by every writer.
 //
+//   - Per-writer formats: the Format / Layout options of Console, File, Net
+//     and Kafka writers override the Logger's format for that writer (e.g.
+//     colored text on the console, JSON to the file). Each distinct rendering
+//     is encoded once per record and shared by the writers that ask for it.
+//
 //   - slog.Handler: NewSlogHandler(logger) adapts log4go to the standard
 //     log/slog.Handler interface — slog.SetDefault(slog.New(log4go.NewSlogHandler(lg)))
 //     routes net/http and any slog-using library through the log4go pipeline
@@ -0,0 +1,121 @@
+package log4go
+
+import (
+	"strings"
+	"time"
+)
+
+// writerFormat is a writer's own Format / Layout (the Format and Layout
+// fields of ConsoleWriterOptions, FileWriterOptions, NetWriterOptions and
+// KafkaWriterOptions). The zero value follows the Logger: the writer emits
+// r.formattedBytes, or the text line when there are none.
+type writerFormat struct {
+	format LogFormat
+	set    bool   // format overrides the Logger's
+	layout string // time layout of text output; "" keeps the Logger's
+}
+
+// newWriterFormat parses the Format / Layout option pair. An empty format
+// follows the Logger; an unknown one falls back to text (ParseLogLogFormat).
+func newWriterFormat(format, layout string) writerFormat {
+	wf := writerFormat{layout: layout}
+	if strings.TrimSpace(format) != "" {
+		wf.format, wf.set = ParseLogLogFormat(format), true
+	}
+	return wf
+}
+
+// overrides reports whether the writer renders records differently from the
+// Logger, i.e. whether bytes must be consulted at all.
+func (wf writerFormat) overrides() bool { return wf.set || wf.layout != "" }
+
+// text reports whether r is written as a text line under wf.
+func (wf writerFormat) text(r *Record) bool {
+	if wf.set {
+		return wf.format == FormatText
+	}
+	return len(r.formattedBytes) == 0
+}
+
+// bytes returns what the writer emits for r: the Logger's pre-serialized
+// bytes when wf follows them, else the record's shared encoding for wf's
+// format and layout (see Record.encoded). nil means the plain text line.
+//
+// It must run on the bootstrap goroutine (inside Writer.Write), before an
+// async writer copies the record for its daemon.
+func (wf writerFormat) bytes(r *Record) []byte {
+	if !wf.set {
+		if len(r.formattedBytes) > 0 || wf.layout == "" {
+			return r.formattedBytes
+		}
+		return r.encoded(FormatText, wf.layout)
+	}
+	return r.encoded(wf.format, wf.layout)
+}
+
+// apply returns the record a writer that emits r.formattedBytes (else
+// r.String()) should write: r itself when wf follows the Logger, else a copy
+// carrying bytes(r).
+func (wf writerFormat) apply(r *Record) *Record {
+	if !wf.overrides() {
+		return r
+	}
+	rc := *r
+	rc.formattedBytes = wf.bytes(r)
+	rc.encs = nil
+	return &rc
+}
+
+// retime returns r with its text time rendered in wf's layout: r itself when
+// the layout is the Logger's (or r has no time), else a copy.
+func (wf writerFormat) retime(r *Record) *Record {
+	if wf.layout == "" || r.unixNano == 0 {
+		return r
+	}
+	rc := *r
+	rc.time = time.Unix(0, r.unixNano).Format(wf.layout)
+	rc.encs = nil
+	return &rc
+}
+
+// recordEncoding is one cached rendering of a record.
+type recordEncoding struct {
+	format LogFormat
+	layout string // text only; JSON and logfmt always carry ISO time
+	b      []byte
+}
+
+// encoded returns r rendered in format (text with its time in layout, ""
+// for the Logger's), encoding it at most once per distinct format and layout
+// however many writers ask: the Logger's own pre-serialized bytes are reused,
+// other renderings are cached on r until it returns to the pool.
+func (r *Record) encoded(format LogFormat, layout string) []byte {
+	if format != FormatText {
+		layout = ""
+	}
+	if format == r.format && layout == "" && len(r.formattedBytes) > 0 {
+		return r.formattedBytes
+	}
+	for _, e := range r.encs {
+		if e.format == format && e.layout == layout {
+			return e.b
+		}
+	}
+	var b []byte
+	switch format {
+	case FormatJSON:
+		b = r.JSON()
+	case FormatLogfmt:
+		b = r.Logfmt()
+	default:
+		b = []byte(writerFormat{layout: layout}.retime(r).String())
+	}
+	r.encs = append(r.encs, recordEncoding{format: format, layout: layout, b: b})
+	return b
+}
+
+// resetEncodings drops r's cached encodings, keeping the slice for reuse.
+func (r *Record) resetEncodings() {
+	clear(r.encs)
+	r.encs = r.encs[:0]
+}
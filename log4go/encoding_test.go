@@ -0,0 +1,119 @@
+package log4go
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/v8fg/kit4go/kafka"
+)
+
+// Test_Record_Encoded covers the per-record encoding cache: the Logger's own
+// bytes are reused, every other format / layout is encoded once, and the
+// cache empties when the record goes back to the pool.
+func Test_Record_Encoded(t *testing.T) {
+	t0 := time.Date(2026, 10, 18, 9, 30, 15, 123e6, time.Local)
+	r := NewRecord(t0, 7, WARNING, "svc.go:3", "slow", String("db", "main"))
+	r.format, r.formattedBytes = FormatJSON, r.JSON()
+
+	same := func(a, b []byte) bool { return len(a) > 0 && len(b) > 0 && &a[0] == &b[0] }
+	if b := r.encoded(FormatJSON, "15:04"); !same(b, r.formattedBytes) {
+		t.Error("JSON re-encoded instead of reusing formattedBytes")
+	}
+	lf := r.encoded(FormatLogfmt, "")
+	if !same(lf, r.encoded(FormatLogfmt, "15:04")) || string(lf) != string(r.Logfmt()) {
+		t.Errorf("logfmt not cached: %q", lf)
+	}
+	txt := r.encoded(FormatText, "15:04:05.000")
+	if !same(txt, r.encoded(FormatText, "15:04:05.000")) || !strings.HasPrefix(string(txt), "#7 09:30:15.123 [WARNING] <svc.go:3> slow {") {
+		t.Errorf("text: %q", txt)
+	}
+	if plain := r.encoded(FormatText, ""); string(plain) != r.String() || same(plain, txt) {
+		t.Errorf("text in the Logger's layout: %q", plain)
+	}
+	if len(r.encs) != 3 {
+		t.Errorf("cached %d encodings want 3", len(r.encs))
+	}
+	r.resetEncodings()
+	if len(r.encs) != 0 || cap(r.encs) == 0 {
+		t.Errorf("reset: len=%d cap=%d", len(r.encs), cap(r.encs))
+	}
+
+	// Layout alone re-times text; JSON records ignore it.
+	if b := newWriterFormat("", "15:04").bytes(r); !same(b, r.formattedBytes) {
+		t.Errorf("layout-only writer under JSON: %q", b)
+	}
+	r.formattedBytes = nil
+	if b := newWriterFormat("", "15:04").bytes(r); !strings.HasPrefix(string(b), "#7 09:30 [WARNING]") {
+		t.Errorf("layout-only writer under text: %q", b)
+	}
+	if newWriterFormat("", "").overrides() || !newWriterFormat("text", "").overrides() {
+		t.Error("overrides")
+	}
+}
+
+// Test_PerWriterFormat_Deliver registers writers with different formats on a
+// JSON Logger and checks each gets its own rendering of the same record.
+func Test_PerWriterFormat_Deliver(t *testing.T) {
+	dir := t.TempDir()
+	lg := newLoggerWithRecords(make(chan *Record, 16))
+	lg.SetLevel(DEBUG)
+	lg.SetFormat(FormatJSON)
+	lg.WithCaller(false)
+
+	files := map[string]*FileWriter{}
+	for name, o := range map[string]FileWriterOptions{
+		"json":   {},
+		"logfmt": {Format: "logfmt"},
+		"text":   {Format: "text", Layout: "15:04:05"},
+		"async":  {Format: "logfmt", Async: true},
+	} {
+		o.Filename, o.Rotate, o.Daily = filepath.Join(dir, name+"-%Y%M%D.log"), true, true
+		files[name] = NewFileWriterWithOptions(o)
+		lg.Register(files[name])
+	}
+	producer := newMockKafkaProducer()
+	kw := NewKafkaWriter(KafkaWriterOptions{ProducerTopic: "t", BufferSize: 16, Format: "logfmt"})
+	kw.producerFactory = func() (kafka.Producer, error) { return producer, nil }
+	lg.Register(kw)
+
+	lg.With("user", 7).Info("checkout done")
+	for deadline := time.Now().Add(2 * time.Second); producer.Len() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	lg.Close() // flushes and stops every writer
+
+	read := func(name string) string {
+		paths, _ := filepath.Glob(filepath.Join(dir, name+"-*.log"))
+		if len(paths) != 1 {
+			t.Fatalf("%s: files %v", name, paths)
+		}
+		b, err := os.ReadFile(paths[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if got := read("json"); !strings.HasPrefix(got, `{"unix_nano":`) || !strings.Contains(got, `"fields":{"user":7}`) {
+		t.Errorf("json: %q", got)
+	}
+	for _, name := range []string{"logfmt", "async"} {
+		if got := read(name); !strings.HasPrefix(got, "time=") || !strings.HasSuffix(got, `msg="checkout done" user=7`+"\n") {
+			t.Errorf("%s: %q", name, got)
+		}
+	}
+	if got := read("text"); !strings.Contains(got, " [INFO] <> checkout done {\"user\":7}\n") ||
+		strings.Contains(got, "/") {
+		t.Errorf("text: %q", got)
+	}
+	producer.mu.Lock()
+	defer producer.mu.Unlock()
+	if len(producer.sent) != 1 {
+		t.Fatalf("kafka: %d messages want 1", len(producer.sent))
+	}
+	if got := string(producer.sent[0].Value); got != strings.TrimSuffix(read("logfmt"), "\n") {
+		t.Errorf("kafka: %q", got)
+	}
+}
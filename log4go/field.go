@@ -25,6 +25,7 @@ const (
 	kindError              // error stored in any
 	kindBytes              // []byte base64-encoded into str (JSON convention)
 	kindAny                // arbitrary value stored in any (rendered via the active codec)
+	kindGroup              // nested fields (fieldGroup) stored in any: a slog group
 )
 
 // field is the internal structured key/value pair. The value is stored unboxed
@@ -61,6 +62,17 @@ func bytesField(k string, v []byte) field {
 	return field{key: k, kind: kindBytes, str: base64.StdEncoding.EncodeToString(v)}
 }
 func anyField(k string, v any) field { return field{key: k, kind: kindAny, any: v} }
+func groupField(k string, fs []field) field {
+	return field{key: k, kind: kindGroup, any: fieldGroup(fs)}
+}
+
+// fieldGroup is the value of a kindGroup field: its members, rendered as a
+// nested JSON object (dotted keys in logfmt).
+type fieldGroup []field
+
+// MarshalJSON renders the group as a JSON object, so writers that hand
+// non-scalar kinds to the codec (syslog, journald, OTLP) nest it too.
+func (g fieldGroup) MarshalJSON() ([]byte, error) { return appendFieldsJSONObject(nil, g), nil }
 
 // fieldOf builds a typed field from an any value, mapping the common
 // scalar types to their unboxed kind and falling back to kindAny for the rest.
@@ -142,6 +154,13 @@ func (f field) value() any {
 			return b
 		}
 		return nil
+	case kindGroup:
+		g := f.any.(fieldGroup)
+		m := make(map[string]any, len(g))
+		for _, c := range g {
+			m[c.key] = c.value()
+		}
+		return m
 	default: // kindError / kindAny
 		return f.any
 	}
@@ -165,6 +184,7 @@ const (
 	FieldKindError    = kindError
 	FieldKindBytes    = kindBytes
 	FieldKindAny      = kindAny
+	FieldKindGroup    = kindGroup
 )
 
 // Field is the public typed key/value pair. Construct it with the String / Int /
@@ -221,6 +241,16 @@ func ErrorField(k string, v error) Field { return Field{errField(k, v)} }
 // JSON codec. Prefer the typed constructors for scalars (allocation-free).
 func Any(k string, v any) Field { return Field{anyField(k, v)} }
 
+// Group constructs a nested field: a JSON object of fs in FormatJSON, dotted
+// "k.member" keys in logfmt (the slog.Group shape).
+func Group(k string, fs ...Field) Field {
+	g := make([]field, len(fs))
+	for i, f := range fs {
+		g[i] = f.f
+	}
+	return Field{groupField(k, g)}
+}
+
 // Key returns the field key.
 func (f Field) Key() string { return f.f.key }
 
@@ -334,6 +364,8 @@ func appendFieldJSON(buf []byte, f field) []byte {
 		} else {
 			buf = append(buf, 'n', 'u', 'l', 'l')
 		}
+	case kindGroup:
+		buf = appendFieldsJSONObject(buf, f.any.(fieldGroup))
 	}
 	return buf
 }
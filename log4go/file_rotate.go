@@ -0,0 +1,394 @@
+package log4go
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression names accepted by FileWriterOptions.Compress.
+const (
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// backupTimeLayout stamps size-rotated segments: "<stem>.<stamp><suffix>". It
+// sorts lexically in time order and has no characters that need escaping on
+// any filesystem.
+const backupTimeLayout = "20060102T150405.000000"
+
+// compressedExt maps a Compress setting to the extension appended to a closed
+// segment once it has been compressed ("" for none).
+func compressedExt(mode string) string {
+	switch mode {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// parseCompress normalizes a Compress option. Unknown values fall back to no
+// compression with a log line, mirroring ParseLogLogFormat.
+func parseCompress(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", CompressNone:
+		return ""
+	case CompressGzip, "gz":
+		return CompressGzip
+	case CompressZstd, "zst":
+		return CompressZstd
+	}
+	log.Printf("[log4go] unknown file compress %q, compression disabled", s)
+	return ""
+}
+
+// openFile opens filePath for append as the active segment and resets the
+// size accounting from what is already on disk (a restart appends to the
+// existing file, so its current size counts toward MaxSizeBytes). activePath is
+// published BEFORE the file is created so the mill never mistakes the new
+// active file for a closed segment.
+func (w *FileWriter) openFile(filePath string) error {
+	p := filePath
+	w.activePath.Store(&p)
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, w.rotatePerm)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	var size int64
+	if fi, serr := file.Stat(); serr == nil {
+		size = fi.Size()
+	}
+	w.curSize.Store(size)
+
+	bufSize := w.bufferSize
+	if bufSize <= 0 {
+		bufSize = defaultBufferSize
+	}
+	w.fileBufWriter = bufio.NewWriterSize(w.file, bufSize)
+	w.suffix = filepath.Ext(filePath)
+	w.filenameOnly = strings.TrimSuffix(filePath, w.suffix)
+	return nil
+}
+
+// closeActive flushes and closes the active segment (if any).
+func (w *FileWriter) closeActive() error {
+	if w.fileBufWriter != nil {
+		if err := w.fileBufWriter.Flush(); err != nil {
+			return err
+		}
+	}
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maybeRotateBySize rotates the active segment when appending n more bytes
+// would push it past MaxSizeBytes. A record larger than the limit on its own
+// still lands in a fresh file (never split), so an empty segment is not rotated.
+// Called on the single writing goroutine (bootstrap in sync mode, the daemon in
+// async mode), like rotateImpl.
+func (w *FileWriter) maybeRotateBySize(n int) {
+	if w.maxSize <= 0 || w.file == nil {
+		return
+	}
+	cur := w.curSize.Load()
+	if cur == 0 || cur+int64(n) <= w.maxSize {
+		return
+	}
+	if err := w.rotateBySize(time.Now()); err != nil {
+		atomic.AddUint64(&w.errored, 1)
+		w.fire("error", 1)
+		log.Printf("[log4go] file writer size rotate err: %v", err)
+	}
+}
+
+// rotateBySize closes the active segment, renames it to a timestamped backup
+// next to it and reopens the original path, then hands the backup to the mill.
+// The time triggers are untouched: the reopened path is still the one the
+// current pattern variables resolve to. ForceRotate goes through it too.
+func (w *FileWriter) rotateBySize(now time.Time) error {
+	p := w.activePath.Load()
+	if p == nil {
+		return nil
+	}
+	active := *p
+	if err := w.closeActive(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(active)
+	stem := strings.TrimSuffix(active, ext) + "." + now.Format(backupTimeLayout)
+	backup := stem + ext
+	// never clobber a backup taken in the same microsecond
+	for i := 1; fileExists(backup); i++ {
+		backup = stem + "-" + strconv.Itoa(i) + ext
+	}
+	renameErr := os.Rename(active, backup)
+	// Reopen regardless of the rename outcome so the writer keeps a live file;
+	// on rename failure the segment simply keeps growing.
+	if err := w.openFile(active); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	atomic.AddUint64(&w.rotated, 1)
+	w.fire("rotate", 1)
+	w.kickMill()
+	return nil
+}
+
+// fileExists reports whether name exists (any error other than not-exist is
+// treated as existing, so the caller picks another name rather than clobber).
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// millEnabled reports whether closed segments need background processing
+// (compression and/or retention).
+func (w *FileWriter) millEnabled() bool {
+	return w.compress != "" || w.maxBackups > 0 || w.maxTotalBytes > 0
+}
+
+// startMill launches the background goroutine that compresses closed segments
+// and enforces MaxBackups/MaxTotalBytes. One pass runs at start so segments left
+// by a previous run are handled too. Idempotent.
+func (w *FileWriter) startMill() {
+	if !w.millEnabled() || w.millSig != nil {
+		return
+	}
+	w.millSig = make(chan struct{}, 1)
+	w.millStop = make(chan struct{})
+	w.millDone = make(chan struct{})
+	go w.millLoop()
+	w.kickMill()
+}
+
+// kickMill schedules a mill pass. Signals coalesce: a pass always rescans the
+// directory, so a dropped signal never loses a segment.
+func (w *FileWriter) kickMill() {
+	if w.millSig == nil {
+		return
+	}
+	select {
+	case w.millSig <- struct{}{}:
+	default:
+	}
+}
+
+// stopMill finishes any in-flight pass and stops the mill goroutine.
+func (w *FileWriter) stopMill() {
+	if w.millStop == nil {
+		return
+	}
+	w.millStopOnce.Do(func() {
+		close(w.millStop)
+		<-w.millDone
+	})
+}
+
+func (w *FileWriter) millLoop() {
+	defer close(w.millDone)
+	defer func() {
+		if r := recover(); r != nil {
+			recordDaemonPanic("file mill", r)
+		}
+	}()
+	for {
+		select {
+		case <-w.millSig:
+			w.millRun()
+		case <-w.millStop:
+			// a rotation right before Stop still gets its segment processed.
+			select {
+			case <-w.millSig:
+				w.millRun()
+			default:
+			}
+			return
+		}
+	}
+}
+
+// segmentGlob turns the path pattern into a glob matching every segment this
+// writer produces: time variables become "*", and a trailing "*" after the
+// stem catches size-rotated backups and compressed extensions. E.g.
+// "/var/log/app-%Y%M%D.log" -> "/var/log/app-*". The glob also catches other
+// files sharing the stem; segmentPattern narrows it down.
+func (w *FileWriter) segmentGlob() string {
+	pattern := strings.NewReplacer("%02d", "*", "%d", "*").Replace(w.pathFmt)
+	return strings.TrimSuffix(pattern, filepath.Ext(pattern)) + "*"
+}
+
+// fmtVerb matches the verbs convertPatternToFmt leaves in pathFmt.
+var fmtVerb = regexp.MustCompile(`%(?:02)?d`)
+
+// segmentPattern matches the base names of this writer's segments only:
+// "<stem>[.<backupTimeLayout>[-n]]<ext>[.gz|.zst]", with the pattern's time
+// variables as digit runs. "app.log" thus leaves "app-error.log" and its
+// segments to their own writer.
+func (w *FileWriter) segmentPattern() *regexp.Regexp {
+	base := filepath.Base(w.pathFmt)
+	ext := filepath.Ext(base)
+	parts := fmtVerb.Split(strings.TrimSuffix(base, ext), -1)
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile(`^` + strings.Join(parts, `\d+`) +
+		`(?:\.\d{8}T\d{6}\.\d{6}(?:-\d+)?)?` + regexp.QuoteMeta(ext) + `(?:\.gz|\.zst)?$`)
+}
+
+type segmentInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// closedSegments lists this writer's segments on disk, excluding the active
+// file. Only names matching segmentPattern are considered, so unrelated files
+// and other writers' segments sharing the stem are left alone.
+func (w *FileWriter) closedSegments() []segmentInfo {
+	matches, err := filepath.Glob(w.segmentGlob())
+	if err != nil {
+		return nil
+	}
+	active := ""
+	if p := w.activePath.Load(); p != nil {
+		active = *p
+	}
+	own := w.segmentPattern()
+	out := make([]segmentInfo, 0, len(matches))
+	for _, m := range matches {
+		if m == active || !own.MatchString(filepath.Base(m)) {
+			continue
+		}
+		fi, err := os.Stat(m)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		out = append(out, segmentInfo{path: m, size: fi.Size(), modTime: fi.ModTime()})
+	}
+	return out
+}
+
+// millRun compresses every uncompressed closed segment, then applies the
+// retention policy newest-first: a segment is kept while both the backup count
+// and the running total stay within MaxBackups / MaxTotalBytes.
+func (w *FileWriter) millRun() {
+	segs := w.closedSegments()
+	if ce := compressedExt(w.compress); ce != "" {
+		for i, s := range segs {
+			if strings.HasSuffix(s.path, ".gz") || strings.HasSuffix(s.path, ".zst") {
+				continue
+			}
+			dst := s.path + ce
+			if err := compressFile(s.path, dst, w.compress); err != nil {
+				atomic.AddUint64(&w.millErrored, 1)
+				w.fire("error", 1)
+				log.Printf("[log4go] file writer compress %s: %v", s.path, err)
+				continue
+			}
+			atomic.AddUint64(&w.compressed, 1)
+			w.fire("compress", 1)
+			if fi, err := os.Stat(dst); err == nil {
+				segs[i] = segmentInfo{path: dst, size: fi.Size(), modTime: s.modTime}
+			}
+		}
+	}
+	if w.maxBackups <= 0 && w.maxTotalBytes <= 0 {
+		return
+	}
+	// newest first; backups rotated within one mtime tick tie-break on the
+	// name, whose timestamp stamp sorts lexically.
+	sort.Slice(segs, func(i, j int) bool {
+		if !segs[i].modTime.Equal(segs[j].modTime) {
+			return segs[i].modTime.After(segs[j].modTime)
+		}
+		return segs[i].path > segs[j].path
+	})
+	var kept int
+	var total int64
+	for _, s := range segs {
+		if (w.maxBackups <= 0 || kept < w.maxBackups) && (w.maxTotalBytes <= 0 || total+s.size <= w.maxTotalBytes) {
+			kept++
+			total += s.size
+			continue
+		}
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			atomic.AddUint64(&w.millErrored, 1)
+			w.fire("error", 1)
+			continue
+		}
+		atomic.AddUint64(&w.removed, 1)
+		w.fire("remove", 1)
+	}
+}
+
+// compressFile writes src compressed to dst (keeping src's mode and mtime, so
+// retention ordering is stable) and removes src. A partial dst is removed on
+// failure so a later pass retries from the intact source.
+func compressFile(src, dst, mode string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = out.Close()
+			_ = os.Remove(dst)
+		}
+	}()
+
+	var enc io.WriteCloser
+	switch mode {
+	case CompressZstd:
+		zw, zerr := zstd.NewWriter(out)
+		if zerr != nil {
+			return zerr
+		}
+		enc = zw
+	default:
+		enc = gzip.NewWriter(out)
+	}
+	if _, err = io.Copy(enc, in); err != nil {
+		_ = enc.Close()
+		return err
+	}
+	if err = enc.Close(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	_ = os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	_ = in.Close()
+	return os.Remove(src)
+}
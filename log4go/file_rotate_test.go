@@ -0,0 +1,292 @@
+package log4go
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// newSizeFileWriter builds and inits a sync FileWriter on a daily pattern in a
+// fresh temp dir with the given size/compress/retention options.
+func newSizeFileWriter(t *testing.T, opts FileWriterOptions) (*FileWriter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	opts.Enable = true
+	opts.Level = LevelFlagDebug
+	opts.Filename = filepath.Join(dir, "size-%Y%M%D.log")
+	opts.Rotate = true
+	opts.Daily = true
+	fw := NewFileWriterWithOptions(opts)
+	if err := fw.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return fw, dir
+}
+
+func writeSizeRecords(t *testing.T, fw *FileWriter, n int) {
+	t.Helper()
+	for i := range n {
+		if err := fw.Write(&Record{level: INFO, time: "2026-01-02 03:04:05", file: "rot_test.go:1", msg: "size rotation payload " + strings.Repeat("x", 40) + string(rune('a'+i%26))}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+}
+
+func globDir(t *testing.T, dir, pattern string) []string {
+	t.Helper()
+	m, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func Test_FileWriter_SizeRotation(t *testing.T) {
+	fw, dir := newSizeFileWriter(t, FileWriterOptions{MaxSizeBytes: 1024})
+	writeSizeRecords(t, fw, 100) // ~100 bytes/record -> ~9 rotations
+	_ = fw.Flush()
+	fw.Stop()
+
+	files := globDir(t, dir, "size-*.log")
+	if len(files) < 5 {
+		t.Fatalf("got %d segments, want several: %v", len(files), files)
+	}
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > 1024 {
+			t.Errorf("%s size=%d exceeds MaxSizeBytes", f, fi.Size())
+		}
+	}
+	m := fw.Metrics()
+	if m.Rotated != uint64(len(files)-1) {
+		t.Errorf("Rotated=%d want %d", m.Rotated, len(files)-1)
+	}
+	if m.Written != 100 {
+		t.Errorf("Written=%d want 100", m.Written)
+	}
+	if m.FileSize <= 0 || m.FileSize > 1024 {
+		t.Errorf("FileSize=%d want (0,1024]", m.FileSize)
+	}
+}
+
+func Test_FileWriter_SizeRotation_OversizedRecord(t *testing.T) {
+	fw, dir := newSizeFileWriter(t, FileWriterOptions{MaxSizeBytes: 16})
+	writeSizeRecords(t, fw, 3) // every record alone exceeds the limit
+	_ = fw.Flush()
+	fw.Stop()
+	// each record lands whole in its own segment; none is split or lost.
+	if got := len(globDir(t, dir, "size-*.log")); got != 3 {
+		t.Fatalf("segments=%d want 3", got)
+	}
+}
+
+func Test_FileWriter_SizeRotation_Async(t *testing.T) {
+	fw, dir := newAsyncFileWriter(t, FileWriterOptions{
+		MaxSizeBytes:    2048,
+		AsyncBufferSize: 1 << 12,
+		OverflowPolicy:  "block",
+	})
+	m := driveAsyncWriter(fw, 500)
+	if m.Written != 500 || m.Rotated == 0 {
+		t.Fatalf("written=%d rotated=%d want 500 / >0", m.Written, m.Rotated)
+	}
+	if got := len(globDir(t, dir, "async-*.log")); uint64(got) != m.Rotated+1 {
+		t.Errorf("segments=%d want %d", got, m.Rotated+1)
+	}
+}
+
+func Test_FileWriter_Compress(t *testing.T) {
+	for _, tc := range []struct {
+		mode, ext string
+		open      func(*os.File) (*bufio.Reader, error)
+	}{
+		{CompressGzip, ".gz", func(f *os.File) (*bufio.Reader, error) {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, err
+			}
+			return bufio.NewReader(zr), nil
+		}},
+		{CompressZstd, ".zst", func(f *os.File) (*bufio.Reader, error) {
+			zr, err := zstd.NewReader(f)
+			if err != nil {
+				return nil, err
+			}
+			return bufio.NewReader(zr), nil
+		}},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			fw, dir := newSizeFileWriter(t, FileWriterOptions{MaxSizeBytes: 1024, Compress: tc.mode})
+			writeSizeRecords(t, fw, 50)
+			fw.Stop() // waits for the mill's final pass
+
+			compressed := globDir(t, dir, "size-*.log"+tc.ext)
+			if len(compressed) == 0 {
+				t.Fatal("no compressed segments")
+			}
+			// only the active file is left uncompressed.
+			if plain := globDir(t, dir, "size-*.log"); len(plain) != 1 {
+				t.Errorf("plain segments=%v want only the active file", plain)
+			}
+			if m := fw.Metrics(); m.Compressed != uint64(len(compressed)) || m.MillErrored != 0 {
+				t.Errorf("Compressed=%d MillErrored=%d want %d/0", m.Compressed, m.MillErrored, len(compressed))
+			}
+			f, err := os.Open(compressed[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			br, err := tc.open(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			line, err := br.ReadString('\n')
+			if err != nil || !strings.Contains(line, "size rotation payload") {
+				t.Fatalf("decompressed line=%q err=%v", line, err)
+			}
+		})
+	}
+}
+
+func Test_FileWriter_Retention(t *testing.T) {
+	t.Run("max_backups", func(t *testing.T) {
+		fw, dir := newSizeFileWriter(t, FileWriterOptions{MaxSizeBytes: 512, MaxBackups: 2})
+		writeSizeRecords(t, fw, 60)
+		fw.Stop()
+		// 2 backups + the active file.
+		if got := globDir(t, dir, "size-*"); len(got) != 3 {
+			t.Fatalf("files=%v want 3", got)
+		}
+		if m := fw.Metrics(); m.Removed == 0 || m.Removed+2 != m.Rotated {
+			t.Errorf("Removed=%d Rotated=%d want Removed=Rotated-2", m.Removed, m.Rotated)
+		}
+	})
+
+	t.Run("max_total_bytes", func(t *testing.T) {
+		fw, dir := newSizeFileWriter(t, FileWriterOptions{MaxSizeBytes: 512, MaxTotalBytes: 1200})
+		writeSizeRecords(t, fw, 60)
+		fw.Stop()
+		var total int64
+		for _, f := range fw.closedSegments() {
+			total += f.size
+		}
+		if total > 1200 {
+			t.Errorf("closed segments total=%d > MaxTotalBytes", total)
+		}
+		if fw.Metrics().Removed == 0 {
+			t.Error("expected removals under MaxTotalBytes")
+		}
+		if len(globDir(t, dir, "size-*")) < 2 {
+			t.Error("retention removed the active file or every backup")
+		}
+	})
+
+	t.Run("leaves_unrelated_files", func(t *testing.T) {
+		fw, dir := newSizeFileWriter(t, FileWriterOptions{MaxSizeBytes: 512, MaxBackups: 1})
+		other := filepath.Join(dir, "size-notes.txt")
+		if err := os.WriteFile(other, []byte("keep"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		writeSizeRecords(t, fw, 30)
+		fw.Stop()
+		if _, err := os.Stat(other); err != nil {
+			t.Errorf("unrelated file removed: %v", err)
+		}
+	})
+}
+
+// ForceRotate starts a new segment regardless of the time and size triggers,
+// while Rotate keeps applying them only.
+func Test_FileWriter_ForceRotate(t *testing.T) {
+	fw, dir := newSizeFileWriter(t, FileWriterOptions{})
+	writeSizeRecords(t, fw, 3)
+	if err := fw.Rotate(); err != nil || fw.Metrics().Rotated != 0 {
+		t.Fatalf("Rotate with no trigger due: err=%v Rotated=%d, want no rotation", err, fw.Metrics().Rotated)
+	}
+	if err := fw.ForceRotate(); err != nil {
+		t.Fatalf("ForceRotate: %v", err)
+	}
+	writeSizeRecords(t, fw, 2)
+	fw.Stop()
+	_ = fw.Flush()
+
+	files := globDir(t, dir, "size-*")
+	if len(files) != 2 || fw.Metrics().Rotated != 1 {
+		t.Fatalf("files=%v Rotated=%d want 2 files, 1 rotation", files, fw.Metrics().Rotated)
+	}
+	backups := globDir(t, dir, "size-*.*.log")
+	if len(backups) != 1 {
+		t.Fatalf("backups=%v", backups)
+	}
+	if b, _ := os.ReadFile(backups[0]); strings.Count(string(b), "\n") != 3 {
+		t.Errorf("backup holds %q, want the 3 records written before ForceRotate", b)
+	}
+
+	if err := NewFileWriter().ForceRotate(); err == nil {
+		t.Error("ForceRotate without an open file: want an error")
+	}
+}
+
+func Test_FileWriter_SizeOptions_FromLogConfig(t *testing.T) {
+	var lc LogConfig
+	cfg := `{"file_writer":{"enable":true,"max_size_bytes":1048576,"compress":"zstd","max_backups":7,"max_total_bytes":1073741824}}`
+	if err := json.Unmarshal([]byte(cfg), &lc); err != nil {
+		t.Fatal(err)
+	}
+	fw := NewFileWriterWithOptions(lc.FileWriter)
+	if fw.maxSize != 1<<20 || fw.compress != CompressZstd || fw.maxBackups != 7 || fw.maxTotalBytes != 1<<30 {
+		t.Fatalf("options not mapped: size=%d compress=%q backups=%d total=%d", fw.maxSize, fw.compress, fw.maxBackups, fw.maxTotalBytes)
+	}
+	if got := parseCompress("bogus"); got != "" {
+		t.Errorf("parseCompress(bogus)=%q want disabled", got)
+	}
+}
+
+// Two writers sharing a directory and a stem prefix ("app-%Y%M%D.log" and
+// "app-error-%Y%M%D.log", whose names the first writer's glob also catches):
+// each mill compresses and prunes only its own segments.
+func Test_FileWriter_Mill_OwnSegmentsOnly(t *testing.T) {
+	dir := t.TempDir()
+	newWriter := func(name string, opts FileWriterOptions) *FileWriter {
+		opts.Enable = true
+		opts.Level = LevelFlagDebug
+		opts.Filename = filepath.Join(dir, name)
+		opts.Rotate = true
+		opts.Daily = true
+		opts.MaxSizeBytes = 512
+		fw := NewFileWriterWithOptions(opts)
+		if err := fw.Init(); err != nil {
+			t.Fatalf("Init %s: %v", name, err)
+		}
+		return fw
+	}
+	errW := newWriter("app-error-%Y%M%D.log", FileWriterOptions{})
+	writeSizeRecords(t, errW, 30)
+	_ = errW.Flush()
+	appW := newWriter("app-%Y%M%D.log", FileWriterOptions{Compress: CompressGzip, MaxBackups: 1})
+	writeSizeRecords(t, appW, 30)
+	appW.Stop()
+	errW.Stop()
+
+	if got := globDir(t, dir, "app-error-*"); uint64(len(got)) != errW.Metrics().Rotated+1 {
+		t.Errorf("other writer's files=%v want %d", got, errW.Metrics().Rotated+1)
+	}
+	if got := globDir(t, dir, "app-error-*.gz"); len(got) != 0 {
+		t.Errorf("other writer's files compressed: %v", got)
+	}
+	if got := globDir(t, dir, "app-[0-9]*.log.gz"); len(got) != 1 {
+		t.Errorf("own segments=%v want 1 compressed backup", got)
+	}
+	if m := appW.Metrics(); m.Removed+1 != m.Rotated {
+		t.Errorf("Removed=%d Rotated=%d want Removed=Rotated-1", m.Removed, m.Rotated)
+	}
+}
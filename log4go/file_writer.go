@@ -42,10 +42,16 @@ const (
 type FileWriter struct {
 	// write log order by order and atomic incr
 	// maxLinesCurLines and maxSizeCurSize
-	level        int
+	level        writerLevel
 	paused       atomic.Bool
+	format       writerFormat
 	lock         sync.RWMutex
 	initFileOnce sync.Once // init once
+	// mu serializes the sync-mode file I/O (Write on the logger's bootstrap
+	// goroutine) with Flush / Rotate / ForceRotate called from other goroutines, such as the
+	// admin handler. Async mode does all file I/O on the daemon and sends it
+	// requests through ctl instead.
+	mu sync.Mutex
 
 	rotatePerm os.FileMode // real used
 	perm       string      // input
@@ -66,9 +72,27 @@ type FileWriter struct {
 	// maxLines         int // Rotate at line
 	// maxLinesCurLines int
 
-	// // Rotate at size
-	// maxSize        int
-	// maxSizeCurSize int
+	// Rotate at size (see file_rotate.go). maxSize <= 0 disables the trigger.
+	// curSize is written only by the writing goroutine; atomic so Metrics can
+	// read it concurrently. activePath is the open segment's path, shared with
+	// the mill goroutine so it never touches the live file.
+	maxSize    int64
+	curSize    atomic.Int64
+	activePath atomic.Pointer[string]
+
+	// Closed-segment processing by the background mill: compression ("" / gzip
+	// / zstd) and retention (MaxBackups / MaxTotalBytes, <=0 unlimited).
+	compress      string
+	maxBackups    int
+	maxTotalBytes int64
+	millSig       chan struct{}
+	millStop      chan struct{}
+	millDone      chan struct{}
+	millStopOnce  sync.Once
+	rotated       uint64
+	compressed    uint64
+	removed       uint64
+	millErrored   uint64
 
 	lastWriteTime time.Time
 
@@ -127,6 +151,7 @@ type FileWriter struct {
 	quit            chan struct{}
 	stop            chan struct{}
 	flushSig        chan struct{}
+	ctl             chan func() // requests run on the daemon (ForceRotate)
 	wg              sync.WaitGroup
 	// closing is set (atomic) BEFORE Stop closes the messages channel. Once set,
 	// producers (send) stop attempting to enqueue and the daemon's drainSpill
@@ -181,6 +206,29 @@ type FileWriterOptions struct {
 	// SpillDir/SpillMaxBytes for "file" spill.
 	SpillDir      string `json:"spill_dir" mapstructure:"spill_dir"`
 	SpillMaxBytes int64  `json:"spill_max_bytes" mapstructure:"spill_max_bytes"`
+
+	// MaxSizeBytes rotates the active file once the next record would push it
+	// past this size (<=0 disables). It works alongside Daily/Hourly/Minutely:
+	// the full file is renamed to "<name>.<20060102T150405.000000><suffix>" and
+	// the same path is reopened, so the time triggers keep their schedule.
+	MaxSizeBytes int64 `json:"max_size_bytes" mapstructure:"max_size_bytes"`
+	// Compress compresses closed segments (time- or size-rotated) in the
+	// background: "none" (default), "gzip" (.gz) or "zstd" (.zst).
+	Compress string `json:"compress" mapstructure:"compress"`
+	// MaxBackups keeps at most this many closed segments, newest first
+	// (<=0 unlimited). Segments are the files matching the filename pattern with
+	// its time variables as wildcards, excluding the active file.
+	MaxBackups int `json:"max_backups" mapstructure:"max_backups"`
+	// MaxTotalBytes caps the combined on-disk size of closed segments, deleting
+	// the oldest first (<=0 unlimited). Applied after compression.
+	MaxTotalBytes int64 `json:"max_total_bytes" mapstructure:"max_total_bytes"`
+
+	// Format is this writer's serialization: "text", "json" or "logfmt".
+	// Empty (default) follows the Logger's format (LogConfig.Format).
+	Format string `json:"format" mapstructure:"format"`
+	// Layout is the time layout of this writer's text lines. Empty keeps the
+	// Logger's layout; JSON and logfmt always carry ISO time.
+	Layout string `json:"layout" mapstructure:"layout"`
 }
 
 // NewFileWriter create new file writer
@@ -195,7 +243,7 @@ func NewFileWriterWithOptions(options FileWriterOptions) *FileWriter {
 		defaultLevel = getLevelDefault(options.Level, defaultLevel, "")
 	}
 	fileWriter := &FileWriter{
-		level:      defaultLevel,
+		level:      writerLevel(defaultLevel),
 		filename:   options.Filename,
 		rotate:     options.Rotate,
 		daily:      options.Daily,
@@ -215,6 +263,12 @@ func NewFileWriterWithOptions(options FileWriterOptions) *FileWriter {
 		spillMaxBytes:  options.SpillMaxBytes,
 		flushInterval:  500 * time.Millisecond,
 		flushBatchSize: options.FlushBatchSize,
+
+		maxSize:       options.MaxSizeBytes,
+		compress:      parseCompress(options.Compress),
+		maxBackups:    options.MaxBackups,
+		maxTotalBytes: options.MaxTotalBytes,
+		format:        newWriterFormat(options.Format, options.Layout),
 	}
 	if err := fileWriter.SetPathPattern(options.Filename); err != nil {
 		log.Printf("[log4go] file writer init err: %v", err.Error())
@@ -226,6 +280,13 @@ func NewFileWriterWithOptions(options FileWriterOptions) *FileWriter {
 // Name returns WriterNameFile.
 func (w *FileWriter) Name() string { return WriterNameFile }
 
+// SetLevel changes the writer's level at runtime (LevelSetter); the next
+// record observes it.
+func (w *FileWriter) SetLevel(level int) { w.level.store(level) }
+
+// Level returns the writer's current level.
+func (w *FileWriter) Level() int { return w.level.load() }
+
 // Pause drops incoming records without removing the writer or closing the file.
 func (w *FileWriter) Pause() { w.paused.Store(true) }
 
@@ -250,10 +311,13 @@ func (w *FileWriter) Write(r *Record) error {
 	if w.paused.Load() {
 		return nil
 	}
-	if r.level > w.level {
+	if r.filteredBy(w.level.load()) {
 		return nil
 	}
+	r = w.format.apply(r)
 	if !w.async || w.messages == nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
 		return w.writeSync(r)
 	}
 	rc := *r // private copy for the daemon
@@ -269,11 +333,16 @@ func (w *FileWriter) writeSync(r *Record) error {
 	// FormatJSON fast path: emit pre-serialized bytes verbatim (set by
 	// deliverRecordToWriter) instead of re-rendering the text line.
 	var err error
+	var n int
 	if len(r.formattedBytes) > 0 {
-		_, err = w.fileBufWriter.Write(r.formattedBytes)
+		w.maybeRotateBySize(len(r.formattedBytes))
+		n, err = w.fileBufWriter.Write(r.formattedBytes)
 	} else {
-		_, err = w.fileBufWriter.WriteString(r.String())
+		line := r.String()
+		w.maybeRotateBySize(len(line))
+		n, err = w.fileBufWriter.WriteString(line)
 	}
+	w.curSize.Add(int64(n))
 	if err == nil {
 		atomic.AddUint64(&w.written, 1)
 	}
@@ -317,6 +386,7 @@ func (w *FileWriter) Init() error {
 	if err := w.Rotate(); err != nil {
 		return err
 	}
+	w.startMill()
 	if w.async {
 		w.startDaemon()
 	}
@@ -324,7 +394,8 @@ func (w *FileWriter) Init() error {
 }
 
 // Flush writes any buffered data to file. In async mode it signals the daemon
-// to flush (non-blocking); use Stop for a synchronous flush on close.
+// to flush (non-blocking); use Stop for a synchronous flush on close. Safe to
+// call from any goroutine.
 func (w *FileWriter) Flush() error {
 	if w.async && w.flushSig != nil {
 		select {
@@ -333,6 +404,8 @@ func (w *FileWriter) Flush() error {
 		}
 		return nil
 	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	if w.fileBufWriter != nil {
 		return w.fileBufWriter.Flush()
 	}
@@ -385,16 +458,49 @@ func (w *FileWriter) initFile() {
 }
 
 // Rotate file writer rotate. In async mode rotation is driven by the daemon
-// (by time), so this is a no-op; in sync mode it performs the original rotate.
+// (by time), so this is a no-op; in sync mode it performs the original rotate:
+// a new file once the time triggers are due. The logger's rotate timer calls
+// it; use ForceRotate to start a new segment now.
 func (w *FileWriter) Rotate() error {
 	if w.async {
 		return nil
 	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.rotateImpl()
 }
 
-// rotateImpl performs the real rotate; called by Rotate (sync) and by the
-// async daemon (single goroutine, no extra locking needed).
+// ForceRotate closes the active segment now, renames it to a timestamped
+// backup (as MaxSizeBytes does) and reopens the path, whatever the time and
+// size triggers say. It runs on the goroutine that owns the file — the daemon
+// in async mode, waiting for it — and is safe to call from any goroutine. It
+// returns an error when nothing could be rotated: no file is open yet (an
+// async writer opens it on its first record), the writer is stopped, or the
+// rename failed.
+func (w *FileWriter) ForceRotate() error {
+	if w.async && w.ctl != nil {
+		done := make(chan error, 1)
+		select {
+		case w.ctl <- func() { done <- w.forceRotate() }:
+			return <-done
+		case <-w.stop:
+			return errors.New("log4go: file writer stopped: " + w.filename)
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.forceRotate()
+}
+
+func (w *FileWriter) forceRotate() error {
+	if w.file == nil {
+		return errors.New("log4go: file writer has no open file to rotate: " + w.filename)
+	}
+	return w.rotateBySize(time.Now())
+}
+
+// rotateImpl performs the time-triggered rotate; called by Rotate (sync)
+// and by the async daemon (single goroutine, no extra locking needed).
 func (w *FileWriter) rotateImpl() error {
 	now := time.Now()
 	var v int
@@ -449,16 +555,9 @@ func (w *FileWriter) rotateImpl() error {
 	w.initFileOnce.Do(w.initFile)
 	w.lastWriteTime = now
 
-	if w.fileBufWriter != nil {
-		if err := w.fileBufWriter.Flush(); err != nil {
-			return err
-		}
-	}
-
-	if w.file != nil {
-		if err := w.file.Close(); err != nil {
-			return err
-		}
+	hadFile := w.file != nil
+	if err := w.closeActive(); err != nil {
+		return err
 	}
 
 	filePath := fmt.Sprintf(w.pathFmt, w.variables...)
@@ -469,22 +568,16 @@ func (w *FileWriter) rotateImpl() error {
 		}
 	}
 
-	if file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, w.rotatePerm); err == nil {
-		w.file = file
-	} else {
+	if err := w.openFile(filePath); err != nil {
 		return err
 	}
-
-	bufSize := w.bufferSize
-	if bufSize <= 0 {
-		bufSize = defaultBufferSize
+	if hadFile {
+		// the previous segment is closed: count it and let the mill compress /
+		// prune it.
+		atomic.AddUint64(&w.rotated, 1)
+		w.fire("rotate", 1)
+		w.kickMill()
 	}
-	// bufio.NewWriterSize never returns nil for a valid size (>=1), and bufSize is
-	// guaranteed >= defaultBufferSize above, so the previous nil-check was dead
-	// code — removed during coverage hardening.
-	w.fileBufWriter = bufio.NewWriterSize(w.file, bufSize)
-	w.suffix = filepath.Ext(filePath)
-	w.filenameOnly = strings.TrimSuffix(filePath, w.suffix)
 	return nil
 }
 
@@ -583,6 +676,7 @@ func (w *FileWriter) startDaemon() {
 	w.quit = make(chan struct{})
 	w.stop = make(chan struct{})
 	w.flushSig = make(chan struct{}, 1)
+	w.ctl = make(chan func())
 	if w.policy == OverflowSpill {
 		switch w.spillType {
 		case "file":
@@ -657,6 +751,8 @@ func (w *FileWriter) daemon() {
 		case <-w.flushSig:
 			_ = w.flushSync()
 			w.drainSpill()
+		case fn := <-w.ctl:
+			fn()
 		case <-w.stop:
 			w.drainQueuedAndSpill()
 			_ = w.flushSync()
@@ -709,11 +805,16 @@ func (w *FileWriter) writeOne(r *Record) {
 	// FormatJSON fast path: emit pre-serialized bytes (set by
 	// deliverRecordToWriter) instead of re-rendering the text line.
 	var writeErr error
+	var n int
 	if len(r.formattedBytes) > 0 {
-		_, writeErr = w.fileBufWriter.Write(r.formattedBytes)
+		w.maybeRotateBySize(len(r.formattedBytes))
+		n, writeErr = w.fileBufWriter.Write(r.formattedBytes)
 	} else {
-		_, writeErr = w.fileBufWriter.WriteString(r.String())
+		line := r.String()
+		w.maybeRotateBySize(len(line))
+		n, writeErr = w.fileBufWriter.WriteString(line)
 	}
+	w.curSize.Add(int64(n))
 	if writeErr != nil {
 		atomic.AddUint64(&w.errored, 1)
 		w.fire("error", 1)
@@ -793,6 +894,10 @@ func (w *FileWriter) flushSync() error {
 // racing producer slipped in after closing was set is either drained by the
 // daemon or left in an unbuffered-to-GC channel (no panic, no race).
 func (w *FileWriter) Stop() {
+	// The mill is stopped last (after the daemon's final flush/close), so a
+	// rotation during the shutdown drain is still compressed/pruned. Sync
+	// writers have no daemon; only the mill needs stopping.
+	defer w.stopMill()
 	if !w.async {
 		return
 	}
@@ -821,6 +926,7 @@ func (w *FileWriter) Stop() {
 }
 
 // FileWriterMetrics is a point-in-time snapshot of async FileWriter counters.
+// The rotation/mill counters are maintained in sync mode too.
 type FileWriterMetrics struct {
 	Written  uint64
 	Errored  uint64
@@ -828,6 +934,12 @@ type FileWriterMetrics struct {
 	Spilled  uint64
 	Queued   int
 	SpillLen int
+
+	Rotated     uint64 // segments closed by any trigger (time or size)
+	Compressed  uint64 // closed segments compressed by the mill
+	Removed     uint64 // closed segments deleted by MaxBackups/MaxTotalBytes
+	MillErrored uint64 // mill compress/remove failures
+	FileSize    int64  // bytes in the active segment (incl. unflushed bufio bytes)
 }
 
 // Metrics returns a snapshot of async FileWriter counters for monitoring.
@@ -847,6 +959,12 @@ func (w *FileWriter) Metrics() FileWriterMetrics {
 		Spilled:  w.stats.Spilled(),
 		Queued:   queued,
 		SpillLen: spillLen,
+
+		Rotated:     atomic.LoadUint64(&w.rotated),
+		Compressed:  atomic.LoadUint64(&w.compressed),
+		Removed:     atomic.LoadUint64(&w.removed),
+		MillErrored: atomic.LoadUint64(&w.millErrored),
+		FileSize:    w.curSize.Load(),
 	}
 }
 
@@ -228,19 +228,35 @@ func Test_FileWriter_Async_SpillChain(t *testing.T) {
 	}
 }
 
-// Test_FileWriter_Async_RotateNoop asserts Rotate is a no-op in async mode
-// (rotation is driven by the daemon by time, not by the sync entrypoint).
-func Test_FileWriter_Async_RotateNoop(t *testing.T) {
+// Test_FileWriter_Async_ForceRotate asserts ForceRotate runs on the daemon and
+// starts a new segment, and reports an error when there is nothing to rotate.
+// Rotate stays a no-op in async mode.
+func Test_FileWriter_Async_ForceRotate(t *testing.T) {
 	fw, dir := newAsyncFileWriter(t, FileWriterOptions{AsyncBufferSize: 64})
-	defer os.RemoveAll(dir)
-	records := make(chan *Record, 64)
-	lg := newLoggerWithRecords(records)
-	lg.SetLevel(DEBUG)
-	lg.Register(fw)
-	defer func() { lg.Close(); fw.Stop() }()
-
+	if err := fw.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := fw.ForceRotate(); err == nil {
+		t.Fatal("ForceRotate before the first record: want an error (no file open)")
+	}
+	_ = fw.Write(&Record{level: INFO, time: "t", file: "f", msg: "before rotate"})
+	deadline := time.Now().Add(2 * time.Second)
+	for fw.Metrics().Written == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := fw.ForceRotate(); err != nil {
+		t.Fatalf("ForceRotate: %v", err)
+	}
 	if err := fw.Rotate(); err != nil {
-		t.Fatalf("async Rotate returned %v (want nil no-op)", err)
+		t.Fatalf("Rotate: %v", err)
+	}
+	_ = fw.Write(&Record{level: INFO, time: "t", file: "f", msg: "after rotate"})
+	fw.Stop()
+	if files, _ := filepath.Glob(filepath.Join(dir, "async-*")); len(files) != 2 || fw.Metrics().Rotated != 1 {
+		t.Errorf("files=%v Rotated=%d want 2 files, 1 rotation", files, fw.Metrics().Rotated)
+	}
+	if err := fw.ForceRotate(); err == nil {
+		t.Error("ForceRotate after Stop: want an error")
 	}
 }
 
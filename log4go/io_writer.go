@@ -22,13 +22,20 @@ import (
 // into an existing io.Writer-based sink owned by the application.
 type IOWriter struct {
 	w      io.Writer
-	level  int
+	level  writerLevel
 	paused atomic.Bool
 }
 
 // Name returns WriterNameIO.
 func (i *IOWriter) Name() string { return WriterNameIO }
 
+// SetLevel changes the writer's level at runtime (LevelSetter); the next
+// record observes it.
+func (i *IOWriter) SetLevel(level int) { i.level.store(level) }
+
+// Level returns the writer's current level.
+func (i *IOWriter) Level() int { return i.level.load() }
+
 // Pause drops incoming records without removing the writer.
 func (i *IOWriter) Pause() { i.paused.Store(true) }
 
@@ -42,7 +49,7 @@ func (i *IOWriter) Paused() bool { return i.paused.Load() }
 // caller retains ownership of w; IOWriter does not close it (closing is the
 // caller's responsibility, matching io.Writer conventions).
 func NewIOWriter(w io.Writer, level int) *IOWriter {
-	return &IOWriter{w: w, level: level}
+	return &IOWriter{w: w, level: writerLevel(level)}
 }
 
 // Init is a no-op (the io.Writer is already open). Present to satisfy the
@@ -56,7 +63,7 @@ func (i *IOWriter) Write(r *Record) error {
 	if i.paused.Load() {
 		return nil
 	}
-	if r.level > i.level {
+	if r.filteredBy(i.level.load()) {
 		return nil
 	}
 	if len(r.formattedBytes) > 0 {
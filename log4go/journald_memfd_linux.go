@@ -0,0 +1,42 @@
+package log4go
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendJournalMemfd delivers an oversized entry the way sd_journal_send does:
+// the payload goes into a sealed memfd whose descriptor is passed to journald
+// with SCM_RIGHTS in an otherwise empty datagram.
+func sendJournalMemfd(conn *net.UnixConn, payload []byte) error {
+	fd, err := unix.MemfdCreate("log4go-journal", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "log4go-journal")
+	defer f.Close()
+	if _, err := f.Write(payload); err != nil {
+		return err
+	}
+	// journald refuses unsealed memfds.
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+		return err
+	}
+	// sendmsg on the raw fd: net.UnixConn.WriteMsgUnix rejects an empty
+	// datagram on a connected socket.
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := unix.UnixRights(int(f.Fd()))
+	var serr error
+	if err := rc.Write(func(s uintptr) bool {
+		serr = unix.Sendmsg(int(s), nil, rights, nil, 0)
+		return serr != unix.EAGAIN
+	}); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package log4go
+
+import (
+	"errors"
+	"net"
+)
+
+// sendJournalMemfd is Linux-only (memfd_create); journald does not exist
+// elsewhere, so oversized entries are reported as errors.
+func sendJournalMemfd(_ *net.UnixConn, _ []byte) error {
+	return errors.New("log4go: journald memfd fallback requires linux")
+}
@@ -0,0 +1,296 @@
+package log4go
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// DefaultJournaldSocket is the systemd-journald native protocol socket.
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriterOptions configures a JournaldWriter.
+//
+// The queue knobs (BufferSize / OverflowPolicy / SpillSize / Timeout) are
+// NetWriter's: JournaldWriter runs on NetWriter's async daemon, so a stalled
+// journald never blocks the logger hot path.
+type JournaldWriterOptions struct {
+	// Enable gates registration from LogConfig.
+	Enable bool `json:"enable" mapstructure:"enable"`
+	// Level is the text level flag (default DEBUG).
+	Level string `json:"level" mapstructure:"level"`
+	// SocketPath is the journald socket (default DefaultJournaldSocket).
+	SocketPath string `json:"socket_path" mapstructure:"socket_path"`
+	// SyslogIdentifier sets SYSLOG_IDENTIFIER (default the executable base
+	// name), the tag `journalctl -t` filters on.
+	SyslogIdentifier string `json:"syslog_identifier" mapstructure:"syslog_identifier"`
+	// BufferSize is the async channel capacity (<=0 -> 1024).
+	BufferSize int `json:"buffer_size" mapstructure:"buffer_size"`
+	// OverflowPolicy: "drop"(default)|"block"|"spill".
+	OverflowPolicy string `json:"overflow_policy" mapstructure:"overflow_policy"`
+	// SpillSize: ring capacity (records) when policy == "spill".
+	SpillSize int `json:"spill_size" mapstructure:"spill_size"`
+	// Timeout is the per-datagram write deadline (<=0 -> 3s).
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+}
+
+// JournaldWriter writes records to systemd-journald over its native datagram
+// protocol, so structured fields stay queryable (`journalctl FIELD=value`)
+// instead of being flattened into a text line.
+//
+// Each record becomes one entry with MESSAGE, PRIORITY (log4go levels are the
+// syslog severities; TRACE is sent as debug), CODE_FILE / CODE_LINE /
+// CODE_FUNC from the caller, SYSLOG_IDENTIFIER, and every record field under
+// its journal name: uppercased, with characters outside [A-Z0-9_] replaced by
+// '_' and leading underscores/digits stripped (journald reserves "_" for
+// trusted fields). Fields that would shadow a writer-owned name are sent as-is
+// and journald keeps both values.
+//
+// A datagram too large for the socket (EMSGSIZE / ENOBUFS) is retried through a
+// sealed memfd passed with SCM_RIGHTS, exactly as sd_journal_send does; that
+// path is Linux-only.
+type JournaldWriter struct {
+	q          *NetWriter
+	socketPath string
+	identifier string
+
+	conn      *net.UnixConn // daemon-only
+	memfdSent uint64
+}
+
+// NewJournaldWriter builds a JournaldWriter. The socket is dialed lazily on the
+// first record, so a host without journald does not fail Register (records are
+// counted as errored instead).
+func NewJournaldWriter(options JournaldWriterOptions) *JournaldWriter {
+	w := &JournaldWriter{
+		socketPath: options.SocketPath,
+		identifier: options.SyslogIdentifier,
+	}
+	if w.socketPath == "" {
+		w.socketPath = DefaultJournaldSocket
+	}
+	if w.identifier == "" {
+		w.identifier = filepath.Base(os.Args[0])
+	}
+	w.q = NewNetWriter(NetWriterOptions{
+		Level:          options.Level,
+		BufferSize:     options.BufferSize,
+		OverflowPolicy: options.OverflowPolicy,
+		SpillSize:      options.SpillSize,
+		Timeout:        options.Timeout,
+	})
+	w.q.transport = w
+	return w
+}
+
+// Init starts the async daemon.
+func (w *JournaldWriter) Init() error { return w.q.Init() }
+
+// Write enqueues r under the overflow policy (see NetWriter.Write).
+func (w *JournaldWriter) Write(r *Record) error { return w.q.Write(r) }
+
+// Name returns WriterNameJournald.
+func (w *JournaldWriter) Name() string { return WriterNameJournald }
+
+// SetLevel changes the writer's level at runtime (LevelSetter).
+func (w *JournaldWriter) SetLevel(level int) { w.q.SetLevel(level) }
+
+// Level returns the writer's current level.
+func (w *JournaldWriter) Level() int { return w.q.Level() }
+
+// Pause drops incoming records without closing the socket.
+func (w *JournaldWriter) Pause() { w.q.Pause() }
+
+// Resume restores delivery after Pause.
+func (w *JournaldWriter) Resume() { w.q.Resume() }
+
+// Paused reports whether the writer is currently paused.
+func (w *JournaldWriter) Paused() bool { return w.q.Paused() }
+
+// Flush is a no-op; each entry is sent as it is dequeued.
+func (w *JournaldWriter) Flush() error { return nil }
+
+// Stop drains the queue and spill store, then closes the socket.
+func (w *JournaldWriter) Stop() { w.q.Stop() }
+
+// Metrics returns the queue counters.
+func (w *JournaldWriter) Metrics() NetWriterMetrics { return w.q.Metrics() }
+
+// MemfdSent returns the number of entries delivered through the memfd
+// fallback.
+func (w *JournaldWriter) MemfdSent() uint64 { return atomic.LoadUint64(&w.memfdSent) }
+
+func (w *JournaldWriter) dial() error {
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: w.socketPath, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	w.conn = c
+	return nil
+}
+
+// send encodes r and writes it as one datagram, falling back to a memfd when
+// the payload is too large. Errors close the socket so the next record re-dials.
+func (w *JournaldWriter) send(r *Record) error {
+	if w.conn == nil {
+		if err := w.dial(); err != nil {
+			atomic.AddUint64(&w.q.errored, 1)
+			atomic.AddUint64(&w.q.dropped, 1)
+			return err
+		}
+	}
+	payload := w.encode(r)
+	_ = w.conn.SetWriteDeadline(time.Now().Add(w.q.timeout))
+	_, err := w.conn.Write(payload)
+	if err != nil && (errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+		if err = sendJournalMemfd(w.conn, payload); err == nil {
+			atomic.AddUint64(&w.memfdSent, 1)
+		}
+	}
+	if err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		atomic.AddUint64(&w.q.errored, 1)
+		atomic.AddUint64(&w.q.dropped, 1)
+		return err
+	}
+	atomic.AddUint64(&w.q.sent, 1)
+	return nil
+}
+
+func (w *JournaldWriter) flush() error { return nil }
+
+func (w *JournaldWriter) close() error {
+	if w.conn != nil {
+		err := w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+// encode renders r in the journal export/native format: "KEY=value\n" for
+// single-line values, or "KEY\n" + little-endian uint64 length + value + "\n"
+// for values containing a newline.
+func (w *JournaldWriter) encode(r *Record) []byte {
+	buf := make([]byte, 0, 128+len(r.msg)+len(r.fields)*32)
+	buf = appendJournalField(buf, "MESSAGE", r.msg)
+	buf = append(buf, "PRIORITY="...)
+	buf = strconv.AppendInt(buf, int64(syslogSeverity(r.level)), 10)
+	buf = append(buf, '\n')
+	buf = appendJournalField(buf, "SYSLOG_IDENTIFIER", w.identifier)
+	if r.file != "" {
+		loc, fn, _ := strings.Cut(r.file, " ")
+		if i := strings.LastIndexByte(loc, ':'); i > 0 {
+			if _, err := strconv.Atoi(loc[i+1:]); err == nil {
+				buf = appendJournalField(buf, "CODE_FILE", loc[:i])
+				buf = appendJournalField(buf, "CODE_LINE", loc[i+1:])
+			} else {
+				buf = appendJournalField(buf, "CODE_FILE", loc)
+			}
+		} else {
+			buf = appendJournalField(buf, "CODE_FILE", loc)
+		}
+		if fn != "" {
+			buf = appendJournalField(buf, "CODE_FUNC", fn)
+		}
+	}
+	var scratch []byte
+	for _, f := range r.fields {
+		name := journalFieldName(f.key)
+		if name == "" {
+			continue
+		}
+		scratch = appendJournalValue(scratch[:0], f)
+		buf = appendJournalField(buf, name, string(scratch))
+	}
+	return buf
+}
+
+func appendJournalField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf = append(buf, '=')
+		buf = append(buf, value...)
+		return append(buf, '\n')
+	}
+	buf = append(buf, '\n')
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+// appendJournalValue renders a field value as plain text (no quoting).
+func appendJournalValue(buf []byte, f field) []byte {
+	switch f.kind {
+	case kindString, kindBytes: // bytes: base64, as in the JSON/logfmt forms
+		return append(buf, f.str...)
+	case kindInt, kindInt64, kindDuration:
+		return strconv.AppendInt(buf, f.i, 10)
+	case kindUint:
+		return strconv.AppendUint(buf, uint64(f.i), 10)
+	case kindBool:
+		return strconv.AppendBool(buf, f.i == 1)
+	case kindFloat64:
+		return strconv.AppendFloat(buf, math.Float64frombits(uint64(f.i)), 'g', -1, 64)
+	case kindTime:
+		return appendISOTimeUTC(buf, f.i)
+	case kindError:
+		if e, ok := f.any.(error); ok {
+			if s, ok := safeErrorString(e); ok {
+				return append(buf, s...)
+			}
+		}
+		return buf
+	default:
+		if s, ok := f.any.(string); ok {
+			return append(buf, s...)
+		}
+		if b, ok := safeJSONMarshal(f.any); ok {
+			return append(buf, b...)
+		}
+		return buf
+	}
+}
+
+// journalFieldName maps a field key to a valid journal field name: uppercase
+// [A-Z0-9_], not starting with '_' or a digit, at most 64 bytes. Returns "" when
+// nothing valid is left.
+func journalFieldName(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := range len(key) {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			c = '_'
+		}
+		if len(b) == 0 && (c == '_' || (c >= '0' && c <= '9')) {
+			continue
+		}
+		b = append(b, c)
+	}
+	if len(b) > 64 {
+		b = b[:64]
+	}
+	return string(b)
+}
+
+// compile-time: JournaldWriter implements the standard writer control surface.
+var (
+	_ Writer       = (*JournaldWriter)(nil)
+	_ Stopper      = (*JournaldWriter)(nil)
+	_ Pauser       = (*JournaldWriter)(nil)
+	_ Named        = (*JournaldWriter)(nil)
+	_ netTransport = (*JournaldWriter)(nil)
+)
@@ -0,0 +1,64 @@
+package log4go
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func Test_JournaldWriter_MemfdFallback(t *testing.T) {
+	c, sock := newFakeJournal(t)
+	w := NewJournaldWriter(JournaldWriterOptions{SocketPath: sock})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	big := strings.Repeat("x", 4<<20) // beyond any unix datagram limit
+	_ = w.Write(&Record{level: INFO, msg: big})
+
+	fd := readJournalFd(t, c)
+	f := os.NewFile(uintptr(fd), "memfd")
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, st.Size())
+	if _, err := f.ReadAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	e := parseJournalEntry(t, data)
+	if got := e["MESSAGE"]; len(got) != 1 || got[0] != big {
+		t.Fatalf("MESSAGE len=%d want %d", len(got[0]), len(big))
+	}
+	if seals, err := unix.FcntlInt(uintptr(fd), unix.F_GET_SEALS, 0); err != nil || seals&unix.F_SEAL_WRITE == 0 {
+		t.Errorf("memfd not sealed: seals=%#x err=%v", seals, err)
+	}
+	if w.MemfdSent() != 1 || w.Metrics().Sent != 1 {
+		t.Errorf("MemfdSent=%d Sent=%d", w.MemfdSent(), w.Metrics().Sent)
+	}
+}
+
+// readJournalFd receives the SCM_RIGHTS descriptor of a memfd entry.
+func readJournalFd(t *testing.T, c *net.UnixConn) int {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := c.ReadMsgUnix(make([]byte, 16), oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("control messages=%d err=%v", len(msgs), err)
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("fds=%v err=%v", fds, err)
+	}
+	return fds[0]
+}
@@ -0,0 +1,152 @@
+package log4go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseJournalEntry decodes a native-protocol datagram into field -> values.
+func parseJournalEntry(t *testing.T, b []byte) map[string][]string {
+	t.Helper()
+	out := map[string][]string{}
+	for len(b) > 0 {
+		nl := bytes.IndexByte(b, '\n')
+		if nl < 0 {
+			t.Fatalf("unterminated field %q", b)
+		}
+		line := b[:nl]
+		b = b[nl+1:]
+		if name, val, ok := bytes.Cut(line, []byte{'='}); ok {
+			out[string(name)] = append(out[string(name)], string(val))
+			continue
+		}
+		// binary-safe form: NAME\n <u64 LE len> value \n
+		n := binary.LittleEndian.Uint64(b[:8])
+		out[string(line)] = append(out[string(line)], string(b[8:8+n]))
+		b = b[8+n+1:]
+	}
+	return out
+}
+
+// newFakeJournal listens on a unixgram socket standing in for journald.
+func newFakeJournal(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "journal.sock")
+	c, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram unsupported: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c, sock
+}
+
+func readJournalDatagram(t *testing.T, c *net.UnixConn) map[string][]string {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 64<<10)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseJournalEntry(t, buf[:n])
+}
+
+func Test_JournaldWriter_Fields(t *testing.T) {
+	c, sock := newFakeJournal(t)
+	w := NewJournaldWriter(JournaldWriterOptions{SocketPath: sock, SyslogIdentifier: "bidder"})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	_ = w.Write(&Record{
+		level: WARNING, msg: "bid\nfailed", file: "bidder.go:42 main.serve", unixNano: 1,
+		fields: []field{
+			strField("req-id", "r1"),
+			intField("count", 3),
+			boolField("ok", false),
+			strField("_hidden", "x"), // leading '_' is reserved for trusted fields
+			strField("9lives", "y"),
+			strField("---", "dropped"),
+		},
+	})
+
+	e := readJournalDatagram(t, c)
+	want := map[string]string{
+		"MESSAGE":           "bid\nfailed",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "bidder",
+		"CODE_FILE":         "bidder.go",
+		"CODE_LINE":         "42",
+		"CODE_FUNC":         "main.serve",
+		"REQ_ID":            "r1",
+		"COUNT":             "3",
+		"OK":                "false",
+		"HIDDEN":            "x",
+		"LIVES":             "y",
+	}
+	for k, v := range want {
+		if got := e[k]; len(got) != 1 || got[0] != v {
+			t.Errorf("%s=%q want %q", k, got, v)
+		}
+	}
+	if len(e) != len(want) {
+		t.Errorf("unexpected fields: %v", e)
+	}
+	if m := w.Metrics(); m.Sent != 1 || w.Name() != WriterNameJournald {
+		t.Errorf("metrics=%+v name=%q", m, w.Name())
+	}
+}
+
+func Test_JournaldWriter_TracePriority(t *testing.T) {
+	w := NewJournaldWriter(JournaldWriterOptions{})
+	e := parseJournalEntry(t, w.encode(&Record{level: TRACE, msg: "m"}))
+	if e["PRIORITY"][0] != "7" {
+		t.Errorf("TRACE priority=%v want 7", e["PRIORITY"])
+	}
+	if w.socketPath != DefaultJournaldSocket || e["SYSLOG_IDENTIFIER"][0] == "" {
+		t.Errorf("defaults: socket=%q identifier=%v", w.socketPath, e["SYSLOG_IDENTIFIER"])
+	}
+}
+
+func Test_JournaldWriter_NoSocket(t *testing.T) {
+	w := NewJournaldWriter(JournaldWriterOptions{SocketPath: filepath.Join(t.TempDir(), "missing.sock")})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Write(&Record{level: INFO, msg: "m"})
+	w.Stop()
+	if m := w.Metrics(); m.Errored != 1 || m.Dropped != 1 {
+		t.Errorf("metrics=%+v want errored=1 dropped=1", m)
+	}
+}
+
+func Test_JournaldWriter_FromLogConfig(t *testing.T) {
+	c, sock := newFakeJournal(t)
+	var lc LogConfig
+	cfg := `{"level":"DEBUG","journald_writer":{"enable":true,"level":"INFO","socket_path":` + strings.TrimSpace(jsonString(sock)) + `,"syslog_identifier":"cfg"}}`
+	if err := json.Unmarshal([]byte(cfg), &lc); err != nil {
+		t.Fatal(err)
+	}
+	l := NewLogger()
+	defer l.Close()
+	if err := l.applyConfig(lc); err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("filtered")
+	l.Info("configured")
+	e := readJournalDatagram(t, c)
+	if e["MESSAGE"][0] != "configured" || e["SYSLOG_IDENTIFIER"][0] != "cfg" {
+		t.Errorf("entry=%v", e)
+	}
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
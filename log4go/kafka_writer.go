@@ -1,6 +1,7 @@
 package log4go
 
 import (
+	"bytes"
 	"context"
 	"log"
 	"reflect"
@@ -141,16 +142,26 @@ type KafkaWriterOptions struct {
 	// Access via ProducerSnapshot() + type-assert for kafka.SnapshotHistory.
 	ProducerSnapshotHistory int `json:"producer_snapshot_history" mapstructure:"producer_snapshot_history"`
 
+	// Format, when set ("text", "json" or "logfmt"), sends each record as that
+	// rendering (without the trailing newline) instead of the Kafka payload, so
+	// the topic carries the same lines as a file or console writer with that
+	// format. MSG and the codec do not apply then. Empty (default) keeps the
+	// Kafka payload, whatever the Logger's format.
+	Format string `json:"format" mapstructure:"format"`
+	// Layout is the time layout of a "text" Format. Empty keeps the Logger's.
+	Layout string `json:"layout" mapstructure:"layout"`
+
 	MSG KafkaMSGFields `json:"msg"`
 }
 
 // KafkaWriter kafka writer (async, bounded, overflow-safe).
 type KafkaWriter struct {
-	level    int
+	level    writerLevel
 	paused   atomic.Bool
 	producer kafka.Producer
 	messages chan kafka.Message
 	options  KafkaWriterOptions
+	format   writerFormat
 
 	policy     OverflowPolicy
 	spiller    Spiller[kafka.Message]
@@ -210,8 +221,9 @@ func NewKafkaWriter(options KafkaWriterOptions) *KafkaWriter {
 	}
 	w := &KafkaWriter{
 		options:            options,
+		format:             newWriterFormat(options.Format, options.Layout),
 		quit:               make(chan struct{}),
-		level:              defaultLevel,
+		level:              writerLevel(defaultLevel),
 		policy:             ParseOverflowPolicy(options.OverflowPolicy),
 		drainInterval:      200 * time.Millisecond,
 		batchMode:          options.BatchMode,
@@ -421,6 +433,13 @@ func (k *KafkaWriter) SetKafkaCodec(c KafkaCodec) {
 // Name returns WriterNameKafka.
 func (k *KafkaWriter) Name() string { return WriterNameKafka }
 
+// SetLevel changes the writer's level at runtime (LevelSetter); the next
+// record observes it.
+func (k *KafkaWriter) SetLevel(level int) { k.level.store(level) }
+
+// Level returns the writer's current level.
+func (k *KafkaWriter) Level() int { return k.level.load() }
+
 // Pause drops incoming records without removing the writer or closing the producer.
 func (k *KafkaWriter) Pause() { k.paused.Store(true) }
 
@@ -431,14 +450,15 @@ func (k *KafkaWriter) Resume() { k.paused.Store(false) }
 func (k *KafkaWriter) Paused() bool { return k.paused.Load() }
 
 // Write writes r by building the Kafka payload (JSON or protobuf, per the
-// configured codec) and delivering it to a bounded channel under the configured
-// overflow policy. It never spawns a goroutine per record. An empty message is
-// skipped (no payload).
+// configured codec; the record's rendering in Format when one is set) and
+// delivering it to a bounded channel under the configured overflow policy.
+// It never spawns a goroutine per record. An empty message is skipped (no
+// payload).
 func (k *KafkaWriter) Write(r *Record) error {
 	if k.paused.Load() {
 		return nil
 	}
-	if r.level > k.level {
+	if r.filteredBy(k.level.load()) {
 		return nil
 	}
 	if r.msg == "" {
@@ -446,7 +466,13 @@ func (k *KafkaWriter) Write(r *Record) error {
 	}
 	// buildPayload never returns nil (MarshalJSON is infallible), so the former
 	// nil-guard was unreachable dead code — removed during coverage hardening.
-	payload := k.buildPayload(r)
+	var payload []byte
+	if k.format.set {
+		// One message per record: the shared encoding minus its line break.
+		payload = bytes.TrimSuffix(k.format.bytes(r), []byte{'\n'})
+	} else {
+		payload = k.buildPayload(r)
+	}
 	key := k.options.Key
 	msg := kafka.Message{
 		Topic: k.options.ProducerTopic,
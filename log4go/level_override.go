@@ -0,0 +1,467 @@
+package log4go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LevelOverride is one entry of a logger's level-override table, as listed by
+// Logger.LevelOverrides and the admin handler.
+type LevelOverride struct {
+	// Pattern selects call sites. A pattern ending in ".go" is a FILE GLOB
+	// (path.Match syntax): without '/' it matches the file's base name
+	// ("*_repo.go"), with '/' it matches the same number of trailing path
+	// elements ("payment/*.go"). Anything else is a PACKAGE PATH — matching
+	// that package and its sub-packages — or, when it extends past the
+	// package, a FUNCTION PREFIX on the qualified name
+	// ("github.com/acme/svc/payment.(*Client).Charge").
+	Pattern string `json:"pattern"`
+	// Level is the level flag in effect for matching call sites ("DEBUG", ...).
+	Level string `json:"level"`
+	// Expires is when the override is removed (zero: never).
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// levelOverrideEntry is a compiled LevelOverride.
+type levelOverrideEntry struct {
+	pattern string
+	glob    bool
+	depth   int // path elements a '/'-glob spans (glob only)
+	level   int
+	expires time.Time
+	timer   *time.Timer
+}
+
+func newLevelOverrideEntry(pattern string, level int) (*levelOverrideEntry, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, errors.New("log4go: empty level override pattern")
+	}
+	if level < EMERGENCY || level > TRACE {
+		return nil, fmt.Errorf("log4go: level override %q: invalid level %d", pattern, level)
+	}
+	e := &levelOverrideEntry{pattern: pattern, level: level}
+	if strings.HasSuffix(pattern, ".go") {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("log4go: level override %q: %w", pattern, err)
+		}
+		e.glob = true
+		e.depth = strings.Count(pattern, "/") + 1
+	}
+	return e, nil
+}
+
+// match reports whether the call site (full file path, qualified func name)
+// is selected by the entry.
+func (e *levelOverrideEntry) match(file, fn string) bool {
+	if e.glob {
+		if file == "" {
+			return false
+		}
+		tail := file
+		for i, n := len(file)-1, 0; i >= 0; i-- {
+			if file[i] == '/' {
+				if n++; n == e.depth {
+					tail = file[i+1:]
+					break
+				}
+			}
+		}
+		ok, _ := path.Match(e.pattern, tail)
+		return ok
+	}
+	if fn == "" {
+		return false
+	}
+	pkg := funcPackage(fn)
+	p := e.pattern
+	if pkg == p || strings.HasPrefix(pkg, p+"/") {
+		return true
+	}
+	return len(p) > len(pkg) && strings.HasPrefix(fn, p)
+}
+
+// funcPackage returns the import path of a qualified func name: everything up
+// to the first '.' after the last '/'.
+func funcPackage(fn string) string {
+	slash := strings.LastIndexByte(fn, '/')
+	if i := strings.IndexByte(fn[slash+1:], '.'); i >= 0 {
+		return fn[:slash+1+i]
+	}
+	return fn
+}
+
+// levelOverrideTable is an immutable snapshot of the overrides, with the
+// per-call-site verdicts memoized: like callerCache, a pc always maps to the
+// same site, so matching runs once per site per table version.
+type levelOverrideTable struct {
+	entries []*levelOverrideEntry // most specific (longest pattern) first
+	verbose int                   // most verbose override level
+	quiet   int                   // least verbose override level
+	sites   sync.Map              // pc -> int (override level, -1: none)
+}
+
+// affects reports whether some override could change the verdict on a record
+// at level under the logger level base. Outside that band the call site is
+// irrelevant, so the caller skips resolving it: with only DEBUG overrides set,
+// INFO and above never pay for the stack walk.
+func (t *levelOverrideTable) affects(level, base int) bool {
+	return level > min(t.quiet, base) && level <= max(t.verbose, base)
+}
+
+// levelFor returns the override level for the call site pc, resolved on a
+// miss by resolve (callerQualified, or slogCaller for slog.Record.PC).
+func (t *levelOverrideTable) levelFor(pc uintptr, resolve func(uintptr) (string, string)) (int, bool) {
+	if v, ok := t.sites.Load(pc); ok {
+		lvl := v.(int)
+		return lvl, lvl >= 0
+	}
+	lvl := -1
+	file, fn := resolve(pc)
+	for _, e := range t.entries {
+		if e.match(file, fn) {
+			lvl = e.level
+			break
+		}
+	}
+	t.sites.Store(pc, lvl)
+	return lvl, lvl >= 0
+}
+
+// levelOverrideHolder is the override state of a logger tree. Like
+// redactorHolder it is shared by pointer across clones, so an override set on
+// the root applies to records from every child. Writers (set/remove/expiry)
+// serialize on mu and publish a fresh table; the hot path only loads v.
+type levelOverrideHolder struct {
+	mu      sync.Mutex
+	entries map[string]*levelOverrideEntry
+	v       atomic.Pointer[levelOverrideTable] // nil when no override is set
+}
+
+// publish rebuilds the table from entries. Caller holds mu.
+func (h *levelOverrideHolder) publish() {
+	if len(h.entries) == 0 {
+		h.v.Store(nil)
+		return
+	}
+	t := &levelOverrideTable{entries: make([]*levelOverrideEntry, 0, len(h.entries)), verbose: EMERGENCY, quiet: TRACE}
+	for _, e := range h.entries {
+		t.entries = append(t.entries, e)
+		t.verbose = max(t.verbose, e.level)
+		t.quiet = min(t.quiet, e.level)
+	}
+	sort.Slice(t.entries, func(i, j int) bool {
+		a, b := t.entries[i].pattern, t.entries[j].pattern
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	h.v.Store(t)
+}
+
+func (h *levelOverrideHolder) set(e *levelOverrideEntry, ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.entries == nil {
+		h.entries = make(map[string]*levelOverrideEntry)
+	}
+	if old := h.entries[e.pattern]; old != nil && old.timer != nil {
+		old.timer.Stop()
+	}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+		e.timer = time.AfterFunc(ttl, func() { h.expire(e) })
+	}
+	h.entries[e.pattern] = e
+	h.publish()
+}
+
+// expire removes e unless it was replaced since its timer was armed.
+func (h *levelOverrideHolder) expire(e *levelOverrideEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.entries[e.pattern] == e {
+		delete(h.entries, e.pattern)
+		h.publish()
+	}
+}
+
+func (h *levelOverrideHolder) remove(pattern string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.entries[strings.TrimSpace(pattern)]
+	if !ok {
+		return false
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(h.entries, e.pattern)
+	h.publish()
+	return true
+}
+
+func (h *levelOverrideHolder) clear() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+	h.entries = nil
+	h.publish()
+}
+
+func (h *levelOverrideHolder) list() []LevelOverride {
+	t := h.v.Load()
+	if t == nil {
+		return []LevelOverride{}
+	}
+	out := make([]LevelOverride, 0, len(t.entries))
+	for _, e := range t.entries {
+		out = append(out, LevelOverride{Pattern: e.pattern, Level: LevelFlags[e.level], Expires: e.expires})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Pattern < out[j].Pattern })
+	return out
+}
+
+// SetLevelOverride sets the level for call sites matching pattern (see
+// LevelOverride.Pattern), replacing the logger level there in both
+// directions: DEBUG on one package while the rest stays at INFO, or WARNING to
+// quiet a noisy one. When several patterns match, the longest wins. ttl > 0
+// removes the override after that long; ttl <= 0 keeps it until removed.
+//
+// The override applies to this logger and every logger sharing its tree
+// (With* children, before or after the call) and to SlogHandler records. A
+// record an override enables below the logger level carries that verdict to
+// the writers: those configured at the logger level or more verbose take it
+// (a DEBUG override reaches the INFO file writer SetupLog builds), while a
+// writer kept stricter than the logger, such as an ERROR-only one, stays so.
+//
+// With no override set the hot path pays one atomic load. With any set, only
+// calls at a level some override could change resolve their call site
+// (runtime.Callers plus lookups memoized per site); the rest skip it.
+func (l *Logger) SetLevelOverride(pattern string, level int, ttl time.Duration) error {
+	if l.overrides == nil {
+		return errors.New("log4go: logger does not support level overrides")
+	}
+	e, err := newLevelOverrideEntry(pattern, level)
+	if err != nil {
+		return err
+	}
+	l.overrides.set(e, ttl)
+	return nil
+}
+
+// RemoveLevelOverride deletes the override for pattern, reporting whether it
+// existed.
+func (l *Logger) RemoveLevelOverride(pattern string) bool {
+	if l.overrides == nil {
+		return false
+	}
+	return l.overrides.remove(pattern)
+}
+
+// ClearLevelOverrides deletes every override.
+func (l *Logger) ClearLevelOverrides() {
+	if l.overrides != nil {
+		l.overrides.clear()
+	}
+}
+
+// LevelOverrides lists the active overrides, sorted by pattern.
+func (l *Logger) LevelOverrides() []LevelOverride {
+	if l.overrides == nil {
+		return []LevelOverride{}
+	}
+	return l.overrides.list()
+}
+
+// SetLevelOverride sets a level override on the default logger.
+func SetLevelOverride(pattern string, level int, ttl time.Duration) error {
+	return defaultLogger().SetLevelOverride(pattern, level, ttl)
+}
+
+// RemoveLevelOverride deletes a level override from the default logger.
+func RemoveLevelOverride(pattern string) bool { return defaultLogger().RemoveLevelOverride(pattern) }
+
+// LevelOverrides lists the default logger's level overrides.
+func LevelOverrides() []LevelOverride { return defaultLogger().LevelOverrides() }
+
+// levelFor returns the effective logger level for the call site pc (see
+// userCallerPC): the override when one matches, else the logger level.
+func (l *Logger) levelFor(pc uintptr) int {
+	return l.overrideLevel(pc, callerQualified)
+}
+
+// overridesAffect reports whether a record at level needs its call site
+// resolved: an override is set and could change its verdict under base.
+func (l *Logger) overridesAffect(level, base int) bool {
+	if l.overrides == nil {
+		return false
+	}
+	t := l.overrides.v.Load()
+	return t != nil && t.affects(level, base)
+}
+
+// admitOverride marks r as enabled by an override more verbose than the
+// logger level base, so writers at base or more verbose take it (see
+// Record.filteredBy).
+func admitOverride(r *Record, minLevel, base int) {
+	if minLevel > base {
+		r.admit, r.admitFrom = minLevel, base
+	}
+}
+
+// slogLevelFor is levelFor for a slog.Record.PC, a return address that must go
+// through runtime.CallersFrames to see past inlined slog frames.
+func (l *Logger) slogLevelFor(pc uintptr) int {
+	return l.overrideLevel(pc, slogCaller)
+}
+
+func (l *Logger) overrideLevel(pc uintptr, resolve func(uintptr) (string, string)) int {
+	if l.overrides != nil && pc != 0 {
+		if t := l.overrides.v.Load(); t != nil {
+			if lvl, ok := t.levelFor(pc, resolve); ok {
+				return lvl
+			}
+		}
+	}
+	return int(l.level.Load())
+}
+
+// slogCaller resolves a slog.Record.PC to its full file path and qualified
+// func name.
+func slogCaller(pc uintptr) (file, fn string) {
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return f.File, f.Function
+}
+
+// maxOverrideLevel returns the most verbose level any override enables, or -1.
+func (l *Logger) maxOverrideLevel() int {
+	if l.overrides != nil {
+		if t := l.overrides.v.Load(); t != nil {
+			return t.verbose
+		}
+	}
+	return -1
+}
+
+// userCallerPC returns the pc of the first frame outside log4go (see
+// callerIsInternal), or 0.
+func userCallerPC() uintptr {
+	var pcs [8]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip runtime.Callers, this func and its caller
+	for i := range n {
+		if !callerIsInternal(pcs[i]) {
+			return pcs[i]
+		}
+	}
+	return 0
+}
+
+// ParseLevel maps a level flag ("DEBUG", "warn", ...) to its level, or an
+// error when it names no level. Unlike the config parsers it never falls
+// back, so a typo on an admin endpoint is reported instead of applied.
+func ParseLevel(flag string) (int, error) {
+	f := strings.ToUpper(strings.TrimSpace(flag))
+	if f == LevelFlagWarn {
+		f = LevelFlagWarning
+	}
+	for i, name := range LevelFlags {
+		if f == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("log4go: unknown level %q", flag)
+}
+
+// levelOverrideRequest is the PUT/POST body of LevelOverrideHandler. The same
+// keys are accepted as query parameters.
+type levelOverrideRequest struct {
+	Pattern string `json:"pattern"`
+	Level   string `json:"level"`
+	TTL     string `json:"ttl"` // Go duration, e.g. "15m"; empty: no expiry
+}
+
+// LevelOverrideHandler returns an admin http.Handler over l's level overrides
+// (nil -> the default logger). Mount it on an internal-only mux:
+//
+//	GET    -> 200, JSON array of LevelOverride
+//	PUT    -> set: {"pattern":"github.com/acme/svc/payment","level":"DEBUG","ttl":"15m"}
+//	          (POST is accepted too; pattern/level/ttl may be query parameters)
+//	DELETE -> ?pattern=... removes one (404 if absent); no pattern clears all
+//
+// Errors are 400/404/405 with a {"error": "..."} body. The handler does no
+// authentication of its own — wrap it.
+func LevelOverrideHandler(l *Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lg := l
+		if lg == nil {
+			lg = defaultLogger()
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			writeAdminJSON(w, http.StatusOK, lg.LevelOverrides())
+		case http.MethodPut, http.MethodPost:
+			q := r.URL.Query()
+			req := levelOverrideRequest{Pattern: q.Get("pattern"), Level: q.Get("level"), TTL: q.Get("ttl")}
+			if r.ContentLength != 0 {
+				if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+					writeAdminError(w, http.StatusBadRequest, fmt.Errorf("log4go: decode level override: %w", err))
+					return
+				}
+			}
+			level, err := ParseLevel(req.Level)
+			if err != nil {
+				writeAdminError(w, http.StatusBadRequest, err)
+				return
+			}
+			var ttl time.Duration
+			if req.TTL != "" {
+				if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl < 0 {
+					writeAdminError(w, http.StatusBadRequest, fmt.Errorf("log4go: invalid ttl %q", req.TTL))
+					return
+				}
+			}
+			if err := lg.SetLevelOverride(req.Pattern, level, ttl); err != nil {
+				writeAdminError(w, http.StatusBadRequest, err)
+				return
+			}
+			writeAdminJSON(w, http.StatusOK, lg.LevelOverrides())
+		case http.MethodDelete:
+			pattern := r.URL.Query().Get("pattern")
+			if pattern == "" {
+				lg.ClearLevelOverrides()
+			} else if !lg.RemoveLevelOverride(pattern) {
+				writeAdminError(w, http.StatusNotFound, fmt.Errorf("log4go: no level override %q", pattern))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE")
+			writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("log4go: method %s not allowed", r.Method))
+		}
+	})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
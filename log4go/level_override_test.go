@@ -0,0 +1,296 @@
+package log4go
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPkg = "github.com/v8fg/kit4go/log4go"
+
+func Test_LevelOverride_Match(t *testing.T) {
+	const (
+		file = "/home/ci/src/svc/internal/payment/charge.go"
+		fn   = "github.com/acme/svc/internal/payment.(*Client).Charge"
+	)
+	for _, tc := range []struct {
+		pattern string
+		want    bool
+	}{
+		{"github.com/acme/svc/internal/payment", true},           // package
+		{"github.com/acme/svc/internal", true},                   // parent package
+		{"github.com/acme/svc/internal/pay", false},              // not a package boundary
+		{"github.com/acme/svc/internal/payment.(*Client)", true}, // function prefix
+		{"github.com/acme/svc/internal/payment.(*Client).Refund", false},
+		{"charge.go", true},    // base-name glob
+		{"*_test.go", false},   // base-name glob
+		{"payment/*.go", true}, // trailing path elements
+		{"internal/*/charge.go", true},
+		{"billing/*.go", false},
+	} {
+		e, err := newLevelOverrideEntry(tc.pattern, DEBUG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.match(file, fn); got != tc.want {
+			t.Errorf("%q match=%v want %v", tc.pattern, got, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "  ", "[a-.go"} {
+		if _, err := newLevelOverrideEntry(bad, DEBUG); err == nil {
+			t.Errorf("pattern %q accepted", bad)
+		}
+	}
+	if _, err := newLevelOverrideEntry("x", TRACE+1); err == nil {
+		t.Error("invalid level accepted")
+	}
+}
+
+//go:noinline
+func debugFromHelper(l *Logger, msg string) { l.Debug(msg) }
+
+//go:noinline
+func infoFromHelper(l *Logger, msg string) { l.Info(msg) }
+
+func Test_LevelOverride_PerCallSite(t *testing.T) {
+	l, cw := newRingTestLogger(t) // INFO
+	child := l.With("k", "v")     // created before the override: still covered
+
+	if err := l.SetLevelOverride(testPkg+".debugFromHelper", DEBUG, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetLevelOverride(testPkg+".infoFromHelper", WARNING, 0); err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("direct debug")          // no matching override: INFO applies
+	debugFromHelper(child, "helper") // DEBUG override
+	infoFromHelper(l, "quieted")     // WARNING override
+	l.Info("direct info")
+	recs := waitCaptured(t, cw, 2)
+	if recs[0].msg != "helper" || recs[1].msg != "direct info" {
+		t.Fatalf("got %q,%q", recs[0].msg, recs[1].msg)
+	}
+	if !strings.HasPrefix(recs[0].file, "level_override_test.go:") {
+		t.Errorf("caller=%q", recs[0].file)
+	}
+
+	// the longest matching pattern wins over the package-wide one.
+	if err := l.SetLevelOverride(testPkg, TRACE, 0); err != nil {
+		t.Fatal(err)
+	}
+	infoFromHelper(l, "still quiet")
+	l.Trace("pkg trace")
+	if recs := waitCaptured(t, cw, 3); recs[2].msg != "pkg trace" {
+		t.Errorf("got %q", recs[2].msg)
+	}
+	if got := l.LevelOverrides(); len(got) != 3 || got[0].Pattern != testPkg || got[0].Level != LevelFlagTrace {
+		t.Errorf("LevelOverrides=%+v", got)
+	}
+
+	l.ClearLevelOverrides()
+	debugFromHelper(l, "off")
+	l.Info("after clear")
+	if recs := waitCaptured(t, cw, 4); recs[3].msg != "after clear" {
+		t.Errorf("got %q", recs[3].msg)
+	}
+}
+
+// An override carries through to writers at the logger level (the INFO file
+// writer a SetupLog config builds) but not to one kept stricter than it.
+func Test_LevelOverride_ReachesWriters(t *testing.T) {
+	l := newLoggerWithRecords(make(chan *Record, 1024))
+	l.SetLevel(INFO)
+	dir := t.TempDir()
+	newWriter := func(name, level string) *FileWriter {
+		fw := NewFileWriterWithOptions(FileWriterOptions{Enable: true, Level: level, Filename: filepath.Join(dir, name+"-%Y%M%D.log"), Rotate: true, Daily: true})
+		if err := fw.Init(); err != nil {
+			t.Fatal(err)
+		}
+		l.Register(fw)
+		return fw
+	}
+	newWriter("main", LevelFlagInfo)
+	newWriter("errors", LevelFlagError)
+	if err := l.SetLevelOverride(testPkg+".debugFromHelper", DEBUG, 0); err != nil {
+		t.Fatal(err)
+	}
+	debugFromHelper(l, "overridden debug")
+	l.Debug("plain debug")
+	l.Error("an error")
+	l.Close()
+
+	read := func(name string) string {
+		files, _ := filepath.Glob(filepath.Join(dir, name+"-*"))
+		if len(files) != 1 {
+			t.Fatalf("%s files=%v", name, files)
+		}
+		b, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if main := read("main"); !strings.Contains(main, "overridden debug") || strings.Contains(main, "plain debug") {
+		t.Errorf("INFO writer:\n%s", main)
+	}
+	if errs := read("errors"); strings.Contains(errs, "debug") || !strings.Contains(errs, "an error") {
+		t.Errorf("ERROR writer:\n%s", errs)
+	}
+}
+
+// Calls at a level no override can change never resolve their call site.
+func Test_LevelOverride_SkipsUnaffectedCalls(t *testing.T) {
+	l, cw := newRingTestLogger(t) // INFO
+	if err := l.SetLevelOverride(testPkg+".debugFromHelper", DEBUG, 0); err != nil {
+		t.Fatal(err)
+	}
+	tbl := l.overrides.v.Load()
+	sites := func() (n int) {
+		tbl.sites.Range(func(any, any) bool { n++; return true })
+		return n
+	}
+	l.Info("info")
+	l.Error("error")
+	l.Trace("trace") // more verbose than any override
+	if n := sites(); n != 0 {
+		t.Errorf("resolved %d call sites for unaffected calls", n)
+	}
+	debugFromHelper(l, "debug")
+	if n := sites(); n != 1 {
+		t.Errorf("resolved %d call sites, want 1", n)
+	}
+	waitCaptured(t, cw, 3)
+
+	for _, tc := range []struct {
+		level, base int
+		want        bool
+	}{
+		{INFO, INFO, false}, {DEBUG, INFO, true}, {TRACE, INFO, false}, {ERROR, INFO, false},
+		{DEBUG, DEBUG, false}, {TRACE, WARNING, false},
+	} {
+		if got := tbl.affects(tc.level, tc.base); got != tc.want {
+			t.Errorf("affects(%d, %d)=%v want %v", tc.level, tc.base, got, tc.want)
+		}
+	}
+}
+
+// BenchmarkLevelOverride_Unaffected measures a suppressed TRACE call on a
+// logger whose only override enables DEBUG elsewhere: it should cost what it
+// does with no override at all (no stack walk).
+func BenchmarkLevelOverride_Unaffected(b *testing.B) {
+	l := newLoggerWithRecords(make(chan *Record, 1024))
+	defer l.Close()
+	l.SetLevel(INFO)
+	l.WithCaller(false)
+	_ = l.SetLevelOverride("github.com/acme/other", DEBUG, 0)
+	b.ReportAllocs()
+	for b.Loop() {
+		l.Trace("suppressed")
+	}
+}
+
+func Test_LevelOverride_TTLAndSlog(t *testing.T) {
+	l, cw := newRingTestLogger(t)
+	sl := slog.New(NewSlogHandler(l))
+	if sl.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug enabled without override")
+	}
+	if err := l.SetLevelOverride("level_override_test.go", DEBUG, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if o := l.LevelOverrides(); len(o) != 1 || o[0].Expires.IsZero() {
+		t.Fatalf("overrides=%+v", o)
+	}
+	sl.Debug("slog debug")
+	l.Debug("debug")
+	waitCaptured(t, cw, 2)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(l.LevelOverrides()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(l.LevelOverrides()) != 0 {
+		t.Fatal("override did not expire")
+	}
+	l.Debug("expired")
+	sl.Debug("expired")
+	l.Info("info")
+	if recs := waitCaptured(t, cw, 3); recs[2].msg != "info" {
+		t.Errorf("got %q", recs[2].msg)
+	}
+
+	// re-setting a pattern replaces it, so the old timer must not remove it.
+	_ = l.SetLevelOverride("x", DEBUG, 20*time.Millisecond)
+	_ = l.SetLevelOverride("x", DEBUG, 0)
+	time.Sleep(40 * time.Millisecond)
+	if len(l.LevelOverrides()) != 1 {
+		t.Error("replaced override expired by the stale timer")
+	}
+}
+
+func Test_LevelOverrideHandler(t *testing.T) {
+	l := newLoggerWithRecords(make(chan *Record, 8))
+	defer l.Close()
+	srv := httptest.NewServer(LevelOverrideHandler(l))
+	defer srv.Close()
+
+	do := func(method, query, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+query, strings.NewReader(body))
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	if code, body := do(http.MethodPut, "", `{"pattern":"github.com/acme/payment","level":"debug","ttl":"1h"}`); code != http.StatusOK {
+		t.Fatalf("PUT %d %s", code, body)
+	}
+	if code, _ := do(http.MethodPost, "?pattern=*_repo.go&level=WARN", ""); code != http.StatusOK {
+		t.Fatalf("POST query %d", code)
+	}
+	code, body := do(http.MethodGet, "", "")
+	var got []LevelOverride
+	if err := json.Unmarshal([]byte(body), &got); err != nil || code != http.StatusOK || len(got) != 2 {
+		t.Fatalf("GET %d %s err=%v", code, body, err)
+	}
+	if got[0].Pattern != "*_repo.go" || got[0].Level != LevelFlagWarning || !got[0].Expires.IsZero() ||
+		got[1].Level != LevelFlagDebug || got[1].Expires.IsZero() {
+		t.Errorf("GET=%+v", got)
+	}
+
+	for _, tc := range []struct{ query, body string }{
+		{"", `{"pattern":"x","level":"LOUD"}`},
+		{"", `{"pattern":"x","level":"INFO","ttl":"soon"}`},
+		{"", `{"pattern":"","level":"INFO"}`},
+		{"", `{`},
+	} {
+		if code, body := do(http.MethodPut, tc.query, tc.body); code != http.StatusBadRequest || !strings.Contains(body, `"error"`) {
+			t.Errorf("PUT %s: %d %s", tc.body, code, body)
+		}
+	}
+
+	if code, _ := do(http.MethodDelete, "?pattern=*_repo.go", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE %d", code)
+	}
+	if code, _ := do(http.MethodDelete, "?pattern=*_repo.go", ""); code != http.StatusNotFound {
+		t.Errorf("DELETE missing %d", code)
+	}
+	if code, _ := do(http.MethodDelete, "", ""); code != http.StatusNoContent || len(l.LevelOverrides()) != 0 {
+		t.Errorf("DELETE all %d left=%v", code, l.LevelOverrides())
+	}
+	if code, _ := do(http.MethodPatch, "", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("PATCH %d", code)
+	}
+}
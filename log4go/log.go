@@ -109,7 +109,9 @@ func newDefaultLoggerInstance() *Logger {
 // The format is decided once per record in deliverRecordToWriter and cached on
 // r.formattedBytes, so every registered writer (Console/File/Net/IO) outputs the
 // pre-serialized bytes without re-serializing. KafkaWriter already emits its
-// own JSON payload and is unaffected.
+// own JSON payload and is unaffected. Console, File, Net and Kafka writers
+// can pick their own format and time layout (their Format / Layout options);
+// each distinct one is still encoded once per record and shared.
 type LogFormat int32
 
 const (
@@ -196,6 +198,25 @@ type Record struct {
 	// bytes verbatim to avoid re-serializing per writer. nil under FormatText.
 	// Reset to nil by the bootstrap goroutine before returning to the pool.
 	formattedBytes []byte
+	// format is the Logger format formattedBytes was rendered in.
+	format LogFormat
+	// encs caches the renderings writers with their own Format / Layout asked
+	// for (see Record.encoded), so each distinct one is encoded once per
+	// record. Reset by the bootstrap goroutine with formattedBytes.
+	encs []recordEncoding
+	// admit lets the record through writers whose own level would drop it: a
+	// writer at admitFrom or more verbose takes it up to level admit (see
+	// filteredBy). Zero on the common path; set for records a level override
+	// enabled below the logger level, and for dump-ring replays (admitted by
+	// the writers at the logger level or more verbose).
+	admit, admitFrom int
+}
+
+// filteredBy reports whether a writer at level drops r. Every writer's level
+// check goes through it so records the logger admitted past the configured
+// writer levels (level overrides, dump-ring replays) are not discarded there.
+func (r *Record) filteredBy(level int) bool {
+	return r.level > level && (level < r.admitFrom || r.level > r.admit)
 }
 
 // globalSeq is the process-global monotonic record sequence counter.
@@ -220,6 +241,9 @@ type callerKey struct {
 	pc       uintptr
 	fullPath bool
 	withFunc bool
+	// qualified keeps the package path in the func name (full file path too);
+	// used only by level-override matching, never rendered into records.
+	qualified bool
 }
 
 // callerFileLine resolves pc to "base.go:line" (or full path, or with func name)
@@ -240,6 +264,28 @@ func callerFileLine(pc uintptr, fullPath, withFunc bool) string {
 	return s
 }
 
+// callerQualified resolves pc (from the frame walk in deliverRecordToWriter)
+// to its full file path and fully qualified func name (e.g.
+// "github.com/acme/svc/payment.(*Client).Charge"), memoized in callerCache
+// like callerFileLine.
+func callerQualified(pc uintptr) (file, fn string) {
+	key := callerKey{pc: pc, fullPath: true, withFunc: true, qualified: true}
+	callerCacheMu.RLock()
+	s, ok := callerCache[key]
+	callerCacheMu.RUnlock()
+	if !ok {
+		if f := runtime.FuncForPC(pc); f != nil {
+			file, _ := f.FileLine(pc)
+			s = file + "\x00" + f.Name()
+		}
+		callerCacheMu.Lock()
+		callerCache[key] = s
+		callerCacheMu.Unlock()
+	}
+	file, fn, _ = strings.Cut(s, "\x00")
+	return file, fn
+}
+
 // computeCallerFileLine builds the caller string for a PC (runs once per site).
 func computeCallerFileLine(pc uintptr, fullPath, withFunc bool) string {
 	fn := runtime.FuncForPC(pc)
@@ -341,17 +387,24 @@ type RecordJSON struct {
 // are rendered by direct typed append (no map, no reflection), so this path is
 // allocation-free for scalar fields. This is called once per record in
 // deliverRecordToWriter (FormatJSON only) and cached on r.formattedBytes, so each
-// record pays exactly one serialization regardless of how many writers run.
+// record pays exactly one serialization regardless of how many writers run. A
+// record without a time (a slog.Record with a zero Time) omits unix_nano and
+// time.
 func (r *Record) JSON() []byte {
 	buf := make([]byte, 0, 192+len(r.fields)*16)
 	buf = append(buf, '{')
-	buf = append(buf, `"unix_nano":`...)
-	buf = strconv.AppendInt(buf, r.unixNano, 10)
-	buf = append(buf, `,"seq":`...)
+	if r.unixNano != 0 {
+		buf = append(buf, `"unix_nano":`...)
+		buf = strconv.AppendInt(buf, r.unixNano, 10)
+		buf = append(buf, ',')
+	}
+	buf = append(buf, `"seq":`...)
 	buf = strconv.AppendUint(buf, r.seq, 10)
-	buf = append(buf, `,"time":"`...)
-	buf = appendISOTimeUTC(buf, r.unixNano)
-	buf = append(buf, '"')
+	if r.unixNano != 0 {
+		buf = append(buf, `,"time":"`...)
+		buf = appendISOTimeUTC(buf, r.unixNano)
+		buf = append(buf, '"')
+	}
 	buf = append(buf, `,"level":`...)
 	buf = appendJSONQuoted(buf, LevelFlags[r.level])
 	buf = append(buf, `,"msg":`...)
@@ -418,6 +471,13 @@ type Rotater interface {
 	SetPathPattern(string) error
 }
 
+// ForceRotater is implemented by writers that can start a new segment on
+// demand, whatever their rotation triggers say (FileWriter). The admin
+// handler's rotate uses it, falling back to Rotate.
+type ForceRotater interface {
+	ForceRotate() error
+}
+
 // Stopper is implemented by writers that own a background daemon and/or a
 // connection that must be released on shutdown (File/Kafka/Net). Logger.Close
 // stops every registered Stopper so a single log4go.Close() reclaims all writer
@@ -450,6 +510,14 @@ type LevelSetter interface {
 	SetLevel(level int)
 }
 
+// writerLevel is a writer's level threshold. The bootstrap goroutine reads it
+// on every Write while LevelSetter may change it from any goroutine, so it is
+// accessed atomically; the integer kind keeps `level: INFO` literals working.
+type writerLevel int32
+
+func (v *writerLevel) load() int       { return int(atomic.LoadInt32((*int32)(v))) }
+func (v *writerLevel) store(level int) { atomic.StoreInt32((*int32)(v), int32(level)) }
+
 // RuntimeConfig is the hot, lock-free configuration surface of a Logger. Every
 // method takes effect on the next record via atomic loads on the delivery path —
 // no mutex, no stall — so any of them is safe to call at any time, including
@@ -519,6 +587,15 @@ type Logger struct {
 	// an atomic.Pointer for lock-free hot-path read.
 	baseFields *baseFieldsHolder
 
+	// redact holds the Redactor and its counters. Shared by pointer across the
+	// clone tree (like baseFields) so SetRedactor covers every child.
+	redact *redactorHolder
+
+	// overrides is the per-package / per-file level-override table. Shared by
+	// pointer across the clone tree (like redact) so an override reaches every
+	// child's records.
+	overrides *levelOverrideHolder
+
 	// fields carries structured key/value pairs attached via With/WithField/
 	// WithFields. A child Logger always gets its OWN copy (see clone), so a
 	// parent's slice is never mutated and is safe to read concurrently from the
@@ -550,6 +627,12 @@ type Logger struct {
 	// Default false ⇒ keep. Read once per record on the hot path.
 	sampleDrop atomic.Bool
 
+	// ring buffers suppressed records for replay when a trigger-level record
+	// is emitted (SetRingDump / WithRingDump). nil (the default) disables it and
+	// suppressed records return before any work, as before. Children share the
+	// parent's ring; WithRingDump gives a child its own.
+	ring atomic.Pointer[ringDump]
+
 	// ctxExtractor derives structured fields from a context.Context supplied via
 	// WithContext. nil (the zero atomic pointer) disables extraction. Held in an
 	// atomic.Pointer so it can be toggled live (SetContextExtractor) — e.g. turn
@@ -596,6 +679,8 @@ func newLoggerWithRecords(records chan *Record) *Logger {
 	l.occurredByLevel = new([TRACE + 1]uint64)
 	l.priorityLevel.Store(-1)          // default: no bypass; sampling governs all
 	l.baseFields = &baseFieldsHolder{} // shared with every clone (see clone)
+	l.redact = &redactorHolder{}       // shared with every clone (see clone)
+	l.overrides = &levelOverrideHolder{}
 	l.level.Store(int32(DEBUG))
 	lp := DefaultLayout
 	l.layout.Store(&lp)
@@ -754,6 +839,16 @@ func (l *Logger) ResumeWriter(name string) bool {
 	return false
 }
 
+// SetWriterLevel changes the level of the named writer (LevelSetter). Returns
+// true if a writer was changed.
+func (l *Logger) SetWriterLevel(name string, level int) bool {
+	if s, ok := l.findWriter(name).(LevelSetter); ok && s != nil {
+		s.SetLevel(level)
+		return true
+	}
+	return false
+}
+
 // WriterPaused reports whether the named writer is paused.
 func (l *Logger) WriterPaused(name string) bool {
 	if p, ok := l.findWriter(name).(Pauser); ok && p != nil {
@@ -767,6 +862,10 @@ type LoggerMetrics struct {
 	Occurred [TRACE + 1]uint64 // every log call (pre-filter/pre-sample)
 	Records  [TRACE + 1]uint64 // Written = delivered (post-sample, from bootstrap)
 	Dropped  [TRACE + 1]uint64 // Occurred − Records (may briefly include in-flight)
+	// Redacted counts records the Redactor changed; RedactedValues counts the
+	// individual values (fields / message matches) masked, hashed or dropped.
+	Redacted       uint64
+	RedactedValues uint64
 }
 
 // Metrics returns per-level counters of this logger for monitoring. Written
@@ -785,6 +884,10 @@ func (l *Logger) Metrics() LoggerMetrics {
 			m.Dropped[i] = m.Occurred[i] - m.Records[i]
 		}
 	}
+	if l.redact != nil {
+		m.Redacted = l.redact.records.Load()
+		m.RedactedValues = l.redact.values.Load()
+	}
 	return m
 }
 
@@ -947,7 +1050,7 @@ func (l *Logger) SetLevel(lvl int) {
 // in deliverRecordToWriter and decides whether r.formattedBytes is pre-serialized.
 // All registered writers honor the format via the FormattedWriter fast path
 // (they emit r.formattedBytes when non-nil, else r.String()), so no per-writer change
-// is needed.
+// is needed. Writers configured with their own Format keep it.
 func (l *Logger) SetFormat(f LogFormat) {
 	l.format.Store(int32(f))
 }
@@ -994,6 +1097,8 @@ func (l *Logger) clone() *Logger {
 		recordsByLevel:  l.recordsByLevel,  // shared pointer so children's emits count on the root
 		occurredByLevel: l.occurredByLevel, // shared: Occurred counters propagate to children
 		baseFields:      l.baseFields,      // shared pointer: SetBaseField on the root is live-visible to every child
+		redact:          l.redact,          // shared pointer: SetRedactor covers every child
+		overrides:       l.overrides,       // shared pointer: level overrides cover every child
 	}
 	// copy current atomic knob values into the child
 	c.level.Store(l.level.Load())
@@ -1020,6 +1125,11 @@ func (l *Logger) clone() *Logger {
 		c.samplingStrategy.Store(ss)
 	}
 	c.sampleDrop.Store(l.sampleDrop.Load())
+	// the dump ring is shared, so a child's debug trail and its error (or the
+	// parent's) land in the same buffer.
+	if rd := l.ring.Load(); rd != nil {
+		c.ring.Store(rd)
+	}
 	c.priorityLevel.Store(l.priorityLevel.Load())
 	// share the writers snapshot (copy-on-write on Register applies to parent;
 	// child reads its own snapshot which is fine since Register on a child is
@@ -1301,6 +1411,12 @@ func (l *Logger) attachContextFields(ctx context.Context) {
 	if ctx == nil {
 		return
 	}
+	l.fields = l.appendContextFields(l.fields, ctx)
+}
+
+// appendContextFields returns fs followed by the fields extracted from ctx,
+// copying fs when there are any (fs may be shared).
+func (l *Logger) appendContextFields(fs []field, ctx context.Context) []field {
 	var m map[string]any
 	if x := l.ctxExtractor.Load(); x != nil {
 		// per-logger override: run ONLY this extractor (not the global stack),
@@ -1310,14 +1426,14 @@ func (l *Logger) attachContextFields(ctx context.Context) {
 		m = runContextExtractors(ctx)
 	}
 	if len(m) == 0 {
-		return
+		return fs
 	}
-	nf := make([]field, 0, len(l.fields)+len(m))
-	nf = append(nf, l.fields...)
+	nf := make([]field, 0, len(fs)+len(m))
+	nf = append(nf, fs...)
 	for k, v := range m {
 		nf = append(nf, fieldOf(k, v))
 	}
-	l.fields = nf
+	return nf
 }
 
 // defaultContextTraceKeys are the context.Value keys probed by the built-in
@@ -1411,22 +1527,21 @@ func (l *Logger) deliverRecordToWriter(level int, f string, args ...any) {
 	var msg string
 	var fileStr string
 
-	if level > int(l.level.Load()) {
-		return
-	}
-	// Per-request sampling verdict (cached at WithContext). A dropped request's
-	// non-priority records never reach the writers. Records at or above
-	// priorityLevel (e.g. ERROR) ALWAYS bypass sampling — the industry-standard
-	// "error protection" pattern (errors kept for alerting even on sampled-out
-	// requests). Default priorityLevel=-1 (no bypass).
-	if l.sampleDrop.Load() && level > int(l.priorityLevel.Load()) {
-		return
-	}
-	// Sampling runs before Metrics increment: a record dropped by the sampler
-	// is never written and must not inflate the per-level counters (otherwise
-	// monitoring would report a write rate the writers never see). nil sampler
-	// is a no-op on the common path.
-	if s := l.sampler.Load(); s != nil && !s.allow(level) {
+	// suppressed marks a record the level filter or sampling would discard. It
+	// is only built (and buffered) when a dump ring wants it; otherwise the
+	// call returns here exactly as before.
+	//
+	// With a level override set, the call site decides the effective level, so
+	// its pc is resolved up front (and reused for the caller below).
+	var pc uintptr
+	base := int(l.level.Load())
+	minLevel := base
+	if l.overridesAffect(level, base) {
+		pc = userCallerPC()
+		minLevel = l.levelFor(pc)
+	}
+	suppressed, ring, ok := l.gate(level, minLevel, l.sampleDrop.Load())
+	if !ok {
 		return
 	}
 	// Written (recordsByLevel) is now incremented in the bootstrap goroutine
@@ -1455,14 +1570,11 @@ func (l *Logger) deliverRecordToWriter(level int, f string, args ...any) {
 	// frames is invariant to all such variation. callerIsInternal + callerFileLine
 	// are both memoized per call site, so steady-state cost is a few map lookups.
 	if l.hasCaller.Load() {
-		var pcs [8]uintptr
-		n := runtime.Callers(2, pcs[:]) // depth 1 = this func; scan from depth 2
-		for i := range n {
-			if callerIsInternal(pcs[i]) {
-				continue
-			}
-			fileStr = callerFileLine(pcs[i], l.fullPath.Load(), l.withFuncName.Load())
-			break
+		if pc == 0 {
+			pc = userCallerPC()
+		}
+		if pc != 0 {
+			fileStr = callerFileLine(pc, l.fullPath.Load(), l.withFuncName.Load())
 		}
 	}
 
@@ -1493,6 +1605,7 @@ func (l *Logger) deliverRecordToWriter(level int, f string, args ...any) {
 	r.level = level
 	r.unixNano = now.UnixNano()
 	r.seq = atomic.AddUint64(&globalSeq, 1)
+	admitOverride(r, minLevel, base)
 	// Merge base fields (global static, e.g. hostname/server_ip) + logger fields
 	// (from With/WithField/WithFields) + context fields. Priority: context >
 	// logger > base (more specific wins). We append base fields first if present.
@@ -1509,16 +1622,61 @@ func (l *Logger) deliverRecordToWriter(level int, f string, args ...any) {
 		r.fields = l.fields
 	}
 
+	l.dispatch(r, suppressed, ring)
+}
+
+// gate applies the level filter and sampling to a record at level, given the
+// call site's effective minimum level and the request's sampling verdict
+// (sampleDrop). suppressed marks a record they would discard that the dump
+// ring still wants (ring is returned for dispatch); ok=false discards it.
+// Shared by the native calls and SlogHandler.
+func (l *Logger) gate(level, minLevel int, sampleDrop bool) (suppressed bool, ring *ringDump, ok bool) {
+	if level > minLevel {
+		suppressed = true
+	} else if sampleDrop && level > int(l.priorityLevel.Load()) {
+		// Per-request sampling verdict (cached at WithContext). A dropped request's
+		// non-priority records never reach the writers. Records at or above
+		// priorityLevel (e.g. ERROR) ALWAYS bypass sampling — the industry-standard
+		// "error protection" pattern (errors kept for alerting even on sampled-out
+		// requests). Default priorityLevel=-1 (no bypass).
+		suppressed = true
+	} else if s := l.sampler.Load(); s != nil && !s.allow(level) {
+		// Sampling runs before Metrics increment: a record dropped by the sampler
+		// is never written and must not inflate the per-level counters (otherwise
+		// monitoring would report a write rate the writers never see). nil sampler
+		// is a no-op on the common path.
+		suppressed = true
+	}
+	ring = l.ring.Load()
+	if suppressed && (ring == nil || !ring.captures(level)) {
+		return false, nil, false
+	}
+	return suppressed, ring, true
+}
+
+// dispatch finishes a record that passed gate: redaction, pre-serialization
+// and the hand-off to the writers.
+func (l *Logger) dispatch(r *Record, suppressed bool, ring *ringDump) {
+	// A suppressed record is parked in the dump ring (redacted and serialized
+	// only if a trigger later replays it); a trigger record first flushes the
+	// ring so the buffered lead-up reaches the writers ahead of it.
+	if suppressed {
+		ring.push(r)
+		return
+	}
+	if ring != nil && r.level <= ring.trigger {
+		l.dumpRing(ring, r.unixNano)
+	}
+
+	// Redact once, before pre-serialization, so no writer or format ever sees
+	// the raw value.
+	l.redactRecord(r)
+
 	// Pre-serialize once for FormatJSON / FormatLogfmt so every registered writer
 	// emits the same bytes without re-serializing. For FormatText r.formattedBytes
 	// stays nil and writers fall back to r.String(). Typed fields render directly
 	// (no map), so base fields (merged into r.fields above) are included.
-	switch LogFormat(l.format.Load()) {
-	case FormatJSON:
-		r.formattedBytes = r.JSON()
-	case FormatLogfmt:
-		r.formattedBytes = r.Logfmt()
-	}
+	l.preSerialize(r)
 
 	l.enqueue(r)
 }
@@ -1555,6 +1713,8 @@ func bootstrapLogWriter(logger *Logger) {
 		}
 		r.fields = nil
 		r.formattedBytes = nil
+		r.resetEncodings()
+		r.admit, r.admitFrom = 0, 0
 		recordPool.Put(r)
 	}
 	// drainAndExit reaps any records buffered at retirement so a Reload/shutdown
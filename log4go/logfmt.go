@@ -44,8 +44,16 @@ func appendLogfmtValue(buf []byte, s string) []byte {
 }
 
 // appendFieldLogfmt appends " key=value" for a typed field. Scalars render
-// directly (no reflection); kindAny falls back to the active codec.
+// directly (no reflection); kindAny falls back to the active codec. A group
+// appends one pair per member, keyed "group.member".
 func appendFieldLogfmt(buf []byte, f field) []byte {
+	if f.kind == kindGroup {
+		for _, c := range f.any.(fieldGroup) {
+			c.key = f.key + "." + c.key
+			buf = appendFieldLogfmt(buf, c)
+		}
+		return buf
+	}
 	buf = append(buf, ' ')
 	buf = appendLogfmtValue(buf, f.key)
 	buf = append(buf, '=')
@@ -98,12 +106,15 @@ func appendFieldLogfmt(buf []byte, f field) []byte {
 //
 // the format Loki/Promtail/docker consume natively. Strings that need quoting
 // (spaces, '=', '"', control chars) are quoted and escaped. Typed scalars never
-// reach the JSON codec.
+// reach the JSON codec. A record without a time omits time=.
 func (r *Record) Logfmt() []byte {
 	buf := make([]byte, 0, 128+len(r.fields)*16)
-	buf = append(buf, "time="...)
-	buf = appendISOTimeUTC(buf, r.unixNano)
-	buf = append(buf, " level="...)
+	if r.unixNano != 0 {
+		buf = append(buf, "time="...)
+		buf = appendISOTimeUTC(buf, r.unixNano)
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, "level="...)
 	buf = appendLogfmtValue(buf, LevelFlags[r.level])
 	buf = append(buf, " msg="...)
 	buf = appendLogfmtValue(buf, r.msg)
@@ -44,6 +44,12 @@ type NetWriterOptions struct {
 	// disconnect (<=0 -> 1s). The daemon dials lazily on the first record and
 	// re-dials after a write/close error.
 	ReconnectBackoff time.Duration `json:"reconnect_backoff" mapstructure:"reconnect_backoff"`
+	// Format is this writer's serialization: "text", "json" or "logfmt".
+	// Empty (default) follows the Logger's format (LogConfig.Format).
+	Format string `json:"format" mapstructure:"format"`
+	// Layout is the time layout of this writer's text lines. Empty keeps the
+	// Logger's layout; JSON and logfmt always carry ISO time.
+	Layout string `json:"layout" mapstructure:"layout"`
 }
 
 // NetWriter ships records to a remote TCP/UDP endpoint. It is async by design:
@@ -58,9 +64,10 @@ type NetWriterOptions struct {
 // Vector), or a low-volume aggregation service. For high-volume shipping prefer
 // FileWriter + Kafka — net throughput is bounded by network RTT and the remote.
 type NetWriter struct {
-	level   int
+	level   writerLevel
 	paused  atomic.Bool
 	options NetWriterOptions
+	format  writerFormat
 
 	policy   OverflowPolicy
 	spiller  Spiller[*Record]
@@ -76,6 +83,16 @@ type NetWriter struct {
 	connMu sync.Mutex
 	conn   net.Conn
 
+	// transport replaces the built-in net.Conn delivery for writers layered on
+	// NetWriter's queue / overflow / spill machinery (OTLPWriter). nil ⇒ dial
+	// Network/Address and write serialize(r). Set before Init; only the daemon
+	// (and Stop, after the daemon exits) touches it.
+	transport netTransport
+	// encode replaces serialize's default payload (formattedBytes / String) for
+	// writers that speak a wire format of their own over the same conn
+	// (SyslogWriter). Set before Init; read only by the daemon.
+	encode func(r *Record) []byte
+
 	run  atomic.Bool
 	quit chan struct{}
 	stop chan struct{}
@@ -91,6 +108,18 @@ type NetWriter struct {
 	closing atomic.Bool
 }
 
+// netTransport is the delivery step of a NetWriter daemon. send ships one
+// dequeued record (a batching transport may only buffer it); flush runs on the
+// daemon tick and after the shutdown drain so buffered records are bounded in
+// latency and never lost on Stop; close releases resources once the daemon has
+// exited. The transport owns the sent/errored/dropped accounting of the records
+// it handles.
+type netTransport interface {
+	send(r *Record) error
+	flush() error
+	close() error
+}
+
 // NewNetWriter builds a NetWriter from options. It does NOT dial yet — the
 // connection is opened lazily by the daemon on the first record (so a
 // misconfigured remote does not block Init/Register).
@@ -105,7 +134,8 @@ func NewNetWriter(options NetWriterOptions) *NetWriter {
 	}
 	w := &NetWriter{
 		options:          options,
-		level:            defaultLevel,
+		format:           newWriterFormat(options.Format, options.Layout),
+		level:            writerLevel(defaultLevel),
 		policy:           ParseOverflowPolicy(options.OverflowPolicy),
 		quit:             make(chan struct{}),
 		stop:             make(chan struct{}),
@@ -133,6 +163,9 @@ func NewNetWriter(options NetWriterOptions) *NetWriter {
 // lazily inside the daemon, so a down/misconfigured remote does not fail
 // Logger.Register (which would otherwise panic).
 func (n *NetWriter) Init() error {
+	// Mark running BEFORE launching the goroutine so a Stop right after Init
+	// still drains (mirrors KafkaWriter.Start).
+	n.run.Store(true)
 	go n.daemon()
 	return nil
 }
@@ -140,6 +173,13 @@ func (n *NetWriter) Init() error {
 // Name returns WriterNameNet.
 func (n *NetWriter) Name() string { return WriterNameNet }
 
+// SetLevel changes the writer's level at runtime (LevelSetter); the next
+// record observes it.
+func (n *NetWriter) SetLevel(level int) { n.level.store(level) }
+
+// Level returns the writer's current level.
+func (n *NetWriter) Level() int { return n.level.load() }
+
 // Pause drops incoming records without removing the writer or closing the conn.
 func (n *NetWriter) Pause() { n.paused.Store(true) }
 
@@ -164,7 +204,7 @@ func (n *NetWriter) Write(r *Record) error {
 	if n.paused.Load() {
 		return nil
 	}
-	if r.level > n.level {
+	if r.filteredBy(n.level.load()) {
 		return nil
 	}
 	if n.closing.Load() {
@@ -173,7 +213,7 @@ func (n *NetWriter) Write(r *Record) error {
 		n.stats.IncDropped()
 		return nil
 	}
-	rc := *r // private copy for the daemon
+	rc := *n.format.apply(r) // private copy for the daemon
 	switch n.policy {
 	case OverflowBlock:
 		select {
@@ -199,15 +239,20 @@ func (n *NetWriter) Write(r *Record) error {
 
 // serialize returns the bytes to send for a record: the Logger's pre-serialized
 // formattedBytes (FormatJSON) when present, else the text String() form. This makes
-// NetWriter honor the Logger's format without its own format logic.
+// NetWriter honor the Logger's format without its own format logic; Write has
+// already swapped in the writer's own Format / Layout rendering, if any. A
+// configured encode hook takes precedence.
 func (n *NetWriter) serialize(r *Record) []byte {
+	if n.encode != nil {
+		return n.encode(r)
+	}
 	if len(r.formattedBytes) > 0 {
 		return r.formattedBytes
 	}
 	return []byte(r.String())
 }
 
-// dial opens the connection (tcp/udp). Caller must hold connMu.
+// dial opens the connection (tcp/udp/unix/unixgram). Caller must hold connMu.
 func (n *NetWriter) dialLocked() error {
 	network := n.options.Network
 	if network == "" {
@@ -223,8 +268,12 @@ func (n *NetWriter) dialLocked() error {
 }
 
 // writeOne sends a single record, lazily (re)dialing as needed. On any error it
-// closes the conn so the next call re-dials (lazy reconnect).
+// closes the conn so the next call re-dials (lazy reconnect). A configured
+// transport takes over delivery entirely.
 func (n *NetWriter) writeOne(r *Record) error {
+	if n.transport != nil {
+		return n.transport.send(r)
+	}
 	n.connMu.Lock()
 	defer n.connMu.Unlock()
 
@@ -267,7 +316,6 @@ func (n *NetWriter) daemon() {
 			recordDaemonPanic("net", r)
 		}
 	}()
-	n.run.Store(true)
 	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -284,6 +332,7 @@ func (n *NetWriter) daemon() {
 			_ = n.writeOne(r)
 		case <-ticker.C:
 			n.drainSpill()
+			n.flushTransport()
 		case <-n.stop:
 			n.drainAll()
 			n.quit <- struct{}{}
@@ -333,14 +382,21 @@ func (n *NetWriter) drainAll() {
 		}
 	}
 spill:
-	if n.spiller == nil {
-		return
-	}
-	for _, r := range n.spiller.Drain() {
-		if r != nil {
-			_ = n.writeOne(r)
+	if n.spiller != nil {
+		for _, r := range n.spiller.Drain() {
+			if r != nil {
+				_ = n.writeOne(r)
+			}
 		}
 	}
+	n.flushTransport()
+}
+
+// flushTransport flushes a batching transport (no-op for the net.Conn path).
+func (n *NetWriter) flushTransport() {
+	if n.transport != nil {
+		_ = n.transport.flush()
+	}
 }
 
 // Stop shuts down the daemon gracefully.
@@ -373,6 +429,9 @@ func (n *NetWriter) Stop() {
 		n.conn = nil
 	}
 	n.connMu.Unlock()
+	if n.transport != nil {
+		_ = n.transport.close()
+	}
 }
 
 // Flush is a no-op for NetWriter (writes are flushed inline by the daemon on
@@ -0,0 +1,528 @@
+package log4go
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// OTLP content encodings accepted by OTLPWriterOptions.Encoding.
+const (
+	OTLPEncodingProtobuf = "protobuf"
+	OTLPEncodingJSON     = "json"
+)
+
+// OTLPWriterOptions configures an OTLPWriter (OTLP/HTTP logs exporter).
+//
+// The queue knobs (BufferSize / OverflowPolicy / SpillSize / Timeout) have the
+// same meaning as on NetWriterOptions: OTLPWriter runs on NetWriter's async
+// daemon, so a slow or down collector never blocks the logger hot path.
+type OTLPWriterOptions struct {
+	// Enable gates registration (used by config plumbing; the writer ignores it).
+	Enable bool `json:"enable" mapstructure:"enable"`
+	// Level is the text level flag (default DEBUG).
+	Level string `json:"level" mapstructure:"level"`
+	// Endpoint is the full OTLP/HTTP logs URL, e.g.
+	// "http://otel-collector:4318/v1/logs".
+	Endpoint string `json:"endpoint" mapstructure:"endpoint"`
+	// Encoding is "protobuf" (default, application/x-protobuf) or "json"
+	// (application/json, the OTLP/JSON mapping).
+	Encoding string `json:"encoding" mapstructure:"encoding"`
+	// Headers are added to every export request (auth tokens, tenant ids).
+	Headers map[string]string `json:"headers" mapstructure:"headers"`
+	// ServiceName sets the service.name resource attribute (omitted when empty).
+	ServiceName string `json:"service_name" mapstructure:"service_name"`
+	// ResourceAttributes are extra string resource attributes
+	// (deployment.environment, host.name, ...).
+	ResourceAttributes map[string]string `json:"resource_attributes" mapstructure:"resource_attributes"`
+	// ScopeName is the instrumentation scope name (default "log4go").
+	ScopeName string `json:"scope_name" mapstructure:"scope_name"`
+	// BatchSize is the max records per export request (<=0 -> 512). A partial
+	// batch is exported on the daemon tick (~200ms) and on Stop.
+	BatchSize int `json:"batch_size" mapstructure:"batch_size"`
+	// BufferSize is the async channel capacity (<=0 -> 1024).
+	BufferSize int `json:"buffer_size" mapstructure:"buffer_size"`
+	// OverflowPolicy: "drop"(default)|"block"|"spill".
+	OverflowPolicy string `json:"overflow_policy" mapstructure:"overflow_policy"`
+	// SpillSize: ring capacity (records) when policy == "spill".
+	SpillSize int `json:"spill_size" mapstructure:"spill_size"`
+	// Timeout bounds one export request (<=0 -> 3s).
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+	// Client overrides the HTTP client (TLS, proxies). nil -> a client with
+	// Timeout.
+	Client *http.Client `json:"-" mapstructure:"-"`
+}
+
+// OTLPWriter exports records to an OpenTelemetry collector over OTLP/HTTP
+// (protobuf or JSON). It reuses NetWriter's async queue, overflow policy and
+// spill store: Write enqueues, and the daemon batches dequeued records into one
+// ExportLogsServiceRequest per BatchSize (or per tick).
+//
+// Mapping: message → body, level → severity_number/severity_text, structured
+// fields → attributes, caller → code.file.path/code.line.number/
+// code.function.name. A trace_id/span_id field (as produced by the context
+// extractor, any of the default key spellings) carrying a valid hex id is moved
+// into the LogRecord's trace_id/span_id instead of the attributes.
+//
+// A failed export (transport error or non-2xx) drops that batch and counts it in
+// Metrics().Errored/Dropped — the same drop-on-error contract as NetWriter.
+type OTLPWriter struct {
+	q        *NetWriter
+	endpoint string
+	json     bool
+	headers  map[string]string
+	client   *http.Client
+	scope    string
+	resource []field
+
+	batchSize int
+	batch     []*Record // daemon-only
+	batches   uint64
+}
+
+// NewOTLPWriter builds an OTLPWriter. Like NetWriter it does not connect until
+// the first export, so a down collector does not fail Register.
+func NewOTLPWriter(options OTLPWriterOptions) *OTLPWriter {
+	q := NewNetWriter(NetWriterOptions{
+		Level:          options.Level,
+		BufferSize:     options.BufferSize,
+		OverflowPolicy: options.OverflowPolicy,
+		SpillSize:      options.SpillSize,
+		Timeout:        options.Timeout,
+	})
+	w := &OTLPWriter{
+		q:         q,
+		endpoint:  options.Endpoint,
+		json:      strings.EqualFold(strings.TrimSpace(options.Encoding), OTLPEncodingJSON),
+		headers:   options.Headers,
+		client:    options.Client,
+		scope:     options.ScopeName,
+		batchSize: options.BatchSize,
+	}
+	if w.client == nil {
+		w.client = &http.Client{Timeout: q.timeout}
+	}
+	if w.scope == "" {
+		w.scope = "log4go"
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = 512
+	}
+	if options.ServiceName != "" {
+		w.resource = append(w.resource, strField("service.name", options.ServiceName))
+	}
+	keys := make([]string, 0, len(options.ResourceAttributes))
+	for k := range options.ResourceAttributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // deterministic resource encoding
+	for _, k := range keys {
+		w.resource = append(w.resource, strField(k, options.ResourceAttributes[k]))
+	}
+	q.transport = w
+	return w
+}
+
+// Init starts the async daemon.
+func (w *OTLPWriter) Init() error { return w.q.Init() }
+
+// Write enqueues r under the overflow policy (see NetWriter.Write).
+func (w *OTLPWriter) Write(r *Record) error { return w.q.Write(r) }
+
+// Name returns WriterNameOTLP.
+func (w *OTLPWriter) Name() string { return WriterNameOTLP }
+
+// SetLevel changes the writer's level at runtime (LevelSetter).
+func (w *OTLPWriter) SetLevel(level int) { w.q.SetLevel(level) }
+
+// Level returns the writer's current level.
+func (w *OTLPWriter) Level() int { return w.q.Level() }
+
+// Pause drops incoming records without stopping the daemon.
+func (w *OTLPWriter) Pause() { w.q.Pause() }
+
+// Resume restores delivery after Pause.
+func (w *OTLPWriter) Resume() { w.q.Resume() }
+
+// Paused reports whether the writer is currently paused.
+func (w *OTLPWriter) Paused() bool { return w.q.Paused() }
+
+// Flush is a no-op; the daemon exports partial batches on its own tick.
+func (w *OTLPWriter) Flush() error { return nil }
+
+// Stop drains the queue and spill store, exports the final batch and stops the
+// daemon.
+func (w *OTLPWriter) Stop() { w.q.Stop() }
+
+// Metrics returns the queue counters; Sent counts records accepted by the
+// collector (2xx).
+func (w *OTLPWriter) Metrics() NetWriterMetrics { return w.q.Metrics() }
+
+// Batches returns the number of export requests accepted by the collector.
+func (w *OTLPWriter) Batches() uint64 { return atomic.LoadUint64(&w.batches) }
+
+// send buffers one dequeued record and exports once the batch is full.
+func (w *OTLPWriter) send(r *Record) error {
+	w.batch = append(w.batch, r)
+	if len(w.batch) >= w.batchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush exports the buffered batch (no-op when empty).
+func (w *OTLPWriter) flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	batch := w.batch
+	w.batch = w.batch[:0:0] // fresh backing array: records must not be pinned
+	err := w.export(batch)
+	n := uint64(len(batch))
+	if err != nil {
+		atomic.AddUint64(&w.q.errored, 1)
+		atomic.AddUint64(&w.q.dropped, n)
+		return err
+	}
+	atomic.AddUint64(&w.q.sent, n)
+	atomic.AddUint64(&w.batches, 1)
+	return nil
+}
+
+func (w *OTLPWriter) close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// export POSTs one ExportLogsServiceRequest.
+func (w *OTLPWriter) export(batch []*Record) error {
+	var body []byte
+	contentType := "application/x-protobuf"
+	if w.json {
+		body = w.appendRequestJSON(nil, batch)
+		contentType = "application/json"
+	} else {
+		body = w.appendRequestProto(nil, batch)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.q.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // keep-alive reuse
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("log4go: otlp export: http %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// otlpSeverity maps log4go levels onto OTLP SeverityNumber (TRACE=1, DEBUG=5,
+// INFO=9, INFO2=10, WARN=13, ERROR=17, FATAL..FATAL3=21..23).
+var otlpSeverity = [TRACE + 1]int32{
+	EMERGENCY: 23,
+	ALERT:     22,
+	CRITICAL:  21,
+	ERROR:     17,
+	WARNING:   13,
+	NOTICE:    10,
+	INFO:      9,
+	DEBUG:     5,
+	TRACE:     1,
+}
+
+// otlpRecord is a record split into the OTLP LogRecord parts.
+type otlpRecord struct {
+	traceID, spanID []byte
+	attrs           []field
+}
+
+// splitOTLP separates a valid hex trace/span id out of the record fields and
+// appends the caller as code.* attributes.
+func splitOTLP(r *Record) otlpRecord {
+	var o otlpRecord
+	o.attrs = make([]field, 0, len(r.fields)+3)
+	for _, f := range r.fields {
+		if f.kind == kindString {
+			switch f.key {
+			case "trace_id", "traceID", "trace-id":
+				if b, err := hex.DecodeString(f.str); err == nil && len(b) == 16 && o.traceID == nil {
+					o.traceID = b
+					continue
+				}
+			case "span_id", "spanID", "span-id":
+				if b, err := hex.DecodeString(f.str); err == nil && len(b) == 8 && o.spanID == nil {
+					o.spanID = b
+					continue
+				}
+			}
+		}
+		o.attrs = append(o.attrs, f)
+	}
+	if r.file != "" {
+		loc, fn, _ := strings.Cut(r.file, " ")
+		if i := strings.LastIndexByte(loc, ':'); i > 0 {
+			if line, err := strconv.Atoi(loc[i+1:]); err == nil {
+				o.attrs = append(o.attrs, strField("code.file.path", loc[:i]), intField("code.line.number", line))
+			} else {
+				o.attrs = append(o.attrs, strField("code.file.path", loc))
+			}
+		}
+		if fn != "" {
+			o.attrs = append(o.attrs, strField("code.function.name", fn))
+		}
+	}
+	return o
+}
+
+// --- protobuf (opentelemetry/proto/collector/logs/v1) ---
+
+func appendFixed64Field(buf []byte, field int, v uint64) []byte {
+	buf = appendTag(buf, field, 1)
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
+
+// appendMessage appends a length-delimited sub-message built by fn.
+func appendMessage(buf []byte, field int, fn func([]byte) []byte) []byte {
+	return appendBytesField(buf, field, fn(nil))
+}
+
+// appendAnyValueProto appends an AnyValue sub-message for f's value.
+func appendAnyValueProto(buf []byte, field int, f field) []byte {
+	return appendMessage(buf, field, func(b []byte) []byte {
+		switch f.kind {
+		case kindString:
+			return appendString(b, 1, f.str)
+		case kindBool:
+			return appendUint64Field(b, 2, uint64(f.i))
+		case kindInt, kindInt64, kindDuration:
+			return appendInt64Field(b, 3, f.i)
+		case kindUint:
+			if f.i >= 0 {
+				return appendInt64Field(b, 3, f.i)
+			}
+			return appendString(b, 1, strconv.FormatUint(uint64(f.i), 10))
+		case kindFloat64:
+			return appendFixed64Field(b, 4, uint64(f.i))
+		case kindTime:
+			return appendString(b, 1, string(appendISOTimeUTC(nil, f.i)))
+		case kindBytes:
+			if raw, err := base64.StdEncoding.DecodeString(f.str); err == nil {
+				return appendBytesField(b, 7, raw)
+			}
+			return appendString(b, 1, f.str)
+		default: // error / any: the same text the JSON path renders
+			return appendString(b, 1, otlpText(f))
+		}
+	})
+}
+
+// appendKeyValueProto appends a KeyValue sub-message.
+func appendKeyValueProto(buf []byte, field int, f field) []byte {
+	return appendMessage(buf, field, func(b []byte) []byte {
+		b = appendString(b, 1, f.key)
+		return appendAnyValueProto(b, 2, f)
+	})
+}
+
+func (w *OTLPWriter) appendRequestProto(buf []byte, batch []*Record) []byte {
+	observed := uint64(time.Now().UnixNano())
+	return appendMessage(buf, 1, func(rl []byte) []byte { // ResourceLogs
+		if len(w.resource) > 0 {
+			rl = appendMessage(rl, 1, func(res []byte) []byte { // Resource
+				for _, f := range w.resource {
+					res = appendKeyValueProto(res, 1, f)
+				}
+				return res
+			})
+		}
+		return appendMessage(rl, 2, func(sl []byte) []byte { // ScopeLogs
+			sl = appendMessage(sl, 1, func(sc []byte) []byte { return appendString(sc, 1, w.scope) })
+			for _, r := range batch {
+				sl = appendMessage(sl, 2, func(lr []byte) []byte { return appendLogRecordProto(lr, r, observed) })
+			}
+			return sl
+		})
+	})
+}
+
+func appendLogRecordProto(buf []byte, r *Record, observed uint64) []byte {
+	o := splitOTLP(r)
+	buf = appendFixed64Field(buf, 1, uint64(r.unixNano))
+	buf = appendUint64Field(buf, 2, uint64(otlpSeverity[r.level]))
+	buf = appendString(buf, 3, LevelFlags[r.level])
+	buf = appendMessage(buf, 5, func(b []byte) []byte { return appendString(b, 1, r.msg) })
+	for _, f := range o.attrs {
+		buf = appendKeyValueProto(buf, 6, f)
+	}
+	if o.traceID != nil {
+		buf = appendBytesField(buf, 9, o.traceID)
+	}
+	if o.spanID != nil {
+		buf = appendBytesField(buf, 10, o.spanID)
+	}
+	return appendFixed64Field(buf, 11, observed)
+}
+
+// --- OTLP/JSON (proto3 JSON mapping: lowerCamel keys, 64-bit ints as strings,
+// trace/span ids as hex) ---
+
+func appendAnyValueJSON(buf []byte, f field) []byte {
+	switch f.kind {
+	case kindString:
+		buf = append(buf, `{"stringValue":`...)
+		buf = appendJSONQuoted(buf, f.str)
+	case kindBool:
+		buf = append(buf, `{"boolValue":`...)
+		buf = strconv.AppendBool(buf, f.i == 1)
+	case kindInt, kindInt64, kindDuration:
+		buf = append(buf, `{"intValue":"`...)
+		buf = strconv.AppendInt(buf, f.i, 10)
+		buf = append(buf, '"')
+	case kindUint:
+		if f.i >= 0 {
+			buf = append(buf, `{"intValue":"`...)
+			buf = strconv.AppendInt(buf, f.i, 10)
+			buf = append(buf, '"')
+		} else {
+			buf = append(buf, `{"stringValue":"`...)
+			buf = strconv.AppendUint(buf, uint64(f.i), 10)
+			buf = append(buf, '"')
+		}
+	case kindFloat64:
+		v := math.Float64frombits(uint64(f.i))
+		switch {
+		case math.IsNaN(v):
+			buf = append(buf, `{"doubleValue":"NaN"`...)
+		case math.IsInf(v, 1):
+			buf = append(buf, `{"doubleValue":"Infinity"`...)
+		case math.IsInf(v, -1):
+			buf = append(buf, `{"doubleValue":"-Infinity"`...)
+		default:
+			buf = append(buf, `{"doubleValue":`...)
+			buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
+		}
+	case kindTime:
+		buf = append(buf, `{"stringValue":"`...)
+		buf = appendISOTimeUTC(buf, f.i)
+		buf = append(buf, '"')
+	case kindBytes:
+		buf = append(buf, `{"bytesValue":`...)
+		buf = appendJSONQuoted(buf, f.str) // already base64, as proto3 JSON wants
+	default:
+		buf = append(buf, `{"stringValue":`...)
+		buf = appendJSONQuoted(buf, otlpText(f))
+	}
+	return append(buf, '}')
+}
+
+func appendAttributesJSON(buf []byte, attrs []field) []byte {
+	buf = append(buf, '[')
+	for i, f := range attrs {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"key":`...)
+		buf = appendJSONQuoted(buf, f.key)
+		buf = append(buf, `,"value":`...)
+		buf = appendAnyValueJSON(buf, f)
+		buf = append(buf, '}')
+	}
+	return append(buf, ']')
+}
+
+func (w *OTLPWriter) appendRequestJSON(buf []byte, batch []*Record) []byte {
+	observed := time.Now().UnixNano()
+	buf = append(buf, `{"resourceLogs":[{`...)
+	if len(w.resource) > 0 {
+		buf = append(buf, `"resource":{"attributes":`...)
+		buf = appendAttributesJSON(buf, w.resource)
+		buf = append(buf, `},`...)
+	}
+	buf = append(buf, `"scopeLogs":[{"scope":{"name":`...)
+	buf = appendJSONQuoted(buf, w.scope)
+	buf = append(buf, `},"logRecords":[`...)
+	for i, r := range batch {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		o := splitOTLP(r)
+		buf = append(buf, `{"timeUnixNano":"`...)
+		buf = strconv.AppendInt(buf, r.unixNano, 10)
+		buf = append(buf, `","observedTimeUnixNano":"`...)
+		buf = strconv.AppendInt(buf, observed, 10)
+		buf = append(buf, `","severityNumber":`...)
+		buf = strconv.AppendInt(buf, int64(otlpSeverity[r.level]), 10)
+		buf = append(buf, `,"severityText":`...)
+		buf = appendJSONQuoted(buf, LevelFlags[r.level])
+		buf = append(buf, `,"body":{"stringValue":`...)
+		buf = appendJSONQuoted(buf, r.msg)
+		buf = append(buf, '}')
+		if len(o.attrs) > 0 {
+			buf = append(buf, `,"attributes":`...)
+			buf = appendAttributesJSON(buf, o.attrs)
+		}
+		if o.traceID != nil {
+			buf = append(buf, `,"traceId":"`...)
+			buf = hex.AppendEncode(buf, o.traceID)
+			buf = append(buf, '"')
+		}
+		if o.spanID != nil {
+			buf = append(buf, `,"spanId":"`...)
+			buf = hex.AppendEncode(buf, o.spanID)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, '}')
+	}
+	return append(buf, `]}]}]}`...)
+}
+
+// otlpText renders an error/any field as a string attribute value: the error
+// text, or the JSON codec output for arbitrary values (unquoted when it is a
+// JSON string).
+func otlpText(f field) string {
+	if f.kind == kindError {
+		if e, ok := f.any.(error); ok {
+			if s, ok := safeErrorString(e); ok {
+				return s
+			}
+		}
+		return ""
+	}
+	if s, ok := f.any.(string); ok {
+		return s
+	}
+	if b, ok := safeJSONMarshal(f.any); ok {
+		return string(b)
+	}
+	return ""
+}
+
+// compile-time: OTLPWriter implements the standard writer control surface.
+var (
+	_ Writer       = (*OTLPWriter)(nil)
+	_ Stopper      = (*OTLPWriter)(nil)
+	_ Pauser       = (*OTLPWriter)(nil)
+	_ Named        = (*OTLPWriter)(nil)
+	_ netTransport = (*OTLPWriter)(nil)
+)
@@ -0,0 +1,347 @@
+package log4go
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	otlpTestTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	otlpTestSpanID  = "00f067aa0ba902b7"
+)
+
+// otlpCollector is a fake OTLP/HTTP receiver that keeps every request body.
+type otlpCollector struct {
+	mu      sync.Mutex
+	bodies  [][]byte
+	ctypes  []string
+	headers []http.Header
+	status  int
+}
+
+func newOTLPCollector(t *testing.T) (*otlpCollector, *httptest.Server) {
+	t.Helper()
+	c := &otlpCollector{status: http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		c.bodies = append(c.bodies, b)
+		c.ctypes = append(c.ctypes, r.Header.Get("Content-Type"))
+		c.headers = append(c.headers, r.Header.Clone())
+		status := c.status
+		c.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func (c *otlpCollector) requests() ([][]byte, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.bodies...), append([]string(nil), c.ctypes...)
+}
+
+func otlpTestRecord(msg string) *Record {
+	return &Record{
+		level: WARNING, msg: msg, file: "bidder.go:42 main.serve",
+		unixNano: 1782563343536622000,
+		fields: []field{
+			strField("trace_id", otlpTestTraceID),
+			strField("span_id", otlpTestSpanID),
+			intField("count", 3),
+			boolField("served", true),
+		},
+	}
+}
+
+// protoMessage decodes one protobuf message into field number -> raw values
+// (varint/fixed64 as uint64, length-delimited as []byte).
+func protoMessage(t *testing.T, b []byte) map[protowire.Number][]any {
+	t.Helper()
+	out := map[protowire.Number][]any{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v any
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatalf("bad value: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		out[num] = append(out[num], v)
+	}
+	return out
+}
+
+// protoLogRecords walks ExportLogsServiceRequest -> ResourceLogs -> ScopeLogs
+// and returns the resource attributes, scope name and LogRecords.
+func protoLogRecords(t *testing.T, body []byte) (resource [][]byte, scope string, records []map[protowire.Number][]any) {
+	t.Helper()
+	req := protoMessage(t, body)
+	rl := protoMessage(t, req[1][0].([]byte))
+	if res, ok := rl[1]; ok {
+		for _, kv := range protoMessage(t, res[0].([]byte))[1] {
+			resource = append(resource, kv.([]byte))
+		}
+	}
+	sl := protoMessage(t, rl[2][0].([]byte))
+	scope = string(protoMessage(t, sl[1][0].([]byte))[1][0].([]byte))
+	for _, lr := range sl[2] {
+		records = append(records, protoMessage(t, lr.([]byte)))
+	}
+	return resource, scope, records
+}
+
+// protoAttrs decodes repeated KeyValue bytes into key -> AnyValue fields.
+func protoAttrs(t *testing.T, kvs []any) map[string]map[protowire.Number][]any {
+	t.Helper()
+	out := map[string]map[protowire.Number][]any{}
+	for _, kv := range kvs {
+		m := protoMessage(t, kv.([]byte))
+		out[string(m[1][0].([]byte))] = protoMessage(t, m[2][0].([]byte))
+	}
+	return out
+}
+
+func Test_OTLPWriter_Protobuf(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	w := NewOTLPWriter(OTLPWriterOptions{
+		Endpoint:           srv.URL + "/v1/logs",
+		Headers:            map[string]string{"Authorization": "Bearer tkn"},
+		ServiceName:        "bidder",
+		ResourceAttributes: map[string]string{"deployment.environment": "prod"},
+		BufferSize:         16,
+	})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Write(otlpTestRecord("bid served"))
+	w.Stop()
+
+	bodies, ctypes := c.requests()
+	if len(bodies) != 1 || ctypes[0] != "application/x-protobuf" {
+		t.Fatalf("requests=%d ctypes=%v want 1 protobuf", len(bodies), ctypes)
+	}
+	if got := c.headers[0].Get("Authorization"); got != "Bearer tkn" {
+		t.Errorf("Authorization=%q", got)
+	}
+	resource, scope, records := protoLogRecords(t, bodies[0])
+	if len(resource) != 2 || scope != "log4go" || len(records) != 1 {
+		t.Fatalf("resource=%d scope=%q records=%d", len(resource), scope, len(records))
+	}
+	lr := records[0]
+	if lr[1][0].(uint64) != 1782563343536622000 {
+		t.Errorf("time_unix_nano=%v", lr[1])
+	}
+	if lr[2][0].(uint64) != 13 || string(lr[3][0].([]byte)) != "WARNING" {
+		t.Errorf("severity=%v/%q want 13/WARNING", lr[2], lr[3][0])
+	}
+	if body := protoMessage(t, lr[5][0].([]byte)); string(body[1][0].([]byte)) != "bid served" {
+		t.Errorf("body=%q", body[1][0])
+	}
+	if got := hex.EncodeToString(lr[9][0].([]byte)); got != otlpTestTraceID {
+		t.Errorf("trace_id=%s", got)
+	}
+	if got := hex.EncodeToString(lr[10][0].([]byte)); got != otlpTestSpanID {
+		t.Errorf("span_id=%s", got)
+	}
+	attrs := protoAttrs(t, lr[6])
+	if _, ok := attrs["trace_id"]; ok {
+		t.Error("trace_id duplicated into attributes")
+	}
+	if v := attrs["count"][3][0].(uint64); v != 3 {
+		t.Errorf("count=%d", v)
+	}
+	if v := attrs["served"][2][0].(uint64); v != 1 {
+		t.Errorf("served=%d", v)
+	}
+	if v := string(attrs["code.file.path"][1][0].([]byte)); v != "bidder.go" {
+		t.Errorf("code.file.path=%q", v)
+	}
+	if v := attrs["code.line.number"][3][0].(uint64); v != 42 {
+		t.Errorf("code.line.number=%d", v)
+	}
+	if v := string(attrs["code.function.name"][1][0].([]byte)); v != "main.serve" {
+		t.Errorf("code.function.name=%q", v)
+	}
+	if m := w.Metrics(); m.Sent != 1 || m.Errored != 0 || w.Batches() != 1 {
+		t.Errorf("metrics=%+v batches=%d", m, w.Batches())
+	}
+}
+
+func Test_OTLPWriter_JSON(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	w := NewOTLPWriter(OTLPWriterOptions{Endpoint: srv.URL, Encoding: "json", ServiceName: "bidder", BufferSize: 16})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r := otlpTestRecord(`quoted "msg"`)
+	r.fields = append(r.fields, strField("trace_id", "not-hex"))
+	_ = w.Write(r)
+	w.Stop()
+
+	bodies, ctypes := c.requests()
+	if len(bodies) != 1 || ctypes[0] != "application/json" {
+		t.Fatalf("requests=%d ctypes=%v", len(bodies), ctypes)
+	}
+	var req struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string         `json:"key"`
+					Value map[string]any `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope      struct{ Name string } `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano   string `json:"timeUnixNano"`
+					SeverityNumber int    `json:"severityNumber"`
+					SeverityText   string `json:"severityText"`
+					Body           struct {
+						StringValue string `json:"stringValue"`
+					} `json:"body"`
+					Attributes []struct {
+						Key   string         `json:"key"`
+						Value map[string]any `json:"value"`
+					} `json:"attributes"`
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatalf("invalid OTLP/JSON: %v\n%s", err, bodies[0])
+	}
+	rl := req.ResourceLogs[0]
+	if rl.Resource.Attributes[0].Key != "service.name" || rl.Resource.Attributes[0].Value["stringValue"] != "bidder" {
+		t.Errorf("resource=%+v", rl.Resource)
+	}
+	lr := rl.ScopeLogs[0].LogRecords[0]
+	if lr.TimeUnixNano != "1782563343536622000" || lr.SeverityNumber != 13 || lr.SeverityText != "WARNING" {
+		t.Errorf("record header=%+v", lr)
+	}
+	if lr.Body.StringValue != `quoted "msg"` || lr.TraceID != otlpTestTraceID || lr.SpanID != otlpTestSpanID {
+		t.Errorf("body=%q trace=%q span=%q", lr.Body.StringValue, lr.TraceID, lr.SpanID)
+	}
+	attrs := map[string]map[string]any{}
+	for _, a := range lr.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["count"]["intValue"] != "3" || attrs["served"]["boolValue"] != true {
+		t.Errorf("attrs=%v", attrs)
+	}
+	// an id that does not parse stays an ordinary attribute.
+	if attrs["trace_id"]["stringValue"] != "not-hex" {
+		t.Errorf("invalid trace_id not kept as attribute: %v", attrs["trace_id"])
+	}
+}
+
+func Test_OTLPWriter_Batching(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	w := NewOTLPWriter(OTLPWriterOptions{Endpoint: srv.URL, BatchSize: 10, BufferSize: 64, OverflowPolicy: "block"})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for range 25 {
+		_ = w.Write(otlpTestRecord("m"))
+	}
+	w.Stop() // the trailing partial batch is exported on Stop
+
+	bodies, _ := c.requests()
+	total := 0
+	for _, b := range bodies {
+		_, _, records := protoLogRecords(t, b)
+		if len(records) > 10 {
+			t.Errorf("batch of %d exceeds BatchSize", len(records))
+		}
+		total += len(records)
+	}
+	if total != 25 || len(bodies) < 3 {
+		t.Fatalf("exported %d records in %d requests, want 25 in >=3", total, len(bodies))
+	}
+	if m := w.Metrics(); m.Sent != 25 {
+		t.Errorf("Sent=%d want 25", m.Sent)
+	}
+}
+
+func Test_OTLPWriter_TickFlush(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	w := NewOTLPWriter(OTLPWriterOptions{Endpoint: srv.URL, BufferSize: 16})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	_ = w.Write(otlpTestRecord("lonely"))
+	deadline := time.Now().Add(3 * time.Second)
+	for w.Metrics().Sent == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if bodies, _ := c.requests(); len(bodies) != 1 {
+		t.Fatalf("partial batch not exported on tick: %d requests", len(bodies))
+	}
+}
+
+func Test_OTLPWriter_CollectorError(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	c.status = http.StatusServiceUnavailable
+	w := NewOTLPWriter(OTLPWriterOptions{Endpoint: srv.URL, BufferSize: 16})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		_ = w.Write(otlpTestRecord("m"))
+	}
+	w.Stop()
+	if m := w.Metrics(); m.Sent != 0 || m.Errored != 1 || m.Dropped != 3 {
+		t.Errorf("metrics=%+v want sent=0 errored=1 dropped=3", m)
+	}
+}
+
+func Test_OTLPWriter_PauseAndName(t *testing.T) {
+	c, srv := newOTLPCollector(t)
+	w := NewOTLPWriter(OTLPWriterOptions{Endpoint: srv.URL, BufferSize: 16})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Name() != WriterNameOTLP {
+		t.Errorf("Name=%q", w.Name())
+	}
+	w.Pause()
+	_ = w.Write(otlpTestRecord("paused"))
+	if !w.Paused() {
+		t.Error("Paused=false after Pause")
+	}
+	w.Resume()
+	_ = w.Write(otlpTestRecord("live"))
+	w.Stop()
+	bodies, _ := c.requests()
+	if len(bodies) != 1 {
+		t.Fatalf("requests=%d", len(bodies))
+	}
+	if _, _, records := protoLogRecords(t, bodies[0]); len(records) != 1 {
+		t.Errorf("records=%d want only the post-Resume one", len(records))
+	}
+}
@@ -0,0 +1,144 @@
+package reader
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/v8fg/kit4go/log4go"
+)
+
+// Filter selects entries; every set criterion must hold. The zero Filter
+// matches every entry.
+type Filter struct {
+	// Level keeps entries at this level or more severe ("WARNING" keeps
+	// WARNING, ERROR, ...). Empty keeps every level.
+	Level string
+	// Since and Until bound the entry time to [Since, Until); a zero bound is
+	// open. An entry without a time never matches a bounded range.
+	Since, Until time.Time
+	// Fields must all match.
+	Fields []FieldMatch
+	// Caller is a path.Match glob on the caller. Like a level override
+	// pattern, without '/' it is matched against the base name, with '/'
+	// against as many trailing path elements. It matches "file.go" or
+	// "file.go:42", so a pattern without ':' ignores the line, or the
+	// function name when one was logged ("payment.(*Client).*").
+	Caller string
+}
+
+// FieldMatch matches one field. Key may be a dotted path into a group
+// ("req.user.id"). With Regexp nil, Value must equal the field's value as
+// text (numbers and bools as written, null, groups and arrays as JSON);
+// otherwise Regexp must match that text.
+type FieldMatch struct {
+	Key    string
+	Value  string
+	Regexp *regexp.Regexp
+}
+
+// ParseFieldMatch parses the log4q -field syntax: "key=value" for equality,
+// "key~regexp" for a regular expression, split at the first '=' or '~'.
+func ParseFieldMatch(s string) (FieldMatch, error) {
+	i := strings.IndexAny(s, "=~")
+	if i <= 0 {
+		return FieldMatch{}, fmt.Errorf("reader: field match %q: want key=value or key~regexp", s)
+	}
+	m := FieldMatch{Key: s[:i], Value: s[i+1:]}
+	if s[i] == '~' {
+		re, err := regexp.Compile(m.Value)
+		if err != nil {
+			return FieldMatch{}, fmt.Errorf("reader: field match %q: %w", s, err)
+		}
+		m.Regexp = re
+	}
+	return m, nil
+}
+
+// matcher is a validated Filter.
+type matcher struct {
+	Filter
+	level int // most verbose level kept
+	depth int // path elements the caller glob spans
+}
+
+func (f Filter) compile() (*matcher, error) {
+	m := &matcher{Filter: f, level: log4go.TRACE}
+	if f.Level != "" {
+		lvl, err := log4go.ParseLevel(f.Level)
+		if err != nil {
+			return nil, err
+		}
+		m.level = lvl
+	}
+	if f.Caller != "" {
+		if _, err := path.Match(f.Caller, ""); err != nil {
+			return nil, fmt.Errorf("reader: caller %q: %w", f.Caller, err)
+		}
+		m.depth = strings.Count(f.Caller, "/") + 1
+	}
+	for _, fm := range f.Fields {
+		if fm.Key == "" {
+			return nil, fmt.Errorf("reader: field match without a key")
+		}
+	}
+	return m, nil
+}
+
+func (m *matcher) match(e *Entry) bool {
+	if e.Level > m.level {
+		return false
+	}
+	if !m.Since.IsZero() || !m.Until.IsZero() {
+		if e.Time.IsZero() || (!m.Since.IsZero() && e.Time.Before(m.Since)) ||
+			(!m.Until.IsZero() && !e.Time.Before(m.Until)) {
+			return false
+		}
+	}
+	if m.Caller != "" && !m.matchCaller(e.File) {
+		return false
+	}
+	for _, fm := range m.Fields {
+		v, ok := e.Field(fm.Key)
+		if !ok {
+			return false
+		}
+		s := valueText(v)
+		if fm.Regexp != nil && !fm.Regexp.MatchString(s) || fm.Regexp == nil && s != fm.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *matcher) matchCaller(caller string) bool {
+	loc, fn, _ := strings.Cut(caller, " ")
+	if loc == "" {
+		return false
+	}
+	file := loc
+	if i := strings.LastIndexByte(loc, ':'); i >= 0 {
+		file = loc[:i]
+	}
+	for _, s := range []string{file, loc} {
+		if ok, _ := path.Match(m.Caller, tail(s, m.depth)); ok {
+			return true
+		}
+	}
+	ok, _ := path.Match(m.Caller, fn)
+	return fn != "" && ok
+}
+
+// tail returns the last depth '/'-separated elements of p.
+func tail(p string, depth int) string {
+	for i, n := len(p)-1, 0; i >= 0; i-- {
+		if p[i] == '/' {
+			if n++; n == depth {
+				return p[i+1:]
+			}
+		}
+	}
+	return p
+}
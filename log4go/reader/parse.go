@@ -0,0 +1,227 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/v8fg/kit4go/log4go"
+)
+
+// parseLine parses one JSON (Record.JSON) or logfmt (Record.Logfmt) line into
+// e. It reports false for anything else, including a line without a known
+// level.
+func parseLine(line []byte, e *Entry) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return false
+	}
+	if line[0] == '{' {
+		return parseJSON(line, e)
+	}
+	return parseLogfmt(line, e)
+}
+
+func parseJSON(line []byte, e *Entry) bool {
+	var level, iso string
+	err := eachMember(line, func(key string, raw json.RawMessage) error {
+		var err error
+		switch key {
+		case "unix_nano":
+			var n int64
+			if err = json.Unmarshal(raw, &n); err == nil {
+				e.Time = time.Unix(0, n)
+			}
+		case "seq":
+			err = json.Unmarshal(raw, &e.Seq)
+		case "time":
+			err = json.Unmarshal(raw, &iso)
+		case "level":
+			err = json.Unmarshal(raw, &level)
+		case "msg":
+			err = json.Unmarshal(raw, &e.Msg)
+		case "file":
+			err = json.Unmarshal(raw, &e.File)
+		case "fields":
+			e.Fields, err = jsonFields(raw)
+		}
+		return err
+	})
+	if err != nil {
+		return false
+	}
+	if e.Time.IsZero() && iso != "" {
+		e.Time, _ = time.Parse(time.RFC3339Nano, iso)
+	}
+	e.Level, err = log4go.ParseLevel(level)
+	return err == nil
+}
+
+// eachMember calls fn with each member of the JSON object obj, in order.
+func eachMember(obj []byte, fn func(key string, raw json.RawMessage) error) error {
+	dec := json.NewDecoder(bytes.NewReader(obj))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return errors.New("not a JSON object")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := t.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		if err := fn(key, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonFields converts a "fields" object into typed fields, nested objects
+// into groups.
+func jsonFields(obj []byte) ([]log4go.Field, error) {
+	var fs []log4go.Field
+	err := eachMember(obj, func(key string, raw json.RawMessage) error {
+		f, err := jsonField(key, raw)
+		fs = append(fs, f)
+		return err
+	})
+	return fs, err
+}
+
+func jsonField(key string, raw json.RawMessage) (log4go.Field, error) {
+	switch raw[0] {
+	case '{':
+		fs, err := jsonFields(raw)
+		return log4go.Group(key, fs...), err
+	case '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return log4go.String(key, s), err
+	case 't', 'f':
+		return log4go.Bool(key, raw[0] == 't'), nil
+	case 'n':
+		return log4go.Any(key, nil), nil
+	case '[':
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var a []any
+		err := dec.Decode(&a)
+		return log4go.Any(key, a), err
+	}
+	if n, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return log4go.Int64(key, n), nil
+	}
+	f, err := strconv.ParseFloat(string(raw), 64)
+	return log4go.Float64(key, f), err
+}
+
+func parseLogfmt(line []byte, e *Entry) bool {
+	haveLevel := false
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		key, n, _, ok := logfmtToken(line[i:], '=')
+		i += n
+		if !ok || i >= len(line) || line[i] != '=' {
+			return false
+		}
+		val, n, quoted, ok := logfmtToken(line[i+1:], ' ')
+		i += 1 + n
+		if !ok {
+			return false
+		}
+		switch key {
+		case "time":
+			e.Time, _ = time.Parse(time.RFC3339Nano, val)
+		case "level":
+			lvl, err := log4go.ParseLevel(val)
+			if err != nil {
+				return false
+			}
+			e.Level, haveLevel = lvl, true
+		case "msg":
+			e.Msg = val
+		case "file":
+			e.File = val
+		default:
+			e.Fields = append(e.Fields, logfmtField(key, val, quoted))
+		}
+	}
+	return haveLevel
+}
+
+// logfmtToken reads a bare token (up to stop or a space) or a quoted one,
+// undoing the escapes log4go writes. n is the bytes consumed.
+func logfmtToken(b []byte, stop byte) (s string, n int, quoted, ok bool) {
+	if len(b) == 0 || b[0] != '"' {
+		for n < len(b) && b[n] != stop && b[n] != ' ' {
+			n++
+		}
+		return string(b[:n]), n, false, true
+	}
+	buf := make([]byte, 0, len(b))
+	for i := 1; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '"':
+			return string(buf), i + 1, true, true
+		case c != '\\':
+			buf = append(buf, c)
+			continue
+		}
+		if i++; i >= len(b) {
+			return "", 0, true, false
+		}
+		switch b[i] {
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'x':
+			if i+2 >= len(b) {
+				return "", 0, true, false
+			}
+			v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8)
+			if err != nil {
+				return "", 0, true, false
+			}
+			buf = append(buf, byte(v))
+			i += 2
+		default:
+			buf = append(buf, b[i])
+		}
+	}
+	return "", 0, true, false
+}
+
+// logfmtField types a bare logfmt value the way log4go writes scalars: a
+// bool, an integer or a float when it reads back to the same text, else a
+// string. Quoted values are always strings.
+func logfmtField(key, val string, quoted bool) log4go.Field {
+	if quoted {
+		return log4go.String(key, val)
+	}
+	switch val {
+	case "true":
+		return log4go.Bool(key, true)
+	case "false":
+		return log4go.Bool(key, false)
+	}
+	if n, err := strconv.ParseInt(val, 10, 64); err == nil && strconv.FormatInt(n, 10) == val {
+		return log4go.Int64(key, n)
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == val {
+		return log4go.Float64(key, f)
+	}
+	return log4go.String(key, val)
+}
@@ -0,0 +1,363 @@
+// Package reader reads log4go output back: it stream-parses the JSON and
+// logfmt lines a Logger writes (FormatJSON / FormatLogfmt), across rotated
+// segments and gzip / zstd compressed ones, and filters them by level, time
+// range, field and caller. It backs the log4q command and is meant for tests
+// too:
+//
+//	entries, err := reader.ReadAll(reader.Filter{Level: "WARNING"}, "logs/app.log")
+//	for _, e := range entries {
+//		if v, _ := e.Field("req.id"); v == "r-9" { ... }
+//	}
+//
+// Lines in neither format (text output, foreign lines) are skipped and
+// counted (Reader.Skipped).
+package reader
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/v8fg/kit4go/log4go"
+)
+
+// Entry is one parsed log line.
+type Entry struct {
+	Time   time.Time // zero when the line has none
+	Seq    uint64
+	Level  int    // log4go.ERROR, log4go.INFO, ...
+	File   string // caller, "file.go:42" (with the function name when logged)
+	Msg    string
+	Fields []log4go.Field // in line order; JSON objects as log4go.Group
+	Source string         // file the line was read from ("-" for stdin)
+	Line   int            // 1-based line number in Source
+}
+
+// Record returns the entry as a log4go record, e.g. for a
+// ConsoleWriter.Format or Record.JSON rendering.
+func (e *Entry) Record() *log4go.Record {
+	return log4go.NewRecord(e.Time, e.Seq, e.Level, e.File, e.Msg, e.Fields...)
+}
+
+// Field returns the value of the first field named key, and whether there is
+// one. A dotted key ("req.user.id") reaches into groups; logfmt lines keep
+// such keys flat, which matches too. Values are string, int64, float64, bool,
+// nil, []any (JSON arrays) or map[string]any (groups).
+func (e *Entry) Field(key string) (any, bool) {
+	for _, f := range e.Fields {
+		if f.Key() == key {
+			return f.Value(), true
+		}
+		if f.Kind() == log4go.FieldKindGroup && strings.HasPrefix(key, f.Key()+".") {
+			if m, ok := f.Value().(map[string]any); ok {
+				if v, ok := lookupMap(m, key[len(f.Key())+1:]); ok {
+					return v, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+func lookupMap(m map[string]any, key string) (any, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for i := range len(key) {
+		if key[i] != '.' {
+			continue
+		}
+		if sub, ok := m[key[:i]].(map[string]any); ok {
+			if v, ok := lookupMap(sub, key[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Reader streams the entries of one or more log files, in order, keeping
+// those its Filter matches. It holds one file open at a time.
+type Reader struct {
+	m       *matcher
+	paths   []string  // files still to read
+	stdin   io.Reader // read for a "-" path
+	name    string    // current source
+	cur     *bufio.Reader
+	closers []io.Closer // current file and decompressor
+	line    int
+	skipped int
+}
+
+// Open returns a Reader over paths, expanded by Files. "-" reads standard
+// input. It fails on an invalid Filter or when paths name no file.
+func Open(f Filter, paths ...string) (*Reader, error) {
+	m, err := f.compile()
+	if err != nil {
+		return nil, err
+	}
+	files, err := Files(paths...)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("reader: no log files")
+	}
+	return &Reader{m: m, paths: files, stdin: os.Stdin}, nil
+}
+
+// NewReader returns a Reader over a single stream (source "-"). Compressed
+// input is detected and decompressed as in Open.
+func NewReader(src io.Reader, f Filter) (*Reader, error) {
+	m, err := f.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{m: m, paths: []string{"-"}, stdin: src}, nil
+}
+
+// ReadAll reads every matching entry of paths (see Open).
+func ReadAll(f Filter, paths ...string) ([]*Entry, error) {
+	r, err := Open(f, paths...)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var out []*Entry
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, e)
+	}
+}
+
+// Next returns the next matching entry, or io.EOF after the last file. A
+// file that cannot be opened or read ends the stream with its error.
+func (r *Reader) Next() (*Entry, error) {
+	for {
+		if r.cur == nil {
+			if len(r.paths) == 0 {
+				return nil, io.EOF
+			}
+			if err := r.open(r.paths[0]); err != nil {
+				return nil, err
+			}
+			r.paths = r.paths[1:]
+		}
+		line, err := r.cur.ReadBytes('\n')
+		if len(line) > 0 {
+			r.line++
+			e := &Entry{Source: r.name, Line: r.line}
+			if !parseLine(line, e) {
+				r.skipped++
+			} else if r.m.match(e) {
+				return e, nil
+			}
+		}
+		if err != nil {
+			r.closeCurrent()
+			if !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("reader: %s: %w", r.name, err)
+			}
+		}
+	}
+}
+
+// Skipped returns the number of lines read so far that were neither log4go
+// JSON nor logfmt.
+func (r *Reader) Skipped() int { return r.skipped }
+
+// Close releases the open file, if any.
+func (r *Reader) Close() error {
+	r.paths = nil
+	return r.closeCurrent()
+}
+
+func (r *Reader) open(path string) error {
+	var src io.Reader = r.stdin
+	r.name, r.line = path, 0
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("reader: %w", err)
+		}
+		r.closers = append(r.closers, f)
+		src = f
+	}
+	br := bufio.NewReaderSize(src, 64<<10)
+	magic, _ := br.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			r.closeCurrent()
+			return fmt.Errorf("reader: %s: %w", path, err)
+		}
+		r.closers = append(r.closers, zr)
+		br = bufio.NewReaderSize(zr, 64<<10)
+	case len(magic) == 4 && magic[0] == 0x28 && magic[1] == 0xb5 && magic[2] == 0x2f && magic[3] == 0xfd:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			r.closeCurrent()
+			return fmt.Errorf("reader: %s: %w", path, err)
+		}
+		r.closers = append(r.closers, zr.IOReadCloser())
+		br = bufio.NewReaderSize(zr, 64<<10)
+	}
+	r.cur = br
+	return nil
+}
+
+func (r *Reader) closeCurrent() error {
+	var err error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		err = errors.Join(err, r.closers[i].Close())
+	}
+	r.closers, r.cur = r.closers[:0], nil
+	return err
+}
+
+// backupStamp matches the "<stamp>[-n]" a FileWriter size rotation inserts
+// between a segment's stem and its suffix.
+var backupStamp = regexp.MustCompile(`^\d{8}T\d{6}\.\d{6}(-\d+)?$`)
+
+// Files expands paths into the files to read, in order, without duplicates:
+//   - a glob ("logs/app-*.log") expands to its matches, sorted by name;
+//   - a directory expands to the regular files in it, sorted by name;
+//   - a file is preceded by its size-rotated segments
+//     ("app.20261018T150405.000000.log", compressed or not), oldest first;
+//   - "-" (standard input) is kept as is.
+//
+// Time-rotated segments carry the date in their name, so a glob or the
+// directory reads them in order.
+func Files(paths ...string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	for _, p := range paths {
+		if p == "-" {
+			add(p)
+			continue
+		}
+		if strings.ContainsAny(p, "*?[") {
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				return nil, fmt.Errorf("reader: %q: %w", p, err)
+			}
+			for _, m := range matches {
+				if fi, err := os.Stat(m); err == nil && fi.Mode().IsRegular() {
+					add(m)
+				}
+			}
+			continue
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("reader: %w", err)
+		}
+		if fi.IsDir() {
+			des, err := os.ReadDir(p)
+			if err != nil {
+				return nil, fmt.Errorf("reader: %w", err)
+			}
+			for _, de := range des {
+				if de.Type().IsRegular() {
+					add(filepath.Join(p, de.Name()))
+				}
+			}
+			continue
+		}
+		for _, b := range rotatedSegments(p) {
+			add(b)
+		}
+		add(p)
+	}
+	return out, nil
+}
+
+// rotatedSegments lists the size-rotated segments of the active file p,
+// oldest first: by stamp, then a "-n" collision after its base name and
+// after any lower n.
+func rotatedSegments(p string) []string {
+	ext := filepath.Ext(p)
+	stem := strings.TrimSuffix(p, ext)
+	matches, _ := filepath.Glob(escapeGlob(stem) + ".*")
+	type segment struct {
+		path, stamp string
+		n           int
+	}
+	var segs []segment
+	for _, m := range matches {
+		rest := strings.TrimSuffix(strings.TrimSuffix(m[len(stem)+1:], ".gz"), ".zst")
+		if stamp, ok := strings.CutSuffix(rest, ext); ok && backupStamp.MatchString(stamp) {
+			stamp, suffix, _ := strings.Cut(stamp, "-")
+			n, _ := strconv.Atoi(suffix) // "" (no collision) → 0
+			segs = append(segs, segment{m, stamp, n})
+		}
+	}
+	slices.SortFunc(segs, func(a, b segment) int {
+		return cmp.Or(strings.Compare(a.stamp, b.stamp), cmp.Compare(a.n, b.n))
+	})
+	out := make([]string, len(segs))
+	for i, s := range segs {
+		out[i] = s.path
+	}
+	return out
+}
+
+// escapeGlob quotes the glob metacharacters of a literal path.
+func escapeGlob(p string) string {
+	var b strings.Builder
+	for _, c := range p {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// valueText renders a field value as the text Filter compares: strings as
+// is, numbers and bools as written, nil as "null", the rest as JSON.
+func valueText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
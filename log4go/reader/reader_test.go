@@ -0,0 +1,323 @@
+package reader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/v8fg/kit4go/log4go"
+)
+
+var t0 = time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+// sample is the record logged at minute i of t0.
+func sample(i, level int, file, msg string, fields ...log4go.Field) *log4go.Record {
+	return log4go.NewRecord(t0.Add(time.Duration(i)*time.Minute), uint64(i), level, file, msg, fields...)
+}
+
+func writeFile(t *testing.T, path string, gz bool, lines ...[]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := io.Writer(&buf)
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	for _, l := range lines {
+		_, _ = w.Write(l)
+	}
+	if zw != nil {
+		_ = zw.Close()
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rotatedDir writes app.log with a gzip and a plain size-rotated segment
+// before it, in JSON and logfmt, plus an unrelated file.
+func rotatedDir(t *testing.T) string {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.20261018T090000.000000.log.gz"), true,
+		sample(0, log4go.INFO, "api.go:10", "start", log4go.String("svc", "api")).JSON(),
+		sample(1, log4go.DEBUG, "db.go:7", "query", log4go.Int("rows", 3)).JSON())
+	writeFile(t, filepath.Join(dir, "app.20261018T090200.000000.log"), false,
+		sample(2, log4go.WARNING, "pay/charge.go:42 pay.(*Client).Charge", "slow charge",
+			log4go.Float64("ms", 1.5), log4go.Bool("retry", true)).Logfmt(),
+		[]byte("not a log line\n"))
+	writeFile(t, filepath.Join(dir, "app.log"), false,
+		sample(3, log4go.ERROR, "api.go:99", "failed \"x\"",
+			log4go.Group("req", log4go.String("id", "r-9"), log4go.Group("user", log4go.Int("id", 42))),
+			log4go.Any("tags", []string{"a", "b"})).JSON(),
+		sample(4, log4go.INFO, "api.go:12", "done", log4go.String("req.id", "r-10")).Logfmt())
+	writeFile(t, filepath.Join(dir, "other.20261018T090000.000000.log"), false,
+		sample(9, log4go.INFO, "x.go:1", "other").JSON())
+	return dir
+}
+
+func msgs(es []*Entry) string {
+	var s []string
+	for _, e := range es {
+		s = append(s, e.Msg)
+	}
+	return strings.Join(s, ",")
+}
+
+func Test_ReadAll_RotatedSegments(t *testing.T) {
+	dir := rotatedDir(t)
+	r, err := Open(Filter{}, filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var es []*Entry
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		es = append(es, e)
+	}
+	if got := msgs(es); got != `start,query,slow charge,failed "x",done` {
+		t.Fatalf("entries=%s", got)
+	}
+	if r.Skipped() != 1 {
+		t.Errorf("Skipped=%d want 1", r.Skipped())
+	}
+
+	e := es[2] // logfmt
+	if !e.Time.Equal(t0.Add(2*time.Minute)) || e.Level != log4go.WARNING || e.File != "pay/charge.go:42 pay.(*Client).Charge" {
+		t.Errorf("logfmt entry=%+v", e)
+	}
+	if v, _ := e.Field("ms"); v != 1.5 {
+		t.Errorf("ms=%v(%T)", v, v)
+	}
+	if v, _ := e.Field("retry"); v != true {
+		t.Errorf("retry=%v", v)
+	}
+	if e.Source != filepath.Join(dir, "app.20261018T090200.000000.log") || e.Line != 1 {
+		t.Errorf("source=%s:%d", e.Source, e.Line)
+	}
+
+	e = es[3] // JSON with groups
+	if e.Seq != 3 || e.Msg != `failed "x"` || !e.Time.Equal(t0.Add(3*time.Minute)) {
+		t.Errorf("JSON entry=%+v", e)
+	}
+	if v, _ := e.Field("req.user.id"); v != int64(42) {
+		t.Errorf("req.user.id=%v(%T)", v, v)
+	}
+	// The entry renders back to the same line.
+	if got, want := string(e.Record().JSON()), string(sample(3, log4go.ERROR, "api.go:99", `failed "x"`,
+		log4go.Group("req", log4go.String("id", "r-9"), log4go.Group("user", log4go.Int("id", 42))),
+		log4go.Any("tags", []string{"a", "b"})).JSON()); got != want {
+		t.Errorf("round trip:\n got %s\nwant %s", got, want)
+	}
+}
+
+func Test_Files(t *testing.T) {
+	dir := rotatedDir(t)
+	base := func(ps []string) string {
+		var s []string
+		for _, p := range ps {
+			s = append(s, filepath.Base(p))
+		}
+		return strings.Join(s, " ")
+	}
+	got, err := Files(filepath.Join(dir, "app.log"), filepath.Join(dir, "app.*"), "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "app.20261018T090000.000000.log.gz app.20261018T090200.000000.log app.log -"; base(got) != want {
+		t.Errorf("Files=%s\nwant %s", base(got), want)
+	}
+	if got, _ := Files(dir); len(got) != 4 {
+		t.Errorf("directory: %s", base(got))
+	}
+	if _, err := Files(filepath.Join(dir, "missing.log")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+// Test_Files_CollisionOrder checks that same-stamp "-n" segments follow
+// their base segment in n order (byte order puts "-" before "." and "-10"
+// before "-2").
+func Test_Files_CollisionOrder(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"app.20261018T090000.000000.log",
+		"app.20261018T090000.000000-1.log.gz",
+		"app.20261018T090000.000000-2.log",
+		"app.20261018T090000.000000-10.log.zst",
+		"app.20261018T090100.000000.log",
+		"app.log",
+	}
+	for _, n := range names {
+		if err := os.WriteFile(filepath.Join(dir, n), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Files(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		got[i] = filepath.Base(got[i])
+	}
+	if !slices.Equal(got, names) {
+		t.Errorf("Files=%v\nwant %v", got, names)
+	}
+}
+
+func Test_Filter(t *testing.T) {
+	dir := rotatedDir(t)
+	mustMatch := func(s string) FieldMatch {
+		m, err := ParseFieldMatch(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	for _, tc := range []struct {
+		name string
+		f    Filter
+		want string
+	}{
+		{"level", Filter{Level: "warn"}, `slow charge,failed "x"`},
+		{"since", Filter{Since: t0.Add(3 * time.Minute)}, `failed "x",done`},
+		{"until", Filter{Until: t0.Add(time.Minute)}, "start"},
+		{"range", Filter{Since: t0.Add(time.Minute), Until: t0.Add(3 * time.Minute)}, "query,slow charge"},
+		{"field eq", Filter{Fields: []FieldMatch{mustMatch("svc=api")}}, "start"},
+		{"field number", Filter{Fields: []FieldMatch{mustMatch("rows=3")}}, "query"},
+		{"field dotted", Filter{Fields: []FieldMatch{mustMatch("req.id~^r-")}}, `failed "x",done`},
+		{"field nested", Filter{Fields: []FieldMatch{mustMatch("req.user.id=42")}}, `failed "x"`},
+		{"field array", Filter{Fields: []FieldMatch{mustMatch(`tags=["a","b"]`)}}, `failed "x"`},
+		{"field and", Filter{Fields: []FieldMatch{mustMatch("req.id~^r-"), mustMatch("req.id=r-10")}}, "done"},
+		{"caller base", Filter{Caller: "api.go"}, `start,failed "x",done`},
+		{"caller line", Filter{Caller: "api.go:1?"}, "start,done"},
+		{"caller path", Filter{Caller: "pay/*.go"}, "slow charge"},
+		{"caller func", Filter{Caller: "pay.(\\*Client).*"}, "slow charge"},
+		{"combined", Filter{Level: "WARNING", Caller: "api.go", Since: t0.Add(time.Minute)}, `failed "x"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			es, err := ReadAll(tc.f, filepath.Join(dir, "app.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := msgs(es); got != tc.want {
+				t.Errorf("got %s want %s", got, tc.want)
+			}
+		})
+	}
+
+	for _, f := range []Filter{{Level: "LOUD"}, {Caller: "["}, {Fields: []FieldMatch{{Value: "x"}}}} {
+		if _, err := Open(f, dir); err == nil {
+			t.Errorf("Open(%+v): no error", f)
+		}
+	}
+	for _, s := range []string{"novalue", "=x", "k~("} {
+		if _, err := ParseFieldMatch(s); err == nil {
+			t.Errorf("ParseFieldMatch(%q): no error", s)
+		}
+	}
+	if m, _ := ParseFieldMatch("url=a=b"); m.Key != "url" || m.Value != "a=b" || m.Regexp != nil {
+		t.Errorf("ParseFieldMatch(url=a=b)=%+v", m)
+	}
+	if m, _ := ParseFieldMatch("k~a|b"); m.Regexp == nil || m.Regexp.String() != "a|b" {
+		t.Errorf("ParseFieldMatch(k~a|b)=%+v", m)
+	}
+}
+
+func Test_NewReader_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(sample(0, log4go.ERROR, "a.go:1", "boom").Logfmt())
+	_ = zw.Close()
+	r, err := NewReader(&buf, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := r.Next()
+	if err != nil || e.Msg != "boom" || e.Source != "-" {
+		t.Fatalf("Next=%+v, %v", e, err)
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("second Next err=%v want EOF", err)
+	}
+}
+
+func Test_ParseLogfmt_Escapes(t *testing.T) {
+	rec := sample(0, log4go.INFO, "a.go:1", "tab\there \"q\" \\ é\x01",
+		log4go.String("quoted", "007"), log4go.String("k=v", "a b"), log4go.Int("n", -7))
+	var e Entry
+	if !parseLine(rec.Logfmt(), &e) {
+		t.Fatalf("unparsed: %s", rec.Logfmt())
+	}
+	if e.Msg != "tab\there \"q\" \\ é\x01" {
+		t.Errorf("msg=%q", e.Msg)
+	}
+	if v, _ := e.Field("quoted"); v != "007" {
+		t.Errorf("quoted=%v(%T) want the string", v, v)
+	}
+	if v, _ := e.Field("k=v"); v != "a b" {
+		t.Errorf("k=v=%v", v)
+	}
+	if v, _ := e.Field("n"); v != int64(-7) {
+		t.Errorf("n=%v(%T)", v, v)
+	}
+	for _, bad := range []string{"level=NOPE msg=x", "msg=x", `level=INFO msg="open`, "level=INFO junk", `{"level":"INFO"`, "#1 2026/10/18 09:00:00 [INFO] <a.go:1> text"} {
+		if parseLine([]byte(bad), &Entry{}) {
+			t.Errorf("parsed %q", bad)
+		}
+	}
+}
+
+// Test_Logger_RoundTrip reads back what a real Logger wrote through a
+// FileWriter that size-rotates into gzip segments.
+func Test_Logger_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	fw := log4go.NewFileWriterWithOptions(log4go.FileWriterOptions{
+		Enable: true, Level: "DEBUG", Filename: filepath.Join(dir, "rt-%Y%M%D.log"),
+		Rotate: true, Daily: true, MaxSizeBytes: 2048, Compress: log4go.CompressGzip,
+	})
+	l := log4go.NewLogger()
+	l.SetLevel(log4go.DEBUG)
+	l.SetFormat(log4go.FormatJSON)
+	l.Register(fw)
+	sl := slog.New(log4go.NewSlogHandler(l))
+	for i := range 100 {
+		sl.Info("request", "i", i, slog.Group("req", "path", "/v1/items", "slow", i%10 == 0))
+	}
+	l.Close()
+
+	if segs, _ := filepath.Glob(filepath.Join(dir, "rt-*.log.gz")); len(segs) == 0 {
+		t.Fatal("no compressed segments")
+	}
+	es, err := ReadAll(Filter{Fields: []FieldMatch{{Key: "req.slow", Value: "true"}}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var is []string
+	for _, e := range es {
+		v, _ := e.Field("i")
+		is = append(is, valueText(v))
+	}
+	if got := strings.Join(is, ","); got != "0,10,20,30,40,50,60,70,80,90" {
+		t.Errorf("slow requests i=%s", got)
+	}
+	if len(es) > 0 && !regexp.MustCompile(`^reader_test\.go:\d+$`).MatchString(es[0].File) {
+		t.Errorf("caller=%q", es[0].File)
+	}
+}
@@ -1,10 +1,30 @@
 package log4go
 
+import "time"
+
 // This file exposes read-only accessors for Record fields so that code in other
 // packages (custom WebhookWriter filters/formatters, monitoring hooks) can
 // inspect a record without reaching into its private fields. The record is
 // immutable once delivered to a writer, so these are safe to call concurrently.
 
+// NewRecord builds a record outside a Logger — for log readers (log4go/reader),
+// tests and custom writer pipelines — from its parts. t is rendered with
+// DefaultLayout; a zero t makes a record without a time. The record is not
+// pooled, so it may be kept after use.
+func NewRecord(t time.Time, seq uint64, level int, file, msg string, fields ...Field) *Record {
+	r := &Record{msg: msg, file: file, level: level, seq: seq}
+	if !t.IsZero() {
+		r.time, r.unixNano = t.Format(defaultLayout), t.UnixNano()
+	}
+	if len(fields) > 0 {
+		r.fields = make([]field, len(fields))
+		for i, f := range fields {
+			r.fields[i] = f.f
+		}
+	}
+	return r
+}
+
 // Msg returns the formatted message text.
 func (r *Record) Msg() string { return r.msg }
 
@@ -34,12 +54,22 @@ func (r *Record) Seq() uint64 { return r.seq }
 // FieldValue returns the value of the first structured field named key, and
 // whether such a field exists. Base Fields (SetBaseField), With/WithFields and
 // context-extracted fields are all merged into the record, so this sees every
-// attached field. Useful in custom WebhookWriter filters/formatters.
+// attached field. Useful in custom WebhookWriter filters/formatters. A dotted
+// key ("req.id") also reaches into groups (slog.Group, WithGroup).
 func (r *Record) FieldValue(key string) (any, bool) {
-	for _, f := range r.fields {
+	return lookupField(r.fields, key)
+}
+
+func lookupField(fields []field, key string) (any, bool) {
+	for _, f := range fields {
 		if f.key == key {
 			return f.value(), true
 		}
+		if f.kind == kindGroup && len(key) > len(f.key) && key[len(f.key)] == '.' && key[:len(f.key)] == f.key {
+			if v, ok := lookupField(f.any.(fieldGroup), key[len(f.key)+1:]); ok {
+				return v, true
+			}
+		}
 	}
 	return nil, false
 }
@@ -0,0 +1,364 @@
+package log4go
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// RedactAction is what a redaction rule does with a sensitive value.
+type RedactAction uint8
+
+const (
+	// RedactMask replaces the value (or the regex match) with the mask string.
+	RedactMask RedactAction = iota
+	// RedactHash replaces it with a keyed, truncated HMAC-SHA256 ("sha256:<16
+	// hex>"), so equal values stay correlatable across records without being
+	// recoverable.
+	RedactHash
+	// RedactDrop removes the field entirely; a regex match in the message is
+	// cut out.
+	RedactDrop
+)
+
+// ParseRedactAction maps "mask"(default)|"hash"|"drop" to a RedactAction.
+// Unknown values fall back to mask with a log line — failing closed, since an
+// unrecognized action must never leak the value.
+func ParseRedactAction(s string) RedactAction {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "mask":
+		return RedactMask
+	case "hash":
+		return RedactHash
+	case "drop":
+		return RedactDrop
+	}
+	log.Printf("[log4go] unknown redact action %q, use mask", s)
+	return RedactMask
+}
+
+// Built-in redaction patterns selectable via RedactOptions.Builtins.
+const (
+	RedactBuiltinCard   = "card"   // payment card numbers (13-19 digits, Luhn-checked)
+	RedactBuiltinEmail  = "email"  // e-mail addresses
+	RedactBuiltinBearer = "bearer" // "Bearer <token>" credentials
+)
+
+// DefaultRedactMask is the replacement used when RedactOptions.Mask is empty.
+const DefaultRedactMask = "[REDACTED]"
+
+// RedactRuleOptions is one regex rule applied to the message and to every
+// string field value.
+type RedactRuleOptions struct {
+	// Name labels the rule (diagnostics only).
+	Name string `json:"name" mapstructure:"name"`
+	// Pattern is a Go regexp; every match is redacted.
+	Pattern string `json:"pattern" mapstructure:"pattern"`
+	// Action: "mask"(default)|"hash"|"drop". drop cuts the match out of the
+	// message and removes a matching string field.
+	Action string `json:"action" mapstructure:"action"`
+}
+
+// RedactOptions configures a Redactor (LogConfig.redact).
+type RedactOptions struct {
+	// Enable gates installation from LogConfig.
+	Enable bool `json:"enable" mapstructure:"enable"`
+	// Keys is the field-key deny-list (case-insensitive exact match), e.g.
+	// ["password", "authorization", "card_number"].
+	Keys []string `json:"keys" mapstructure:"keys"`
+	// KeyAction applies to deny-listed keys: "mask"(default)|"hash"|"drop".
+	KeyAction string `json:"key_action" mapstructure:"key_action"`
+	// Builtins enables the shipped patterns: "card", "email", "bearer". They
+	// use BuiltinAction.
+	Builtins []string `json:"builtins" mapstructure:"builtins"`
+	// BuiltinAction applies to the builtin patterns (default "mask").
+	BuiltinAction string `json:"builtin_action" mapstructure:"builtin_action"`
+	// Rules are custom regex rules, applied after the builtins.
+	Rules []RedactRuleOptions `json:"rules" mapstructure:"rules"`
+	// Mask is the replacement for the mask action (default "[REDACTED]").
+	Mask string `json:"mask" mapstructure:"mask"`
+	// HashKey keys the HMAC of the hash action. Set it (and keep it secret) so
+	// hashed low-entropy values such as emails cannot be brute-forced.
+	HashKey string `json:"hash_key" mapstructure:"hash_key"`
+}
+
+// redactRule is a compiled regex rule. valid, when set, vets each match (the
+// card rule Luhn-checks so order ids and timestamps are left alone).
+type redactRule struct {
+	name   string
+	re     *regexp.Regexp
+	action RedactAction
+	valid  func(string) bool
+}
+
+// Redactor masks, hashes or drops sensitive data in a record before it reaches
+// any writer. It is immutable once built, so one Redactor is safely shared by
+// every logger and goroutine. Install it with Logger.SetRedactor or
+// LogConfig.redact.
+//
+// Redaction runs once per record on the logging goroutine, after the record's
+// fields are merged (base + With + context) and BEFORE the JSON/logfmt
+// pre-serialization, so every writer — and every format — sees only the
+// redacted form. Field slices shared with the logger are never mutated: a
+// record that needs redaction gets its own copy.
+type Redactor struct {
+	keys      map[string]struct{}
+	keyAction RedactAction
+	rules     []redactRule
+	mask      string
+	hashKey   []byte
+}
+
+// NewRedactor compiles options into a Redactor. It returns an error for an
+// invalid regex or an unknown builtin name.
+func NewRedactor(options RedactOptions) (*Redactor, error) {
+	rd := &Redactor{
+		keys:      make(map[string]struct{}, len(options.Keys)),
+		keyAction: ParseRedactAction(options.KeyAction),
+		mask:      options.Mask,
+		hashKey:   []byte(options.HashKey),
+	}
+	if rd.mask == "" {
+		rd.mask = DefaultRedactMask
+	}
+	for _, k := range options.Keys {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			rd.keys[k] = struct{}{}
+		}
+	}
+	builtinAction := ParseRedactAction(options.BuiltinAction)
+	for _, b := range options.Builtins {
+		r, ok := builtinRedactRule(strings.ToLower(strings.TrimSpace(b)))
+		if !ok {
+			return nil, fmt.Errorf("log4go: unknown redact builtin %q", b)
+		}
+		r.action = builtinAction
+		rd.rules = append(rd.rules, r)
+	}
+	for _, ro := range options.Rules {
+		re, err := regexp.Compile(ro.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("log4go: redact rule %q: %w", ro.Name, err)
+		}
+		rd.rules = append(rd.rules, redactRule{name: ro.Name, re: re, action: ParseRedactAction(ro.Action)})
+	}
+	return rd, nil
+}
+
+var (
+	redactCardRe   = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	redactEmailRe  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	redactBearerRe = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+)
+
+func builtinRedactRule(name string) (redactRule, bool) {
+	switch name {
+	case RedactBuiltinCard:
+		return redactRule{name: name, re: redactCardRe, valid: luhnValid}, true
+	case RedactBuiltinEmail:
+		return redactRule{name: name, re: redactEmailRe}, true
+	case RedactBuiltinBearer:
+		return redactRule{name: name, re: redactBearerRe}, true
+	}
+	return redactRule{}, false
+}
+
+// luhnValid reports whether the digits of s (separators ignored) pass the Luhn
+// checksum used by payment card numbers.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// hash returns the keyed, truncated digest used by RedactHash.
+func (rd *Redactor) hash(s string) string {
+	m := hmac.New(sha256.New, rd.hashKey)
+	m.Write([]byte(s))
+	return "sha256:" + hex.EncodeToString(m.Sum(nil)[:8])
+}
+
+// replacement renders the substitute for a redacted value under action.
+func (rd *Redactor) replacement(action RedactAction, v string) string {
+	switch action {
+	case RedactHash:
+		return rd.hash(v)
+	case RedactDrop:
+		return ""
+	}
+	return rd.mask
+}
+
+// redactString applies every regex rule to s. dropped reports that a drop
+// rule matched (the caller removes a field; the message keeps the cut text).
+func (rd *Redactor) redactString(s string) (out string, n int, dropped bool) {
+	out = s
+	for i := range rd.rules {
+		r := &rd.rules[i]
+		if !r.re.MatchString(out) {
+			continue
+		}
+		out = r.re.ReplaceAllStringFunc(out, func(m string) string {
+			if r.valid != nil && !r.valid(m) {
+				return m
+			}
+			n++
+			if r.action == RedactDrop {
+				dropped = true
+			}
+			return rd.replacement(r.action, m)
+		})
+	}
+	return out, n, dropped
+}
+
+// deniedKey reports whether key is on the deny-list (case-insensitive).
+func (rd *Redactor) deniedKey(key string) bool {
+	if len(rd.keys) == 0 {
+		return false
+	}
+	if _, ok := rd.keys[key]; ok {
+		return true
+	}
+	_, ok := rd.keys[strings.ToLower(key)]
+	return ok
+}
+
+// redactField returns the redacted form of f. keep=false removes the field.
+func (rd *Redactor) redactField(f field) (out field, n int, keep bool) {
+	if rd.deniedKey(f.key) {
+		switch rd.keyAction {
+		case RedactDrop:
+			return f, 1, false
+		case RedactHash:
+			return strField(f.key, rd.hash(fieldText(f))), 1, true
+		}
+		return strField(f.key, rd.mask), 1, true
+	}
+	if f.kind == kindGroup {
+		g, n := rd.redactFields(f.any.(fieldGroup))
+		if n == 0 {
+			return f, 0, true
+		}
+		return groupField(f.key, g), n, true
+	}
+	if f.kind != kindString || len(rd.rules) == 0 {
+		return f, 0, true
+	}
+	s, n, dropped := rd.redactString(f.str)
+	if n == 0 {
+		return f, 0, true
+	}
+	if dropped {
+		return f, n, false
+	}
+	return strField(f.key, s), n, true
+}
+
+// Apply redacts r in place and returns the number of values redacted. r.fields
+// is replaced (never mutated) when a field changes, since it may alias the
+// logger's shared field slices.
+func (rd *Redactor) Apply(r *Record) int {
+	total := 0
+	if len(rd.rules) > 0 && r.msg != "" {
+		msg, n, _ := rd.redactString(r.msg)
+		r.msg = msg
+		total += n
+	}
+	fields, n := rd.redactFields(r.fields)
+	r.fields = fields
+	return total + n
+}
+
+// redactFields returns fields redacted, and the number of values redacted.
+// The slice is copied on the first change, never mutated.
+func (rd *Redactor) redactFields(fields []field) ([]field, int) {
+	total := 0
+	var out []field // nil until the first change (copy-on-write)
+	for i, f := range fields {
+		nf, n, keep := rd.redactField(f)
+		if n == 0 {
+			if out != nil {
+				out = append(out, f)
+			}
+			continue
+		}
+		total += n
+		if out == nil {
+			out = make([]field, i, len(fields))
+			copy(out, fields[:i])
+		}
+		if keep {
+			out = append(out, nf)
+		}
+	}
+	if out == nil {
+		return fields, total
+	}
+	return out, total
+}
+
+// fieldText renders a field value as plain text for hashing.
+func fieldText(f field) string {
+	if f.kind == kindString {
+		return f.str
+	}
+	return fmt.Sprint(f.value())
+}
+
+// redactorHolder is the shared redaction state of a logger tree: like
+// baseFieldsHolder it is shared by pointer across clones, so SetRedactor on the
+// root also covers children created earlier (a security control must not be
+// bypassable through an old With child).
+type redactorHolder struct {
+	v       atomic.Pointer[Redactor]
+	records atomic.Uint64 // records with at least one redaction
+	values  atomic.Uint64 // individual values redacted
+}
+
+// SetRedactor installs rd on this logger tree (nil disables redaction). Safe
+// to call concurrently with logging; the next record observes the change.
+func (l *Logger) SetRedactor(rd *Redactor) {
+	if l.redact == nil {
+		return
+	}
+	l.redact.v.Store(rd)
+}
+
+// SetRedactor installs rd on the default logger.
+func SetRedactor(rd *Redactor) { defaultLogger().SetRedactor(rd) }
+
+// redactRecord runs the installed Redactor (if any) on r and counts the result.
+// Called once per record, before pre-serialization.
+func (l *Logger) redactRecord(r *Record) {
+	h := l.redact
+	if h == nil {
+		return
+	}
+	rd := h.v.Load()
+	if rd == nil {
+		return
+	}
+	if n := rd.Apply(r); n > 0 {
+		h.records.Add(1)
+		h.values.Add(uint64(n))
+	}
+}
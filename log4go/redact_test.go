@@ -0,0 +1,206 @@
+package log4go
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustRedactor(t *testing.T, o RedactOptions) *Redactor {
+	t.Helper()
+	rd, err := NewRedactor(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rd
+}
+
+func fieldsByKey(fs []field) map[string]field {
+	m := make(map[string]field, len(fs))
+	for _, f := range fs {
+		m[f.key] = f
+	}
+	return m
+}
+
+func Test_Redactor_KeyDenyList(t *testing.T) {
+	for _, tc := range []struct {
+		action string
+		check  func(t *testing.T, f field, ok bool)
+	}{
+		{"mask", func(t *testing.T, f field, ok bool) {
+			if !ok || f.str != DefaultRedactMask {
+				t.Errorf("password=%+v want masked", f)
+			}
+		}},
+		{"hash", func(t *testing.T, f field, ok bool) {
+			if !ok || !strings.HasPrefix(f.str, "sha256:") || len(f.str) != len("sha256:")+16 {
+				t.Errorf("password=%+v want sha256:<16 hex>", f)
+			}
+		}},
+		{"drop", func(t *testing.T, _ field, ok bool) {
+			if ok {
+				t.Error("password not dropped")
+			}
+		}},
+	} {
+		t.Run(tc.action, func(t *testing.T) {
+			rd := mustRedactor(t, RedactOptions{Keys: []string{"password", "Authorization"}, KeyAction: tc.action, HashKey: "k"})
+			shared := []field{strField("user", "bob"), strField("Password", "hunter2"), intField("authorization", 7)}
+			r := &Record{msg: "login", fields: shared}
+			if n := rd.Apply(r); n != 2 {
+				t.Errorf("redacted=%d want 2", n)
+			}
+			got := fieldsByKey(r.fields)
+			f, ok := got["Password"]
+			tc.check(t, f, ok)
+			if got["user"].str != "bob" {
+				t.Errorf("user=%+v changed", got["user"])
+			}
+			if shared[1].str != "hunter2" {
+				t.Error("logger-shared field slice was mutated")
+			}
+		})
+	}
+
+	// hash is deterministic per key (correlatable) and keyed.
+	a := mustRedactor(t, RedactOptions{Keys: []string{"email"}, KeyAction: "hash", HashKey: "k1"})
+	b := mustRedactor(t, RedactOptions{Keys: []string{"email"}, KeyAction: "hash", HashKey: "k2"})
+	if a.hash("x@y.io") != a.hash("x@y.io") || a.hash("x@y.io") == b.hash("x@y.io") {
+		t.Error("hash must be deterministic and depend on HashKey")
+	}
+}
+
+func Test_Redactor_Builtins(t *testing.T) {
+	rd := mustRedactor(t, RedactOptions{Builtins: []string{"card", "email", "bearer"}})
+	r := &Record{
+		msg: "paid with 4111 1111 1111 1111 by ann@example.com order 1234567890123",
+		fields: []field{
+			strField("auth", "Bearer eyJhbGciOi.J9.abc-_="),
+			strField("card", "5500-0000-0000-0004"),
+			intField("amount", 42),
+		},
+	}
+	if n := rd.Apply(r); n != 4 {
+		t.Errorf("redacted=%d want 4", n)
+	}
+	// the 13-digit order id fails the Luhn check and is left alone.
+	if want := "paid with [REDACTED] by [REDACTED] order 1234567890123"; r.msg != want {
+		t.Errorf("msg=%q want %q", r.msg, want)
+	}
+	got := fieldsByKey(r.fields)
+	if got["auth"].str != DefaultRedactMask || got["card"].str != DefaultRedactMask || got["amount"].i != 42 {
+		t.Errorf("fields=%+v", r.fields)
+	}
+}
+
+func Test_Redactor_CustomRules(t *testing.T) {
+	rd := mustRedactor(t, RedactOptions{
+		Mask: "***",
+		Rules: []RedactRuleOptions{
+			{Name: "ssn", Pattern: `\b\d{3}-\d{2}-\d{4}\b`},
+			{Name: "session", Pattern: `sess_[a-z0-9]+`, Action: "drop"},
+		},
+	})
+	r := &Record{msg: "ssn 123-45-6789 sess_abc1 done", fields: []field{strField("cookie", "id=sess_zz9"), strField("note", "ok")}}
+	if n := rd.Apply(r); n != 3 {
+		t.Errorf("redacted=%d want 3", n)
+	}
+	if r.msg != "ssn ***  done" {
+		t.Errorf("msg=%q", r.msg)
+	}
+	if got := fieldsByKey(r.fields); len(got) != 1 || got["note"].str != "ok" {
+		t.Errorf("fields=%+v want only note (drop rule removes the field)", r.fields)
+	}
+
+	if _, err := NewRedactor(RedactOptions{Rules: []RedactRuleOptions{{Name: "bad", Pattern: "("}}}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+	if _, err := NewRedactor(RedactOptions{Builtins: []string{"iban"}}); err == nil {
+		t.Error("unknown builtin accepted")
+	}
+	if ParseRedactAction("bogus") != RedactMask {
+		t.Error("unknown action must fail closed to mask")
+	}
+}
+
+// waitCaptured waits until cw holds n records.
+func waitCaptured(t *testing.T, cw *captureWriter, n int) []*Record {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for cw.Len() < n && time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if len(cw.records) != n {
+		t.Fatalf("captured %d records want %d", len(cw.records), n)
+	}
+	return append([]*Record(nil), cw.records...)
+}
+
+func Test_Logger_Redactor_BeforeFanOut(t *testing.T) {
+	root := newLoggerWithRecords(make(chan *Record, 64))
+	defer root.Close()
+	root.SetFormat(FormatJSON)
+	cw := &captureWriter{}
+	root.Register(cw)
+
+	child := root.With("token", "s3cret") // created BEFORE the redactor: still covered
+	root.SetRedactor(mustRedactor(t, RedactOptions{Keys: []string{"token"}, Builtins: []string{"email"}}))
+
+	child.Info("mail ann@example.com")
+	slog.New(NewSlogHandler(root)).Info("slog", "token", "t2")
+	root.Info("clean")
+
+	recs := waitCaptured(t, cw, 3)
+	for _, r := range recs[:2] {
+		if s := string(r.formattedBytes); strings.Contains(s, "s3cret") || strings.Contains(s, "t2\"") || strings.Contains(s, "ann@") {
+			t.Errorf("raw value reached the writer: %s", s)
+		}
+		var obj struct{ Fields map[string]any }
+		if err := json.Unmarshal(r.formattedBytes, &obj); err != nil || obj.Fields["token"] != DefaultRedactMask {
+			t.Errorf("token=%v err=%v in %s", obj.Fields["token"], err, r.formattedBytes)
+		}
+	}
+	m := root.Metrics()
+	if m.Redacted != 2 || m.RedactedValues != 3 {
+		t.Errorf("Redacted=%d RedactedValues=%d want 2/3", m.Redacted, m.RedactedValues)
+	}
+
+	root.SetRedactor(nil)
+	child.Info("off")
+	if recs := waitCaptured(t, cw, 4); recs[3].fields[0].str != "s3cret" {
+		t.Errorf("redaction still active after SetRedactor(nil): %+v", recs[3].fields)
+	}
+}
+
+func Test_Logger_Redactor_FromLogConfig(t *testing.T) {
+	var lc LogConfig
+	cfg := `{"redact":{"enable":true,"keys":["password"],"key_action":"drop","builtins":["card"],"rules":[{"name":"x","pattern":"("}]}}`
+	if err := json.Unmarshal([]byte(cfg), &lc); err != nil {
+		t.Fatal(err)
+	}
+	l := newLoggerWithRecords(make(chan *Record, 8))
+	defer l.Close()
+	if err := l.applyConfig(lc); err == nil || !strings.Contains(err.Error(), "redactor") {
+		t.Fatalf("bad rule not reported: %v", err)
+	}
+
+	lc.Redact.Rules = nil
+	if err := l.applyConfig(lc); err != nil {
+		t.Fatal(err)
+	}
+	rd := l.redact.v.Load()
+	if rd == nil || rd.keyAction != RedactDrop || len(rd.rules) != 1 {
+		t.Fatalf("redactor not installed from config: %+v", rd)
+	}
+	r := &Record{fields: []field{strField("password", "x"), errField("err", errors.New("e"))}}
+	if rd.Apply(r); len(r.fields) != 1 {
+		t.Errorf("fields=%+v", r.fields)
+	}
+}
@@ -0,0 +1,231 @@
+package log4go
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RingDumpOptions configures the "dump on error" ring (see Logger.SetRingDump).
+type RingDumpOptions struct {
+	// Size is the max number of suppressed records kept (<=0 -> 256). The
+	// oldest record is evicted when full.
+	Size int `json:"size" mapstructure:"size"`
+	// MaxAge discards buffered records older than this at dump time (<=0 ->
+	// 30s), so a trigger never replays stale context from an earlier burst.
+	MaxAge time.Duration `json:"max_age" mapstructure:"max_age"`
+	// CaptureLevel is the most verbose level buffered (default TRACE). Records
+	// suppressed at a more verbose level are discarded as before.
+	CaptureLevel string `json:"capture_level" mapstructure:"capture_level"`
+	// TriggerLevel flushes the ring when a record at or above it is emitted
+	// (default ERROR).
+	TriggerLevel string `json:"trigger_level" mapstructure:"trigger_level"`
+	// MarkerKey is the bool field set on every dumped record so backends can
+	// tell replayed context from live traffic (default "ring_dump").
+	MarkerKey string `json:"marker_key" mapstructure:"marker_key"`
+}
+
+// RingDumpMetrics is a point-in-time snapshot of a logger's dump ring.
+type RingDumpMetrics struct {
+	Buffered int    // records currently held
+	Captured uint64 // suppressed records buffered
+	Dumped   uint64 // records flushed to the writers by a trigger
+	Triggers uint64 // trigger records that flushed a non-empty ring
+	Evicted  uint64 // records overwritten because the ring was full
+	Expired  uint64 // records discarded at dump time for exceeding MaxAge
+}
+
+// ringDump buffers records the logger suppressed (level filter, request
+// sampling, rate sampling) so they can be replayed when an error fires. All
+// methods are safe for concurrent use; the ring is shared by the loggers that
+// hold it.
+type ringDump struct {
+	mu   sync.Mutex
+	buf  []*Record
+	head int // index of the oldest record
+	n    int
+
+	maxAge  time.Duration
+	capture int
+	trigger int
+	marker  string
+
+	captured, dumped, triggers, evicted, expired atomic.Uint64
+}
+
+func newRingDump(o RingDumpOptions) *ringDump {
+	size := o.Size
+	if size <= 0 {
+		size = 256
+	}
+	rd := &ringDump{
+		buf:     make([]*Record, size),
+		maxAge:  o.MaxAge,
+		capture: TRACE,
+		trigger: ERROR,
+		marker:  o.MarkerKey,
+	}
+	if o.CaptureLevel != "" {
+		rd.capture = getLevelDefault(o.CaptureLevel, TRACE, "ring_dump")
+	}
+	if o.TriggerLevel != "" {
+		rd.trigger = getLevelDefault(o.TriggerLevel, ERROR, "ring_dump")
+	}
+	if rd.maxAge <= 0 {
+		rd.maxAge = 30 * time.Second
+	}
+	if rd.marker == "" {
+		rd.marker = "ring_dump"
+	}
+	return rd
+}
+
+// captures reports whether a suppressed record at level should be buffered.
+func (rd *ringDump) captures(level int) bool { return level <= rd.capture }
+
+// push buffers r (a pooled record owned by the ring from now on), evicting the
+// oldest when full.
+func (rd *ringDump) push(r *Record) {
+	rd.mu.Lock()
+	var old *Record
+	if rd.n == len(rd.buf) {
+		old = rd.buf[rd.head]
+		rd.buf[rd.head] = r
+		rd.head = (rd.head + 1) % len(rd.buf)
+	} else {
+		rd.buf[(rd.head+rd.n)%len(rd.buf)] = r
+		rd.n++
+	}
+	rd.mu.Unlock()
+	rd.captured.Add(1)
+	if old != nil {
+		rd.evicted.Add(1)
+		releaseRecord(old)
+	}
+}
+
+// take removes every buffered record, oldest first, dropping those older than
+// MaxAge relative to now.
+func (rd *ringDump) take(now int64) []*Record {
+	rd.mu.Lock()
+	if rd.n == 0 {
+		rd.mu.Unlock()
+		return nil
+	}
+	out := make([]*Record, 0, rd.n)
+	for i := range rd.n {
+		j := (rd.head + i) % len(rd.buf)
+		out = append(out, rd.buf[j])
+		rd.buf[j] = nil
+	}
+	rd.head, rd.n = 0, 0
+	rd.mu.Unlock()
+
+	cutoff := now - int64(rd.maxAge)
+	kept := out[:0]
+	for _, r := range out {
+		if r.unixNano < cutoff {
+			rd.expired.Add(1)
+			releaseRecord(r)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+func (rd *ringDump) metrics() RingDumpMetrics {
+	rd.mu.Lock()
+	n := rd.n
+	rd.mu.Unlock()
+	return RingDumpMetrics{
+		Buffered: n,
+		Captured: rd.captured.Load(),
+		Dumped:   rd.dumped.Load(),
+		Triggers: rd.triggers.Load(),
+		Evicted:  rd.evicted.Load(),
+		Expired:  rd.expired.Load(),
+	}
+}
+
+// releaseRecord returns a record that will never be delivered to the pool.
+func releaseRecord(r *Record) {
+	r.fields = nil
+	r.formattedBytes = nil
+	r.resetEncodings()
+	r.admit, r.admitFrom = 0, 0
+	recordPool.Put(r)
+}
+
+// SetRingDump installs a dump ring on this logger in place: records it would
+// suppress (below its level, sampled out by SamplingStrategy, or dropped by
+// rate sampling) are buffered instead of discarded, and when a record at or
+// above TriggerLevel is emitted the buffered ones are written first — oldest
+// first, each with MarkerKey=true — so the error arrives with its lead-up.
+//
+// Children created afterwards via With*/WithContext share this logger's ring,
+// so a request's context and its error meet in the same buffer. Use
+// WithRingDump for a private, request-scoped ring. Pass Size < 0 to remove the
+// ring. Buffering costs a record build (format, caller, time) per suppressed
+// call, so keep CaptureLevel as tight as the diagnosis needs.
+func (l *Logger) SetRingDump(o RingDumpOptions) {
+	if o.Size < 0 {
+		l.ring.Store(nil)
+		return
+	}
+	l.ring.Store(newRingDump(o))
+}
+
+// WithRingDump returns a child logger with its own dump ring (see
+// SetRingDump) — typically called per request after WithContext, so one
+// request's error replays only that request's debug trail.
+func (l *Logger) WithRingDump(o RingDumpOptions) *Logger {
+	c := l.clone()
+	c.SetRingDump(o)
+	return c
+}
+
+// RingDumpMetrics returns the counters of this logger's dump ring (zero when
+// none is installed).
+func (l *Logger) RingDumpMetrics() RingDumpMetrics {
+	if rd := l.ring.Load(); rd != nil {
+		return rd.metrics()
+	}
+	return RingDumpMetrics{}
+}
+
+// dumpRing flushes the buffered records ahead of a trigger record. Each goes
+// through the same redaction and pre-serialization as a live record, and is
+// admitted by the writers at the logger level or more verbose, as a level
+// override's records are: the replayed records are below the logger level by
+// construction, and a writer set stricter (an ERROR-only alert) still drops
+// them.
+func (l *Logger) dumpRing(rd *ringDump, now int64) {
+	recs := rd.take(now)
+	if len(recs) == 0 {
+		return
+	}
+	rd.triggers.Add(1)
+	base := int(l.level.Load())
+	for _, r := range recs {
+		fs := make([]field, 0, len(r.fields)+1)
+		fs = append(fs, r.fields...)
+		r.fields = append(fs, boolField(rd.marker, true))
+		r.admit, r.admitFrom = TRACE, base
+		l.redactRecord(r)
+		l.preSerialize(r)
+		rd.dumped.Add(1)
+		l.enqueue(r)
+	}
+}
+
+// preSerialize fills r.formattedBytes for the logger's format (nil for text).
+func (l *Logger) preSerialize(r *Record) {
+	r.format = LogFormat(l.format.Load())
+	switch r.format {
+	case FormatJSON:
+		r.formattedBytes = r.JSON()
+	case FormatLogfmt:
+		r.formattedBytes = r.Logfmt()
+	}
+}
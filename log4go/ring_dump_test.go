@@ -0,0 +1,250 @@
+package log4go
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRingTestLogger(t *testing.T) (*Logger, *captureWriter) {
+	t.Helper()
+	l := newLoggerWithRecords(make(chan *Record, 1024))
+	t.Cleanup(l.Close)
+	l.SetLevel(INFO)
+	cw := &captureWriter{}
+	l.Register(cw)
+	return l, cw
+}
+
+func recordMarked(r *Record, key string) bool {
+	v, ok := r.FieldValue(key)
+	return ok && v == true
+}
+
+func Test_RingDump_FlushOnTrigger(t *testing.T) {
+	l, cw := newRingTestLogger(t)
+	l.SetRingDump(RingDumpOptions{Size: 8})
+
+	l.Debug("d1")
+	l.Trace("t1")
+	l.Info("live") // passes the level filter: written now, not buffered
+	l.Debug("d2")
+	l.Error("boom") // trigger: d1, t1, d2 are written first
+
+	recs := waitCaptured(t, cw, 5)
+	want := []string{"live", "d1", "t1", "d2", "boom"}
+	for i, r := range recs {
+		if r.msg != want[i] {
+			t.Fatalf("order: got %q at %d want %v", r.msg, i, want)
+		}
+		if marked := recordMarked(r, "ring_dump"); marked != (i >= 1 && i <= 3) {
+			t.Errorf("%q marker=%v", r.msg, marked)
+		}
+	}
+	m := l.RingDumpMetrics()
+	if m.Captured != 3 || m.Dumped != 3 || m.Triggers != 1 || m.Buffered != 0 {
+		t.Errorf("metrics=%+v", m)
+	}
+
+	// a second trigger with an empty ring writes only itself.
+	l.Critical("again")
+	if recs := waitCaptured(t, cw, 6); recs[5].msg != "again" {
+		t.Errorf("last=%q", recs[5].msg)
+	}
+}
+
+func Test_RingDump_Bounds(t *testing.T) {
+	l, cw := newRingTestLogger(t)
+	l.SetRingDump(RingDumpOptions{Size: 3, CaptureLevel: LevelFlagDebug, TriggerLevel: LevelFlagWarning, MarkerKey: "replayed"})
+	for i := range 5 {
+		l.Debug("d%d", i)
+	}
+	l.Trace("not captured")
+	l.Warn("w")
+	recs := waitCaptured(t, cw, 4)
+	for i, want := range []string{"d2", "d3", "d4", "w"} {
+		if recs[i].msg != want {
+			t.Errorf("rec %d=%q want %q", i, recs[i].msg, want)
+		}
+	}
+	if !recordMarked(recs[0], "replayed") {
+		t.Error("custom marker key not set")
+	}
+	if m := l.RingDumpMetrics(); m.Evicted != 2 || m.Captured != 5 {
+		t.Errorf("metrics=%+v want evicted=2 captured=5", m)
+	}
+
+	// age bound: a buffered record older than MaxAge is discarded at dump time.
+	l.SetRingDump(RingDumpOptions{MaxAge: 20 * time.Millisecond})
+	l.Debug("stale")
+	time.Sleep(40 * time.Millisecond)
+	l.Debug("fresh")
+	l.Error("e")
+	recs = waitCaptured(t, cw, 6)
+	if recs[4].msg != "fresh" || recs[5].msg != "e" {
+		t.Errorf("got %q,%q want fresh,e", recs[4].msg, recs[5].msg)
+	}
+	if m := l.RingDumpMetrics(); m.Expired != 1 {
+		t.Errorf("Expired=%d want 1", m.Expired)
+	}
+}
+
+func Test_RingDump_ChildrenAndRequestScope(t *testing.T) {
+	l, cw := newRingTestLogger(t)
+	l.SetRingDump(RingDumpOptions{})
+
+	// With* children share the parent's ring (and keep their fields).
+	child := l.With("req", "a")
+	child.Debug("child debug")
+	l.Error("parent error")
+	recs := waitCaptured(t, cw, 2)
+	if recs[0].msg != "child debug" || !recordMarked(recs[0], "ring_dump") {
+		t.Fatalf("child record not dumped: %+v", recs[0])
+	}
+	if v, _ := recs[0].FieldValue("req"); v != "a" {
+		t.Errorf("child field lost: %v", v)
+	}
+
+	// WithRingDump gives each request its own ring.
+	r1 := l.WithRingDump(RingDumpOptions{})
+	r2 := l.WithRingDump(RingDumpOptions{})
+	r1.Debug("r1 debug")
+	r2.Debug("r2 debug")
+	r2.Error("r2 error")
+	recs = waitCaptured(t, cw, 4)
+	if recs[2].msg != "r2 debug" || recs[3].msg != "r2 error" {
+		t.Errorf("got %q,%q", recs[2].msg, recs[3].msg)
+	}
+	if r1.RingDumpMetrics().Buffered != 1 {
+		t.Error("r1's ring was flushed by r2's error")
+	}
+}
+
+func Test_RingDump_SampledOutRequest(t *testing.T) {
+	l, cw := newRingTestLogger(t)
+	l.SetSamplingStrategy(TraceIDRatioBased{Ratio: 0}) // every request sampled out
+	l.SetPriorityLevel(ERROR)                          // ... but errors always kept
+	ctx := context.WithValue(context.Background(), "trace_id", "4a3f0b1c2d3e4f60718293a4b5c6d7e8")
+	req := l.WithContext(ctx).WithRingDump(RingDumpOptions{})
+
+	req.Info("handled step 1") // sampled out -> buffered
+	req.Info("handled step 2")
+	req.Error("failed")
+	recs := waitCaptured(t, cw, 3)
+	if recs[0].msg != "handled step 1" || recs[2].msg != "failed" {
+		t.Errorf("got %q..%q", recs[0].msg, recs[2].msg)
+	}
+
+	// without an error nothing from a sampled-out request is written.
+	quiet := l.WithContext(ctx).WithRingDump(RingDumpOptions{})
+	quiet.Info("x")
+	time.Sleep(20 * time.Millisecond)
+	if cw.Len() != 3 {
+		t.Errorf("sampled-out record written without a trigger")
+	}
+}
+
+func Test_RingDump_RedactsReplayedRecords(t *testing.T) {
+	l, cw := newRingTestLogger(t)
+	l.SetFormat(FormatJSON)
+	l.SetRedactor(mustRedactor(t, RedactOptions{Keys: []string{"token"}}))
+	l.SetRingDump(RingDumpOptions{})
+	l.With("token", "s3cret").Debug("d")
+	l.Error("e")
+	recs := waitCaptured(t, cw, 2)
+	if v, _ := recs[0].FieldValue("token"); v != DefaultRedactMask {
+		t.Errorf("replayed token=%v", v)
+	}
+	if len(recs[0].formattedBytes) == 0 {
+		t.Error("replayed record not pre-serialized")
+	}
+
+	l.SetRingDump(RingDumpOptions{Size: -1})
+	if l.ring.Load() != nil {
+		t.Error("Size<0 did not remove the ring")
+	}
+}
+
+// Replayed records reach real writers even though they sit below the writer's
+// configured level, while live records below it are still filtered.
+func Test_RingDump_ReachesLeveledWriter(t *testing.T) {
+	l := newLoggerWithRecords(make(chan *Record, 1024))
+	l.SetLevel(INFO)
+	path := filepath.Join(t.TempDir(), "ring-%Y%M%D.log")
+	fw := NewFileWriterWithOptions(FileWriterOptions{Enable: true, Level: LevelFlagInfo, Filename: path, Rotate: true, Daily: true})
+	if err := fw.Init(); err != nil {
+		t.Fatal(err)
+	}
+	l.Register(fw)
+	l.SetRingDump(RingDumpOptions{Size: 8})
+
+	l.Debug("lead-up")
+	l.Error("boom")
+	l.Close() // drains the bootstrap goroutine and flushes the file
+
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "ring*"))
+	if len(files) != 1 {
+		t.Fatalf("files=%v", files)
+	}
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	if !strings.Contains(out, "lead-up") || !strings.Contains(out, "boom") {
+		t.Fatalf("replayed record missing from the INFO writer:\n%s", out)
+	}
+	if i, j := strings.Index(out, "lead-up"), strings.Index(out, "boom"); i > j {
+		t.Errorf("lead-up written after its trigger:\n%s", out)
+	}
+
+	// a live DEBUG record is still below the writer's level.
+	r := &Record{level: DEBUG}
+	if !r.filteredBy(INFO) {
+		t.Error("live DEBUG record passed an INFO writer")
+	}
+}
+
+// A writer set stricter than the logger (an ERROR-only alert) gets the trigger
+// but none of the replayed lead-up.
+func Test_RingDump_SkipsStricterWriter(t *testing.T) {
+	l := newLoggerWithRecords(make(chan *Record, 1024))
+	l.SetLevel(INFO)
+	dir := t.TempDir()
+	info := NewFileWriterWithOptions(FileWriterOptions{Enable: true, Level: LevelFlagInfo,
+		Filename: filepath.Join(dir, "info-%Y%M%D.log"), Rotate: true, Daily: true})
+	alert := NewFileWriterWithOptions(FileWriterOptions{Enable: true, Level: LevelFlagError,
+		Filename: filepath.Join(dir, "alert-%Y%M%D.log"), Rotate: true, Daily: true})
+	for _, w := range []*FileWriter{info, alert} {
+		if err := w.Init(); err != nil {
+			t.Fatal(err)
+		}
+		l.Register(w)
+	}
+	l.SetRingDump(RingDumpOptions{Size: 8})
+
+	l.Debug("lead-up")
+	l.Error("boom")
+	l.Close()
+
+	read := func(prefix string) string {
+		files, _ := filepath.Glob(filepath.Join(dir, prefix+"*"))
+		if len(files) != 1 {
+			t.Fatalf("files=%v", files)
+		}
+		b, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if out := read("info-"); !strings.Contains(out, "lead-up") {
+		t.Errorf("INFO writer missed the replay:\n%s", out)
+	}
+	if out := read("alert-"); strings.Contains(out, "lead-up") || !strings.Contains(out, "boom") {
+		t.Errorf("ERROR writer:\n%s", out)
+	}
+}
@@ -1,6 +1,7 @@
 package log4go
 
 import (
+	"fmt"
 	"hash/fnv"
 	"math"
 	"strconv"
@@ -29,6 +30,33 @@ type FullSampling struct{}
 // ShouldLog always returns true.
 func (FullSampling) ShouldLog(string) bool { return true }
 
+// ParseSamplingStrategy parses the textual form Logger.Status reports back into
+// a strategy: "full", "trace_id_ratio:<ratio>" (0..1) or
+// "tail_digit:<modulus>:<keep>". Unlike the config parsers it never falls back —
+// an invalid spec is an error.
+func ParseSamplingStrategy(s string) (SamplingStrategy, error) {
+	kind, args, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	switch kind {
+	case "full":
+		if args == "" {
+			return FullSampling{}, nil
+		}
+	case "trace_id_ratio":
+		r, err := strconv.ParseFloat(args, 64)
+		if err == nil && r >= 0 && r <= 1 {
+			return TraceIDRatioBased{Ratio: r}, nil
+		}
+	case "tail_digit":
+		m, k, _ := strings.Cut(args, ":")
+		mod, err1 := strconv.ParseUint(m, 10, 64)
+		keep, err2 := strconv.ParseUint(k, 10, 64)
+		if err1 == nil && err2 == nil && mod > 0 && keep <= mod {
+			return TailDigitSampling{Modulus: mod, Keep: keep}, nil
+		}
+	}
+	return nil, fmt.Errorf("log4go: invalid sampling strategy %q", s)
+}
+
 // TraceIDRatioBased keeps a record with probability Ratio, decided
 // deterministically by the id — the OpenTelemetry TraceIDRatioBased algorithm.
 //
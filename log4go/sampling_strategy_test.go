@@ -620,3 +620,17 @@ func TestPackage_Status(t *testing.T) {
 	}
 	Close()
 }
+
+func TestParseSamplingStrategy(t *testing.T) {
+	for _, s := range []SamplingStrategy{FullSampling{}, TraceIDRatioBased{Ratio: 0.25}, TailDigitSampling{Modulus: 10, Keep: 3}} {
+		got, err := ParseSamplingStrategy(describeStrategy(&s))
+		if err != nil || got != s {
+			t.Errorf("round trip %#v: got %#v err=%v", s, got, err)
+		}
+	}
+	for _, bad := range []string{"", "full:1", "trace_id_ratio:2", "trace_id_ratio:x", "tail_digit:0:0", "tail_digit:10:11", "tail_digit:10", "random"} {
+		if _, err := ParseSamplingStrategy(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
@@ -17,89 +17,209 @@ import (
 //
 //	slog.SetDefault(slog.New(log4go.NewSlogHandler(log4go.NewLogger())))
 //
-// Records flow into the logger's records channel like any other log4go call,
-// carrying the same base fields and pre-serialized format bytes.
+// Records take the same path as native calls: level overrides, per-request
+// sampling, the sampler, the dump ring and redaction, then the logger's base
+// and context fields and pre-serialized format bytes. slog groups become
+// nested fields (a nested JSON object, "group.key" pairs in logfmt), and
+// LogValuer values are resolved only for records that pass the filters. The
+// handler passes testing/slogtest.
 type SlogHandler struct {
 	logger *Logger
-	attrs  []field // attrs accumulated via WithAttrs
-	group  string  // group prefix, flattened as "group.key"
+	opts   SlogHandlerOptions
+	goas   []groupOrAttrs // WithGroup / WithAttrs calls, in order
+}
+
+// SlogHandlerOptions configures NewSlogHandlerWithOptions.
+type SlogHandlerOptions struct {
+	// ReplaceAttr, as in slog.HandlerOptions, rewrites each non-group attr
+	// (after LogValuer resolution) before it is logged; groups lists the
+	// enclosing group names. Returning the zero Attr drops it. It also sees the
+	// built-in slog.TimeKey, slog.MessageKey and slog.SourceKey attrs (groups
+	// nil; source as a "file:line" string): dropping time or source omits it,
+	// and a replacement of the same kind is used. The level is not passed —
+	// log4go renders its own level names.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+}
+
+// groupOrAttrs is one WithGroup (group set) or WithAttrs (attrs set) call.
+// The attrs are kept unresolved so LogValuers run at Handle, after the gate.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
 }
 
 // NewSlogHandler returns a slog.Handler forwarding to logger (nil -> the package
 // singleton).
 func NewSlogHandler(logger *Logger) *SlogHandler {
+	return NewSlogHandlerWithOptions(logger, SlogHandlerOptions{})
+}
+
+// NewSlogHandlerWithOptions is NewSlogHandler with options (ReplaceAttr).
+func NewSlogHandlerWithOptions(logger *Logger, opts SlogHandlerOptions) *SlogHandler {
 	if logger == nil {
 		logger = defaultLogger()
 	}
-	return &SlogHandler{logger: logger}
+	return &SlogHandler{logger: logger, opts: opts}
 }
 
-// Enabled reports whether the logger would emit at the given slog level.
+// Enabled reports whether the logger would emit at the given slog level. With
+// level overrides set, a level any override enables is reported enabled and
+// Handle decides per call site.
 func (h *SlogHandler) Enabled(_ context.Context, sl slog.Level) bool {
-	return int32(slogToLog4goLevel(sl)) <= h.logger.level.Load()
+	lvl := slogToLog4goLevel(sl)
+	return int32(lvl) <= h.logger.level.Load() || lvl <= h.logger.maxOverrideLevel()
 }
 
-// Handle converts a slog.Record into a log4go Record and delivers it.
-func (h *SlogHandler) Handle(_ context.Context, sr slog.Record) error {
+// Handle converts a slog.Record into a log4go Record and delivers it. A
+// correlation id in ctx drives the sampling strategy as WithContext would, and
+// the context extractors add their fields.
+func (h *SlogHandler) Handle(ctx context.Context, sr slog.Record) error {
+	l := h.logger
 	lvl := slogToLog4goLevel(sr.Level)
-	if int32(lvl) > h.logger.level.Load() {
+	if l.occurredByLevel != nil {
+		atomic.AddUint64(&l.occurredByLevel[lvl], 1)
+	}
+	sampleDrop := l.sampleDrop.Load()
+	if ss := l.samplingStrategy.Load(); ss != nil {
+		if id := correlationIDFromContext(ctx); id != "" {
+			sampleDrop = !(*ss).ShouldLog(id)
+		}
+	}
+	base := int(l.level.Load())
+	minLevel := base
+	if l.overridesAffect(lvl, base) {
+		minLevel = l.slogLevelFor(sr.PC)
+	}
+	suppressed, ring, ok := l.gate(lvl, minLevel, sampleDrop)
+	if !ok {
 		return nil
 	}
-	extra := make([]field, 0, len(h.attrs)+sr.NumAttrs())
-	extra = append(extra, h.attrs...)
-	sr.Attrs(func(a slog.Attr) bool {
-		extra = append(extra, slogAttrToField(h.group, a))
-		return true
-	})
 
-	now := sr.Time
-	if now.IsZero() {
-		now = time.Now()
-	}
-	layout := defaultLayout
-	if lp := h.logger.layout.Load(); lp != nil {
-		layout = *lp
+	msg, when, source := sr.Message, sr.Time, slogSource(sr)
+	if rep := h.opts.ReplaceAttr; rep != nil {
+		if a := rep(nil, slog.Time(slog.TimeKey, when)); a.Key == "" {
+			when = time.Time{}
+		} else if a.Value.Kind() == slog.KindTime {
+			when = a.Value.Time()
+		}
+		if a := rep(nil, slog.String(slog.MessageKey, msg)); a.Key == "" {
+			msg = ""
+		} else {
+			msg = a.Value.Resolve().String()
+		}
+		if source != "" {
+			if a := rep(nil, slog.String(slog.SourceKey, source)); a.Key == "" {
+				source = ""
+			} else if a.Value.Kind() == slog.KindString {
+				source = a.Value.String()
+			}
+		}
 	}
 
 	r := recordPool.Get().(*Record)
-	r.msg = sr.Message
-	r.file = slogSource(sr)
-	r.time = now.Format(layout)
+	r.msg = msg
+	r.file = source
 	r.level = lvl
-	r.unixNano = now.UnixNano()
+	// A zero slog time means "no time" (slog.Handler contract): the record
+	// renders without one.
+	if when.IsZero() {
+		r.time, r.unixNano = "", 0
+	} else {
+		layout := defaultLayout
+		if lp := l.layout.Load(); lp != nil {
+			layout = *lp
+		}
+		r.time, r.unixNano = when.Format(layout), when.UnixNano()
+	}
 	r.seq = atomic.AddUint64(&globalSeq, 1)
-	r.fields = mergeLoggerFields(h.logger, extra)
+	admitOverride(r, minLevel, base)
+	r.fields = mergeLoggerFields(l, l.appendContextFields(h.fields(sr), ctx))
+	l.dispatch(r, suppressed, ring)
+	return nil
+}
 
-	switch LogFormat(h.logger.format.Load()) {
-	case FormatJSON:
-		r.formattedBytes = r.JSON()
-	case FormatLogfmt:
-		r.formattedBytes = r.Logfmt()
+// fields builds the record's fields: the WithAttrs attrs and the record's
+// own, each nested in the groups opened before it. Empty groups are omitted.
+func (h *SlogHandler) fields(sr slog.Record) []field {
+	var groups []string
+	for _, g := range h.goas {
+		if g.group != "" {
+			groups = append(groups, g.group)
+		}
 	}
+	var fs []field
+	if sr.NumAttrs() > 0 {
+		attrs := make([]slog.Attr, 0, sr.NumAttrs())
+		sr.Attrs(func(a slog.Attr) bool {
+			attrs = append(attrs, a)
+			return true
+		})
+		fs = h.attrFields(groups, attrs)
+	}
+	// Walk outwards: each group wraps everything logged after it.
+	for i := len(h.goas) - 1; i >= 0; i-- {
+		g := h.goas[i]
+		if g.group != "" {
+			groups = groups[:len(groups)-1]
+			if len(fs) > 0 {
+				fs = []field{groupField(g.group, fs)}
+			}
+			continue
+		}
+		fs = append(h.attrFields(groups, g.attrs), fs...)
+	}
+	return fs
+}
 
-	h.logger.enqueue(r)
-	return nil
+// attrFields converts attrs logged inside groups, resolving LogValuers and
+// applying ReplaceAttr. Zero attrs and empty groups are dropped; a group
+// with an empty key is inlined.
+func (h *SlogHandler) attrFields(groups []string, attrs []slog.Attr) []field {
+	fs := make([]field, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if rep := h.opts.ReplaceAttr; rep != nil && a.Value.Kind() != slog.KindGroup {
+			a = rep(groups, a)
+			a.Value = a.Value.Resolve()
+		}
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() != slog.KindGroup {
+			fs = append(fs, slogValueField(a.Key, a.Value))
+			continue
+		}
+		members := a.Value.Group()
+		if a.Key == "" {
+			fs = append(fs, h.attrFields(groups, members)...)
+			continue
+		}
+		if sub := h.attrFields(append(groups[:len(groups):len(groups)], a.Key), members); len(sub) > 0 {
+			fs = append(fs, groupField(a.Key, sub))
+		}
+	}
+	return fs
 }
 
 // WithAttrs returns a child handler carrying the additional attrs.
 func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	nh := *h
-	fs := make([]field, len(attrs))
-	for i, a := range attrs {
-		fs[i] = slogAttrToField(h.group, a)
+	if len(attrs) == 0 {
+		return h
 	}
-	nh.attrs = append(append([]field(nil), h.attrs...), fs...)
-	return &nh
+	return h.with(groupOrAttrs{attrs: attrs})
 }
 
-// WithGroup returns a child handler with a group prefix (flattened as group.key).
+// WithGroup returns a child handler whose later attrs nest under name.
 func (h *SlogHandler) WithGroup(name string) slog.Handler {
-	nh := *h
-	if h.group == "" {
-		nh.group = name
-	} else {
-		nh.group = h.group + "." + name
+	if name == "" {
+		return h
 	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *SlogHandler) with(g groupOrAttrs) *SlogHandler {
+	nh := *h
+	nh.goas = append(h.goas[:len(h.goas):len(h.goas)], g)
 	return &nh
 }
 
@@ -121,19 +241,15 @@ func slogToLog4goLevel(sl slog.Level) int {
 	}
 }
 
-// slogAttrToField converts a resolved slog.Attr into a typed field, applying the
-// group prefix. Group values are flattened recursively with a dotted prefix.
-func slogAttrToField(group string, a slog.Attr) field {
-	v := a.Value.Resolve()
-	key := a.Key
-	if group != "" {
-		key = group + "." + key
-	}
+// slogValueField converts a resolved, non-group slog.Value into a typed field.
+func slogValueField(key string, v slog.Value) field {
 	switch v.Kind() {
 	case slog.KindString:
 		return strField(key, v.String())
 	case slog.KindInt64:
 		return int64Field(key, v.Int64())
+	case slog.KindUint64:
+		return uint64Field(key, v.Uint64())
 	case slog.KindFloat64:
 		return floatField(key, v.Float64())
 	case slog.KindBool:
@@ -142,10 +258,6 @@ func slogAttrToField(group string, a slog.Attr) field {
 		return durField(key, v.Duration())
 	case slog.KindTime:
 		return timeField(key, v.Time())
-	case slog.KindGroup:
-		// Group attrs are flattened; represented as the JSON of the group object
-		// via kindAny so nested structure is preserved on output.
-		return anyField(key, v.Any())
 	default:
 		return anyField(key, v.Any())
 	}
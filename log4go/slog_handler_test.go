@@ -2,9 +2,12 @@ package log4go
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/slogtest"
 	"time"
 )
 
@@ -120,15 +123,11 @@ func Test_SlogHandler_WithAttrsAndGroup(t *testing.T) {
 	r := cw.records[0]
 	cw.mu.Unlock()
 
-	got := map[string]any{}
-	for _, f := range r.fields {
-		got[f.key] = f.value()
+	if v, _ := r.FieldValue("svc"); v != "api" {
+		t.Errorf("With attr svc=%v want api", v)
 	}
-	if got["svc"] != "api" {
-		t.Errorf("With attr svc=%v want api", got["svc"])
-	}
-	if got["req.id"] != "r-9" {
-		t.Errorf("grouped attr req.id=%v want r-9", got["req.id"])
+	if v, _ := r.FieldValue("req.id"); v != "r-9" {
+		t.Errorf("grouped attr req.id=%v want r-9", v)
 	}
 }
 
@@ -161,3 +160,167 @@ func Test_SlogHandler_BaseFieldsIncluded(t *testing.T) {
 		t.Error("base field hostname missing from slog record")
 	}
 }
+
+// slogCapture returns a JSON logger at DEBUG with a captureWriter registered.
+func slogCapture(t *testing.T) (*Logger, *captureWriter) {
+	t.Helper()
+	l := newLoggerWithRecords(make(chan *Record, 16))
+	t.Cleanup(l.Close)
+	l.SetLevel(DEBUG)
+	l.SetFormat(FormatJSON)
+	cw := &captureWriter{}
+	l.Register(cw)
+	return l, cw
+}
+
+// lastRecord waits for the n-th record to reach cw and returns it.
+func lastRecord(t *testing.T, cw *captureWriter, n int) *Record {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && cw.Len() < n {
+		time.Sleep(time.Millisecond)
+	}
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if len(cw.records) < n {
+		t.Fatalf("%d records reached the writer, want %d", len(cw.records), n)
+	}
+	return cw.records[n-1]
+}
+
+// Test_SlogHandler_Conformance runs the standard library's slog.Handler
+// conformance suite. Each case gets a fresh logger; its JSON line is decoded
+// and the "fields" object lifted to the top level, where slogtest expects attrs.
+func Test_SlogHandler_Conformance(t *testing.T) {
+	var cw *captureWriter
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		var l *Logger
+		l, cw = slogCapture(t)
+		return NewSlogHandler(l)
+	}, func(t *testing.T) map[string]any {
+		r := lastRecord(t, cw, 1)
+		m := map[string]any{}
+		if err := json.Unmarshal(r.formattedBytes, &m); err != nil {
+			t.Fatalf("%v: %s", err, r.formattedBytes)
+		}
+		if fs, ok := m["fields"].(map[string]any); ok {
+			delete(m, "fields")
+			for k, v := range fs {
+				m[k] = v
+			}
+		}
+		return m
+	})
+}
+
+// Test_SlogHandler_GroupsRendering checks nested groups as a JSON object and
+// as dotted logfmt keys.
+func Test_SlogHandler_GroupsRendering(t *testing.T) {
+	l, cw := slogCapture(t)
+	sl := slog.New(NewSlogHandler(l)).With("svc", "api").WithGroup("req")
+	sl.Info("handled", "id", "r-9", slog.Group("user", "name", "ann", "age", 7), slog.Group("empty"))
+	r := lastRecord(t, cw, 1)
+	if want := `"fields":{"svc":"api","req":{"id":"r-9","user":{"name":"ann","age":7}}}`; !strings.Contains(string(r.formattedBytes), want) {
+		t.Errorf("JSON=%s\nwant %s", r.formattedBytes, want)
+	}
+	if got := string(r.Logfmt()); !strings.Contains(got, "svc=api req.id=r-9 req.user.name=ann req.user.age=7\n") {
+		t.Errorf("logfmt=%q", got)
+	}
+	if v, ok := r.FieldValue("req.user.age"); !ok || v != int64(7) {
+		t.Errorf("FieldValue(req.user.age)=%v,%v", v, ok)
+	}
+}
+
+// Test_SlogHandler_ReplaceAttr checks ReplaceAttr sees each attr with its
+// group path, can rewrite or drop it, and can drop the built-in time.
+func Test_SlogHandler_ReplaceAttr(t *testing.T) {
+	l, cw := slogCapture(t)
+	var paths []string
+	h := NewSlogHandlerWithOptions(l, SlogHandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		switch {
+		case a.Key == slog.TimeKey && groups == nil:
+			return slog.Attr{}
+		case a.Key == "secret":
+			return slog.Attr{}
+		case a.Key == "n":
+			paths = append(paths, strings.Join(groups, "."))
+			return slog.Int64("n", a.Value.Int64()*10)
+		}
+		return a
+	}})
+	slog.New(h).WithGroup("g").Info("m", "n", 4, "secret", "s3", slog.Group("in", "n", 1))
+	r := lastRecord(t, cw, 1)
+	if r.unixNano != 0 || strings.Contains(string(r.formattedBytes), `"time"`) {
+		t.Errorf("time not dropped: %s", r.formattedBytes)
+	}
+	if want := `"fields":{"g":{"n":40,"in":{"n":10}}}`; !strings.Contains(string(r.formattedBytes), want) {
+		t.Errorf("JSON=%s\nwant %s", r.formattedBytes, want)
+	}
+	if strings.Join(paths, ",") != "g,g.in" {
+		t.Errorf("group paths=%q", paths)
+	}
+}
+
+// Test_SlogHandler_RedactsInsideGroups checks the logger's Redactor reaches
+// attrs nested in slog groups.
+func Test_SlogHandler_RedactsInsideGroups(t *testing.T) {
+	l, cw := slogCapture(t)
+	rd, err := NewRedactor(RedactOptions{Keys: []string{"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetRedactor(rd)
+	slog.New(NewSlogHandler(l)).Info("login", slog.Group("user", "name", "ann", "password", "hunter2"))
+	r := lastRecord(t, cw, 1)
+	if strings.Contains(string(r.formattedBytes), "hunter2") {
+		t.Errorf("password leaked: %s", r.formattedBytes)
+	}
+	if v, _ := r.FieldValue("user.name"); v != "ann" {
+		t.Errorf("user.name=%v", v)
+	}
+}
+
+// countingValuer counts its LogValue calls.
+type countingValuer struct{ n *atomic.Int32 }
+
+func (v countingValuer) LogValue() slog.Value {
+	v.n.Add(1)
+	return slog.StringValue("resolved")
+}
+
+// Test_SlogHandler_SharedFilterPath checks slog records go through the same
+// sampling path as native calls, and that LogValuers of dropped records are
+// never resolved.
+func Test_SlogHandler_SharedFilterPath(t *testing.T) {
+	l, cw := slogCapture(t)
+	l.SetSampling(1, 1000)
+	var n atomic.Int32
+	sl := slog.New(NewSlogHandler(l))
+	for range 5 {
+		sl.Info("sampled", "v", countingValuer{&n})
+	}
+	l.SetSampling(0, 0)
+	sl.Info("marker")
+	if r := lastRecord(t, cw, 2); r.msg != "marker" {
+		t.Fatalf("second record=%q, want the sampler to drop 4 of 5", r.msg)
+	}
+	if got := n.Load(); got != 1 {
+		t.Errorf("LogValue resolved %d times, want 1 (only the kept record)", got)
+	}
+	if v, _ := cw.records[0].FieldValue("v"); v != "resolved" {
+		t.Errorf("v=%v", v)
+	}
+
+	// The per-request sampling strategy reads the correlation id from ctx.
+	l.SetSamplingStrategy(dropAll{})
+	sl.InfoContext(context.WithValue(context.Background(), "trace_id", "t-1"), "dropped")
+	sl.Info("kept")
+	if r := lastRecord(t, cw, 3); r.msg != "kept" {
+		t.Errorf("third record=%q, want the sampled-out request dropped", r.msg)
+	}
+}
+
+// dropAll samples out every request.
+type dropAll struct{}
+
+func (dropAll) ShouldLog(string) bool { return false }
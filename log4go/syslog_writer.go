@@ -0,0 +1,414 @@
+package log4go
+
+import (
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Syslog message formats accepted by SyslogWriterOptions.Format.
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+)
+
+// defaultSyslogSDID is the SD-ID of the structured-data element carrying the
+// record fields. "@32473" is the private enterprise number RFC 5612 reserves
+// for documentation/examples; override SDID with your own PEN in production.
+const defaultSyslogSDID = "log4go@32473"
+
+// syslogFacilities maps facility names (RFC 5424 §6.2.1) to their codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"ntp": 12, "security": 13, "console": 14, "solaris-cron": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// ParseSyslogFacility resolves a facility name ("local0", "daemon", ...) to its
+// code. Unknown names fall back to "user" (1) with a log line, mirroring
+// ParseOverflowPolicy.
+func ParseSyslogFacility(s string) int {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return syslogFacilities["user"]
+	}
+	if f, ok := syslogFacilities[name]; ok {
+		return f
+	}
+	log.Printf("[log4go] unknown syslog facility %q, use user", s)
+	return syslogFacilities["user"]
+}
+
+// SyslogWriterOptions configures a SyslogWriter.
+//
+// The queue knobs (BufferSize / OverflowPolicy / SpillSize / Timeout /
+// ReconnectBackoff) are NetWriter's: SyslogWriter runs on NetWriter's async
+// daemon with the same lazy dial, reconnect-on-error and spill behavior.
+type SyslogWriterOptions struct {
+	// Enable gates registration (used by config plumbing; the writer ignores it).
+	Enable bool `json:"enable" mapstructure:"enable"`
+	// Network is "udp" (default), "tcp", "unix" or "unixgram".
+	Network string `json:"network" mapstructure:"network"`
+	// Address is host:port for udp/tcp, or the socket path for unix/unixgram
+	// (default "/dev/log").
+	Address string `json:"address" mapstructure:"address"`
+	// Level is the text level flag (default DEBUG).
+	Level string `json:"level" mapstructure:"level"`
+	// Format is "rfc5424" (default) or "rfc3164" (BSD syslog, for legacy relays).
+	Format string `json:"format" mapstructure:"format"`
+	// Facility is the facility name: kern, user (default), mail, daemon, auth,
+	// syslog, lpr, news, uucp, cron, authpriv, ftp, local0..local7, ...
+	Facility string `json:"facility" mapstructure:"facility"`
+	// Hostname is the HOSTNAME header (default os.Hostname()).
+	Hostname string `json:"hostname" mapstructure:"hostname"`
+	// AppName is the APP-NAME (RFC 5424) / TAG (RFC 3164) header (default the
+	// executable base name).
+	AppName string `json:"app_name" mapstructure:"app_name"`
+	// ProcID is the PROCID header (default the process id).
+	ProcID string `json:"proc_id" mapstructure:"proc_id"`
+	// MsgID is the RFC 5424 MSGID header (default "-").
+	MsgID string `json:"msg_id" mapstructure:"msg_id"`
+	// SDID is the RFC 5424 SD-ID of the element carrying the record fields
+	// (default "log4go@32473"). Use your own enterprise number in production.
+	SDID string `json:"sd_id" mapstructure:"sd_id"`
+	// BufferSize is the async channel capacity (<=0 -> 1024).
+	BufferSize int `json:"buffer_size" mapstructure:"buffer_size"`
+	// OverflowPolicy: "drop"(default)|"block"|"spill".
+	OverflowPolicy string `json:"overflow_policy" mapstructure:"overflow_policy"`
+	// SpillSize: ring capacity (records) when policy == "spill".
+	SpillSize int `json:"spill_size" mapstructure:"spill_size"`
+	// Timeout is the per-write deadline on the conn (<=0 -> 3s).
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+	// ReconnectBackoff is the wait between dial attempts (<=0 -> 1s).
+	ReconnectBackoff time.Duration `json:"reconnect_backoff" mapstructure:"reconnect_backoff"`
+}
+
+// SyslogWriter ships records to a syslog receiver (rsyslog, syslog-ng, a SIEM
+// relay) in RFC 5424 or RFC 3164 format over udp, tcp, unix or unixgram.
+//
+// log4go levels EMERGENCY..DEBUG are numerically the syslog severities 0..7, so
+// PRI = facility*8 + level (TRACE is sent as debug). Under RFC 5424 the record
+// fields and caller become one structured-data element
+// ([SDID key="value" ...]); under RFC 3164, which has no structured data, they
+// are appended to the message as logfmt pairs.
+//
+// Framing follows the transport: tcp uses octet-counting (RFC 6587 §3.4.1,
+// "LEN SP MSG") so messages may contain newlines; unix stream sockets get a
+// trailing LF; datagram transports send one message per packet.
+//
+// Delivery is NetWriter's: Write enqueues, the daemon dials lazily, a write
+// error closes the conn (the record is dropped and counted) and the next record
+// re-dials; "spill" parks overflow in the ring.
+type SyslogWriter struct {
+	q *NetWriter
+
+	rfc3164  bool
+	octet    bool // tcp: octet-counting framing
+	lf       bool // unix stream: trailing LF framing
+	facility int
+	hostname string
+	appName  string
+	procID   string
+	msgID    string
+	sdID     string
+}
+
+// NewSyslogWriter builds a SyslogWriter. Like NetWriter it does not dial until
+// the first record, so a down receiver does not fail Register.
+func NewSyslogWriter(options SyslogWriterOptions) *SyslogWriter {
+	network := strings.ToLower(strings.TrimSpace(options.Network))
+	if network == "" {
+		network = "udp"
+	}
+	address := options.Address
+	if address == "" && strings.HasPrefix(network, "unix") {
+		address = "/dev/log"
+	}
+	w := &SyslogWriter{
+		rfc3164:  strings.EqualFold(strings.TrimSpace(options.Format), SyslogRFC3164),
+		octet:    strings.HasPrefix(network, "tcp"),
+		lf:       network == "unix",
+		facility: ParseSyslogFacility(options.Facility),
+		hostname: options.Hostname,
+		appName:  options.AppName,
+		procID:   options.ProcID,
+		msgID:    options.MsgID,
+		sdID:     options.SDID,
+	}
+	if w.hostname == "" {
+		w.hostname, _ = os.Hostname()
+	}
+	if w.appName == "" {
+		w.appName = filepath.Base(os.Args[0])
+	}
+	if w.procID == "" {
+		w.procID = strconv.Itoa(os.Getpid())
+	}
+	if w.sdID == "" {
+		w.sdID = defaultSyslogSDID
+	}
+	// RFC 5424 §6: header fields are PRINTUSASCII without spaces, length-capped.
+	w.hostname = syslogHeaderField(w.hostname, 255)
+	w.appName = syslogHeaderField(w.appName, 48)
+	w.procID = syslogHeaderField(w.procID, 128)
+	w.msgID = syslogHeaderField(w.msgID, 32)
+	w.sdID = syslogSDName(w.sdID)
+
+	w.q = NewNetWriter(NetWriterOptions{
+		Network:          network,
+		Address:          address,
+		Level:            options.Level,
+		BufferSize:       options.BufferSize,
+		OverflowPolicy:   options.OverflowPolicy,
+		SpillSize:        options.SpillSize,
+		Timeout:          options.Timeout,
+		ReconnectBackoff: options.ReconnectBackoff,
+	})
+	w.q.encode = w.encode
+	return w
+}
+
+// Init starts the async daemon (no dial; see NetWriter.Init).
+func (w *SyslogWriter) Init() error { return w.q.Init() }
+
+// Write enqueues r under the overflow policy (see NetWriter.Write).
+func (w *SyslogWriter) Write(r *Record) error { return w.q.Write(r) }
+
+// Name returns WriterNameSyslog.
+func (w *SyslogWriter) Name() string { return WriterNameSyslog }
+
+// SetLevel changes the writer's level at runtime (LevelSetter).
+func (w *SyslogWriter) SetLevel(level int) { w.q.SetLevel(level) }
+
+// Level returns the writer's current level.
+func (w *SyslogWriter) Level() int { return w.q.Level() }
+
+// Pause drops incoming records without closing the conn.
+func (w *SyslogWriter) Pause() { w.q.Pause() }
+
+// Resume restores delivery after Pause.
+func (w *SyslogWriter) Resume() { w.q.Resume() }
+
+// Paused reports whether the writer is currently paused.
+func (w *SyslogWriter) Paused() bool { return w.q.Paused() }
+
+// Flush is a no-op; each record is written by the daemon as it is dequeued.
+func (w *SyslogWriter) Flush() error { return nil }
+
+// Stop drains the queue and spill store, then closes the conn.
+func (w *SyslogWriter) Stop() { w.q.Stop() }
+
+// Metrics returns the underlying NetWriter counters.
+func (w *SyslogWriter) Metrics() NetWriterMetrics { return w.q.Metrics() }
+
+// syslogSeverity maps a log4go level to the syslog severity (TRACE -> debug).
+func syslogSeverity(level int) int {
+	if level > DEBUG {
+		return DEBUG
+	}
+	return level
+}
+
+// encode renders one framed syslog message.
+func (w *SyslogWriter) encode(r *Record) []byte {
+	msg := make([]byte, 0, 160+len(r.msg)+len(r.fields)*24)
+	if w.rfc3164 {
+		msg = w.appendRFC3164(msg, r)
+	} else {
+		msg = w.appendRFC5424(msg, r)
+	}
+	switch {
+	case w.octet:
+		out := make([]byte, 0, len(msg)+8)
+		out = strconv.AppendInt(out, int64(len(msg)), 10)
+		out = append(out, ' ')
+		return append(out, msg...)
+	case w.lf:
+		return append(msg, '\n')
+	}
+	return msg
+}
+
+func syslogTime(r *Record) time.Time {
+	if r.unixNano != 0 {
+		return time.Unix(0, r.unixNano)
+	}
+	return time.Now()
+}
+
+func (w *SyslogWriter) appendPRI(buf []byte, r *Record) []byte {
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(w.facility*8+syslogSeverity(r.level)), 10)
+	return append(buf, '>')
+}
+
+// appendRFC5424 renders
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SDID k="v" ...] MSG
+func (w *SyslogWriter) appendRFC5424(buf []byte, r *Record) []byte {
+	buf = w.appendPRI(buf, r)
+	buf = append(buf, '1', ' ')
+	buf = syslogTime(r).AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	buf = append(buf, ' ')
+	buf = append(buf, w.hostname...)
+	buf = append(buf, ' ')
+	buf = append(buf, w.appName...)
+	buf = append(buf, ' ')
+	buf = append(buf, w.procID...)
+	buf = append(buf, ' ')
+	buf = append(buf, w.msgID...)
+	buf = append(buf, ' ')
+	if len(r.fields) == 0 && r.file == "" {
+		buf = append(buf, '-')
+	} else {
+		buf = append(buf, '[')
+		buf = append(buf, w.sdID...)
+		if r.file != "" {
+			buf = appendSDParam(buf, strField("file", r.file))
+		}
+		for _, f := range r.fields {
+			buf = appendSDParam(buf, f)
+		}
+		buf = append(buf, ']')
+	}
+	if r.msg != "" {
+		buf = append(buf, ' ')
+		buf = append(buf, r.msg...)
+	}
+	return buf
+}
+
+// appendRFC3164 renders
+//
+//	<PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG [file=...] [k=v ...]
+func (w *SyslogWriter) appendRFC3164(buf []byte, r *Record) []byte {
+	buf = w.appendPRI(buf, r)
+	buf = syslogTime(r).AppendFormat(buf, time.Stamp)
+	buf = append(buf, ' ')
+	buf = append(buf, w.hostname...)
+	buf = append(buf, ' ')
+	buf = append(buf, w.appName...)
+	buf = append(buf, '[')
+	buf = append(buf, w.procID...)
+	buf = append(buf, "]: "...)
+	buf = append(buf, r.msg...)
+	if r.file != "" {
+		buf = append(buf, " file="...)
+		buf = appendLogfmtValue(buf, r.file)
+	}
+	for _, f := range r.fields {
+		buf = appendFieldLogfmt(buf, f)
+	}
+	return buf
+}
+
+// appendSDParam appends ` name="value"`: the name is sanitized to an SD-NAME and
+// the value escapes '"', '\' and ']' (RFC 5424 §6.3.3).
+func appendSDParam(buf []byte, f field) []byte {
+	buf = append(buf, ' ')
+	buf = append(buf, syslogSDName(f.key)...)
+	buf = append(buf, '=', '"')
+	buf = appendSDValue(buf, f)
+	return append(buf, '"')
+}
+
+func appendSDValue(buf []byte, f field) []byte {
+	switch f.kind {
+	case kindString, kindBytes: // bytes: base64, as in the JSON/logfmt forms
+		return appendSDEscaped(buf, f.str)
+	case kindInt, kindInt64, kindDuration:
+		return strconv.AppendInt(buf, f.i, 10)
+	case kindUint:
+		return strconv.AppendUint(buf, uint64(f.i), 10)
+	case kindBool:
+		return strconv.AppendBool(buf, f.i == 1)
+	case kindFloat64:
+		return strconv.AppendFloat(buf, math.Float64frombits(uint64(f.i)), 'g', -1, 64)
+	case kindTime:
+		return appendISOTimeUTC(buf, f.i)
+	case kindError:
+		if e, ok := f.any.(error); ok {
+			if s, ok := safeErrorString(e); ok {
+				return appendSDEscaped(buf, s)
+			}
+		}
+		return buf
+	default:
+		if s, ok := f.any.(string); ok {
+			return appendSDEscaped(buf, s)
+		}
+		if b, ok := safeJSONMarshal(f.any); ok {
+			return appendSDEscaped(buf, string(b))
+		}
+		return buf
+	}
+}
+
+func appendSDEscaped(buf []byte, s string) []byte {
+	for i := range len(s) {
+		switch c := s[i]; c {
+		case '"', '\\', ']':
+			buf = append(buf, '\\', c)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// syslogSDName sanitizes an SD-ID / PARAM-NAME: PRINTUSASCII except '=', ' ',
+// ']' and '"', at most 32 characters. Invalid bytes become '_'.
+func syslogSDName(s string) string {
+	if s == "" {
+		return "_"
+	}
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	var b []byte
+	for i := range len(s) {
+		c := s[i]
+		if c > ' ' && c < 0x7f && c != '=' && c != ']' && c != '"' {
+			continue
+		}
+		if b == nil {
+			b = []byte(s)
+		}
+		b[i] = '_'
+	}
+	if b == nil {
+		return s
+	}
+	return string(b)
+}
+
+// syslogHeaderField sanitizes a header field: empty -> NILVALUE "-", bytes
+// outside PRINTUSASCII -> '_', truncated to maxLen.
+func syslogHeaderField(s string, maxLen int) string {
+	if s == "" {
+		return "-"
+	}
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	return strings.Map(func(c rune) rune {
+		if c > ' ' && c < 0x7f {
+			return c
+		}
+		return '_'
+	}, s)
+}
+
+// compile-time: SyslogWriter implements the standard writer control surface.
+var (
+	_ Writer  = (*SyslogWriter)(nil)
+	_ Stopper = (*SyslogWriter)(nil)
+	_ Pauser  = (*SyslogWriter)(nil)
+	_ Named   = (*SyslogWriter)(nil)
+)
@@ -0,0 +1,255 @@
+package log4go
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func syslogTestRecord() *Record {
+	return &Record{
+		level: ERROR, msg: "bid failed", file: "bidder.go:42",
+		unixNano: time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC).UnixNano(),
+		fields: []field{
+			strField("req_id", `a"b]c\d`),
+			intField("count", 3),
+			boolField("ok", false),
+		},
+	}
+}
+
+func newTestSyslogWriter(opts SyslogWriterOptions) *SyslogWriter {
+	opts.Hostname = "host1"
+	opts.AppName = "bidder"
+	opts.ProcID = "77"
+	return NewSyslogWriter(opts)
+}
+
+func Test_SyslogWriter_RFC5424Encoding(t *testing.T) {
+	w := newTestSyslogWriter(SyslogWriterOptions{Facility: "local0", MsgID: "BID"})
+	got := string(w.encode(syslogTestRecord()))
+	ts := time.Unix(0, syslogTestRecord().unixNano).Format("2006-01-02T15:04:05.000000Z07:00")
+	// local0(16)*8 + err(3) = 131
+	want := `<131>1 ` + ts + ` host1 bidder 77 BID [log4go@32473 file="bidder.go:42" req_id="a\"b\]c\\d" count="3" ok="false"] bid failed`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	// no fields, no caller -> NILVALUE structured data; TRACE -> debug(7).
+	got = string(w.encode(&Record{level: TRACE, msg: "m", unixNano: 1}))
+	if !strings.HasPrefix(got, "<135>1 ") || !strings.HasSuffix(got, " 77 BID - m") {
+		t.Errorf("got %s", got)
+	}
+}
+
+func Test_SyslogWriter_RFC3164Encoding(t *testing.T) {
+	w := newTestSyslogWriter(SyslogWriterOptions{Format: "RFC3164", Facility: "daemon"})
+	r := syslogTestRecord()
+	got := string(w.encode(r))
+	ts := time.Unix(0, r.unixNano).Format(time.Stamp)
+	// daemon(3)*8 + err(3) = 27
+	want := `<27>` + ts + ` host1 bidder[77]: bid failed file=bidder.go:42 req_id="a\"b]c\\d" count=3 ok=false`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func Test_SyslogWriter_Sanitize(t *testing.T) {
+	w := NewSyslogWriter(SyslogWriterOptions{Hostname: "my host", AppName: "", SDID: `bad id="x"`})
+	if w.hostname != "my_host" {
+		t.Errorf("hostname=%q", w.hostname)
+	}
+	if w.sdID != "bad_id__x_" {
+		t.Errorf("sdID=%q", w.sdID)
+	}
+	if w.msgID != "-" {
+		t.Errorf("msgID=%q want NILVALUE", w.msgID)
+	}
+	got := string(w.encode(&Record{level: INFO, msg: "m", unixNano: 1, fields: []field{strField("a b=c", "v")}}))
+	if !strings.Contains(got, ` a_b_c="v"]`) {
+		t.Errorf("param name not sanitized: %s", got)
+	}
+	if ParseSyslogFacility("LOCAL7") != 23 || ParseSyslogFacility("") != 1 || ParseSyslogFacility("bogus") != 1 {
+		t.Error("ParseSyslogFacility mapping")
+	}
+}
+
+func Test_SyslogWriter_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w := newTestSyslogWriter(SyslogWriterOptions{Address: pc.LocalAddr().String(), Facility: "local3"})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	_ = w.Write(syslogTestRecord())
+
+	_ = pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(buf[:n])
+	if !strings.HasPrefix(got, "<155>1 ") || !strings.HasSuffix(got, "] bid failed") {
+		t.Errorf("datagram=%q", got)
+	}
+}
+
+// readOctetFrame reads one RFC 6587 octet-counted frame.
+func readOctetFrame(br *bufio.Reader) (string, error) {
+	lenStr, err := br.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(lenStr, " "))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(br, msg)
+	return string(msg), err
+}
+
+func Test_SyslogWriter_TCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	frames := make(chan string, 8)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		br := bufio.NewReader(c)
+		for {
+			msg, err := readOctetFrame(br)
+			if err != nil {
+				return
+			}
+			frames <- msg
+		}
+	}()
+
+	w := newTestSyslogWriter(SyslogWriterOptions{Network: "tcp", Address: ln.Addr().String()})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Write(&Record{level: WARNING, msg: "line one\nline two", unixNano: 1})
+	_ = w.Write(syslogTestRecord())
+	w.Stop()
+
+	for i, want := range []string{"line one\nline two", "bid failed"} {
+		select {
+		case got := <-frames:
+			if !strings.HasSuffix(got, want) {
+				t.Errorf("frame %d=%q want suffix %q", i, got, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("frame %d not received", i)
+		}
+	}
+	if m := w.Metrics(); m.Sent != 2 {
+		t.Errorf("Sent=%d want 2", m.Sent)
+	}
+}
+
+func Test_SyslogWriter_TCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	frames := make(chan string, 8)
+	go func() {
+		// first conn: read one frame then hang up; second conn: keep reading.
+		for round := 0; ; round++ {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			br := bufio.NewReader(c)
+			for {
+				msg, err := readOctetFrame(br)
+				if err != nil {
+					break
+				}
+				frames <- msg
+				if round == 0 {
+					break
+				}
+			}
+			_ = c.Close()
+		}
+	}()
+
+	w := newTestSyslogWriter(SyslogWriterOptions{Network: "tcp", Address: ln.Addr().String()})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	_ = w.Write(&Record{level: INFO, msg: "first", unixNano: 1})
+	if got := <-frames; !strings.HasSuffix(got, "first") {
+		t.Fatalf("frame=%q", got)
+	}
+	// the peer has closed; keep writing until a write fails, the conn is
+	// recycled and a later record arrives over a fresh dial.
+	deadline := time.After(5 * time.Second)
+	for i := 0; ; i++ {
+		_ = w.Write(&Record{level: INFO, msg: "again-" + strconv.Itoa(i), unixNano: 1})
+		select {
+		case got := <-frames:
+			if !strings.Contains(got, "again-") {
+				t.Fatalf("frame=%q", got)
+			}
+			if w.Metrics().Errored == 0 {
+				t.Error("expected a write error before the reconnect")
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no record after reconnect")
+		}
+	}
+}
+
+func Test_SyslogWriter_Unixgram(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "log.sock")
+	pc, err := net.ListenPacket("unixgram", sock)
+	if err != nil {
+		t.Skipf("unixgram unsupported: %v", err)
+	}
+	defer pc.Close()
+	w := newTestSyslogWriter(SyslogWriterOptions{Network: "unixgram", Address: sock, Format: SyslogRFC3164})
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	_ = w.Write(&Record{level: NOTICE, msg: "local", unixNano: 1})
+
+	_ = pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+	// user(1)*8 + notice(5) = 13
+	if got := string(buf[:n]); !strings.HasPrefix(got, "<13>") || !strings.HasSuffix(got, "bidder[77]: local") {
+		t.Errorf("datagram=%q", got)
+	}
+	if w.Name() != WriterNameSyslog {
+		t.Errorf("Name=%q", w.Name())
+	}
+}
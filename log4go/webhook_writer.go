@@ -29,7 +29,7 @@ import (
 // automatically by log4go.Close() (Logger.Close invokes io.Closer on writers).
 type WebhookWriter struct {
 	sink          AlertSink
-	level         int
+	level         writerLevel
 	filter        Filter
 	formatter     RecordWebhookFormatter
 	rateFormatter RateWebhookFormatter
@@ -96,7 +96,7 @@ func NewWebhookWriter(sink AlertSink, opts WebhookWriterOptions) *WebhookWriter
 	}
 	return &WebhookWriter{
 		sink:          sink,
-		level:         lvl,
+		level:         writerLevel(lvl),
 		filter:        opts.Filter,
 		formatter:     f,
 		rateFormatter: opts.RateFormatter,
@@ -104,6 +104,13 @@ func NewWebhookWriter(sink AlertSink, opts WebhookWriterOptions) *WebhookWriter
 	}
 }
 
+// SetLevel changes the writer's level at runtime (LevelSetter); the next
+// record observes it.
+func (w *WebhookWriter) SetLevel(level int) { w.level.store(level) }
+
+// Level returns the writer's current level.
+func (w *WebhookWriter) Level() int { return w.level.load() }
+
 // Init is a no-op: the sink owns its own async daemon (started at construction).
 func (w *WebhookWriter) Init() error { return nil }
 
@@ -111,7 +118,7 @@ func (w *WebhookWriter) Init() error { return nil }
 // sink. It never returns an error — a failing webhook is the sink's concern and
 // is handled (retry/drop) inside it, so the log path is never disturbed.
 func (w *WebhookWriter) Write(r *Record) error {
-	if r.level > w.level { // below threshold
+	if r.filteredBy(w.level.load()) { // below threshold
 		w.bump(false)
 		return nil
 	}
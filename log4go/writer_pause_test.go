@@ -154,3 +154,34 @@ func TestWriter_PauseResume_Concurrent(t *testing.T) {
 	stop.Store(true)
 	wg.Wait()
 }
+
+// TestWriter_SetLevel covers the runtime LevelSetter on every built-in writer
+// and Logger.SetWriterLevel by name.
+func TestWriter_SetLevel(t *testing.T) {
+	for _, w := range []Writer{
+		&ConsoleWriter{level: DEBUG}, &FileWriter{level: DEBUG}, &KafkaWriter{level: DEBUG},
+		&NetWriter{level: DEBUG}, &IOWriter{w: io.Discard, level: DEBUG},
+		NewOTLPWriter(OTLPWriterOptions{}), NewSyslogWriter(SyslogWriterOptions{}),
+		NewJournaldWriter(JournaldWriterOptions{}), NewWebhookWriter(nil, WebhookWriterOptions{}),
+	} {
+		s := w.(LevelSetter)
+		s.SetLevel(WARNING)
+		if got := w.(interface{ Level() int }).Level(); got != WARNING {
+			t.Errorf("%T: Level=%d want WARNING", w, got)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	iw := &IOWriter{w: buf, level: DEBUG}
+	l := newLoggerWithRecords(make(chan *Record, 8))
+	defer l.Close()
+	l.Register(iw)
+	if !l.SetWriterLevel(WriterNameIO, ERROR) || l.SetWriterLevel("nope", ERROR) {
+		t.Fatal("SetWriterLevel by name")
+	}
+	_ = iw.Write(&Record{level: INFO, msg: "filtered"})
+	_ = iw.Write(&Record{level: ERROR, msg: "kept"})
+	if bytes.Contains(buf.Bytes(), []byte("filtered")) || !bytes.Contains(buf.Bytes(), []byte("kept")) {
+		t.Errorf("output=%q", buf.String())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -113,6 +114,83 @@ func RateLimit(allow AllowFunc, retryAfter int) func(http.Handler) http.Handler
 	}
 }
 
+// --- Concurrency Limit ---
+
+// ErrOverloaded is what ConcurrencyLimit reports to done for a response that
+// signals overload (429, 503 or 504).
+var ErrOverloaded = errors.New("middleware: overloaded response")
+
+// AcquireFunc admits a request or returns an error to reject it (e.g.,
+// limiter.ConcurrencyLimiter.Admit). On admission, done must be called once
+// when the request ends: with nil if it completed, context.Canceled if its
+// outcome says nothing about load, or another error if it was dropped for
+// overload.
+type AcquireFunc func(ctx context.Context) (done func(err error), err error)
+
+// ConcurrencyLimit returns middleware that admits each request through
+// acquire and rejects the rest with 503 Service Unavailable. The outcome
+// passed to done is derived from the response: 429/503/504 report
+// ErrOverloaded, a request whose context ended reports its error, anything
+// else (4xx and 500 included — application errors, not overload) reports nil.
+// A handler that panics reports context.Canceled before the panic propagates.
+//
+// acquire must be non-nil; ConcurrencyLimit panics at construction otherwise
+// (see RateLimit).
+func ConcurrencyLimit(acquire AcquireFunc) func(http.Handler) http.Handler {
+	if acquire == nil {
+		panic("middleware: ConcurrencyLimit requires a non-nil AcquireFunc")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			done, err := acquire(r.Context())
+			if err != nil {
+				http.Error(w, "concurrency limit exceeded", http.StatusServiceUnavailable)
+				return
+			}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					done(context.Canceled)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+			completed = true
+			switch {
+			case sw.status == http.StatusTooManyRequests,
+				sw.status == http.StatusServiceUnavailable,
+				sw.status == http.StatusGatewayTimeout:
+				done(ErrOverloaded)
+			default:
+				done(r.Context().Err())
+			}
+		})
+	}
+}
+
+// statusWriter records the response status for ConcurrencyLimit.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (Flush,
+// deadlines, Hijack).
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
 // --- CORS ---
 
 // CORSConfig holds CORS policy. Zero value = permissive (all origins, standard
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
@@ -71,6 +73,80 @@ func TestRateLimit_RetryAfter(t *testing.T) {
 	}
 }
 
+func TestConcurrencyLimit_Outcomes(t *testing.T) {
+	var (
+		inflight atomic.Int32
+		reported []error
+	)
+	acquire := func(ctx context.Context) (func(error), error) {
+		if inflight.Add(1) > 1 {
+			inflight.Add(-1)
+			return nil, errors.New("full")
+		}
+		return func(err error) {
+			inflight.Add(-1)
+			reported = append(reported, err)
+		}, nil
+	}
+	status := http.StatusOK
+	h := ConcurrencyLimit(acquire)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch status {
+		case -1:
+			panic(http.ErrAbortHandler)
+		case 0:
+			// Another request arrives while this one is in flight.
+			rec := httptest.NewRecorder()
+			ConcurrencyLimit(acquire)(http.NotFoundHandler()).ServeHTTP(rec, r)
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("over the limit: %d want 503", rec.Code)
+			}
+			w.Write([]byte("ok"))
+		default:
+			w.WriteHeader(status)
+		}
+	}))
+	serve := func(ctx context.Context) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+		return rec.Code
+	}
+
+	for _, status = range []int{0, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		serve(context.Background())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	status = http.StatusOK
+	serve(ctx)
+	status = -1
+	func() {
+		defer func() { recover() }()
+		serve(context.Background())
+	}()
+
+	want := []error{nil, nil, ErrOverloaded, ErrOverloaded, context.Canceled, context.Canceled}
+	if len(reported) != len(want) {
+		t.Fatalf("reported %v want %v", reported, want)
+	}
+	for i := range want {
+		if reported[i] != want[i] {
+			t.Errorf("request %d: reported %v want %v", i, reported[i], want[i])
+		}
+	}
+	if inflight.Load() != 0 {
+		t.Errorf("%d slots leaked", inflight.Load())
+	}
+}
+
+func TestConcurrencyLimit_NilAcquireFuncPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("ConcurrencyLimit(nil) should panic at construction")
+		}
+	}()
+	_ = ConcurrencyLimit(nil)
+}
+
 func TestCORS_Preflight(t *testing.T) {
 	h := CORS(CORSConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Fatal("preflight should not reach handler")
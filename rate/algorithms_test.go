@@ -0,0 +1,141 @@
+package rate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/v8fg/kit4go/rate"
+)
+
+func TestSlidingLog(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(6000, 0)}
+	l, _ := newLimiter(t, clk)
+	limit := rate.SlidingLog(3, time.Second)
+	ctx := context.Background()
+
+	r, err := l.AllowN(ctx, "k", limit, 2)
+	require.NoError(t, err)
+	require.Equal(t, rate.Result{Allowed: true, Remaining: 1, ResetAfter: time.Second}, r)
+	clk.t = clk.t.Add(400 * time.Millisecond)
+	r, _ = l.Allow(ctx, "k", limit)
+	require.True(t, r.Allowed)
+
+	// Full: the oldest entry leaves the trailing second 600ms from now.
+	r, err = l.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	require.Equal(t, rate.Result{Allowed: false, Remaining: 0, RetryAfter: 600 * time.Millisecond, ResetAfter: time.Second}, r)
+
+	clk.t = clk.t.Add(600 * time.Millisecond)
+	r, _ = l.Allow(ctx, "k", limit)
+	require.Equal(t, rate.Result{Allowed: true, Remaining: 1, ResetAfter: time.Second}, r)
+	r, _ = l.AllowN(ctx, "k", limit, 2)
+	require.False(t, r.Allowed)
+	require.Equal(t, 400*time.Millisecond, r.RetryAfter, "the two entries ahead of it must expire")
+
+	r, _ = l.AllowN(ctx, "k", limit, 4)
+	require.False(t, r.Allowed)
+	require.Equal(t, time.Duration(-1), r.RetryAfter, "more than the limit never fits")
+}
+
+func TestFixedWindow(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(6000, 0).Add(10 * time.Second)} // 6000 is minute-aligned
+	l, mr := newLimiter(t, clk)
+	limit := rate.FixedWindow(2, time.Minute)
+	ctx := context.Background()
+
+	r, err := l.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	require.Equal(t, rate.Result{Allowed: true, Remaining: 1, ResetAfter: 50 * time.Second}, r)
+	require.Equal(t, 50*time.Second, mr.TTL("k"))
+	l.Allow(ctx, "k", limit)
+	r, _ = l.Allow(ctx, "k", limit)
+	require.Equal(t, rate.Result{Allowed: false, Remaining: 0, RetryAfter: 50 * time.Second, ResetAfter: 50 * time.Second}, r)
+
+	// A new window starts at the minute, whatever was spent before it.
+	clk.t = clk.t.Add(50 * time.Second)
+	r, _ = l.Allow(ctx, "k", limit)
+	require.Equal(t, rate.Result{Allowed: true, Remaining: 1, ResetAfter: time.Minute}, r)
+}
+
+func TestGCRAResetAfter(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(6000, 0)}
+	l, _ := newLimiter(t, clk)
+	r, err := l.AllowN(context.Background(), "k", rate.PerSecond(10, 10), 3)
+	require.NoError(t, err)
+	require.Equal(t, 300*time.Millisecond, r.ResetAfter)
+	r, _ = l.AllowN(context.Background(), "k", rate.PerSecond(10, 10), 11)
+	require.Equal(t, time.Duration(-1), r.RetryAfter)
+}
+
+func TestAllowMulti(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(6000, 0)}
+	l, mr := newLimiter(t, clk)
+	limits := []rate.Limit{
+		rate.PerSecond(10, 10),
+		rate.FixedWindow(3, 24*time.Hour),
+		rate.SlidingLog(5, time.Minute),
+	}
+	ctx := context.Background()
+
+	for range 3 {
+		r, err := l.AllowMulti(ctx, "partner:{7}", limits)
+		require.NoError(t, err)
+		require.True(t, r.Allowed)
+		require.Equal(t, -1, r.Denied)
+	}
+	require.ElementsMatch(t, []string{
+		"partner:{7}:gcra:1000",
+		"partner:{7}:fixed_window:86400000",
+		"partner:{7}:sliding_log:60000",
+	}, mr.Keys())
+
+	// The daily quota rejects; the others would allow but are not charged.
+	r, err := l.AllowMulti(ctx, "partner:{7}", limits)
+	require.NoError(t, err)
+	require.False(t, r.Allowed)
+	require.Equal(t, 1, r.Denied)
+	require.Equal(t, 6000*time.Second%(24*time.Hour), 24*time.Hour-r.RetryAfter)
+	require.Equal(t, []bool{true, false, true}, []bool{r.Results[0].Allowed, r.Results[1].Allowed, r.Results[2].Allowed})
+	require.Equal(t, 7, r.Results[0].Remaining)
+	require.Equal(t, 2, r.Results[2].Remaining)
+
+	// Several reject: RetryAfter is the longest wait; one that never fits wins.
+	r, _ = l.AllowMultiN(ctx, "partner:{7}", limits, 3)
+	require.Equal(t, 1, r.Denied)
+	require.Equal(t, r.Results[1].RetryAfter, r.RetryAfter)
+	r, _ = l.AllowMultiN(ctx, "partner:{7}", limits, 6)
+	require.Equal(t, 1, r.Denied)
+	require.Equal(t, time.Duration(-1), r.RetryAfter)
+	require.False(t, r.Results[2].Allowed)
+}
+
+func TestAllowMultiInvalid(t *testing.T) {
+	l, _ := newLimiter(t, nil)
+	ctx := context.Background()
+	for name, limits := range map[string][]rate.Limit{
+		"empty":      nil,
+		"duplicate":  {rate.SlidingLog(1, time.Second), rate.SlidingLog(5, time.Second)},
+		"algorithm":  {{Rate: 1, Period: time.Second, Burst: 1, Algorithm: "leaky"}},
+		"sub-ms":     {rate.FixedWindow(1, time.Microsecond)},
+		"zero rate":  {rate.FixedWindow(0, time.Second)},
+		"gcra burst": {{Rate: 1, Period: time.Second}},
+	} {
+		_, err := l.AllowMulti(ctx, "k", limits)
+		require.ErrorIs(t, err, rate.ErrLimitInvalid, name)
+	}
+	_, err := l.AllowMultiN(ctx, "k", []rate.Limit{rate.FixedWindow(1, time.Second)}, 0)
+	require.ErrorIs(t, err, rate.ErrLimitInvalid)
+
+	// Same period, different algorithms: distinct keys, accepted.
+	r, err := l.AllowMulti(ctx, "k", []rate.Limit{rate.PerSecond(1, 1), rate.SlidingLog(1, time.Second)})
+	require.NoError(t, err)
+	require.True(t, r.Allowed)
+
+	ctxDone, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = l.AllowMulti(ctxDone, "k", []rate.Limit{rate.FixedWindow(1, time.Second)})
+	require.ErrorIs(t, err, context.Canceled)
+}
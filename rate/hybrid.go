@@ -0,0 +1,415 @@
+package rate
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// HybridOptions configures a [Hybrid]. Pass it to [NewHybrid].
+type HybridOptions struct {
+	// Batch is how many tokens one Redis call leases for a key (<= 0 selects
+	// a tenth of the limit's capacity — Burst for GCRA, Rate for windows —
+	// at least 1). It is capped at the capacity. Without Local the leased
+	// tokens are not paced: a key may spend a whole batch, plus MaxOvershoot,
+	// in one instant.
+	Batch int `json:"batch" mapstructure:"batch"`
+
+	// Local builds the in-process limiter that paces a key's leased tokens,
+	// called once per key with the key's limit and batch — typically a
+	// limiter.NewLimiter token bucket at the limit's rate with Burst batch.
+	// A request it denies is denied without touching the lease. nil
+	// (default) serves leased tokens unpaced.
+	Local func(limit Limit, batch int) LocalLimiter `json:"-" mapstructure:"-"`
+
+	// LowWater starts an asynchronous refill once a key's local tokens fall
+	// to it (<= 0 selects Batch/4), so requests rarely wait on Redis.
+	LowWater int `json:"low_water" mapstructure:"low_water"`
+
+	// MaxOvershoot is how many tokens a key may spend on credit when its
+	// local tokens run out and Redis has not denied it: the request is served
+	// at once and the debt is repaid from the next lease. 0 (default) waits
+	// for a synchronous lease instead. Across N instances the global limit is
+	// exceeded by at most N × MaxOvershoot.
+	MaxOvershoot int `json:"max_overshoot" mapstructure:"max_overshoot"`
+
+	// Timeout bounds an asynchronous refill's Redis call (default 1s).
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+}
+
+// LocalLimiter paces a key's leased tokens in process ([HybridOptions.Local]).
+// The limiters of github.com/v8fg/kit4go/limiter satisfy it.
+type LocalLimiter interface {
+	TryAcquire(n int) bool
+	Close()
+}
+
+// HybridMetrics is the observability snapshot returned by [Hybrid.Metrics].
+type HybridMetrics struct {
+	// LocalHits counts requests served from leased tokens without a Redis
+	// call; Overshoot those served on credit (see MaxOvershoot).
+	LocalHits uint64
+	Overshoot uint64
+
+	// RedisCalls counts lease and refund script calls; LeaseErrors the
+	// asynchronous leases that failed.
+	RedisCalls  uint64
+	LeaseErrors uint64
+
+	// Allowed and Denied count decisions, Paced the denials by the local
+	// limiter (see Local); Leased and Refunded count tokens taken from and
+	// returned to Redis.
+	Allowed  uint64
+	Denied   uint64
+	Paced    uint64
+	Leased   uint64
+	Refunded uint64
+
+	// Keys is the number of keys holding local state.
+	Keys int
+}
+
+// Hybrid serves a [Limiter]'s limits from tokens leased in batches, so most
+// decisions are an atomic add in process instead of a Redis round-trip. Each
+// key leases Batch tokens at a time and refills in the background when it runs
+// low; a lease Redis denies is remembered until its RetryAfter, so an
+// exhausted key is rejected locally too. Close returns unused tokens.
+//
+// The limit stays global: Redis only ever grants tokens it would have
+// allowed, and leased tokens not yet spent are unavailable to other
+// instances until used or returned. Each key keeps its state for the
+// Hybrid's lifetime, which suits bounded key sets (tenants, partners, routes).
+// All methods are safe for concurrent use.
+type Hybrid struct {
+	lim  *Limiter
+	opts HybridOptions
+
+	keys sync.Map // string -> *hybridKey
+
+	mu     sync.RWMutex // guards closed against refills starting during Close
+	closed bool
+	wg     sync.WaitGroup
+	base   context.Context
+	cancel context.CancelFunc
+
+	localHits   atomic.Uint64
+	overshoot   atomic.Uint64
+	redisCalls  atomic.Uint64
+	leaseErrors atomic.Uint64
+	allowed     atomic.Uint64
+	denied      atomic.Uint64
+	paced       atomic.Uint64
+	leased      atomic.Uint64
+	refunded    atomic.Uint64
+}
+
+type hybridKey struct {
+	tokens      atomic.Int64 // leased and unspent; negative is overshoot debt
+	deniedUntil atomic.Int64 // unix nano until which Redis denies leases
+	refilling   atomic.Bool  // an asynchronous refill is in flight
+	local       LocalLimiter // paces the leased tokens; nil without Local
+
+	mu    sync.Mutex // serializes leases
+	limit Limit      // of the latest lease, for the refund
+}
+
+// NewHybrid builds a [Hybrid] leasing from lim.
+func NewHybrid(lim *Limiter, opts HybridOptions) *Hybrid {
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	h := &Hybrid{lim: lim, opts: opts}
+	h.base, h.cancel = context.WithCancel(context.Background())
+	return h
+}
+
+// Allow checks whether one token is available for key under limit (see
+// [Limiter.Allow]).
+func (h *Hybrid) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return h.AllowN(ctx, key, limit, 1)
+}
+
+// AllowN checks whether n tokens are available for key under limit, taking
+// them from the key's leased tokens and calling Redis only to lease more.
+// Remaining is the key's local tokens. n above the limit's capacity is denied
+// with RetryAfter -1 without a Redis call. After Close it is [Limiter.AllowN].
+func (h *Hybrid) AllowN(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	if err := validate(limit, n); err != nil {
+		return Result{}, err
+	}
+	if n > limit.capacity() {
+		h.denied.Add(1)
+		return Result{RetryAfter: -1}, nil
+	}
+	if h.isClosed() {
+		return h.lim.AllowN(ctx, key, limit, n)
+	}
+	k := h.key(key, limit)
+	if k.local != nil && !k.local.TryAcquire(n) {
+		if h.isClosed() { // Close closed the local limiter under this request
+			return h.lim.AllowN(ctx, key, limit, n)
+		}
+		h.paced.Add(1)
+		h.denied.Add(1)
+		return Result{RetryAfter: limit.Period * time.Duration(n) / time.Duration(limit.Rate)}, nil
+	}
+
+	if left := k.tokens.Add(-int64(n)); left >= 0 {
+		h.localHits.Add(1)
+		if left <= int64(h.lowWater(limit)) {
+			h.refill(k, key, limit)
+		}
+		return h.allow(int(left)), nil
+	} else if retry := k.denied(h.lim.now()); retry > 0 {
+		k.tokens.Add(int64(n))
+		h.denied.Add(1)
+		return Result{RetryAfter: retry}, nil
+	} else if left >= -int64(h.opts.MaxOvershoot) {
+		h.overshoot.Add(1)
+		h.refill(k, key, limit)
+		return h.allow(0), nil
+	}
+	k.tokens.Add(int64(n))
+
+	// Out of tokens: lease synchronously. A lease that completed (or was
+	// denied) while this request waited for the lock is used first.
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if left := k.tokens.Add(-int64(n)); left >= 0 {
+		h.localHits.Add(1)
+		return h.allow(int(left)), nil
+	}
+	k.tokens.Add(int64(n))
+	if retry := k.denied(h.lim.now()); retry > 0 {
+		h.denied.Add(1)
+		return Result{RetryAfter: retry}, nil
+	}
+	// A Close that ran since the check above has refunded k already: a lease
+	// now would stay in k.tokens for good.
+	if h.isClosed() {
+		return h.lim.AllowN(ctx, key, limit, n)
+	}
+	res, err := h.lease(ctx, k, key, limit, max(h.batch(limit), n))
+	if err != nil {
+		return Result{}, err
+	}
+	if left := k.tokens.Add(-int64(n)); left >= 0 {
+		return h.allow(int(left)), nil
+	}
+	k.tokens.Add(int64(n))
+	h.denied.Add(1)
+	return Result{RetryAfter: res.RetryAfter, ResetAfter: res.ResetAfter}, nil
+}
+
+func (h *Hybrid) isClosed() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.closed
+}
+
+// key returns key's local state, creating it (and its local limiter) on
+// first use.
+func (h *Hybrid) key(key string, limit Limit) *hybridKey {
+	if v, ok := h.keys.Load(key); ok {
+		return v.(*hybridKey)
+	}
+	k := &hybridKey{}
+	if h.opts.Local != nil {
+		k.local = h.opts.Local(limit, h.batch(limit))
+	}
+	v, loaded := h.keys.LoadOrStore(key, k)
+	if loaded && k.local != nil {
+		k.local.Close()
+	}
+	return v.(*hybridKey)
+}
+
+func (h *Hybrid) allow(remaining int) Result {
+	h.allowed.Add(1)
+	return Result{Allowed: true, Remaining: remaining}
+}
+
+// denied returns how long Redis still denies k's leases at now (0 if not).
+func (k *hybridKey) denied(now time.Time) time.Duration {
+	if d := time.Duration(k.deniedUntil.Load() - now.UnixNano()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// batch is the lease size for limit.
+func (h *Hybrid) batch(limit Limit) int {
+	b := h.opts.Batch
+	if b <= 0 {
+		b = limit.capacity() / 10
+	}
+	return min(max(b, 1), limit.capacity())
+}
+
+func (h *Hybrid) lowWater(limit Limit) int {
+	if h.opts.LowWater > 0 {
+		return h.opts.LowWater
+	}
+	return h.batch(limit) / 4
+}
+
+// lease takes up to want tokens for key from Redis and adds them to k's
+// local tokens. When fewer than want are left it takes what remains; when
+// none are, it records the denial until RetryAfter. Caller holds k.mu.
+func (h *Hybrid) lease(ctx context.Context, k *hybridKey, key string, limit Limit, want int) (Result, error) {
+	k.limit = limit
+	h.redisCalls.Add(1)
+	res, err := h.lim.AllowN(ctx, key, limit, want)
+	if err != nil {
+		return Result{}, err
+	}
+	if !res.Allowed && res.Remaining > 0 && res.Remaining < want {
+		h.redisCalls.Add(1)
+		rest, err := h.lim.AllowN(ctx, key, limit, res.Remaining)
+		if err != nil {
+			return Result{}, err
+		}
+		if rest.Allowed {
+			h.grant(k, res.Remaining)
+		}
+		return res, nil // RetryAfter still answers for want
+	}
+	if res.Allowed {
+		h.grant(k, want)
+	} else if res.RetryAfter > 0 {
+		k.deniedUntil.Store(h.lim.now().Add(res.RetryAfter).UnixNano())
+	}
+	return res, nil
+}
+
+func (h *Hybrid) grant(k *hybridKey, n int) {
+	k.tokens.Add(int64(n))
+	h.leased.Add(uint64(n))
+	k.deniedUntil.Store(0)
+}
+
+// refill leases a batch for key in the background, unless one is in flight,
+// Redis is denying the key or the Hybrid is closing.
+func (h *Hybrid) refill(k *hybridKey, key string, limit Limit) {
+	if k.denied(h.lim.now()) > 0 || !k.refilling.CompareAndSwap(false, true) {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		k.refilling.Store(false)
+		return
+	}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer k.refilling.Store(false)
+		ctx, cancel := context.WithTimeout(h.base, h.opts.Timeout)
+		defer cancel()
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if _, err := h.lease(ctx, k, key, limit, h.batch(limit)); err != nil {
+			h.leaseErrors.Add(1)
+		}
+	}()
+}
+
+// refundScript gives n unspent tokens of KEYS[1] back. ARGV: now_us, then the
+// limit's 4 arguments (see limitScript), then n. GCRA moves the TAT back, the
+// sliding log drops its n newest entries and the fixed window lowers its
+// count if the window is still current.
+var refundScript = goredis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local alg = tonumber(ARGV[2])
+local a   = tonumber(ARGV[3])
+local b   = tonumber(ARGV[4])
+local n   = tonumber(ARGV[6])
+
+if alg == 1 then
+  local raw = redis.call('GET', key)
+  local ttl = redis.call('PTTL', key)
+  if raw and ttl > 0 then
+    local tat = tonumber(raw) - a * n
+    if tat < now then tat = now end
+    redis.call('SET', key, tostring(tat), 'PX', ttl)
+  end
+elseif alg == 2 then
+  redis.call('ZPOPMAX', key, n)
+else
+  local h = redis.call('HMGET', key, 'w', 'c')
+  if tonumber(h[1]) == now - (now % b) then
+    local c = tonumber(h[2]) - n
+    if c < 0 then c = 0 end
+    redis.call('HSET', key, 'c', c)
+  end
+end
+return 0
+`)
+
+// Close stops background refills, closes the local limiters and returns
+// every key's unspent tokens to Redis, so other instances can use them;
+// overshoot debt is not charged back. Later calls go straight to Redis. It returns the first refund
+// error; ctx bounds the refunds. Idempotent.
+func (h *Hybrid) Close(ctx context.Context) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.mu.Unlock()
+	h.cancel()
+	h.wg.Wait()
+
+	var first error
+	h.keys.Range(func(key, v any) bool {
+		k := v.(*hybridKey)
+		if k.local != nil {
+			k.local.Close()
+		}
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		// Take only a positive balance: a negative one may be a request's
+		// reservation it is about to hand back, not debt.
+		n := k.tokens.Load()
+		for n > 0 && !k.tokens.CompareAndSwap(n, 0) {
+			n = k.tokens.Load()
+		}
+		if n <= 0 {
+			return true
+		}
+		args := k.limit.appendArgs([]any{h.lim.now().UnixMicro()})
+		h.redisCalls.Add(1)
+		if err := refundScript.Run(ctx, h.lim.client, []string{key.(string)}, append(args, n)...).Err(); err != nil {
+			if first == nil {
+				first = err
+			}
+			return true
+		}
+		h.refunded.Add(uint64(n))
+		return true
+	})
+	return first
+}
+
+// Metrics returns a best-effort snapshot of the counters.
+func (h *Hybrid) Metrics() HybridMetrics {
+	keys := 0
+	h.keys.Range(func(any, any) bool { keys++; return true })
+	return HybridMetrics{
+		LocalHits:   h.localHits.Load(),
+		Overshoot:   h.overshoot.Load(),
+		RedisCalls:  h.redisCalls.Load(),
+		LeaseErrors: h.leaseErrors.Load(),
+		Allowed:     h.allowed.Load(),
+		Denied:      h.denied.Load(),
+		Paced:       h.paced.Load(),
+		Leased:      h.leased.Load(),
+		Refunded:    h.refunded.Load(),
+		Keys:        keys,
+	}
+}
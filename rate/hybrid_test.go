@@ -0,0 +1,361 @@
+package rate_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/v8fg/kit4go/rate"
+)
+
+// settle waits for h's background refills to land: Redis calls stop moving.
+func settle(t *testing.T, h *rate.Hybrid) rate.HybridMetrics {
+	t.Helper()
+	prev := h.Metrics()
+	for range 100 {
+		time.Sleep(5 * time.Millisecond)
+		m := h.Metrics()
+		if m == prev {
+			return m
+		}
+		prev = m
+	}
+	t.Fatal("refills did not settle")
+	return prev
+}
+
+func TestHybrid_LeasesAndServesLocally(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(7000, 0)}
+	l, mr := newLimiter(t, clk)
+	h := rate.NewHybrid(l, rate.HybridOptions{Batch: 10, LowWater: 2})
+	limit := rate.FixedWindow(100, time.Minute)
+	ctx := context.Background()
+
+	r, err := h.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	require.Equal(t, rate.Result{Allowed: true, Remaining: 9}, r)
+	require.Equal(t, "10", mr.HGet("k", "c"), "one lease of 10")
+	for range 6 {
+		r, _ = h.Allow(ctx, "k", limit)
+		require.True(t, r.Allowed)
+	}
+	m := settle(t, h)
+	require.Equal(t, rate.HybridMetrics{LocalHits: 6, RedisCalls: 1, Allowed: 7, Leased: 10, Keys: 1}, m)
+
+	r, _ = h.Allow(ctx, "k", limit) // down to the low-water mark: refill
+	require.Equal(t, 2, r.Remaining)
+	m = settle(t, h)
+	require.Equal(t, uint64(2), m.RedisCalls)
+	require.Equal(t, "20", mr.HGet("k", "c"))
+	r, _ = h.AllowN(ctx, "k", limit, 5)
+	require.Equal(t, rate.Result{Allowed: true, Remaining: 7}, r)
+
+	// Close gives the 7 unspent tokens back.
+	require.NoError(t, h.Close(ctx))
+	require.NoError(t, h.Close(ctx))
+	require.Equal(t, "13", mr.HGet("k", "c"))
+	m = h.Metrics()
+	require.Equal(t, uint64(7), m.Refunded)
+	require.Equal(t, uint64(3), m.RedisCalls)
+
+	// After Close every call goes to Redis.
+	r, err = h.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	require.Equal(t, 86, r.Remaining)
+	require.Equal(t, uint64(3), h.Metrics().RedisCalls)
+}
+
+func TestHybrid_ExhaustedKeyDeniedLocally(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(7200, 0).Add(30 * time.Second)}
+	l, _ := newLimiter(t, clk)
+	h := rate.NewHybrid(l, rate.HybridOptions{Batch: 4})
+	defer h.Close(context.Background())
+	limit := rate.FixedWindow(6, time.Minute)
+	ctx := context.Background()
+
+	allowed := 0
+	for range 20 {
+		r, err := h.Allow(ctx, "k", limit)
+		require.NoError(t, err)
+		if r.Allowed {
+			allowed++
+		} else {
+			require.Greater(t, r.RetryAfter, time.Duration(0))
+			require.LessOrEqual(t, r.RetryAfter, 30*time.Second)
+		}
+		settle(t, h)
+	}
+	require.Equal(t, 6, allowed, "4 leased, then the 2 left")
+	m := h.Metrics()
+	require.Equal(t, uint64(14), m.Denied)
+	require.LessOrEqual(t, m.RedisCalls, uint64(4), "denials are served from the cached RetryAfter")
+
+	// The window rolls over: leasing resumes.
+	clk.t = clk.t.Add(30 * time.Second)
+	r, err := h.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	require.True(t, r.Allowed)
+}
+
+func TestHybrid_MaxOvershoot(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(7400, 0)}
+	l, mr := newLimiter(t, clk)
+	h := rate.NewHybrid(l, rate.HybridOptions{Batch: 5, MaxOvershoot: 2})
+	defer h.Close(context.Background())
+	limit := rate.SlidingLog(5, time.Minute)
+	ctx := context.Background()
+
+	// The first request is served on credit while the lease runs.
+	r, err := h.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	require.Equal(t, rate.Result{Allowed: true}, r)
+	m := settle(t, h)
+	require.Equal(t, uint64(1), m.Overshoot)
+	require.Equal(t, uint64(5), m.Leased)
+	card, _ := mr.ZMembers("k")
+	require.Len(t, card, 5)
+
+	// 4 tokens are left after repaying the debt. Once Redis denies the
+	// refill, no more credit is given: the key spent exactly its limit.
+	allowed := 0
+	for range 10 {
+		if r, _ := h.Allow(ctx, "k", limit); r.Allowed {
+			allowed++
+		}
+		settle(t, h)
+	}
+	require.Equal(t, 4, allowed)
+	require.Equal(t, uint64(1), h.Metrics().Overshoot)
+
+	// While Redis is unreachable a key spends at most MaxOvershoot on credit,
+	// then surfaces the error.
+	mr.SetError("down")
+	for range 2 {
+		r, err := h.Allow(ctx, "other", limit)
+		require.NoError(t, err)
+		require.True(t, r.Allowed)
+		settle(t, h)
+	}
+	_, err = h.Allow(ctx, "other", limit)
+	require.Error(t, err)
+	m = h.Metrics()
+	require.Equal(t, uint64(3), m.Overshoot)
+	require.GreaterOrEqual(t, m.LeaseErrors, uint64(1))
+	mr.SetError("")
+}
+
+func TestHybrid_RefundsEveryAlgorithm(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(7600, 0)}
+	l, mr := newLimiter(t, clk)
+	h := rate.NewHybrid(l, rate.HybridOptions{Batch: 10, LowWater: 1})
+	ctx := context.Background()
+	limits := map[string]rate.Limit{
+		"gcra":   rate.PerSecond(10, 10),
+		"log":    rate.SlidingLog(20, time.Second),
+		"window": rate.FixedWindow(20, time.Second),
+	}
+	for key, limit := range limits {
+		for range 3 {
+			r, err := h.Allow(ctx, key, limit)
+			require.NoError(t, err)
+			require.True(t, r.Allowed)
+		}
+	}
+	require.NoError(t, h.Close(ctx))
+	require.Equal(t, uint64(21), h.Metrics().Refunded)
+
+	// Redis is charged only for the 3 tokens each key spent.
+	for key, limit := range limits {
+		r, err := l.Allow(ctx, key, limit)
+		require.NoError(t, err)
+		require.Equal(t, limit.Rate-4, r.Remaining, key)
+	}
+	log, _ := mr.ZMembers("log")
+	require.Len(t, log, 4)
+
+	// A refund never credits more than the limit's capacity.
+	h2 := rate.NewHybrid(l, rate.HybridOptions{Batch: 5})
+	h2.Allow(ctx, "gcra2", limits["gcra"])
+	clk.t = clk.t.Add(10 * time.Second)
+	require.NoError(t, h2.Close(ctx))
+	r, _ := l.Allow(ctx, "gcra2", limits["gcra"])
+	require.Equal(t, 9, r.Remaining)
+}
+
+func TestHybrid_Errors(t *testing.T) {
+	l, mr := newLimiter(t, nil)
+	h := rate.NewHybrid(l, rate.HybridOptions{Batch: 5})
+	ctx := context.Background()
+	_, err := h.Allow(ctx, "k", rate.Limit{})
+	require.ErrorIs(t, err, rate.ErrLimitInvalid)
+
+	r, err := h.AllowN(ctx, "k", rate.PerSecond(10, 5), 6)
+	require.NoError(t, err)
+	require.False(t, r.Allowed)
+	require.Equal(t, time.Duration(-1), r.RetryAfter)
+	require.Zero(t, h.Metrics().RedisCalls, "never fits: no lease")
+
+	mr.SetError("down")
+	_, err = h.Allow(ctx, "k", rate.PerSecond(10, 5))
+	require.Error(t, err)
+	mr.SetError("")
+	h.Allow(ctx, "k", rate.PerSecond(10, 5))
+	mr.SetError("down")
+	require.Error(t, h.Close(ctx), "the refund fails")
+}
+
+func TestHybrid_Concurrent(t *testing.T) {
+	l, mr := newLimiter(t, &fakeClock{t: time.Unix(7200, 0)})
+	limit := rate.FixedWindow(1000, time.Hour) // default Batch: 100
+	ctx := context.Background()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	// Two instances share the Redis limit.
+	hs := []*rate.Hybrid{rate.NewHybrid(l, rate.HybridOptions{}), rate.NewHybrid(l, rate.HybridOptions{})}
+	for i := range 8 {
+		wg.Go(func() {
+			n := 0
+			for range 500 {
+				if r, err := hs[i%2].Allow(ctx, "k"+strconv.Itoa(i%4/2), limit); err == nil && r.Allowed {
+					n++
+				}
+			}
+			mu.Lock()
+			allowed += n
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	for _, h := range hs {
+		require.NoError(t, h.Close(ctx))
+	}
+	require.Equal(t, 2000, allowed)
+	// Unspent leases were returned: Redis is charged exactly what was allowed.
+	charged := 0
+	for _, key := range []string{"k0", "k1"} {
+		c, _ := strconv.Atoi(mr.HGet(key, "c"))
+		charged += c
+	}
+	require.Equal(t, allowed, charged)
+}
+
+// A request that passed the closed check before Close but leases after it
+// returns must not strand its lease: Close already refunded the key, so the
+// request goes straight to Redis instead.
+func TestHybrid_CloseDuringAllowN(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	c := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	defer c.Close()
+	var (
+		armed   atomic.Bool
+		entered = make(chan struct{})
+		resume  = make(chan struct{})
+	)
+	// The clock parks the request where it reads it before taking the key's
+	// lock, out of tokens and past the closed check.
+	clock := func() time.Time {
+		if armed.CompareAndSwap(true, false) {
+			close(entered)
+			<-resume
+		}
+		return time.Unix(7800, 0)
+	}
+	h := rate.NewHybrid(rate.New(c, rate.WithClock(clock)), rate.HybridOptions{Batch: 10})
+	limit := rate.FixedWindow(100, time.Hour)
+	ctx := context.Background()
+
+	armed.Store(true)
+	done := make(chan rate.Result)
+	go func() {
+		r, err := h.Allow(ctx, "k", limit)
+		require.NoError(t, err)
+		done <- r
+	}()
+	<-entered
+	require.NoError(t, h.Close(ctx))
+	close(resume)
+	require.True(t, (<-done).Allowed)
+	require.Equal(t, "1", mr.HGet("k", "c"), "a lease taken after Close is never refunded")
+	require.Zero(t, h.Metrics().Leased)
+}
+
+// Local paces the leased tokens: a request its limiter denies is rejected
+// without spending the lease.
+func TestHybrid_LocalLimiter(t *testing.T) {
+	l, _ := newLimiter(t, &fakeClock{t: time.Unix(8000, 0)})
+	var locals []*countingLocal
+	h := rate.NewHybrid(l, rate.HybridOptions{Batch: 10, Local: func(limit rate.Limit, batch int) rate.LocalLimiter {
+		require.Equal(t, 10, batch)
+		lc := &countingLocal{left: 3}
+		locals = append(locals, lc)
+		return lc
+	}})
+	limit := rate.PerSecond(100, 100)
+	ctx := context.Background()
+
+	for range 3 {
+		r, err := h.Allow(ctx, "k", limit)
+		require.NoError(t, err)
+		require.True(t, r.Allowed)
+	}
+	r, err := h.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	require.Equal(t, rate.Result{RetryAfter: 10 * time.Millisecond}, r)
+	m := settle(t, h)
+	require.Equal(t, uint64(1), m.Paced)
+	require.Equal(t, uint64(1), m.Denied)
+	require.Equal(t, uint64(10), m.Leased, "the paced request did not lease")
+	require.Len(t, locals, 1)
+
+	require.NoError(t, h.Close(ctx))
+	require.True(t, locals[0].closed)
+	require.Equal(t, uint64(7), h.Metrics().Refunded)
+}
+
+type countingLocal struct {
+	left   int
+	closed bool
+}
+
+func (c *countingLocal) TryAcquire(n int) bool {
+	if c.left < n {
+		return false
+	}
+	c.left -= n
+	return true
+}
+
+func (c *countingLocal) Close() { c.closed = true }
+
+// BenchmarkHybrid_Allow_Parallel measures a local hit: an atomic add on the
+// key's leased tokens, with a background refill every Batch requests.
+func BenchmarkHybrid_Allow_Parallel(b *testing.B) {
+	mr, err := miniredis.Run()
+	require.NoError(b, err)
+	defer mr.Close()
+	c := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	defer c.Close()
+	h := rate.NewHybrid(rate.New(c), rate.HybridOptions{Batch: 10_000})
+	defer h.Close(context.Background())
+	limit := rate.PerSecond(1_000_000_000, 1_000_000)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = h.Allow(ctx, "bench", limit)
+		}
+	})
+}
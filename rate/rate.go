@@ -8,23 +8,57 @@
 // use it when many instances must agree on a global rate (a shared bid QPS cap,
 // a per-user API budget, a cross-pod postback throttle).
 //
+// A [Limit] can instead use a sliding log or a fixed window (see [Algorithm]),
+// and [Limiter.AllowMulti] checks several limits — per second, per minute,
+// per day — in one atomic script, charging all of them or none. A [Hybrid]
+// leases tokens in batches and serves them in process, for hot keys where a
+// Redis round-trip per request is too slow.
+//
 // Dependencies: github.com/redis/go-redis/v9. Pass any redis.Cmdable (single,
 // cluster, or the kit4go/redis wrapper's Cmdable()).
 package rate
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"slices"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	goredis "github.com/redis/go-redis/v9"
 )
 
-// Limit is a rate (tokens per Period) with a Burst allowance.
+// Algorithm selects how a [Limit] is enforced.
+type Algorithm string
+
+const (
+	// AlgorithmGCRA is the Generic Cell Rate Algorithm: Rate tokens per Period
+	// refilled continuously, up to Burst. One number per key. The zero
+	// Algorithm is GCRA.
+	AlgorithmGCRA Algorithm = "gcra"
+
+	// AlgorithmSlidingLog admits at most Rate requests in any trailing Period.
+	// Exact, but it keeps one sorted-set entry per admitted request, so it
+	// suits small quotas (per second / per minute). Burst is ignored.
+	AlgorithmSlidingLog Algorithm = "sliding_log"
+
+	// AlgorithmFixedWindow admits at most Rate requests per Period-long
+	// window aligned to the Unix epoch (UTC midnight for a 24h Period). Two
+	// numbers per key, so it suits large or long quotas (per hour / per day);
+	// up to 2×Rate may pass around a window boundary. Burst is ignored.
+	AlgorithmFixedWindow Algorithm = "fixed_window"
+)
+
+// Limit is a rate (tokens per Period) with a Burst allowance, enforced by
+// Algorithm.
 type Limit struct {
-	Rate   int           // tokens granted per Period
-	Period time.Duration // the period over which Rate is measured
-	Burst  int           // maximum tokens that may accumulate (burst)
+	Rate      int           // tokens granted per Period
+	Period    time.Duration // the period over which Rate is measured
+	Burst     int           // maximum tokens that may accumulate (GCRA only)
+	Algorithm Algorithm     // "" selects AlgorithmGCRA
 }
 
 // PerSecond is a convenience for a per-second rate.
@@ -37,69 +71,190 @@ func PerMinute(rate, burst int) Limit {
 	return Limit{Rate: rate, Period: time.Minute, Burst: burst}
 }
 
+// SlidingLog is a limit of rate requests in any trailing period, enforced by
+// [AlgorithmSlidingLog].
+func SlidingLog(rate int, period time.Duration) Limit {
+	return Limit{Rate: rate, Period: period, Algorithm: AlgorithmSlidingLog}
+}
+
+// FixedWindow is a limit of rate requests per epoch-aligned period, enforced
+// by [AlgorithmFixedWindow].
+func FixedWindow(rate int, period time.Duration) Limit {
+	return Limit{Rate: rate, Period: period, Algorithm: AlgorithmFixedWindow}
+}
+
 // Result is the outcome of an Allow/AllowN decision.
 type Result struct {
-	Allowed    bool          // whether the request was permitted (and consumed a token)
-	Remaining  int           // tokens remaining in the bucket after the decision
-	RetryAfter time.Duration // when Allowed is false, how long until one token is free (0 when allowed)
+	Allowed   bool // whether the request was permitted (and consumed a token)
+	Remaining int  // tokens remaining in the bucket after the decision
+
+	// RetryAfter is, when Allowed is false, how long until the request could
+	// be allowed (0 when allowed, -1 when n exceeds the limit's capacity and
+	// never will be).
+	RetryAfter time.Duration
+
+	// ResetAfter is how long until the limit is back to full capacity: the
+	// bucket refilled (GCRA), the newest logged request expired (sliding log)
+	// or the window ended (fixed window).
+	ResetAfter time.Duration
+}
+
+// MultiResult is the outcome of an AllowMulti/AllowMultiN decision.
+type MultiResult struct {
+	// Allowed is true when every limit allowed the request; only then is it
+	// consumed, from every limit.
+	Allowed bool
+
+	// Denied is the index of the first limit that rejected, -1 when Allowed.
+	Denied int
+
+	// RetryAfter is the longest RetryAfter of the rejecting limits: how long
+	// until all of them could allow the request (-1 if one never will).
+	RetryAfter time.Duration
+
+	// Results holds each limit's own verdict, in the order given.
+	Results []Result
 }
 
-// ErrLimitInvalid is returned for a non-positive Rate/Period/Burst or n<=0.
+// ErrLimitInvalid is returned for a non-positive Rate/Period, a non-positive
+// GCRA Burst, an unknown Algorithm, n<=0, or an empty or duplicated
+// AllowMulti limit set.
 var ErrLimitInvalid = errors.New("rate: invalid limit")
 
-// gcraScript atomically checks and advances the TAT for a key.
-// Args (all microseconds, as strings): now, emission_interval, burst, cost, ttl_ms.
-// Returns: {allowed(0/1), remaining, retry_after_us}.
-var gcraScript = goredis.NewScript(`
-local key     = KEYS[1]
-local now     = tonumber(ARGV[1])
-local emiss   = tonumber(ARGV[2])
-local burst   = tonumber(ARGV[3])
-local cost    = tonumber(ARGV[4])
-local ttl_ms  = tonumber(ARGV[5])
-
-local raw = redis.call('GET', key)
-local tat
-if raw == false or raw == nil then
-  tat = now
-else
-  tat = tonumber(raw)
-end
-if tat < now then tat = now end
+// limitScript atomically checks every limit in KEYS and, only when all allow,
+// consumes cost from each of them.
+//
+// ARGV: now_us, cost, member, then 4 per key: algorithm (1 GCRA, 2 sliding
+// log, 3 fixed window), a, b, ttl_ms. GCRA: a = emission interval (us per
+// token), b = burst; windows: a = rate, b = period (us). member prefixes the
+// sliding-log entries this call adds.
+//
+// Returns: {all_allowed(0/1), then per key: allowed(0/1), remaining,
+// retry_after_us, reset_after_us}.
+var limitScript = goredis.NewScript(`
+local now    = tonumber(ARGV[1])
+local cost   = tonumber(ARGV[2])
+local member = ARGV[3]
 
-local allowed = false
-if cost <= burst and (tat - now) <= emiss * (burst - cost) then
-  allowed = true
+-- logged returns a sliding-log entry's time. It is read from the member,
+-- "<now_us>:<member>:<n>", as scores may come back in a lossy float format.
+local function logged(m)
+  return tonumber(string.match(m, '^%d+'))
 end
 
-local new_tat = tat
-if allowed then
-  new_tat = tat + emiss * cost
-  redis.call('SET', key, tostring(new_tat), 'PX', ttl_ms)
+local st  = {}
+local all = true
+for i, key in ipairs(KEYS) do
+  local base = 3 + (i - 1) * 4
+  local s = {
+    alg = tonumber(ARGV[base + 1]),
+    a   = tonumber(ARGV[base + 2]),
+    b   = tonumber(ARGV[base + 3]),
+    ttl = tonumber(ARGV[base + 4]),
+    retry = 0,
+  }
+  if s.alg == 1 then
+    local raw = redis.call('GET', key)
+    local tat = now
+    if raw then tat = tonumber(raw) end
+    if tat < now then tat = now end
+    s.tat = tat
+    s.allowed = cost <= s.b and (tat - now) <= s.a * (s.b - cost)
+    if not s.allowed then
+      if cost > s.b then
+        s.retry = -1
+      else
+        s.retry = math.ceil(tat - now - s.a * (s.b - cost))
+        if s.retry < 0 then s.retry = 0 end
+      end
+    end
+  elseif s.alg == 2 then
+    redis.call('ZREMRANGEBYSCORE', key, '-inf', now - s.b)
+    s.count = redis.call('ZCARD', key)
+    s.allowed = cost <= s.a and s.count + cost <= s.a
+    if not s.allowed then
+      if cost > s.a then
+        s.retry = -1
+      else
+        local e = redis.call('ZRANGE', key, s.count + cost - s.a - 1, s.count + cost - s.a - 1)
+        s.retry = logged(e[1]) + s.b - now
+      end
+    end
+  else
+    s.start = now - (now % s.b)
+    local h = redis.call('HMGET', key, 'w', 'c')
+    s.count = 0
+    if tonumber(h[1]) == s.start then s.count = tonumber(h[2]) end
+    s.allowed = cost <= s.a and s.count + cost <= s.a
+    if not s.allowed then
+      if cost > s.a then s.retry = -1 else s.retry = s.start + s.b - now end
+    end
+  end
+  all = all and s.allowed
+  st[i] = s
 end
 
-local remaining = burst - math.ceil((new_tat - now) / emiss)
-if remaining < 0 then remaining = 0 end
-if remaining > burst then remaining = burst end
-
-local retry_after = 0
-if not allowed then
-  retry_after = math.ceil(new_tat - now - emiss * (burst - cost))
-  if retry_after < 0 then retry_after = 0 end
+local out = {all and 1 or 0}
+for i, key in ipairs(KEYS) do
+  local s = st[i]
+  local remaining, reset
+  if s.alg == 1 then
+    local tat = s.tat
+    if all then
+      tat = tat + s.a * cost
+      redis.call('SET', key, tostring(tat), 'PX', s.ttl)
+    end
+    remaining = s.b - math.ceil((tat - now) / s.a)
+    if remaining < 0 then remaining = 0 end
+    if remaining > s.b then remaining = s.b end
+    reset = math.ceil(tat - now)
+  elseif s.alg == 2 then
+    local count = s.count
+    if all then
+      for j = 1, cost do
+        redis.call('ZADD', key, now, ARGV[1] .. ':' .. member .. ':' .. j)
+      end
+      redis.call('PEXPIRE', key, math.ceil(s.b / 1000))
+      count = count + cost
+    end
+    remaining = s.a - count
+    reset = 0
+    if count > 0 then
+      local e = redis.call('ZRANGE', key, -1, -1)
+      reset = logged(e[1]) + s.b - now
+    end
+  else
+    local count = s.count
+    reset = s.start + s.b - now
+    if all then
+      count = count + cost
+      redis.call('HSET', key, 'w', s.start, 'c', count)
+      redis.call('PEXPIRE', key, math.ceil(reset / 1000))
+    end
+    remaining = s.a - count
+    if count == 0 then reset = 0 end
+  end
+  out[#out + 1] = s.allowed and 1 or 0
+  out[#out + 1] = remaining
+  out[#out + 1] = s.retry
+  out[#out + 1] = reset
 end
-return {allowed and 1 or 0, remaining, retry_after}
+return out
 `)
 
-// Limiter applies GCRA limits against a Redis instance.
+// Limiter applies limits against a Redis instance.
 //
 // Concurrency: safe for concurrent use. The Limiter holds no local mutable
-// state beyond the immutable Redis client, the clock, and the option fields, so
-// each Allow/AllowN is an independent Redis call; cross-process rate-limit
-// atomicity is provided by the embedded Lua script. A single Limiter is safe to
-// share across goroutines and across machines that share the same Redis.
+// state beyond the immutable Redis client, the clock, the option fields and an
+// atomic sequence naming sliding-log entries, so each call is an independent
+// Redis call; cross-process rate-limit atomicity is provided by the embedded
+// Lua script. A single Limiter is safe to share across goroutines and across
+// machines that share the same Redis.
 type Limiter struct {
 	client goredis.Cmdable
 	now    func() time.Time
+	id     string        // random per-Limiter prefix of sliding-log members
+	seq    atomic.Uint64 // per-call suffix of sliding-log members
 }
 
 // Option configures a Limiter.
@@ -110,7 +265,9 @@ func WithClock(f func() time.Time) Option { return func(l *Limiter) { l.now = f
 
 // New builds a Limiter over the given Redis client.
 func New(client goredis.Cmdable, opts ...Option) *Limiter {
-	l := &Limiter{client: client, now: time.Now}
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	l := &Limiter{client: client, now: time.Now, id: hex.EncodeToString(id[:])}
 	for _, opt := range opts {
 		opt(l)
 	}
@@ -125,31 +282,143 @@ func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, e
 
 // AllowN checks whether n tokens are available for key under limit, consuming
 // them when they are. n must be >= 1.
+//
+// The Redis key type depends on the algorithm (a string for GCRA, a sorted set
+// for the sliding log, a hash for the fixed window), so a key must keep one
+// algorithm.
 func (l *Limiter) AllowN(ctx context.Context, key string, limit Limit, n int) (Result, error) {
 	if err := validate(limit, n); err != nil {
 		return Result{}, err
 	}
-	emiss := float64(limit.Period.Microseconds()) / float64(limit.Rate) // microseconds per token
-	ttl := int64(limit.Period.Microseconds()) * int64(limit.Burst+1) / int64(limit.Rate)
-	if ttl < int64(time.Second.Microseconds()) {
-		ttl = int64(time.Second.Microseconds())
-	}
-	nowUs := l.now().UnixMicro()
-	res, err := gcraScript.Run(ctx, l.client,
-		[]string{key},
-		nowUs, emiss, limit.Burst, n, ttl/1000, // ttl in ms
-	).Slice()
+	res, err := l.run(ctx, []string{key}, []Limit{limit}, n)
 	if err != nil {
 		return Result{}, err
 	}
-	allowed := res[0].(int64) == 1
-	remaining := int(res[1].(int64))
-	retry := time.Duration(res[2].(int64)) * time.Microsecond
-	return Result{Allowed: allowed, Remaining: remaining, RetryAfter: retry}, nil
+	return res.Results[0], nil
+}
+
+// AllowMulti checks one request for key against every limit at once — e.g. a
+// partner's per-second, per-minute and per-day quotas — consuming it from
+// all of them only when all allow. See AllowMultiN.
+func (l *Limiter) AllowMulti(ctx context.Context, key string, limits []Limit) (MultiResult, error) {
+	return l.AllowMultiN(ctx, key, limits, 1)
+}
+
+// AllowMultiN checks n tokens for key against every limit in one atomic Lua
+// call, consuming them from all limits only when all allow. Limits may mix
+// algorithms; no two may share both Algorithm and Period.
+//
+// Each limit keeps its state under "<key>:<algorithm>:<period in ms>". Under
+// Redis Cluster, put a hash tag in key ("{partner:42}") so those keys share a
+// slot.
+func (l *Limiter) AllowMultiN(ctx context.Context, key string, limits []Limit, n int) (MultiResult, error) {
+	if len(limits) == 0 {
+		return MultiResult{}, ErrLimitInvalid
+	}
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		if err := validate(limit, n); err != nil {
+			return MultiResult{}, err
+		}
+		keys[i] = key + ":" + string(limit.algorithm()) + ":" + strconv.FormatInt(limit.Period.Milliseconds(), 10)
+		if slices.Contains(keys[:i], keys[i]) {
+			return MultiResult{}, ErrLimitInvalid
+		}
+	}
+	return l.run(ctx, keys, limits, n)
+}
+
+// run evaluates limitScript over keys[i] / limits[i].
+func (l *Limiter) run(ctx context.Context, keys []string, limits []Limit, n int) (MultiResult, error) {
+	args := make([]any, 0, 3+4*len(limits))
+	args = append(args, l.now().UnixMicro(), n, l.id+":"+strconv.FormatUint(l.seq.Add(1), 36))
+	for _, limit := range limits {
+		args = limit.appendArgs(args)
+	}
+	res, err := limitScript.Run(ctx, l.client, keys, args...).Slice()
+	if err != nil {
+		return MultiResult{}, err
+	}
+	out := MultiResult{Allowed: res[0].(int64) == 1, Denied: -1, Results: make([]Result, len(limits))}
+	for i := range out.Results {
+		v := res[1+4*i:]
+		r := Result{
+			Allowed:    v[0].(int64) == 1,
+			Remaining:  int(v[1].(int64)),
+			RetryAfter: usDuration(v[2].(int64)),
+			ResetAfter: usDuration(v[3].(int64)),
+		}
+		out.Results[i] = r
+		if r.Allowed {
+			continue
+		}
+		if out.Denied < 0 {
+			out.Denied = i
+		}
+		if r.RetryAfter < 0 || out.RetryAfter < 0 {
+			out.RetryAfter = -1
+		} else {
+			out.RetryAfter = max(out.RetryAfter, r.RetryAfter)
+		}
+	}
+	return out, nil
+}
+
+// usDuration converts a script's microseconds; -1 (never) stays -1.
+func usDuration(us int64) time.Duration {
+	if us < 0 {
+		return -1
+	}
+	return time.Duration(us) * time.Microsecond
+}
+
+// appendArgs appends limit's 4 per-key script arguments: algorithm, a, b,
+// ttl_ms (see limitScript).
+func (l Limit) appendArgs(args []any) []any {
+	periodUs := l.Period.Microseconds()
+	switch l.algorithm() {
+	case AlgorithmSlidingLog:
+		return append(args, 2, l.Rate, periodUs, 0)
+	case AlgorithmFixedWindow:
+		return append(args, 3, l.Rate, periodUs, 0)
+	}
+	emiss := float64(periodUs) / float64(l.Rate) // microseconds per token
+	ttl := periodUs * int64(l.Burst+1) / int64(l.Rate)
+	if ttl < time.Second.Microseconds() {
+		ttl = time.Second.Microseconds()
+	}
+	return append(args, 1, emiss, l.Burst, ttl/1000) // ttl in ms
+}
+
+// capacity is the most tokens one request can take under l.
+func (l Limit) capacity() int {
+	if l.algorithm() == AlgorithmGCRA {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+func (l Limit) algorithm() Algorithm {
+	if l.Algorithm == "" {
+		return AlgorithmGCRA
+	}
+	return l.Algorithm
 }
 
 func validate(limit Limit, n int) error {
-	if limit.Rate <= 0 || limit.Period <= 0 || limit.Burst <= 0 {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return ErrLimitInvalid
+	}
+	switch limit.algorithm() {
+	case AlgorithmGCRA:
+		if limit.Burst <= 0 {
+			return ErrLimitInvalid
+		}
+	case AlgorithmSlidingLog, AlgorithmFixedWindow:
+		if limit.Period < time.Millisecond {
+			return ErrLimitInvalid
+		}
+	default:
 		return ErrLimitInvalid
 	}
 	if n <= 0 {
@@ -34,3 +34,29 @@ func ExampleNew() {
 	// ... critical section: lock is held while this runs ...
 	_ = lock
 }
+
+// ExampleNewRedlock shows a Redlock over three independent nodes with fencing
+// tokens: the lock is held while a quorum of two holds it, and Fence is passed
+// to the protected store so it can reject a holder that outlived its lock.
+// Compile-checked only, like ExampleNew.
+func ExampleNewRedlock() {
+	var nodes []goredis.Cmdable
+	for _, addr := range []string{"10.0.0.1:6379", "10.0.0.2:6379", "10.0.0.3:6379"} {
+		nodes = append(nodes, goredis.NewClient(&goredis.Options{Addr: addr}))
+	}
+
+	locker := redislock.NewRedlock(nodes,
+		redislock.WithTTL(5*time.Second),
+		redislock.WithFencing(true),
+	)
+
+	ctx := context.Background()
+	lock, err := locker.TryLock(ctx, "{camp42}:budget")
+	if err != nil {
+		return // no quorum, or the lock is held
+	}
+	defer lock.Release(context.Background())
+
+	// ... write with lock.Fence(); the store keeps the highest token seen ...
+	_ = lock.Fence()
+}
@@ -7,11 +7,14 @@
 // re-acquired by someone else). An optional heartbeat goroutine renews the TTL
 // while the critical section runs.
 //
-// This is a single-instance Redis lock (one Redis node). For environments that
-// cannot tolerate a single Redis being a SPoF, run Redis in a failover setup
-// (Sentinel/Cluster) and let the client route to the current master. Ad-tech
-// uses: single-flight budget/pacing updates, leader election, dedup of
-// concurrent bid requests for the same auction.
+// New locks on a single Redis (one node, or Sentinel/Cluster routing to the
+// current master). NewRedlock locks on N independent nodes with the Redlock
+// algorithm: a lock is held when a quorum (N/2+1) granted it within its TTL,
+// less the elapsed time and a clock-drift allowance. WithFencing adds a
+// monotonically increasing fencing token to each Lock, so a store can reject
+// writes from a holder whose lock has since expired. Ad-tech uses:
+// single-flight budget/pacing updates, leader election, dedup of concurrent bid
+// requests for the same auction.
 package redislock
 
 import (
@@ -20,7 +23,10 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	mathrand "math/rand/v2"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	goredis "github.com/redis/go-redis/v9"
@@ -52,6 +58,27 @@ else
 end
 `)
 
+// Lua acquire with fencing: take the lock, then bump the key's fencing
+// counter (KEYS[2]). Returns the new fencing token, or 0 if the lock is held.
+var acquireFencedScript = goredis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+else
+	return 0
+end
+`)
+
+// Lua raise: move a node's fencing counter up to ARGV[1] (never down).
+// Returns the counter afterwards.
+var raiseFenceScript = goredis.NewScript(`
+local c = tonumber(redis.call('GET', KEYS[1]) or '0')
+if c < tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[1], ARGV[1])
+	return tonumber(ARGV[1])
+end
+return c
+`)
+
 type options struct {
 	ttl           time.Duration
 	retryInterval time.Duration // for Lock (blocking)
@@ -60,6 +87,9 @@ type options struct {
 	autoRenew     bool
 	renewInterval time.Duration // 0 = ttl/2
 	onLost        func(error)   // invoked once when an auto-renew fails
+	fencing       bool
+	nodeTimeout   time.Duration // per-node call bound in Redlock mode
+	driftFactor   float64       // clock drift allowance, as a fraction of the TTL
 }
 
 // Option configures a Locker.
@@ -103,27 +133,70 @@ func WithRenewInterval(d time.Duration) Option { return func(o *options) { o.ren
 // WithOnLost registers a callback invoked once when an auto-renew fails.
 func WithOnLost(fn func(error)) Option { return func(o *options) { o.onLost = fn } }
 
-// Locker acquires distributed locks against a Redis instance.
+// WithFencing gives every Lock a fencing token (Lock.Fence): a number that
+// grows with each acquisition of the key, kept in the counter "<key>:fence"
+// (persistent; under Redis Cluster, put a hash tag in key so both share a
+// slot). Pass it with every write the lock protects and have the store reject
+// tokens lower than the highest it has seen: a holder paused past its TTL can
+// then no longer overwrite its successor's work.
+func WithFencing(on bool) Option { return func(o *options) { o.fencing = on } }
+
+// WithNodeTimeout bounds each node's call in Redlock mode (default 50ms), so
+// an unreachable node costs a small part of the TTL. Keep it well below the
+// TTL.
+func WithNodeTimeout(d time.Duration) Option { return func(o *options) { o.nodeTimeout = d } }
+
+// WithDriftFactor sets the clock-drift allowance as a fraction of the TTL
+// (default 0.01, plus 2ms). Lock.Until is the acquisition start plus the TTL
+// less this allowance; in Redlock mode an acquisition or refresh that
+// took longer than that fails.
+func WithDriftFactor(f float64) Option { return func(o *options) { o.driftFactor = f } }
+
+// Locker acquires distributed locks against one Redis, or against a quorum of
+// independent Redis nodes (NewRedlock).
 type Locker struct {
-	client goredis.Cmdable
-	opts   options
+	nodes nodes
+	opts  options
 }
 
 // New builds a Locker over the given Redis client (single-node, cluster, or the
 // redis.Cmdable from the kit4go/redis wrapper).
 func New(client goredis.Cmdable, opts ...Option) *Locker {
+	return newLocker([]goredis.Cmdable{client}, opts)
+}
+
+// NewRedlock builds a Locker implementing Redlock over independent Redis
+// nodes (not replicas of each other; an odd number, 3 or 5, is typical). A lock
+// is acquired, refreshed and released on every node in parallel and is held
+// while a quorum of N/2+1 nodes holds it, so it survives the loss of a
+// minority of nodes. Auto-renew, Lost and fencing work as with New. It panics
+// if clients is empty.
+func NewRedlock(clients []goredis.Cmdable, opts ...Option) *Locker {
+	if len(clients) == 0 {
+		panic("redislock: NewRedlock requires at least one client")
+	}
+	return newLocker(clients, opts)
+}
+
+func newLocker(clients []goredis.Cmdable, opts []Option) *Locker {
 	o := options{
 		ttl:           10 * time.Second,
 		retryInterval: 50 * time.Millisecond,
+		nodeTimeout:   50 * time.Millisecond,
+		driftFactor:   0.01,
 	}
 	for _, opt := range opts {
 		opt(&o)
 	}
-	return &Locker{client: client, opts: o}
+	return &Locker{
+		nodes: nodes{clients: clients, quorum: len(clients)/2 + 1, timeout: o.nodeTimeout},
+		opts:  o,
+	}
 }
 
 // TryLock makes a single attempt to acquire key. Returns ErrLockNotAcquired if
-// the lock is currently held.
+// the lock is currently held (in Redlock mode: if no quorum granted it in
+// time).
 func (l *Locker) TryLock(ctx context.Context, key string) (*Lock, error) {
 	return l.tryLock(ctx, key, l.opts.token)
 }
@@ -158,6 +231,10 @@ func (l *Locker) Lock(ctx context.Context, key string) (*Lock, error) {
 		if interval <= 0 {
 			interval = 50 * time.Millisecond
 		}
+		if l.nodes.redlock() {
+			// Jitter so contenders do not keep splitting the nodes' votes.
+			interval += mathrand.N(interval/2 + 1)
+		}
 		remaining := interval
 		if !deadline.IsZero() {
 			if r := time.Until(deadline); r < remaining {
@@ -176,23 +253,41 @@ func (l *Locker) tryLock(ctx context.Context, key, token string) (*Lock, error)
 			return nil, fmt.Errorf("redislock: generate token: %w", err)
 		}
 	}
-	ok, err := l.client.SetNX(ctx, key, token, l.opts.ttl).Result()
-	if err != nil {
-		return nil, err
-	}
-	if !ok {
-		return nil, ErrLockNotAcquired
-	}
+	start := time.Now()
+	vals, errs := l.nodes.each(ctx, func(ctx context.Context, c goredis.Cmdable) (int64, error) {
+		if l.opts.fencing {
+			return acquireFencedScript.Run(ctx, c, []string{key, fenceKey(key)}, token, l.opts.ttl.Milliseconds()).Int64()
+		}
+		ok, err := c.SetNX(ctx, key, token, l.opts.ttl).Result()
+		if ok {
+			return 1, err
+		}
+		return 0, err
+	})
 	lk := &Lock{
-		client:        l.client,
+		nodes:         l.nodes,
 		key:           key,
 		token:         token,
 		ttl:           l.opts.ttl,
+		drift:         drift(l.opts.ttl, l.opts.driftFactor),
 		autoRenew:     l.opts.autoRenew,
 		renewInterval: l.opts.renewInterval,
 		onLost:        l.opts.onLost,
 		lost:          make(chan struct{}),
 	}
+	if countPositive(vals) < l.nodes.quorum || !lk.valid(start) {
+		lk.rollback(ctx, vals)
+		return nil, l.nodes.failure(errs)
+	}
+	if l.opts.fencing {
+		fence, err := lk.settleFence(ctx, vals)
+		if err != nil {
+			lk.rollback(ctx, vals)
+			return nil, err
+		}
+		lk.fence = fence
+	}
+	lk.until.Store(start.Add(l.opts.ttl - lk.drift).UnixNano())
 	if lk.autoRenew {
 		// Derive a context from the acquire ctx so its cancellation (request done,
 		// shutdown, parent timeout) stops the renewer instead of leaking the lock
@@ -203,12 +298,127 @@ func (l *Locker) tryLock(ctx context.Context, key, token string) (*Lock, error)
 	return lk, nil
 }
 
+// fenceKey is the counter behind key's fencing tokens.
+func fenceKey(key string) string { return key + ":fence" }
+
+// drift is the clock-drift allowance for ttl (Redlock: factor × TTL + 2ms).
+func drift(ttl time.Duration, factor float64) time.Duration {
+	return time.Duration(float64(ttl)*factor) + 2*time.Millisecond
+}
+
+// settleFence returns the fencing token of an acquisition: the highest
+// counter among the nodes that granted it (vals). In Redlock mode the
+// granting nodes behind it are raised to it, so any later quorum — which
+// shares a node with this one — counts past it; fewer than a quorum at the
+// token fails the acquisition.
+func (l *Lock) settleFence(ctx context.Context, vals []int64) (int64, error) {
+	fence := slices.Max(vals)
+	if !l.nodes.redlock() {
+		return fence, nil
+	}
+	raised, errs := l.nodes.each(ctx, func(ctx context.Context, c goredis.Cmdable) (int64, error) {
+		return raiseFenceScript.Run(ctx, c, []string{fenceKey(l.key)}, fence).Int64()
+	})
+	at := 0
+	for i, v := range raised {
+		if vals[i] > 0 && v >= fence {
+			at++
+		}
+	}
+	if at < l.nodes.quorum {
+		return 0, l.nodes.failure(errs)
+	}
+	return fence, nil
+}
+
+// rollback releases an acquisition that failed after some nodes granted it,
+// so they do not keep the key locked until the TTL. Best effort.
+func (l *Lock) rollback(ctx context.Context, vals []int64) {
+	if countPositive(vals) == 0 {
+		return
+	}
+	l.nodes.each(ctx, func(ctx context.Context, c goredis.Cmdable) (int64, error) {
+		return releaseScript.Run(ctx, c, []string{l.key}, l.token).Int64()
+	})
+}
+
+// valid reports whether a lock (re)acquired at start is still within its
+// TTL less the drift allowance. A single node's TTL runs in Redis itself, so
+// only Redlock mode checks it.
+func (l *Lock) valid(start time.Time) bool {
+	return !l.nodes.redlock() || l.ttl-time.Since(start)-l.drift > 0
+}
+
+// nodes is the set of Redis nodes a lock lives on: one for New, N
+// independent ones for NewRedlock.
+type nodes struct {
+	clients []goredis.Cmdable
+	quorum  int
+	timeout time.Duration // per-node call bound; Redlock mode only
+}
+
+func (n nodes) redlock() bool { return len(n.clients) > 1 }
+
+// each runs fn on every node — directly for a single node, in parallel and
+// bounded by timeout for Redlock — and returns each node's value and error.
+func (n nodes) each(ctx context.Context, fn func(context.Context, goredis.Cmdable) (int64, error)) ([]int64, []error) {
+	vals := make([]int64, len(n.clients))
+	errs := make([]error, len(n.clients))
+	if !n.redlock() {
+		vals[0], errs[0] = fn(ctx, n.clients[0])
+		return vals, errs
+	}
+	var wg sync.WaitGroup
+	for i, c := range n.clients {
+		wg.Go(func() {
+			cctx, cancel := context.WithTimeout(ctx, n.timeout)
+			defer cancel()
+			vals[i], errs[i] = fn(cctx, c)
+		})
+	}
+	wg.Wait()
+	return vals, errs
+}
+
+// failure is the error for an operation that did not reach a quorum: a
+// single node's own error, else ErrLockNotAcquired (wrapping the first node
+// error, if any, in Redlock mode).
+func (n nodes) failure(errs []error) error {
+	var first error
+	for _, err := range errs {
+		if err != nil {
+			first = err
+			break
+		}
+	}
+	switch {
+	case first == nil:
+		return ErrLockNotAcquired
+	case n.redlock():
+		return fmt.Errorf("%w: %w", ErrLockNotAcquired, first)
+	}
+	return first
+}
+
+func countPositive(vals []int64) int {
+	n := 0
+	for _, v := range vals {
+		if v > 0 {
+			n++
+		}
+	}
+	return n
+}
+
 // Lock represents a held distributed lock.
 type Lock struct {
-	client        goredis.Cmdable
+	nodes         nodes
 	key           string
 	token         string
+	fence         int64
+	until         atomic.Int64 // unix nano; see Until
 	ttl           time.Duration
+	drift         time.Duration
 	autoRenew     bool
 	renewInterval time.Duration
 	onLost        func(error)
@@ -228,20 +438,41 @@ func (l *Lock) Key() string { return l.key }
 // Token returns the owner token written into Redis.
 func (l *Lock) Token() string { return l.token }
 
-// Refresh extends the lock's TTL, but only if this holder still owns it.
+// Fence returns the lock's fencing token (see WithFencing); 0 without
+// fencing. Tokens of successive acquisitions of a key only grow.
+func (l *Lock) Fence() int64 { return l.fence }
+
+// Until returns when the lock stops being safely held unless refreshed: the
+// start of the last successful acquisition or Refresh, plus the TTL, less the
+// clock-drift allowance (see WithDriftFactor).
+func (l *Lock) Until() time.Time { return time.Unix(0, l.until.Load()) }
+
+// Refresh extends the lock's TTL, but only if this holder still owns it (on a
+// quorum of nodes, in Redlock mode).
 func (l *Lock) Refresh(ctx context.Context) error {
-	res, err := refreshScript.Run(ctx, l.client, []string{l.key}, l.token,
-		l.ttl.Milliseconds()).Result()
-	if err != nil {
-		return err
+	start := time.Now()
+	vals, errs := l.nodes.each(ctx, func(ctx context.Context, c goredis.Cmdable) (int64, error) {
+		res, err := refreshScript.Run(ctx, c, []string{l.key}, l.token,
+			l.ttl.Milliseconds()).Result()
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.(int64)
+		return n, nil
+	})
+	if countPositive(vals) < l.nodes.quorum {
+		return l.nodes.failure(errs)
 	}
-	if n, ok := res.(int64); !ok || n == 0 {
+	if !l.valid(start) {
 		return ErrLockNotAcquired
 	}
+	l.until.Store(start.Add(l.ttl - l.drift).UnixNano())
 	return nil
 }
 
 // Release gives up the lock atomically (no-op if ownership was already lost).
+// In Redlock mode it releases every node and succeeds when a quorum still held
+// the lock.
 func (l *Lock) Release(ctx context.Context) error {
 	l.stopOnce.Do(func() {
 		if l.stop != nil {
@@ -251,16 +482,20 @@ func (l *Lock) Release(ctx context.Context) error {
 			l.acqCancel() // abort any in-flight Refresh fast; renewer exits cleanly
 		}
 	})
-	res, err := releaseScript.Run(ctx, l.client, []string{l.key}, l.token).Result()
-	if err != nil {
-		// If the error is nil reply (key gone), treat as already released.
-		if errors.Is(err, goredis.Nil) {
-			return nil
+	vals, errs := l.nodes.each(ctx, func(ctx context.Context, c goredis.Cmdable) (int64, error) {
+		res, err := releaseScript.Run(ctx, c, []string{l.key}, l.token).Result()
+		if err != nil {
+			// If the error is nil reply (key gone), treat as already released.
+			if errors.Is(err, goredis.Nil) {
+				return 1, nil
+			}
+			return 0, err
 		}
-		return err
-	}
-	if n, ok := res.(int64); !ok || n == 0 {
-		return ErrLockNotAcquired
+		n, _ := res.(int64)
+		return n, nil
+	})
+	if countPositive(vals) < l.nodes.quorum {
+		return l.nodes.failure(errs)
 	}
 	return nil
 }
@@ -0,0 +1,233 @@
+package redislock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/v8fg/kit4go/redislock"
+)
+
+// newNodes starts n independent miniredis nodes.
+func newNodes(t *testing.T, n int) ([]goredis.Cmdable, []*miniredis.Miniredis) {
+	t.Helper()
+	clients := make([]goredis.Cmdable, n)
+	mrs := make([]*miniredis.Miniredis, n)
+	for i := range n {
+		clients[i], mrs[i] = newClient(t)
+	}
+	return clients, mrs
+}
+
+// holders counts the nodes on which key holds token.
+func holders(mrs []*miniredis.Miniredis, key, token string) int {
+	n := 0
+	for _, mr := range mrs {
+		if v, err := mr.Get(key); err == nil && v == token {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFencing_Monotonic(t *testing.T) {
+	client, mr := newClient(t)
+	lk := redislock.New(client, redislock.WithFencing(true), redislock.WithTTL(time.Second))
+	ctx := context.Background()
+
+	a, err := lk.TryLock(ctx, "f")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), a.Fence())
+	_, err = lk.TryLock(ctx, "f")
+	require.ErrorIs(t, err, redislock.ErrLockNotAcquired)
+
+	// A stalls past its TTL; B's token outranks it.
+	mr.FastForward(2 * time.Second)
+	b, err := lk.TryLock(ctx, "f")
+	require.NoError(t, err)
+	require.Greater(t, b.Fence(), a.Fence())
+	require.ErrorIs(t, a.Release(ctx), redislock.ErrLockNotAcquired)
+	require.NoError(t, b.Release(ctx))
+
+	c, err := lk.TryLock(ctx, "f")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), c.Fence())
+	v, _ := mr.Get("f:fence")
+	require.Equal(t, "3", v)
+
+	plain, err := redislock.New(client).TryLock(ctx, "nofence")
+	require.NoError(t, err)
+	require.Zero(t, plain.Fence())
+}
+
+func TestRedlock_Quorum(t *testing.T) {
+	clients, mrs := newNodes(t, 3)
+	lk := redislock.NewRedlock(clients, redislock.WithTTL(time.Second))
+	ctx := context.Background()
+
+	lock, err := lk.TryLock(ctx, "r")
+	require.NoError(t, err)
+	require.Equal(t, 3, holders(mrs, "r", lock.Token()))
+	_, err = lk.TryLock(ctx, "r")
+	require.ErrorIs(t, err, redislock.ErrLockNotAcquired)
+	require.NoError(t, lock.Release(ctx))
+	require.Zero(t, holders(mrs, "r", lock.Token()))
+
+	// A minority held elsewhere does not block a quorum.
+	require.NoError(t, mrs[0].Set("r", "other"))
+	lock, err = lk.TryLock(ctx, "r")
+	require.NoError(t, err)
+	require.Equal(t, 2, holders(mrs, "r", lock.Token()))
+	require.NoError(t, lock.Release(ctx))
+
+	// A majority held elsewhere does, and the one granting node is rolled back.
+	require.NoError(t, mrs[1].Set("r", "other"))
+	_, err = lk.TryLock(ctx, "r")
+	require.ErrorIs(t, err, redislock.ErrLockNotAcquired)
+	require.False(t, mrs[2].Exists("r"))
+}
+
+func TestRedlock_NodeDown(t *testing.T) {
+	clients, mrs := newNodes(t, 3)
+	lk := redislock.NewRedlock(clients, redislock.WithTTL(time.Second))
+	ctx := context.Background()
+
+	mrs[0].SetError("down")
+	lock, err := lk.TryLock(ctx, "d")
+	require.NoError(t, err)
+	require.NoError(t, lock.Refresh(ctx))
+	require.NoError(t, lock.Release(ctx))
+
+	mrs[1].SetError("down")
+	_, err = lk.TryLock(ctx, "d")
+	require.ErrorIs(t, err, redislock.ErrLockNotAcquired)
+	require.ErrorContains(t, err, "down")
+	require.False(t, mrs[2].Exists("d"))
+}
+
+func TestRedlock_ReleaseAndRefreshNeedQuorum(t *testing.T) {
+	clients, mrs := newNodes(t, 3)
+	lk := redislock.NewRedlock(clients, redislock.WithTTL(time.Second))
+	ctx := context.Background()
+
+	lock, err := lk.TryLock(ctx, "q")
+	require.NoError(t, err)
+	require.NoError(t, mrs[0].Set("q", "other"))
+	require.NoError(t, lock.Refresh(ctx))
+	require.NoError(t, mrs[1].Set("q", "other"))
+	require.ErrorIs(t, lock.Refresh(ctx), redislock.ErrLockNotAcquired)
+	require.ErrorIs(t, lock.Release(ctx), redislock.ErrLockNotAcquired)
+	require.Zero(t, holders(mrs, "q", lock.Token()))
+}
+
+func TestRedlock_Validity(t *testing.T) {
+	clients, mrs := newNodes(t, 3)
+	ctx := context.Background()
+
+	before := time.Now()
+	lock, err := redislock.NewRedlock(clients, redislock.WithTTL(time.Second)).TryLock(ctx, "v")
+	require.NoError(t, err)
+	// Until = start + TTL - (1% of TTL + 2ms).
+	require.False(t, lock.Until().Before(before.Add(988*time.Millisecond)))
+	require.False(t, lock.Until().After(time.Now().Add(988*time.Millisecond)))
+	prev := lock.Until()
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, lock.Refresh(ctx))
+	require.True(t, lock.Until().After(prev))
+
+	// A TTL smaller than the drift allowance is never valid; granting nodes
+	// are rolled back.
+	_, err = redislock.NewRedlock(clients, redislock.WithTTL(time.Millisecond)).TryLock(ctx, "tiny")
+	require.ErrorIs(t, err, redislock.ErrLockNotAcquired)
+	for _, mr := range mrs {
+		require.False(t, mr.Exists("tiny"))
+	}
+}
+
+// Fencing under Redlock: any two quorums share a node, and every acquisition
+// leaves its token on a quorum, so tokens grow even as the quorums change.
+func TestRedlock_FencingAcrossQuorums(t *testing.T) {
+	clients, mrs := newNodes(t, 3)
+	lk := redislock.NewRedlock(clients, redislock.WithFencing(true), redislock.WithTTL(time.Second))
+	ctx := context.Background()
+
+	var last int64
+	for _, down := range []int{2, 0, 1, -1} {
+		for i, mr := range mrs {
+			if i == down {
+				mr.SetError("down")
+			} else {
+				mr.SetError("")
+			}
+		}
+		lock, err := lk.TryLock(ctx, "fx")
+		require.NoError(t, err)
+		require.Greater(t, lock.Fence(), last, "quorum without node %d", down)
+		last = lock.Fence()
+		require.NoError(t, lock.Release(ctx))
+	}
+
+	// Diverged counters: the token is the highest, and lagging nodes catch up.
+	require.NoError(t, mrs[0].Set("fx:fence", "41"))
+	lock, err := lk.TryLock(ctx, "fx")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), lock.Fence())
+	for _, mr := range mrs {
+		v, _ := mr.Get("fx:fence")
+		require.Equal(t, "42", v)
+	}
+}
+
+func TestRedlock_AutoRenewLost(t *testing.T) {
+	clients, mrs := newNodes(t, 3)
+	lk := redislock.NewRedlock(clients,
+		redislock.WithTTL(200*time.Millisecond),
+		redislock.WithAutoRenew(true),
+		redislock.WithRenewInterval(10*time.Millisecond))
+	ctx := context.Background()
+
+	lock, err := lk.TryLock(ctx, "a")
+	require.NoError(t, err)
+	defer lock.Release(ctx)
+
+	mrs[0].Del("a") // a minority loss is survivable
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-lock.Lost():
+		t.Fatal("lost with a quorum still held")
+	default:
+	}
+
+	mrs[1].Del("a")
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Lost did not close after the quorum was lost")
+	}
+}
+
+func TestRedlock_Lock_Contended(t *testing.T) {
+	clients, _ := newNodes(t, 5)
+	lk := redislock.NewRedlock(clients,
+		redislock.WithRetryInterval(5*time.Millisecond),
+		redislock.WithWaitTimeout(time.Second))
+	ctx := context.Background()
+
+	held, err := lk.TryLock(ctx, "c")
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		_ = held.Release(ctx)
+	}()
+	lock, err := lk.Lock(ctx, "c")
+	require.NoError(t, err)
+	require.NoError(t, lock.Release(ctx))
+}
+
+func TestNewRedlock_NoClients(t *testing.T) {
+	require.Panics(t, func() { redislock.NewRedlock(nil) })
+}